@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// flagEnv maps each flag that has an environment variable equivalent to that
+// variable. applyConfigFile consults it so env values keep priority over the
+// config file. New flags with an env fallback must be added here.
+var flagEnv = map[string]string{
+	"backend":                  "HOOKS_STORE_BACKEND",
+	"dir":                      "HOOKS_STORE_DIR",
+	"file-path":                "HOOKS_STORE_FILE_PATH",
+	"port":                     "HOOKS_STORE_PORT",
+	"meili-url":                "MEILI_URL",
+	"meili-key":                "MEILI_KEY",
+	"meili-index":              "MEILI_INDEX",
+	"prompts-index":            "PROMPTS_INDEX",
+	"skip-empty-prompts":       "SKIP_EMPTY_PROMPTS",
+	"strict-prompts":           "STRICT_PROMPTS",
+	"prompts-optional":         "PROMPTS_OPTIONAL",
+	"warm-up":                  "WARM_UP",
+	"searchable-attributes":    "SEARCHABLE_ATTRIBUTES",
+	"prompt-rank":              "PROMPT_RANK",
+	"max-total-hits":           "MAX_TOTAL_HITS",
+	"max-values-per-facet":     "MAX_VALUES_PER_FACET",
+	"settings-timeout":         "SETTINGS_TIMEOUT",
+	"default-hook-type":        "DEFAULT_HOOK_TYPE",
+	"known-hook-types":         "KNOWN_HOOK_TYPES",
+	"extra-hook-type":          "EXTRA_HOOK_TYPES",
+	"backlog-limit":            "BACKLOG_LIMIT",
+	"backlog-refresh":          "BACKLOG_REFRESH",
+	"otel-endpoint":            "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"tui-render-window":        "TUI_RENDER_WINDOW",
+	"tui-collapse":             "TUI_COLLAPSE",
+	"tui-history":              "TUI_HISTORY",
+	"tee":                      "TEE",
+	"tui-dump-on-quit":         "TUI_DUMP_ON_QUIT",
+	"max-future-skew":          "MAX_FUTURE_SKEW",
+	"ingest-status":            "INGEST_STATUS",
+	"future-skew-action":       "FUTURE_SKEW_ACTION",
+	"retention":                "RETENTION",
+	"retention-action":         "RETENTION_ACTION",
+	"retention-project":        "RETENTION_PROJECTS",
+	"retention-purge-interval": "RETENTION_PURGE_INTERVAL",
+	"admin-token":              "HOOKS_STORE_ADMIN_TOKEN",
+	"max-value-len":            "MAX_VALUE_LEN",
+	"strip-ansi":               "STRIP_ANSI",
+	"normalize-paths":          "NORMALIZE_PATHS",
+	"max-prompt-bytes":         "MAX_PROMPT_BYTES",
+	"source-label":             "SOURCE_LABEL",
+	"migrate-workers":          "MIGRATE_WORKERS",
+	"audit-log":                "AUDIT_LOG",
+	"audit-fsync":              "AUDIT_FSYNC",
+	"audit-fields":             "AUDIT_FIELDS",
+	"audit-flush-count":        "AUDIT_FLUSH_COUNT",
+	"audit-flush-interval":     "AUDIT_FLUSH_INTERVAL",
+	"reject-log":               "REJECT_LOG",
+	"slow-request-threshold":   "SLOW_REQUEST_THRESHOLD",
+	"stream-max-bytes":         "STREAM_MAX_BYTES",
+	"batch-max-bytes":          "BATCH_MAX_BYTES",
+	"allow-cidr":               "ALLOW_CIDR",
+	"trusted-proxy":            "TRUSTED_PROXIES",
+	"allowed-index":            "ALLOWED_INDEXES",
+	"route":                    "ROUTES",
+	"sample-rate":              "SAMPLE_RATES",
+	"session-context":          "SESSION_CONTEXT",
+	"session-first-seen":       "SESSION_FIRST_SEEN",
+	"session-context-max":      "SESSION_CONTEXT_MAX",
+	"session-context-ttl":      "SESSION_CONTEXT_TTL",
+	"selftest-sla":             "SELFTEST_SLA",
+	"no-create-index":          "NO_CREATE_INDEX",
+	"transform-stages":         "TRANSFORM_STAGES",
+	"flat-envelope":            "FLAT_ENVELOPE",
+	"hash-session-ids":         "HASH_SESSION_IDS",
+	"store-raw-body":           "STORE_RAW_BODY",
+	"validate-json":            "VALIDATE_JSON",
+	"content-hash":             "CONTENT_HASH",
+	"session-id-salt":          "SESSION_ID_SALT",
+}
+
+// configPath returns the --config value from args without parsing the rest,
+// so the file can be applied before flag.Parse lets command-line flags win.
+// Falls back to fallback (the env/default value) when --config is absent.
+func configPath(args []string, fallback string) string {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		name := strings.TrimLeft(a, "-")
+		if name == a {
+			continue
+		}
+		if v, ok := strings.CutPrefix(name, "config="); ok {
+			return v
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return fallback
+}
+
+// parseConfig reads an INI-style config file: "key = value" lines, optional
+// [section] headers (ignored), and "#" or ";" comment lines. Keys are flag
+// names; underscores are accepted in place of dashes (meili_url →
+// meili-url). Values are parsed by parseConfigValue, which accepts the
+// TOML-looking forms people tend to write, but the file is not TOML:
+// there are no nested tables, multi-line strings, or typed values.
+func parseConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		if values[key], err = parseConfigValue(value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %w", path, n, key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return values, nil
+}
+
+// parseConfigValue returns the flag value of the text after "=": a bare
+// value up to an inline "#" comment (one preceded by whitespace, so URL
+// fragments survive), a "double-quoted" string with Go/TOML escapes, a
+// 'single-quoted' literal, or an [array] of those, joined with commas as the
+// repeatable flags accept.
+func parseConfigValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, "["); ok {
+		var items []string
+		for {
+			rest = strings.TrimSpace(rest)
+			if rest == "" {
+				return "", fmt.Errorf("unterminated array")
+			}
+			if rest[0] == ']' {
+				break
+			}
+			item, r, err := configScalar(rest, ",]")
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+			rest = strings.TrimSpace(r)
+			if strings.HasPrefix(rest, ",") {
+				rest = rest[1:]
+			} else if !strings.HasPrefix(rest, "]") {
+				return "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+		return strings.Join(items, ","), configTrailer(rest[1:])
+	}
+	value, rest, err := configScalar(s, "")
+	if err != nil {
+		return "", err
+	}
+	return value, configTrailer(rest)
+}
+
+// configScalar parses one quoted or bare value at the start of s, a bare
+// one ending at any byte in stops or at an inline comment. It returns the
+// value and the unparsed remainder.
+func configScalar(s, stops string) (value, rest string, err error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid quoted string %s", s[:i+1])
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	end := len(s)
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(stops, s[i]) >= 0 || (s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t')) {
+			end = i
+			break
+		}
+	}
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
+
+// configTrailer checks that only whitespace or a comment follows a value.
+func configTrailer(rest string) error {
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
+
+// applyConfigFile sets flags in fs from the config file at path. A value is
+// skipped when the flag's environment variable is set, so the effective
+// priority after fs.Parse is flags > env > file > defaults. Unknown keys and
+// invalid values are errors.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	values, err := parseConfig(path)
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown option %q", path, name)
+		}
+		if env := flagEnv[name]; env != "" && os.Getenv(env) != "" {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, name, err)
+		}
+		if d, ok := fs.Lookup(name).Value.(defaulter); ok {
+			d.markDefault()
+		}
+	}
+	return nil
+}
+
+// defaulter is implemented by flag values that accumulate across Set calls
+// (listFlag). applyConfigFile marks the file's value as a default, so the
+// first command-line occurrence replaces it instead of adding to it.
+type defaulter interface {
+	markDefault()
+}
+
+// secretFlagWords mark flags whose values effectiveConfig redacts.
+var secretFlagWords = []string{"key", "token", "secret", "password", "salt"}
+
+// effectiveConfig returns every flag in fs with its effective value, for
+// /admin/debug. Non-empty values of flags whose names contain a
+// secretFlagWords entry are replaced with "[redacted]".
+func effectiveConfig(fs *flag.FlagSet) map[string]string {
+	config := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if value != "" {
+			for _, word := range secretFlagWords {
+				if strings.Contains(f.Name, word) {
+					value = "[redacted]"
+					break
+				}
+			}
+		}
+		config[f.Name] = value
+	})
+	return config
+}
+
+// writeConfigJSON writes effectiveConfig(fs) to w as indented JSON, keys
+// sorted, for --print-config. The print-config flag itself is left out.
+func writeConfigJSON(w io.Writer, fs *flag.FlagSet) error {
+	config := effectiveConfig(fs)
+	delete(config, "print-config")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(config)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hooks-store.conf")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestConfigPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--config", "a.conf"}, "a.conf"},
+		{[]string{"-config=b.conf", "--port", "1"}, "b.conf"},
+		{[]string{"--port", "1"}, "env.conf"},
+		{[]string{"--", "--config", "c.conf"}, "env.conf"},
+	}
+	for _, tt := range tests {
+		if got := configPath(tt.args, "env.conf"); got != tt.want {
+			t.Errorf("configPath(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfig(t, `# comment
+; also a comment
+[store]
+port = 9900 # dev
+meili_url = "http://meili:7700"
+meili-key =
+source_label = 'team "a"' # literal
+otel_endpoint = http://otel:4318/#frag
+allow_cidr = ["10.0.0.0/8", '172.16.0.0/12', ::1] # private
+audit_fields = []
+`)
+	values, err := parseConfig(path)
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	want := map[string]string{
+		"port":          "9900",
+		"meili-url":     "http://meili:7700",
+		"meili-key":     "",
+		"source-label":  `team "a"`,
+		"otel-endpoint": "http://otel:4318/#frag",
+		"allow-cidr":    "10.0.0.0/8,172.16.0.0/12,::1",
+		"audit-fields":  "",
+	}
+	if len(values) != len(want) {
+		t.Fatalf("values = %v, want %v", values, want)
+	}
+	for k, v := range want {
+		if values[k] != v {
+			t.Errorf("values[%q] = %q, want %q", k, values[k], v)
+		}
+	}
+
+	for _, bad := range []string{
+		"port 9900\n",
+		"meili_url = \"http://meili:7700\n",
+		"meili_url = \"http://meili:7700\" trailing\n",
+		"allow_cidr = [\"10.0.0.0/8\"\n",
+		"allow_cidr = [\"10.0.0.0/8\" \"::1\"]\n",
+		"source_label = 'open\n",
+	} {
+		if _, err := parseConfig(writeConfig(t, bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestApplyConfigFile_Priority(t *testing.T) {
+	t.Setenv("MEILI_INDEX", "from-env")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	port := fs.String("port", "9800", "")
+	index := fs.String("meili-index", os.Getenv("MEILI_INDEX"), "")
+	url := fs.String("meili-url", "http://localhost:7700", "")
+
+	path := writeConfig(t, "port = 9900\nmeili_index = from-file\nmeili_url = http://file:7700\n")
+	if err := applyConfigFile(fs, path); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	if err := fs.Parse([]string{"--meili-url", "http://flag:7700"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if *port != "9900" {
+		t.Errorf("port = %q, want file value 9900", *port)
+	}
+	if *index != "from-env" {
+		t.Errorf("meili-index = %q, want env value", *index)
+	}
+	if *url != "http://flag:7700" {
+		t.Errorf("meili-url = %q, want flag value", *url)
+	}
+}
+
+func TestApplyConfigFile_ListFlag(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfig(t, "allow_cidr = 10.0.0.0/8,172.16.0.0/12\n")
+	parse := func(args ...string) []string {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		cidrs := newListFlag([]string{"127.0.0.1"})
+		fs.Var(cidrs, "allow-cidr", "")
+		if err := applyConfigFile(fs, path); err != nil {
+			t.Fatalf("applyConfigFile: %v", err)
+		}
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		return cidrs.values
+	}
+
+	if got := parse(); !slices.Equal(got, []string{"10.0.0.0/8", "172.16.0.0/12"}) {
+		t.Errorf("file only = %v, want the file's list", got)
+	}
+	// Command-line occurrences replace the file's list, then accumulate.
+	got := parse("--allow-cidr", "192.168.0.0/16", "--allow-cidr", "::1")
+	if !slices.Equal(got, []string{"192.168.0.0/16", "::1"}) {
+		t.Errorf("file + flags = %v, want only the command-line values", got)
+	}
+}
+
+func TestApplyConfigFile_Errors(t *testing.T) {
+	t.Parallel()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int64("backlog-limit", 0, "")
+
+	if err := applyConfigFile(fs, writeConfig(t, "no_such_option = 1\n")); err == nil {
+		t.Error("expected error for unknown option")
+	}
+	if err := applyConfigFile(fs, writeConfig(t, "backlog_limit = lots\n")); err == nil {
+		t.Error("expected error for invalid value")
+	}
+	if err := applyConfigFile(fs, filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestEffectiveConfig_Redacts(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("meili-key", "", "")
+	fs.String("admin-token", "", "")
+	fs.String("meili-url", "", "")
+	if err := fs.Parse([]string{"--meili-key=s3cret", "--meili-url=http://m:7700"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	got := effectiveConfig(fs)
+	if got["meili-key"] != "[redacted]" {
+		t.Errorf("meili-key = %q, want redacted", got["meili-key"])
+	}
+	if got["admin-token"] != "" {
+		t.Errorf("admin-token = %q, want empty (unset secrets stay empty)", got["admin-token"])
+	}
+	if got["meili-url"] != "http://m:7700" {
+		t.Errorf("meili-url = %q", got["meili-url"])
+	}
+}
+
+func TestWriteConfigJSON(t *testing.T) {
+	t.Parallel()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("port", "9800", "")
+	fs.String("meili-url", "http://localhost:7700", "")
+	fs.String("meili-key", "", "")
+	fs.Bool("print-config", false, "")
+
+	path := writeConfig(t, "meili_url = http://file:7700\nmeili_key = s3cret\n")
+	if err := applyConfigFile(fs, path); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	if err := fs.Parse([]string{"--port", "9900", "--print-config"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeConfigJSON(&buf, fs); err != nil {
+		t.Fatalf("writeConfigJSON: %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	want := map[string]string{"port": "9900", "meili-url": "http://file:7700", "meili-key": "[redacted]"}
+	if len(got) != len(want) {
+		t.Errorf("config = %v, want %v (print-config omitted)", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -4,88 +4,485 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"hooks-store/internal/hookevt"
 	"hooks-store/internal/ingest"
 	"hooks-store/internal/store"
+	"hooks-store/internal/tracing"
 	"hooks-store/internal/tui"
 )
 
 var version = "dev"
 
 func main() {
+	backend := flag.String("backend", envOrDefault("HOOKS_STORE_BACKEND", "meili"), "Storage backend: meili or file")
+	dataDir := flag.String("dir", envOrDefault("HOOKS_STORE_DIR", ""), "Directory for --backend file (one JSON file per event under {date}/)")
+	filePath := flag.String("file-path", envOrDefault("HOOKS_STORE_FILE_PATH", ""), "File for --backend file, instead of --dir: every event appended as one JSON line")
 	port := flag.String("port", envOrDefault("HOOKS_STORE_PORT", "9800"), "HTTP listen port")
 	meiliURL := flag.String("meili-url", envOrDefault("MEILI_URL", "http://localhost:7700"), "MeiliSearch endpoint")
 	meiliKey := flag.String("meili-key", envOrDefault("MEILI_KEY", ""), "MeiliSearch API key")
 	meiliIndex := flag.String("meili-index", envOrDefault("MEILI_INDEX", "hook-events"), "MeiliSearch index name")
 	promptsIndex := flag.String("prompts-index", envOrDefault("PROMPTS_INDEX", "hook-prompts"), "MeiliSearch prompts index name (empty to disable)")
+	promptsOptional := flag.Bool("prompts-optional", envBoolOrDefault("PROMPTS_OPTIONAL", false), "Keep running without the prompts index if it fails to set up (default: abort startup)")
+	skipEmptyPrompts := flag.Bool("skip-empty-prompts", envBoolOrDefault("SKIP_EMPTY_PROMPTS", false), "Keep UserPromptSubmit events with a blank prompt out of the prompts index (they are still indexed in the main index)")
+	strictPrompts := flag.Bool("strict-prompts", envBoolOrDefault("STRICT_PROMPTS", false), "Fail ingest when the prompts index write fails (default: warn and count in /stats)")
+	warmUp := flag.Bool("warm-up", envBoolOrDefault("WARM_UP", false), "Run a trivial search on each index at startup so the first real query is fast")
+	searchable := flag.String("searchable-attributes", envOrDefault("SEARCHABLE_ATTRIBUTES", ""), "Comma-separated main index searchable attributes, highest ranking first (empty for the default order)")
+	maxTotalHits := flag.Int64("max-total-hits", envInt64OrDefault("MAX_TOTAL_HITS", store.DefaultMaxTotalHits), "Hits a search on either MeiliSearch index can count and page through (higher is slower for deep searches)")
+	maxValuesPerFacet := flag.Int64("max-values-per-facet", envInt64OrDefault("MAX_VALUES_PER_FACET", store.DefaultMaxValuesPerFacet), "Distinct values a facet search reports per field on either MeiliSearch index; also the largest /distinct limit")
+	settingsTimeout := flag.Duration("settings-timeout", envDurationOrDefault("SETTINGS_TIMEOUT", store.DefaultSettingsTimeout), "How long MeiliSearch index setup waits for its settings tasks before aborting startup")
+	knownHookTypes := flag.Bool("known-hook-types", envBoolOrDefault("KNOWN_HOOK_TYPES", false), "Reject events whose hook_type is not one of the canonical hook types or an --extra-hook-type with 422")
+	extraHookTypes := newListFlag(splitList(envOrDefault("EXTRA_HOOK_TYPES", "")))
+	flag.Var(extraHookTypes, "extra-hook-type", "Hook type accepted by --known-hook-types besides the canonical ones (repeatable or comma-separated)")
+	defaultHookType := flag.String("default-hook-type", envOrDefault("DEFAULT_HOOK_TYPE", ""), "hook_type applied to events that omit it (empty to reject them)")
+	backlogLimit := flag.Int64("backlog-limit", envInt64OrDefault("BACKLOG_LIMIT", 0), "Pending MeiliSearch tasks at which ingest returns 503 + Retry-After (0 to disable)")
+	backlogRefresh := flag.Duration("backlog-refresh", envDurationOrDefault("BACKLOG_REFRESH", 5*time.Second), "How often the MeiliSearch backlog is re-checked")
+	otelEndpoint := flag.String("otel-endpoint", envOrDefault("OTEL_EXPORTER_OTLP_ENDPOINT", ""), "OTLP/HTTP endpoint for ingest traces, e.g. http://localhost:4318 (empty to disable)")
+	tuiHistory := flag.Int("tui-history", int(envInt64OrDefault("TUI_HISTORY", 4)), "Number of recent events kept in the TUI activity log")
+	tuiDump := flag.String("tui-dump-on-quit", envOrDefault("TUI_DUMP_ON_QUIT", ""), "File to write the TUI activity log to as NDJSON on quit (empty to skip)")
+	tee := flag.Bool("tee", envBoolOrDefault("TEE", false), "Also write every indexed document to stdout as NDJSON; runs headless (no TUI)")
+	tuiCollapse := flag.Bool("tui-collapse", envBoolOrDefault("TUI_COLLAPSE", false), "Start the TUI with consecutive duplicate events collapsed into one line (toggle with c)")
+	renderWindow := flag.Duration("tui-render-window", envDurationOrDefault("TUI_RENDER_WINDOW", 100*time.Millisecond), "Coalesce TUI updates for events arriving within this window (negative to disable)")
+	maxFutureSkew := flag.Duration("max-future-skew", envDurationOrDefault("MAX_FUTURE_SKEW", 0), "Max allowed event timestamp ahead of server time (0 to disable)")
+	promptRank := flag.String("prompt-rank", envOrDefault("PROMPT_RANK", ""), "Where prompt sits in the main index's searchable attributes: first, last (just above data_flat), or off (empty keeps the configured order)")
+	ingestStatus := flag.String("ingest-status", envOrDefault("INGEST_STATUS", "accepted"), "Success response of /ingest: accepted (always 202 accepted) or detailed (200 with queued for asynchronous backends, indexed otherwise)")
+	futureSkewAction := flag.String("future-skew-action", envOrDefault("FUTURE_SKEW_ACTION", "clamp"), "What to do with events beyond --max-future-skew: clamp or reject")
+	retention := flag.Duration("retention", envDurationOrDefault("RETENTION", 0), "Retention window: events already older than this are not indexed (0 to disable)")
+	retentionProjects := newListFlag(splitList(envOrDefault("RETENTION_PROJECTS", "")))
+	flag.Var(retentionProjects, "retention-project", "Per-project retention window overriding --retention, as project_dir=duration (0 keeps the project forever; repeatable or comma-separated)")
+	retentionPurge := flag.Duration("retention-purge-interval", envDurationOrDefault("RETENTION_PURGE_INTERVAL", 0), "Delete documents older than their project's retention window this often (0 to disable; requires a store that deletes by filter)")
+	retentionAction := flag.String("retention-action", envOrDefault("RETENTION_ACTION", "drop"), "What to do with events older than --retention: drop (202, counted) or reject (422)")
+	maxValueLen := flag.Int64("max-value-len", envInt64OrDefault("MAX_VALUE_LEN", 64<<10), "Max bytes of a single string value copied into data_flat (0 for no limit; data is kept intact)")
+	stripANSI := flag.Bool("strip-ansi", envBoolOrDefault("STRIP_ANSI", false), "Remove ANSI escape codes from data_flat and error_message (data is kept intact)")
+	adminToken := flag.String("admin-token", envOrDefault("HOOKS_STORE_ADMIN_TOKEN", ""), "Bearer token for /admin/* endpoints (empty to disable them)")
+	maxPromptBytes := flag.Int64("max-prompt-bytes", envInt64OrDefault("MAX_PROMPT_BYTES", 0), "Truncate the indexed prompt field to this many bytes, recording the original length (0 for no limit; data is kept intact)")
+	normalizePaths := flag.Bool("normalize-paths", envBoolOrDefault("NORMALIZE_PATHS", false), "Convert backslashes to forward slashes and strip trailing slashes in file_path, cwd, and project_dir")
+	sourceLabel := flag.String("source-label", envOrDefault("SOURCE_LABEL", ""), "Source stamped on every ingested document, e.g. laptop or ci (X-Source header overrides; --migrate labels unlabeled documents)")
+	auditLogPath := flag.String("audit-log", envOrDefault("AUDIT_LOG", ""), "Append every indexed document to this NDJSON file (empty to disable)")
+	auditFsync := flag.Bool("audit-fsync", envBoolOrDefault("AUDIT_FSYNC", false), "fsync the audit log after every record")
+	auditFlushCount := flag.Int("audit-flush-count", int(envInt64OrDefault("AUDIT_FLUSH_COUNT", 0)), "Buffer the audit log and flush and fsync it every N records (0 for no count boundary)")
+	auditFlushInterval := flag.Duration("audit-flush-interval", envDurationOrDefault("AUDIT_FLUSH_INTERVAL", 0), "Buffer the audit log and flush and fsync it at this interval (0 for no time boundary)")
+	auditFields := flag.String("audit-fields", envOrDefault("AUDIT_FIELDS", ""), "Comma-separated document fields to record in the audit log (empty for the whole document)")
+	replayPath := flag.String("replay", "", "Index every event in this NDJSON file (optionally gzipped) in batches, then exit")
+	noCreateIndex := flag.Bool("no-create-index", envBoolOrDefault("NO_CREATE_INDEX", false), "Assume the MeiliSearch indexes already exist and are configured: skip index creation and settings updates, only check the indexes are readable")
+	validateJSON := flag.Bool("validate-json", envBoolOrDefault("VALIDATE_JSON", false), "Re-marshal each document to JSON before indexing and reject it with 422 (counted as unmarshalable in /stats) if that fails")
+	storeRawBody := flag.Bool("store-raw-body", envBoolOrDefault("STORE_RAW_BODY", false), "Keep each exact request body, gzipped, as raw_body (not searchable; GET /documents/{id}?include_raw=true returns it)")
+	contentHash := flag.Bool("content-hash", envBoolOrDefault("CONTENT_HASH", false), "Store a SHA-256 of each event's canonicalized data as content_hash, for finding identical events and verifying exports")
+	transformStages := flag.String("transform-stages", envOrDefault("TRANSFORM_STAGES", ""), "Comma-separated transform pipeline stages in order: sample, envelope, redact, extract-fields, strip-ansi, normalize-paths, sanitize-utf8, enrich (empty for the default sample,envelope,redact,extract-fields,strip-ansi,normalize-paths,enrich)")
+	flatEnvelope := flag.Bool("flat-envelope", envBoolOrDefault("FLAT_ENVELOPE", false), "Also read known event fields (tool_name, session_id, ...) from the envelope's top level when data lacks them")
+	hashSessionIDs := flag.Bool("hash-session-ids", envBoolOrDefault("HASH_SESSION_IDS", false), "Store session IDs as salted HMAC pseudonyms instead of raw values (requires --session-id-salt)")
+	sessionIDSalt := flag.String("session-id-salt", envOrDefault("SESSION_ID_SALT", ""), "Secret HMAC key for --hash-session-ids; keep it stable or sessions stop grouping across restarts")
+	selfTestRun := flag.Bool("selftest", false, "Ingest one marker event, wait until it is searchable, print the latency, then exit")
+	selfTestSLA := flag.Duration("selftest-sla", envDurationOrDefault("SELFTEST_SLA", 0), "Fail --selftest if the event takes longer than this to become searchable (0 for no limit)")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration (flags > env > config file > defaults) as JSON with secrets redacted, then exit")
+	validatePath := flag.String("validate", "", "Check every line of this NDJSON file (optionally gzipped) against ingest validation without indexing, then exit")
 	migrate := flag.Bool("migrate", false, "Backfill top-level fields on existing documents and exit")
+	fixTimestamps := flag.Bool("migrate-fix-timestamps", false, "Rewrite timestamp_unix from timestamp on existing documents where they disagree, report how many were corrected, and exit")
+	migrateField := flag.String("migrate-field", "", "Backfill only this top-level field (e.g. exit_code) on existing documents and exit")
+	migrateWorkers := flag.Int("migrate-workers", int(envInt64OrDefault("MIGRATE_WORKERS", 1)), "Pages each --migrate step fetches and writes concurrently (1 for sequential)")
+	batchMaxBytes := flag.Int64("batch-max-bytes", envInt64OrDefault("BATCH_MAX_BYTES", 16<<20), "Request body limit of POST /ingest/batch; each event in a batch is still limited to 1 MiB")
+	streamMaxBytes := flag.Int64("stream-max-bytes", envInt64OrDefault("STREAM_MAX_BYTES", 0), "Total bytes a client may send over one /ws connection before it is closed (0 for no limit)")
+	slowRequestThreshold := flag.Duration("slow-request-threshold", envDurationOrDefault("SLOW_REQUEST_THRESHOLD", 0), "Log a warning for /ingest requests slower than this (0 to disable)")
+	rejectLogPath := flag.String("reject-log", envOrDefault("REJECT_LOG", ""), "Append the raw body of events whose processing panicked to this NDJSON file (empty to only log to stderr)")
+	allowCIDRs := newListFlag(splitList(envOrDefault("ALLOW_CIDR", "")))
+	flag.Var(allowCIDRs, "allow-cidr", "Only accept /ingest from this CIDR range or address (repeatable or comma-separated; empty to allow all)")
+	trustedProxies := newListFlag(splitList(envOrDefault("TRUSTED_PROXIES", "")))
+	flag.Var(trustedProxies, "trusted-proxy", "CIDR range of a reverse proxy whose X-Forwarded-For is trusted for --allow-cidr (repeatable or comma-separated)")
+	allowedIndexes := newListFlag(splitList(envOrDefault("ALLOWED_INDEXES", "")))
+	flag.Var(allowedIndexes, "allowed-index", "Index a request may select with the X-Index header instead of the default (repeatable or comma-separated; empty to reject X-Index)")
+	sampleRates := newListFlag(splitList(envOrDefault("SAMPLE_RATES", "")))
+	flag.Var(sampleRates, "sample-rate", "Fraction of a hook type's events to index, as HookType=rate with rate in [0,1] (repeatable or comma-separated; adjustable at runtime via /admin/sampling)")
+	routeSpecs := newListFlag(splitList(envOrDefault("ROUTES", "")))
+	flag.Var(routeSpecs, "route", "Also write documents of a category (error, prompt, tool, other, or *) to another backend, as category=file:DIR (repeatable or comma-separated)")
+	sessionContext := flag.Bool("session-context", envBoolOrDefault("SESSION_CONTEXT", false), "Copy project_dir, has_claude_md, claude_version, and session_model from each session's SessionStart onto its later events that lack them")
+	sessionFirstSeen := flag.Bool("session-first-seen", envBoolOrDefault("SESSION_FIRST_SEEN", false), "Mark the first event seen for each session with session_first_seen, even when its SessionStart never arrived")
+	sessionContextMax := flag.Int("session-context-max", int(envInt64OrDefault("SESSION_CONTEXT_MAX", 10000)), "Sessions whose SessionStart context --session-context keeps, and that --session-first-seen remembers (least recently used evicted)")
+	sessionContextTTL := flag.Duration("session-context-ttl", envDurationOrDefault("SESSION_CONTEXT_TTL", 24*time.Hour), "How long --session-context keeps a session's context after its last event")
+	configFile := flag.String("config", envOrDefault("HOOKS_STORE_CONFIG", ""), "Path to an INI-style config file (key = value per line; quoted strings, [arrays] and # comments are accepted, but it is not TOML); flags and env override it")
+
+	// The config file is applied before Parse so command-line flags win.
+	if path := configPath(os.Args[1:], *configFile); path != "" {
+		if err := applyConfigFile(flag.CommandLine, path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 	flag.Parse()
 
-	// Connect to MeiliSearch — fail fast if unreachable.
-	fmt.Printf("Connecting to MeiliSearch at %s...\n", *meiliURL)
-	ms, err := store.NewMeiliStore(*meiliURL, *meiliKey, *meiliIndex, *promptsIndex)
+	if *printConfig {
+		if err := writeConfigJSON(os.Stdout, flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *backend != "meili" && *backend != "file" {
+		fmt.Fprintf(os.Stderr, "Error: --backend must be meili or file, got %q\n", *backend)
+		os.Exit(1)
+	}
+	if *backend == "file" && (*dataDir == "") == (*filePath == "") {
+		fmt.Fprintln(os.Stderr, "Error: --backend file requires exactly one of --dir or --file-path")
+		os.Exit(1)
+	}
+	if *filePath != "" && *backend != "file" {
+		fmt.Fprintln(os.Stderr, "Error: --file-path requires --backend file")
+		os.Exit(1)
+	}
+	if *migrate && *migrateField != "" {
+		fmt.Fprintln(os.Stderr, "Error: --migrate and --migrate-field are mutually exclusive")
+		os.Exit(1)
+	}
+	if *fixTimestamps && (*migrate || *migrateField != "") {
+		fmt.Fprintln(os.Stderr, "Error: --migrate-fix-timestamps cannot be combined with --migrate or --migrate-field")
+		os.Exit(1)
+	}
+	if *fixTimestamps && *backend != "meili" {
+		fmt.Fprintln(os.Stderr, "Error: --migrate-fix-timestamps requires --backend meili")
+		os.Exit(1)
+	}
+	if *migrateField != "" && *backend != "meili" {
+		fmt.Fprintln(os.Stderr, "Error: --migrate-field requires --backend meili")
+		os.Exit(1)
+	}
+	if *migrate && *backend != "meili" {
+		fmt.Fprintln(os.Stderr, "Error: --migrate requires --backend meili")
+		os.Exit(1)
+	}
+	if *hashSessionIDs && *sessionIDSalt == "" {
+		fmt.Fprintln(os.Stderr, "Error: --hash-session-ids requires --session-id-salt")
+		os.Exit(1)
+	}
+	stages := splitList(*transformStages)
+	if err := store.ValidateStages(stages); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --transform-stages: %v (known: %s)\n", err, strings.Join(store.StageNames(), ", "))
+		os.Exit(1)
+	}
+	if *hashSessionIDs {
+		if err := store.ValidateRedaction(stages); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --hash-session-ids with --transform-stages: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *stripANSI {
+		if err := store.ValidateStageAfter(stages, "strip-ansi", "extract-fields"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --strip-ansi with --transform-stages: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *normalizePaths {
+		if err := store.ValidateStageAfter(stages, "normalize-paths", "extract-fields"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --normalize-paths with --transform-stages: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *flatEnvelope && len(stages) > 0 && !slices.Contains(stages, "envelope") {
+		fmt.Fprintln(os.Stderr, "Error: --flat-envelope needs the envelope stage in --transform-stages")
+		os.Exit(1)
+	}
+	if *migrateWorkers < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --migrate-workers must be at least 1, got %d\n", *migrateWorkers)
+		os.Exit(1)
+	}
+	if *maxTotalHits < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --max-total-hits must be at least 1, got %d\n", *maxTotalHits)
+		os.Exit(1)
+	}
+	if *maxValuesPerFacet < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --max-values-per-facet must be at least 1, got %d\n", *maxValuesPerFacet)
+		os.Exit(1)
+	}
+	if *settingsTimeout <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --settings-timeout must be positive, got %s\n", *settingsTimeout)
+		os.Exit(1)
+	}
+	if *tee && *tuiDump != "" {
+		fmt.Fprintf(os.Stderr, "Error: --tee runs without the TUI, so --tui-dump-on-quit cannot be used with it\n")
+		os.Exit(1)
+	}
+	rates, err := parseSampleRates(sampleRates.values)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: --sample-rate: %v\n", err)
+		os.Exit(1)
+	}
+	if len(rates) > 0 && len(stages) > 0 && !slices.Contains(stages, "sample") {
+		fmt.Fprintln(os.Stderr, "Error: --sample-rate needs the sample stage in --transform-stages")
+		os.Exit(1)
+	}
+	// With --tee, stdout carries only NDJSON documents; progress goes to stderr.
+	status := io.Writer(os.Stdout)
+	if *tee {
+		status = os.Stderr
+	}
+	if *batchMaxBytes <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --batch-max-bytes must be positive, got %d\n", *batchMaxBytes)
+		os.Exit(1)
+	}
+	if *streamMaxBytes < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --stream-max-bytes must not be negative, got %d\n", *streamMaxBytes)
+		os.Exit(1)
+	}
+	if *auditFlushCount < 0 || *auditFlushInterval < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --audit-flush-count and --audit-flush-interval must not be negative\n")
+		os.Exit(1)
+	}
+	if *auditFsync && (*auditFlushCount > 0 || *auditFlushInterval > 0) {
+		fmt.Fprintf(os.Stderr, "Error: --audit-fsync cannot be combined with --audit-flush-count or --audit-flush-interval\n")
+		os.Exit(1)
+	}
+	var hookTypes []string
+	if *knownHookTypes {
+		hookTypes = append(slices.Clone(hookevt.KnownHookTypes), extraHookTypes.values...)
+		if *defaultHookType != "" && !slices.Contains(hookTypes, *defaultHookType) {
+			fmt.Fprintf(os.Stderr, "Error: --default-hook-type %q is not a known hook type; add it with --extra-hook-type\n", *defaultHookType)
+			os.Exit(1)
+		}
+	} else if len(extraHookTypes.values) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: --extra-hook-type requires --known-hook-types\n")
+		os.Exit(1)
+	}
+	if *ingestStatus != "accepted" && *ingestStatus != "detailed" {
+		fmt.Fprintf(os.Stderr, "Error: --ingest-status must be accepted or detailed, got %q\n", *ingestStatus)
+		os.Exit(1)
+	}
+	if *futureSkewAction != "clamp" && *futureSkewAction != "reject" {
+		fmt.Fprintf(os.Stderr, "Error: --future-skew-action must be clamp or reject, got %q\n", *futureSkewAction)
+		os.Exit(1)
+	}
+	projectRetention, err := parseProjectRetention(retentionProjects.values, *normalizePaths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --retention-project: %v\n", err)
+		os.Exit(1)
+	}
+	retentionPolicy := store.RetentionPolicy{Default: *retention, Projects: projectRetention}
+	if *retentionPurge < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --retention-purge-interval must not be negative\n")
+		os.Exit(1)
+	}
+	if *retentionPurge > 0 && !retentionPolicy.Enabled() {
+		fmt.Fprintf(os.Stderr, "Error: --retention-purge-interval requires --retention or a --retention-project window\n")
+		os.Exit(1)
+	}
+	if *retentionAction != "drop" && *retentionAction != "reject" {
+		fmt.Fprintf(os.Stderr, "Error: --retention-action must be drop or reject, got %q\n", *retentionAction)
 		os.Exit(1)
 	}
-	defer ms.Close()
 
-	if *migrate {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+	// Validation needs no store, so it runs before connecting to one.
+	if *validatePath != "" {
+		os.Exit(runValidate(*validatePath, *defaultHookType))
+	}
 
-		// Handle SIGINT during migration for clean shutdown.
-		go func() {
-			sig := make(chan os.Signal, 1)
-			signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-			defer signal.Stop(sig)
-			<-sig
-			cancel()
-		}()
+	// Shared by the ingest server and MeiliStore.Update so patched documents
+	// are transformed exactly like ingested ones.
+	transformOpts := store.TransformOptions{
+		MaxValueLen:    int(*maxValueLen),
+		StripANSI:      *stripANSI,
+		NormalizePaths: *normalizePaths,
+		MaxPromptBytes: int(*maxPromptBytes),
+		ContentHash:    *contentHash,
+		Stages:         stages,
+		FlatEnvelope:   *flatEnvelope,
+	}
+	if *hashSessionIDs {
+		transformOpts.SessionIDKey = []byte(*sessionIDSalt)
+	}
 
-		fmt.Println("Starting migration...")
-		count, err := ms.MigrateDocuments(ctx, 100)
+	var es store.EventStore
+	var fileDir string // shown in the TUI header instead of MeiliSearch
+	if *backend == "file" && *filePath != "" {
+		fmt.Fprintf(status, "Appending events to %s...\n", *filePath)
+		js, err := store.NewJSONLStore(*filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		es = js
+		fileDir = *filePath
+	} else if *backend == "file" {
+		fmt.Fprintf(status, "Writing events to %s...\n", *dataDir)
+		fs, err := store.NewFileStore(*dataDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		es = fs
+		fileDir = *dataDir
+	} else {
+		// Connect to MeiliSearch — fail fast if unreachable.
+		fmt.Fprintf(status, "Connecting to MeiliSearch at %s...\n", *meiliURL)
+		ms, err := store.NewMeiliStoreWithOptions(*meiliURL, *meiliKey, *meiliIndex, *promptsIndex, store.MeiliOptions{
+			SearchableAttributes: splitList(*searchable),
+			PromptRank:           *promptRank,
+			Transform:            transformOpts,
+			StrictPrompts:        *strictPrompts,
+			SkipEmptyPrompts:     *skipEmptyPrompts,
+			PromptsOptional:      *promptsOptional,
+			SourceLabel:          *sourceLabel,
+			MigrateWorkers:       *migrateWorkers,
+			NoCreateIndex:        *noCreateIndex,
+			MaxTotalHits:         *maxTotalHits,
+			MaxValuesPerFacet:    *maxValuesPerFacet,
+			SettingsTimeout:      *settingsTimeout,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *migrate {
+			runMigrations(ms)
+			ms.Close()
+			os.Exit(0)
+		}
+		if *migrateField != "" {
+			runMigrateField(ms, *migrateField)
+			ms.Close()
+			os.Exit(0)
+		}
+		if *fixTimestamps {
+			runFixTimestamps(ms)
+			ms.Close()
+			os.Exit(0)
+		}
+		if *warmUp {
+			warmUpStore(ms, status)
+		}
+		es = ms
+	}
+	if len(routeSpecs.values) > 0 {
+		routes, err := parseRoutes(routeSpecs.values)
+		if err == nil {
+			es, err = store.NewRoutingStore(es, routes)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *sessionContext {
+		es = store.NewSessionContextStore(es, *sessionContextMax, *sessionContextTTL)
+	}
+	if *sessionFirstSeen {
+		es = store.NewFirstSeenStore(es, *sessionContextMax)
+	}
+	var purger store.FilterDeleter
+	if *retentionPurge > 0 {
+		fd, ok := store.As[store.FilterDeleter](es)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: --retention-purge-interval requires a store that deletes by filter (--backend meili)\n")
 			os.Exit(1)
 		}
-		fmt.Printf("Migration complete: %d documents processed\n", count)
+		purger = fd
+	}
+	if *selfTestRun {
+		code := runSelfTest(es, transformOpts, *selfTestSLA)
+		es.Close()
+		os.Exit(code)
+	}
+	if *replayPath != "" {
+		code := runReplay(es, *replayPath, transformOpts, *sourceLabel)
+		es.Close()
+		os.Exit(code)
+	}
+	defer es.Close()
 
-		fmt.Println("Migrating data_flat format...")
-		dfcount, err := ms.MigrateDataFlat(ctx, 100)
+	shutdownTracing, err := tracing.Setup(context.Background(), *otelEndpoint, version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer flushCancel()
+		shutdownTracing(flushCtx)
+	}()
+
+	srv := ingest.New(es)
+	srv.SetDefaultHookType(*defaultHookType)
+	srv.SetKnownHookTypes(hookTypes)
+	srv.SetBacklogLimit(*backlogLimit, *backlogRefresh)
+	srv.SetDetailedStatus(*ingestStatus == "detailed")
+	srv.SetMaxFutureSkew(*maxFutureSkew, *futureSkewAction == "reject")
+	srv.SetRetentionPolicy(retentionPolicy, *retentionAction == "reject")
+	srv.SetAdminToken(*adminToken)
+	srv.SetSlowRequestThreshold(*slowRequestThreshold)
+	srv.SetStreamReadLimit(*streamMaxBytes)
+	srv.SetBatchBodyLimit(*batchMaxBytes)
+	srv.SetValidateJSON(*validateJSON)
+	srv.SetStoreRawBody(*storeRawBody)
+	srv.SetTransformOptions(transformOpts)
+	srv.SetSourceLabel(*sourceLabel)
+	srv.SetDiagnostics(version, effectiveConfig(flag.CommandLine))
+
+	allow, err := ingest.ParsePrefixes(allowCIDRs.values)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --allow-cidr: %v\n", err)
+		os.Exit(1)
+	}
+	proxies, err := ingest.ParsePrefixes(trustedProxies.values)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --trusted-proxy: %v\n", err)
+		os.Exit(1)
+	}
+	srv.SetIPAllowlist(allow, proxies)
+	srv.SetIndexAllowlist(allowedIndexes.values)
+	if err := srv.SetSamplingRates(rates); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --sample-rate: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *auditLogPath != "" {
+		audit, err := store.OpenAuditLogWithOptions(*auditLogPath, store.AuditOptions{
+			Fsync:         *auditFsync,
+			Fields:        splitList(*auditFields),
+			FlushCount:    *auditFlushCount,
+			FlushInterval: *auditFlushInterval,
+		})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "data_flat migration failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("data_flat migration complete: %d documents processed\n", dfcount)
+		defer audit.Close()
+		srv.SetAuditLog(audit)
+	}
+
+	if *tee {
+		srv.SetTee(os.Stdout)
+	}
 
-		fmt.Println("Migrating prompts index...")
-		pcount, err := ms.MigratePrompts(ctx, 100)
+	if *rejectLogPath != "" {
+		rejects, err := os.OpenFile(*rejectLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Prompts migration failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: reject log: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Prompts migration complete: %d documents processed\n", pcount)
-		os.Exit(0)
+		defer rejects.Close()
+		srv.SetRejectLog(rejects)
 	}
 
-	srv := ingest.New(ms)
+	// Graceful shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
 
 	// Event channel: owned by main, shared between ingest callback and TUI.
+	// It is never closed — a request still in flight when shutdown begins
+	// would otherwise send on a closed channel; the TUI stops on ctx instead.
 	eventCh := make(chan ingest.IngestEvent, 256)
-	srv.SetOnIngest(func(evt ingest.IngestEvent) {
-		select {
-		case eventCh <- evt:
-		default: // drop if TUI is slow
-		}
-	})
+	srv.SetOnIngest(forwardEvents(ctx, eventCh))
+
+	if purger != nil {
+		go purgeLoop(ctx, purger, retentionPolicy, *retentionPurge)
+	}
 
 	httpSrv := &http.Server{
 		Handler:           srv.Handler(),
@@ -104,15 +501,13 @@ func main() {
 	actualPort := ln.Addr().(*net.TCPAddr).Port
 	listenAddr := fmt.Sprintf("http://localhost:%d", actualPort)
 
-	// Graceful shutdown.
-	ctx, cancel := context.WithCancel(context.Background())
 	var shutdownOnce sync.Once
 	doShutdown := func() {
 		cancel()
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer shutdownCancel()
+		srv.CloseStreams()
 		httpSrv.Shutdown(shutdownCtx)
-		close(eventCh)
 	}
 
 	// Signal handler — SIGINT/SIGTERM triggers shutdown.
@@ -135,13 +530,35 @@ func main() {
 		}
 	}()
 
-	// Run the TUI — blocks until user quits.
-	m := tui.NewModel(tui.Config{
+	if *tee {
+		// Headless: stdout carries the NDJSON stream, so there is no TUI.
+		// Runs until SIGINT/SIGTERM.
+		fmt.Fprintf(os.Stderr, "hooks-store %s listening on %s (tee mode)\n", version, listenAddr)
+		<-ctx.Done()
+		shutdownOnce.Do(doShutdown) // waits for the signal handler's shutdown
+		return
+	}
+
+	tuiCfg := tui.Config{
 		Version:    version,
 		MeiliURL:   *meiliURL,
 		MeiliIndex: *meiliIndex,
 		ListenAddr: listenAddr,
-	}, eventCh, ctx, srv.ErrCount())
+		FileDir:    fileDir,
+
+		RenderWindow:       *renderWindow,
+		CollapseDuplicates: *tuiCollapse,
+		MaxRecentEvents:    *tuiHistory,
+		DumpOnQuit:         *tuiDump,
+	}
+	if br, ok := store.As[store.BacklogReporter](es); ok {
+		tuiCfg.Backlog = br.Backlog
+		// Warn at half the shedding limit, before ingest starts returning 503.
+		tuiCfg.BacklogWarn = *backlogLimit / 2
+	}
+
+	// Run the TUI — blocks until user quits.
+	m := tui.NewModel(tuiCfg, eventCh, ctx, srv.ErrCount())
 
 	if err := tui.Run(m); err != nil {
 		fmt.Fprintf(os.Stderr, "TUI error: %v\n", err)
@@ -150,9 +567,257 @@ func main() {
 	shutdownOnce.Do(doShutdown)
 }
 
+// runMigrations backfills existing MeiliSearch documents (top-level fields,
+// data_flat format, prompts index), exiting non-zero on the first failure.
+func runMigrations(ms *store.MeiliStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle SIGINT during migration for clean shutdown.
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sig)
+		<-sig
+		cancel()
+	}()
+
+	fmt.Println("Starting migration...")
+	count, err := ms.MigrateDocuments(ctx, 100)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Migration complete: %d documents processed\n", count)
+
+	fmt.Println("Migrating data_flat format...")
+	dfcount, err := ms.MigrateDataFlat(ctx, 100)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "data_flat migration failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("data_flat migration complete: %d documents processed\n", dfcount)
+
+	fmt.Println("Migrating prompts index...")
+	pcount, err := ms.MigratePrompts(ctx, 100)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Prompts migration failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Prompts migration complete: %d documents processed\n", pcount)
+}
+
+// runMigrateField backfills a single top-level field with
+// MeiliStore.MigrateField, exiting non-zero on failure.
+func runMigrateField(ms *store.MeiliStore, field string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sig)
+		<-sig
+		cancel()
+	}()
+
+	fmt.Printf("Backfilling %s...\n", field)
+	count, err := ms.MigrateField(ctx, 100, field)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Backfill complete: %d documents updated\n", count)
+}
+
+// runFixTimestamps repairs timestamp_unix with MeiliStore.MigrateTimestamps,
+// exiting non-zero on failure.
+func runFixTimestamps(ms *store.MeiliStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sig)
+		<-sig
+		cancel()
+	}()
+
+	fmt.Println("Checking timestamp_unix against timestamp...")
+	count, err := ms.MigrateTimestamps(ctx, 100)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Timestamp repair complete: %d documents corrected\n", count)
+}
+
+// warmUpStore runs MeiliStore.WarmUp and prints each index's latency to w. A
+// failed warm-up is only a warning: the store is already usable.
+func warmUpStore(ms *store.MeiliStore, w io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	results, err := ms.WarmUp(ctx)
+	for _, r := range results {
+		fmt.Fprintf(w, "Warm-up search on %s: %s\n", r.Index, r.Latency.Round(time.Millisecond))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// splitList splits a comma-separated flag value, trimming blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// forwardEvents returns the ingest callback feeding the TUI: a non-blocking
+// send to ch (events are dropped if the TUI is slow) that stops once ctx is
+// done. ch is never closed, so late callbacks during shutdown are harmless.
+func forwardEvents(ctx context.Context, ch chan<- ingest.IngestEvent) func(ingest.IngestEvent) {
+	return func(evt ingest.IngestEvent) {
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case ch <- evt:
+		default: // drop if TUI is slow
+		}
+	}
+}
+
+// purgeLoop deletes expired documents with store.PurgeExpired right away and
+// then every interval, until ctx is done. Failures only warn; the next run
+// retries.
+func purgeLoop(ctx context.Context, fd store.FilterDeleter, p store.RetentionPolicy, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := store.PurgeExpired(ctx, fd, p, time.Now()); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Warning: retention purge: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// listFlag is a repeatable flag; each occurrence may also hold a
+// comma-separated list. The first occurrence replaces the env default, or
+// the config file's value (see markDefault).
+type listFlag struct {
+	values []string
+	set    bool
+}
+
+func newListFlag(defaults []string) *listFlag {
+	return &listFlag{values: defaults}
+}
+
+func (f *listFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, ",")
+}
+
+func (f *listFlag) Set(v string) error {
+	if !f.set {
+		f.values, f.set = nil, true
+	}
+	f.values = append(f.values, splitList(v)...)
+	return nil
+}
+
+// markDefault makes the current values a default that the next Set
+// replaces.
+func (f *listFlag) markDefault() { f.set = false }
+
+// parseProjectRetention parses --retention-project values of the form
+// project_dir=duration. With normalize (--normalize-paths) the project dirs
+// go through store.NormalizePath, the form such documents are stored in;
+// otherwise they are kept as given, since stored project_dir is too.
+func parseProjectRetention(specs []string, normalize bool) (map[string]time.Duration, error) {
+	windows := make(map[string]time.Duration, len(specs))
+	for _, spec := range specs {
+		dir, v, ok := strings.Cut(spec, "=")
+		dir = strings.TrimSpace(dir)
+		if !ok || dir == "" {
+			return nil, fmt.Errorf("%q: want project_dir=duration", spec)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || window < 0 {
+			return nil, fmt.Errorf("%q: invalid duration", spec)
+		}
+		if normalize {
+			dir = store.NormalizePath(dir)
+		}
+		windows[dir] = window
+	}
+	return windows, nil
+}
+
+// parseSampleRates parses --sample-rate values of the form HookType=rate.
+// Range checks are left to Server.SetSamplingRates.
+func parseSampleRates(specs []string) (map[string]float64, error) {
+	rates := make(map[string]float64, len(specs))
+	for _, spec := range specs {
+		hookType, v, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want HookType=rate", spec)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid rate", spec)
+		}
+		rates[strings.TrimSpace(hookType)] = rate
+	}
+	return rates, nil
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+// envInt64OrDefault is envOrDefault for integer flags. Unparseable values
+// fall back to the default rather than aborting startup.
+func envInt64OrDefault(key string, fallback int64) int64 {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// envBoolOrDefault is envOrDefault for boolean flags ("true", "1", ...).
+func envBoolOrDefault(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+// envDurationOrDefault is envOrDefault for duration flags (e.g. "5s").
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hooks-store/internal/ingest"
+	"hooks-store/internal/store"
+)
+
+// TestForwardEvents_IngestDuringShutdown keeps ingesting while shutdown
+// cancels the context, the way doShutdown does with requests in flight.
+// Handlers are called directly, not through net/http, so a panic in the
+// callback would crash the test instead of being recovered by the server.
+func TestForwardEvents_IngestDuringShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan ingest.IngestEvent, 4)
+	srv := ingest.New(&lagStore{})
+	srv.SetOnIngest(forwardEvents(ctx, ch))
+	h := srv.Handler()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+				if w.Code != http.StatusAccepted {
+					t.Errorf("status = %d, want 202", w.Code)
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// Drain what was forwarded before the cancel; nothing arrives after.
+	for len(ch) > 0 {
+		<-ch
+	}
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(ch) != 0 {
+		t.Errorf("event forwarded after shutdown")
+	}
+}
+
+func TestParseProjectRetention(t *testing.T) {
+	specs := []string{`C:\work\app\=24h`, "/srv/api/ = 0"}
+	got, err := parseProjectRetention(specs, true)
+	if err != nil {
+		t.Fatalf("parseProjectRetention: %v", err)
+	}
+	want := map[string]time.Duration{"C:/work/app": 24 * time.Hour, "/srv/api": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalized windows = %v, want %v", got, want)
+	}
+
+	// Without --normalize-paths project_dir is stored as sent, so the
+	// purge must match the dirs as given: the override's own pass deletes
+	// on its window and the default pass excludes it.
+	got, err = parseProjectRetention(specs, false)
+	if err != nil {
+		t.Fatalf("parseProjectRetention: %v", err)
+	}
+	want = map[string]time.Duration{`C:\work\app\`: 24 * time.Hour, "/srv/api/": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("raw windows = %v, want %v", got, want)
+	}
+	policy := store.RetentionPolicy{Default: time.Hour, Projects: got}
+	filters := policy.PurgeFilters(time.Unix(1_000_000, 0))
+	wantFilters := []string{
+		`project_dir = "C:\\work\\app\\" AND timestamp_unix > 0 AND timestamp_unix < 913600`,
+		`timestamp_unix > 0 AND timestamp_unix < 996400 AND NOT project_dir IN ["/srv/api/", "C:\\work\\app\\"]`,
+	}
+	if !reflect.DeepEqual(filters, wantFilters) {
+		t.Errorf("purge filters = %q, want %q", filters, wantFilters)
+	}
+
+	for _, spec := range []string{"/srv/api", "=24h", "/srv/api=-1h", "/srv/api=soon"} {
+		if _, err := parseProjectRetention([]string{spec}, false); err == nil {
+			t.Errorf("%q: err = nil, want error", spec)
+		}
+	}
+}
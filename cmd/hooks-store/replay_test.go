@@ -0,0 +1,115 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+// recordingStore records indexed documents and the size of each batch.
+type recordingStore struct {
+	docs    []store.Document
+	batches []int
+}
+
+func (s *recordingStore) Index(ctx context.Context, doc store.Document) error {
+	s.docs = append(s.docs, doc)
+	return nil
+}
+
+func (s *recordingStore) BatchIndex(ctx context.Context, docs []store.Document) error {
+	s.batches = append(s.batches, len(docs))
+	s.docs = append(s.docs, docs...)
+	return nil
+}
+
+func (s *recordingStore) Close() error { return nil }
+
+// replayFixture returns n NDJSON events, one per line.
+func replayFixture(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `{"hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00Z","data":{"tool_name":"Read","session_id":"s%d"}}`+"\n", i)
+	}
+	return b.String()
+}
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	io.WriteString(zw, content)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func TestReplay_Gzip(t *testing.T) {
+	dir := t.TempDir()
+	fixture := replayFixture(250)
+
+	byExt := filepath.Join(dir, "backup.ndjson.gz")
+	writeGzip(t, byExt, fixture)
+	byMagic := filepath.Join(dir, "backup.ndjson") // gzipped, misleading name
+	writeGzip(t, byMagic, fixture)
+	plain := filepath.Join(dir, "plain.ndjson")
+	os.WriteFile(plain, []byte(fixture), 0o644)
+
+	for _, path := range []string{byExt, byMagic, plain} {
+		r, err := openReplay(path)
+		if err != nil {
+			t.Fatalf("openReplay(%s): %v", path, err)
+		}
+		es := &recordingStore{}
+		n, err := replay(context.Background(), es, r, store.TransformOptions{}, "backup")
+		r.Close()
+		if err != nil {
+			t.Fatalf("replay(%s): %v", path, err)
+		}
+		if n != 250 || len(es.docs) != 250 {
+			t.Errorf("%s: replayed %d (%d docs), want 250", filepath.Base(path), n, len(es.docs))
+		}
+		if fmt.Sprint(es.batches) != "[100 100 50]" {
+			t.Errorf("%s: batches = %v, want [100 100 50]", filepath.Base(path), es.batches)
+		}
+		if es.docs[249].SessionID != "s249" || es.docs[0].Source != "backup" {
+			t.Errorf("%s: last doc = %+v, want transformed s249 with source backup", filepath.Base(path), es.docs[249])
+		}
+	}
+}
+
+func TestReplay_BlankLines(t *testing.T) {
+	es := &recordingStore{}
+	n, err := replay(context.Background(), es, strings.NewReader(replayFixture(3)+"\n"), store.TransformOptions{}, "")
+	if err != nil || n != 3 || len(es.docs) != 3 {
+		t.Errorf("replay = %d, %v (%d docs), want 3 docs", n, err, len(es.docs))
+	}
+}
+
+func TestReplay_Errors(t *testing.T) {
+	for name, input := range map[string]string{
+		"invalid JSON":      replayFixture(1) + "{oops\n",
+		"missing hook_type": `{"data":{}}` + "\n",
+	} {
+		if _, err := replay(context.Background(), &recordingStore{}, strings.NewReader(input), store.TransformOptions{}, ""); err == nil {
+			t.Errorf("%s: err = nil, want error", name)
+		}
+	}
+
+	bad := filepath.Join(t.TempDir(), "corrupt.gz")
+	os.WriteFile(bad, []byte("not gzip"), 0o644)
+	if _, err := openReplay(bad); err == nil {
+		t.Error("openReplay(corrupt .gz): err = nil, want error")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"hooks-store/internal/store"
+)
+
+// parseRoutes builds the extra stores named by --route values of the form
+// "category=file:DIR": every document of that category (see
+// store.DocumentCategory; "*" for all) is also written to a FileStore at DIR.
+// On error, stores already opened are closed.
+func parseRoutes(specs []string) ([]store.Route, error) {
+	var routes []store.Route
+	fail := func(err error) ([]store.Route, error) {
+		for _, r := range routes {
+			r.Store.Close()
+		}
+		return nil, err
+	}
+	for _, spec := range specs {
+		category, target, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fail(fmt.Errorf("route %q: want category=file:DIR", spec))
+		}
+		dir, ok := strings.CutPrefix(target, "file:")
+		if !ok {
+			return fail(fmt.Errorf("route %q: unsupported backend %q (want file:DIR)", spec, target))
+		}
+		fs, err := store.NewFileStore(dir)
+		if err != nil {
+			return fail(fmt.Errorf("route %q: %w", spec, err))
+		}
+		routes = append(routes, store.Route{Category: category, Name: target, Store: fs})
+	}
+	return routes, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"hooks-store/internal/hookevt"
+	"hooks-store/internal/ingest"
+	"hooks-store/internal/store"
+)
+
+const (
+	// selfTestPoll is how often selfTest checks whether its event is searchable.
+	selfTestPoll = 50 * time.Millisecond
+	// selfTestTimeout bounds the wait, SLA or not, so latency past the SLA
+	// is still measured and reported.
+	selfTestTimeout = 30 * time.Second
+)
+
+// runSelfTest sends one marker event through the ingest handler into es and
+// waits until search returns it, printing the round-trip latency. With
+// sla > 0 a slower round trip fails. The marker document is deleted again
+// when the store supports it. Returns the process exit code.
+func runSelfTest(es store.EventStore, opts store.TransformOptions, sla time.Duration) int {
+	searcher, ok := store.As[store.Searcher](es)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Selftest failed: backend does not support search (use --backend meili)")
+		return 1
+	}
+	srv := ingest.New(es)
+	srv.SetTransformOptions(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+	defer cancel()
+	sessionID := fmt.Sprintf("selftest-%d", time.Now().UnixNano())
+	storedID := sessionID
+	if len(opts.SessionIDKey) > 0 {
+		storedID = store.HashSessionID(opts.SessionIDKey, sessionID)
+	}
+	latency, err := selfTest(ctx, srv.Handler(), searcher, sessionID, storedID)
+	if del, ok := store.As[store.FilterDeleter](es); ok {
+		if _, derr := del.DeleteByFilter(context.Background(), selfTestFilter(storedID)); derr != nil {
+			fmt.Fprintf(os.Stderr, "warning: selftest cleanup: %v\n", derr)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Selftest failed: %v\n", err)
+		return 1
+	}
+
+	if sla <= 0 {
+		fmt.Printf("Selftest passed: searchable after %s\n", latency.Round(time.Millisecond))
+		return 0
+	}
+	if latency > sla {
+		fmt.Printf("Selftest failed: searchable after %s, over the %s SLA\n", latency.Round(time.Millisecond), sla)
+		return 1
+	}
+	fmt.Printf("Selftest passed: searchable after %s (SLA %s)\n", latency.Round(time.Millisecond), sla)
+	return 0
+}
+
+// selfTest POSTs a Notification event for sessionID to handler's /ingest
+// and polls search until a hit for storedID (sessionID as the transform
+// stores it) appears, returning the time from POST to first hit. Fails if ingest is rejected, a search errors, or
+// ctx ends first.
+func selfTest(ctx context.Context, handler http.Handler, searcher store.Searcher, sessionID, storedID string) (time.Duration, error) {
+	body, err := json.Marshal(hookevt.HookEvent{
+		HookType:  "Notification",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"session_id": sessionID, "message": "hooks-store selftest"},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body)))
+	if w.Code != http.StatusAccepted {
+		return 0, fmt.Errorf("ingest: status %d: %s", w.Code, bytes.TrimSpace(w.Body.Bytes()))
+	}
+
+	ticker := time.NewTicker(selfTestPoll)
+	defer ticker.Stop()
+	for {
+		res, err := searcher.Search(ctx, store.SearchParams{Filter: selfTestFilter(storedID), Limit: 1})
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return 0, fmt.Errorf("search: %w", err)
+		}
+		if len(res.Hits) > 0 {
+			return time.Since(start), nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("event not searchable after %s", time.Since(start).Round(time.Millisecond))
+		case <-ticker.C:
+		}
+	}
+}
+
+// selfTestFilter matches the selftest's marker event.
+func selfTestFilter(sessionID string) string {
+	return fmt.Sprintf("session_id = %q", sessionID)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hooks-store/internal/ingest"
+	"hooks-store/internal/store"
+)
+
+// lagStore makes indexed documents searchable only after lag, like
+// MeiliSearch's asynchronous indexing, and records deletes.
+type lagStore struct {
+	mu      sync.Mutex
+	lag     time.Duration
+	docs    []store.Document
+	indexed []time.Time
+	deleted []string
+}
+
+func (s *lagStore) Index(ctx context.Context, doc store.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs = append(s.docs, doc)
+	s.indexed = append(s.indexed, time.Now())
+	return nil
+}
+
+func (s *lagStore) BatchIndex(ctx context.Context, docs []store.Document) error {
+	for _, doc := range docs {
+		s.Index(ctx, doc)
+	}
+	return nil
+}
+
+func (s *lagStore) Search(ctx context.Context, p store.SearchParams) (store.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res store.SearchResult
+	for i, doc := range s.docs {
+		if time.Since(s.indexed[i]) >= s.lag && strings.Contains(p.Filter, doc.SessionID) {
+			res.Hits = append(res.Hits, doc)
+		}
+	}
+	return res, nil
+}
+
+func (s *lagStore) CountByFilter(ctx context.Context, filter string) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (s *lagStore) DeleteByFilter(ctx context.Context, filter string) (store.DeleteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, filter)
+	return store.DeleteResult{}, nil
+}
+
+func (s *lagStore) Close() error { return nil }
+
+func TestSelfTest_Latency(t *testing.T) {
+	t.Parallel()
+	ls := &lagStore{lag: 120 * time.Millisecond}
+
+	latency, err := selfTest(context.Background(), ingest.New(ls).Handler(), ls, "selftest-1", "selftest-1")
+	if err != nil {
+		t.Fatalf("selfTest: %v", err)
+	}
+	if latency < ls.lag || latency > ls.lag+time.Second {
+		t.Errorf("latency = %s, want about %s", latency, ls.lag)
+	}
+	if len(ls.docs) != 1 || ls.docs[0].SessionID != "selftest-1" || ls.docs[0].HookType != "Notification" {
+		t.Errorf("indexed = %+v, want one selftest Notification", ls.docs)
+	}
+}
+
+func TestSelfTest_Timeout(t *testing.T) {
+	t.Parallel()
+	ls := &lagStore{lag: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := selfTest(ctx, ingest.New(ls).Handler(), ls, "selftest-2", "selftest-2"); err == nil || !strings.Contains(err.Error(), "not searchable") {
+		t.Errorf("selfTest = %v, want not searchable error", err)
+	}
+}
+
+func TestRunSelfTest(t *testing.T) {
+	t.Parallel()
+
+	ls := &lagStore{lag: 50 * time.Millisecond}
+	if code := runSelfTest(ls, store.TransformOptions{}, time.Second); code != 0 {
+		t.Errorf("within SLA: exit code = %d, want 0", code)
+	}
+	if len(ls.deleted) != 1 || !strings.Contains(ls.deleted[0], "selftest-") {
+		t.Errorf("deleted = %v, want the marker removed", ls.deleted)
+	}
+
+	if code := runSelfTest(&lagStore{lag: 50 * time.Millisecond}, store.TransformOptions{}, time.Millisecond); code != 1 {
+		t.Errorf("over SLA: exit code = %d, want 1", code)
+	}
+	hashed := &lagStore{}
+	if code := runSelfTest(hashed, store.TransformOptions{SessionIDKey: []byte("salt")}, time.Second); code != 0 {
+		t.Errorf("hashed session IDs: exit code = %d, want 0", code)
+	}
+	if len(hashed.docs) != 1 || !strings.HasPrefix(hashed.docs[0].SessionID, "hs_") {
+		t.Errorf("hashed docs = %+v, want a hashed session_id", hashed.docs)
+	}
+	if code := runSelfTest(&recordingStore{}, store.TransformOptions{}, 0); code != 1 {
+		t.Errorf("no search support: exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"hooks-store/internal/ingest"
+)
+
+// runValidate checks every line of an NDJSON file (optionally gzipped, as
+// for --replay) against /ingest's validation, printing each failure and a
+// summary, and returns the process exit code: 0 only if every line is valid.
+// Nothing is indexed.
+func runValidate(path, defaultHookType string) int {
+	r, err := openReplay(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Validate failed: %v\n", err)
+		return 1
+	}
+	defer r.Close()
+
+	valid, invalid, err := validate(r, defaultHookType, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Validate failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s: %d valid, %d invalid\n", path, valid, invalid)
+	if invalid > 0 {
+		return 1
+	}
+	return 0
+}
+
+// validate runs ingest.DecodeEvent over each non-blank line of r, writing
+// "line N: reason" to out for each failure. err reports a read failure,
+// after which the counts cover only the lines before it.
+func validate(r io.Reader, defaultHookType string, out io.Writer) (valid, invalid int, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if _, err := ingest.DecodeEvent(text, defaultHookType); err != nil {
+			fmt.Fprintf(out, "line %d: %v\n", line, err)
+			invalid++
+			continue
+		}
+		valid++
+	}
+	if err := sc.Err(); err != nil {
+		return valid, invalid, fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return valid, invalid, nil
+}
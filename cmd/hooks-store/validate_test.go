@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	deep := strings.Repeat(`{"a":`, 101) + "1" + strings.Repeat("}", 101)
+	input := strings.Join([]string{
+		`{"hook_type":"PreToolUse","data":{}}`,
+		`{not json`,
+		``,
+		`{"data":{"tool_name":"Read"}}`,
+		`{"hook_type":"Stop","data":` + deep + `}`,
+		`{"hook_type":"Stop"}`,
+	}, "\n")
+
+	var out strings.Builder
+	valid, invalid, err := validate(strings.NewReader(input), "", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid != 2 || invalid != 3 {
+		t.Errorf("valid, invalid = %d, %d; want 2, 3", valid, invalid)
+	}
+	want := "line 2: invalid JSON\nline 4: missing hook_type\nline 5: JSON nesting exceeds maximum depth of 100\n"
+	if out.String() != want {
+		t.Errorf("report =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// A default hook type makes a missing one valid, as on /ingest.
+	valid, invalid, _ = validate(strings.NewReader(input), "Legacy", &strings.Builder{})
+	if valid != 3 || invalid != 2 {
+		t.Errorf("with default hook type: valid, invalid = %d, %d; want 3, 2", valid, invalid)
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.ndjson.gz")
+	writeGzip(t, good, replayFixture(3))
+	if code := runValidate(good, ""); code != 0 {
+		t.Errorf("valid gzipped file: exit code %d, want 0", code)
+	}
+
+	bad := filepath.Join(dir, "bad.ndjson.gz")
+	writeGzip(t, bad, replayFixture(2)+"{}\n")
+	if code := runValidate(bad, ""); code != 1 {
+		t.Errorf("invalid line: exit code %d, want 1", code)
+	}
+	if code := runValidate(filepath.Join(dir, "missing"), ""); code != 1 {
+		t.Errorf("missing file: exit code %d, want 1", code)
+	}
+}
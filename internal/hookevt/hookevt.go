@@ -1,6 +1,23 @@
 package hookevt
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// RawDataKey is the Data key holding a data value that is not a JSON object.
+const RawDataKey = "_raw"
+
+// KnownHookTypes are the canonical hook types the Claude Hooks Monitor
+// sends. The ingest server only enforces them in strict mode; by default any
+// non-empty hook_type is accepted.
+var KnownHookTypes = []string{
+	"SessionStart", "UserPromptSubmit", "PreToolUse", "PostToolUse",
+	"PostToolUseFailure", "PermissionRequest", "Notification",
+	"SubagentStart", "SubagentStop", "Stop", "TeammateIdle",
+	"TaskCompleted", "ConfigChange", "PreCompact", "SessionEnd",
+}
 
 // HookEvent matches the JSON wire format sent by the Claude Hooks Monitor.
 // This is an independent definition — no imports from the monitor module.
@@ -9,4 +26,51 @@ type HookEvent struct {
 	HookType  string                 `json:"hook_type"`
 	Timestamp time.Time              `json:"timestamp"`
 	Data      map[string]interface{} `json:"data"`
+
+	// Extra holds the envelope's other top-level keys, undecoded, for
+	// senders that put event fields beside data instead of inside it. Nil
+	// when there are none. Never marshaled.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes the wire format, tolerating a data value that is not
+// an object: a buggy sender's array, string, or number is kept as
+// Data[RawDataKey] so the event is still indexed instead of rejected. A null
+// or missing data leaves Data nil. Unknown top-level keys go to Extra.
+func (e *HookEvent) UnmarshalJSON(b []byte) error {
+	type wire HookEvent
+	var aux struct {
+		wire
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	*e = HookEvent(aux.wire)
+	e.Data = nil
+
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(b, &top); err != nil {
+		return err
+	}
+	delete(top, "hook_type")
+	delete(top, "timestamp")
+	delete(top, "data")
+	if len(top) > 0 {
+		e.Extra = top
+	}
+
+	raw := bytes.TrimSpace(aux.Data)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	if raw[0] == '{' {
+		return json.Unmarshal(raw, &e.Data)
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return err
+	}
+	e.Data = map[string]interface{}{RawDataKey: v}
+	return nil
 }
@@ -0,0 +1,97 @@
+package hookevt
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHookEvent_UnmarshalData(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data string
+		want map[string]interface{}
+	}{
+		{"object", `{"tool_name":"Bash"}`, map[string]interface{}{"tool_name": "Bash"}},
+		{"array", `["a",1]`, map[string]interface{}{RawDataKey: []interface{}{"a", float64(1)}}},
+		{"string", `"oops"`, map[string]interface{}{RawDataKey: "oops"}},
+		{"number", `42`, map[string]interface{}{RawDataKey: float64(42)}},
+		{"null", `null`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evt HookEvent
+			body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":` + tt.data + `}`
+			if err := json.Unmarshal([]byte(body), &evt); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if evt.HookType != "Stop" || evt.Timestamp.IsZero() {
+				t.Errorf("HookType = %q, Timestamp = %v; want Stop and a parsed time", evt.HookType, evt.Timestamp)
+			}
+			if !reflect.DeepEqual(evt.Data, tt.want) {
+				t.Errorf("Data = %#v, want %#v", evt.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestHookEvent_UnmarshalMissingData(t *testing.T) {
+	t.Parallel()
+
+	var evt HookEvent
+	if err := json.Unmarshal([]byte(`{"hook_type":"Stop"}`), &evt); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if evt.Data != nil {
+		t.Errorf("Data = %#v, want nil", evt.Data)
+	}
+	if err := json.Unmarshal([]byte(`{"hook_type":"Stop","timestamp":"yesterday"}`), &evt); err == nil {
+		t.Error("bad timestamp: err = nil, want error")
+	}
+}
+
+func TestHookEvent_UnmarshalExtra(t *testing.T) {
+	t.Parallel()
+
+	var evt HookEvent
+	body := `{"hook_type":"PreToolUse","tool_name":"Bash","session_id":"s1","data":{}}`
+	if err := json.Unmarshal([]byte(body), &evt); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := map[string]json.RawMessage{"tool_name": json.RawMessage(`"Bash"`), "session_id": json.RawMessage(`"s1"`)}
+	if !reflect.DeepEqual(evt.Extra, want) {
+		t.Errorf("Extra = %s, want tool_name and session_id only", evt.Extra)
+	}
+
+	evt = HookEvent{}
+	if err := json.Unmarshal([]byte(`{"hook_type":"Stop","data":{}}`), &evt); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if evt.Extra != nil {
+		t.Errorf("Extra = %s, want nil for a standard envelope", evt.Extra)
+	}
+
+	out, _ := json.Marshal(HookEvent{HookType: "Stop", Extra: want})
+	if strings.Contains(string(out), "tool_name") {
+		t.Errorf("Marshal = %s, want Extra omitted", out)
+	}
+}
+
+func TestKnownHookTypes(t *testing.T) {
+	t.Parallel()
+
+	// Spelled out independently so a typo or rename in KnownHookTypes
+	// fails here instead of silently changing strict mode.
+	want := []string{
+		"SessionStart", "UserPromptSubmit", "PreToolUse", "PostToolUse",
+		"PostToolUseFailure", "PermissionRequest", "Notification",
+		"SubagentStart", "SubagentStop", "Stop", "TeammateIdle",
+		"TaskCompleted", "ConfigChange", "PreCompact", "SessionEnd",
+	}
+	if !reflect.DeepEqual(KnownHookTypes, want) {
+		t.Errorf("KnownHookTypes = %v, want %v", KnownHookTypes, want)
+	}
+}
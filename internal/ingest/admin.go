@@ -0,0 +1,140 @@
+package ingest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"hooks-store/internal/store"
+)
+
+// requireAdmin wraps an /admin/* handler with bearer-token authentication.
+// Without a configured token the endpoint is disabled (403); a missing or
+// wrong token gets 401.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			jsonError(w, "admin endpoints disabled", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			jsonError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// deleteRequest is the body of POST /admin/delete.
+type deleteRequest struct {
+	Filter  string `json:"filter"`
+	Confirm bool   `json:"confirm"`
+}
+
+// handleAdminDelete serves POST /admin/delete — deletes every document
+// matching a filter expression. Filters may only reference filterable
+// attributes, and a filter matching every document is refused unless
+// confirm is set (in the body or as ?confirm=true).
+func (s *Server) handleAdminDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fd, ok := store.As[store.FilterDeleter](s.store)
+	if !ok {
+		jsonError(w, "delete by filter not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyLen))
+	if err != nil {
+		jsonError(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	var req deleteRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("confirm") == "true" {
+		req.Confirm = true
+	}
+	if strings.TrimSpace(req.Filter) == "" {
+		jsonError(w, "filter is required", http.StatusBadRequest)
+		return
+	}
+	if err := store.ValidateFilter(req.Filter); err != nil {
+		jsonError(w, "invalid filter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !req.Confirm {
+		matched, total, err := fd.CountByFilter(r.Context(), req.Filter)
+		if err != nil {
+			jsonError(w, "count failed", http.StatusServiceUnavailable)
+			return
+		}
+		if total > 0 && matched >= total {
+			jsonError(w, "filter matches every document; set confirm=true to proceed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := fd.DeleteByFilter(r.Context(), req.Filter)
+	if err != nil {
+		jsonError(w, "delete failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleAdminClear serves POST /admin/clear?confirm=true — deletes every
+// document from every index the store manages, keeping index settings.
+// Meant for resetting dev and test environments; without confirm=true it
+// refuses with 400.
+func (s *Server) handleAdminClear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cl, ok := store.As[store.Clearer](s.store)
+	if !ok {
+		jsonError(w, "clear not supported by store", http.StatusNotImplemented)
+		return
+	}
+	if r.URL.Query().Get("confirm") != "true" {
+		jsonError(w, "clear deletes every document; set confirm=true to proceed", http.StatusBadRequest)
+		return
+	}
+
+	results, err := cl.Clear(r.Context())
+	if err != nil {
+		jsonError(w, "clear failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"indexes": results})
+}
+
+// handleAdminSettings serves GET /admin/settings — the live settings of every
+// index the store manages, as the backend reports them.
+func (s *Server) handleAdminSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sr, ok := store.As[store.SettingsReporter](s.store)
+	if !ok {
+		jsonError(w, "settings not supported by store", http.StatusNotImplemented)
+		return
+	}
+	settings, err := sr.GetSettings(r.Context())
+	if err != nil {
+		jsonError(w, "get settings failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"indexes": settings})
+}
@@ -0,0 +1,246 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+// deleteStore is a mockStore that implements store.FilterDeleter.
+type deleteStore struct {
+	mockStore
+	matched, total int64
+	deleted        []string
+}
+
+func (d *deleteStore) CountByFilter(ctx context.Context, filter string) (int64, int64, error) {
+	return d.matched, d.total, nil
+}
+
+func (d *deleteStore) DeleteByFilter(ctx context.Context, filter string) (store.DeleteResult, error) {
+	d.deleted = append(d.deleted, filter)
+	return store.DeleteResult{TaskUID: 7, Status: "succeeded", DeletedDocuments: d.matched}, nil
+}
+
+func adminDelete(srv *Server, token, url, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminDelete(t *testing.T) {
+	t.Parallel()
+	ds := &deleteStore{matched: 3, total: 10}
+	srv := New(ds)
+	srv.SetAdminToken("secret")
+
+	w := adminDelete(srv, "secret", "/admin/delete", `{"filter":"session_id = \"abc\""}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if len(ds.deleted) != 1 || ds.deleted[0] != `session_id = "abc"` {
+		t.Errorf("deleted = %v", ds.deleted)
+	}
+	if !strings.Contains(w.Body.String(), `"deleted_documents":3`) {
+		t.Errorf("body = %s, want deleted_documents 3", w.Body.String())
+	}
+}
+
+func TestAdminDelete_Auth(t *testing.T) {
+	t.Parallel()
+	body := `{"filter":"session_id = abc"}`
+
+	disabled := New(&deleteStore{total: 10})
+	if w := adminDelete(disabled, "secret", "/admin/delete", body); w.Code != http.StatusForbidden {
+		t.Errorf("no token configured: status = %d, want 403", w.Code)
+	}
+
+	srv := New(&deleteStore{total: 10})
+	srv.SetAdminToken("secret")
+	if w := adminDelete(srv, "", "/admin/delete", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: status = %d, want 401", w.Code)
+	}
+	if w := adminDelete(srv, "wrong", "/admin/delete", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestAdminDelete_InvalidFilter(t *testing.T) {
+	t.Parallel()
+	ds := &deleteStore{total: 10}
+	srv := New(ds)
+	srv.SetAdminToken("secret")
+
+	for _, body := range []string{
+		`{}`,
+		`{"filter":"  "}`,
+		`{"filter":"data_flat = x"}`,
+		`not json`,
+	} {
+		if w := adminDelete(srv, "secret", "/admin/delete", body); w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+	if len(ds.deleted) != 0 {
+		t.Errorf("deleted = %v, want none", ds.deleted)
+	}
+}
+
+func TestAdminDelete_MatchesAllNeedsConfirm(t *testing.T) {
+	t.Parallel()
+	ds := &deleteStore{matched: 10, total: 10}
+	srv := New(ds)
+	srv.SetAdminToken("secret")
+	body := `{"filter":"timestamp_unix > 0"}`
+
+	if w := adminDelete(srv, "secret", "/admin/delete", body); w.Code != http.StatusBadRequest {
+		t.Fatalf("unconfirmed: status = %d, want 400", w.Code)
+	}
+	if len(ds.deleted) != 0 {
+		t.Fatalf("unconfirmed delete ran: %v", ds.deleted)
+	}
+
+	if w := adminDelete(srv, "secret", "/admin/delete?confirm=true", body); w.Code != http.StatusOK {
+		t.Errorf("query confirm: status = %d, want 200", w.Code)
+	}
+	if w := adminDelete(srv, "secret", "/admin/delete", `{"filter":"timestamp_unix > 0","confirm":true}`); w.Code != http.StatusOK {
+		t.Errorf("body confirm: status = %d, want 200", w.Code)
+	}
+	if len(ds.deleted) != 2 {
+		t.Errorf("deleted %d times, want 2", len(ds.deleted))
+	}
+}
+
+func TestAdminDelete_NotSupported(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.SetAdminToken("secret")
+
+	if w := adminDelete(srv, "secret", "/admin/delete", `{"filter":"session_id = a"}`); w.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", w.Code)
+	}
+}
+
+// settingsStore is a mockStore that implements store.SettingsReporter.
+type settingsStore struct {
+	mockStore
+}
+
+func (s *settingsStore) GetSettings(ctx context.Context) ([]store.IndexSettings, error) {
+	return []store.IndexSettings{
+		{Index: "hook-events", SearchableAttributes: []string{"prompt", "data_flat"}, MaxTotalHits: 10000},
+		{Index: "hook-prompts", SearchableAttributes: []string{"prompt"}},
+	}, nil
+}
+
+func TestAdminSettings(t *testing.T) {
+	t.Parallel()
+	srv := New(&settingsStore{})
+	srv.SetAdminToken("secret")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/settings", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp struct {
+		Indexes []store.IndexSettings `json:"indexes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Indexes) != 2 || resp.Indexes[0].Index != "hook-events" || resp.Indexes[0].MaxTotalHits != 10000 {
+		t.Errorf("indexes = %+v", resp.Indexes)
+	}
+}
+
+func TestAdminSettings_AuthAndSupport(t *testing.T) {
+	t.Parallel()
+
+	srv := New(&settingsStore{})
+	srv.SetAdminToken("secret")
+	req := httptest.NewRequest(http.MethodGet, "/admin/settings", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: status = %d, want 401", w.Code)
+	}
+
+	plain := New(&mockStore{})
+	plain.SetAdminToken("secret")
+	req = httptest.NewRequest(http.MethodGet, "/admin/settings", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	plain.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unsupported store: status = %d, want 501", w.Code)
+	}
+}
+
+// clearStore is a mockStore that implements store.Clearer.
+type clearStore struct {
+	mockStore
+	cleared int
+}
+
+func (c *clearStore) Clear(ctx context.Context) ([]store.DeleteResult, error) {
+	c.cleared++
+	return []store.DeleteResult{
+		{Index: "hook-events", TaskUID: 1, Status: "succeeded", DeletedDocuments: 10},
+		{Index: "hook-prompts", TaskUID: 2, Status: "succeeded", DeletedDocuments: 2},
+	}, nil
+}
+
+func TestAdminClear(t *testing.T) {
+	t.Parallel()
+	cs := &clearStore{}
+	srv := New(cs)
+	srv.SetAdminToken("secret")
+
+	w := adminDelete(srv, "secret", "/admin/clear", "")
+	if w.Code != http.StatusBadRequest || cs.cleared != 0 {
+		t.Fatalf("unconfirmed: status = %d, cleared = %d; want 400 and no clear", w.Code, cs.cleared)
+	}
+
+	w = adminDelete(srv, "secret", "/admin/clear?confirm=true", "")
+	if w.Code != http.StatusOK || cs.cleared != 1 {
+		t.Fatalf("confirmed: status = %d, cleared = %d: %s", w.Code, cs.cleared, w.Body.String())
+	}
+	var resp struct {
+		Indexes []store.DeleteResult `json:"indexes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Indexes) != 2 || resp.Indexes[1].Index != "hook-prompts" {
+		t.Errorf("indexes = %+v", resp.Indexes)
+	}
+}
+
+func TestAdminClear_AuthAndSupport(t *testing.T) {
+	t.Parallel()
+	cs := &clearStore{}
+	srv := New(cs)
+	srv.SetAdminToken("secret")
+	if w := adminDelete(srv, "wrong", "/admin/clear?confirm=true", ""); w.Code != http.StatusUnauthorized || cs.cleared != 0 {
+		t.Errorf("wrong token: status = %d, cleared = %d; want 401 and no clear", w.Code, cs.cleared)
+	}
+
+	plain := New(&mockStore{})
+	plain.SetAdminToken("secret")
+	if w := adminDelete(plain, "secret", "/admin/clear?confirm=true", ""); w.Code != http.StatusNotImplemented {
+		t.Errorf("unsupported store: status = %d, want 501", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParsePrefixes parses CIDR ranges such as "10.0.0.0/8". A bare address is
+// accepted as a single-host range.
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ipAllowed reports whether r's client address is in s.allowCIDRs. An empty
+// allowlist allows everyone.
+func (s *Server) ipAllowed(r *http.Request) bool {
+	if len(s.allowCIDRs) == 0 {
+		return true
+	}
+	addr, ok := s.clientAddr(r)
+	return ok && containsAddr(s.allowCIDRs, addr)
+}
+
+// clientAddr returns the request's client address: the TCP peer, or — when
+// the peer is a trusted proxy — the nearest X-Forwarded-For hop that isn't
+// one. X-Forwarded-For from untrusted peers is ignored, since any client can
+// set it.
+func (s *Server) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	if !containsAddr(s.trustedProxies, addr) {
+		return addr, true
+	}
+
+	// Walk right to left: each trusted hop vouches for the one before it.
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = hop.Unmap()
+		if !containsAddr(s.trustedProxies, addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+// containsAddr reports whether any prefix contains addr.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"hooks-store/internal/hookevt"
+	"hooks-store/internal/store"
+)
+
+// defaultBatchMaxBytes is the /ingest/batch body limit when
+// SetBatchBodyLimit is unset: room for a monitor's offline buffer.
+const defaultBatchMaxBytes = 16 << 20 // 16 MiB
+
+// SetBatchBodyLimit caps the request body of POST /ingest/batch, which is
+// naturally larger than a single event; each event in the batch is still held
+// to the 1 MiB /ingest limit. Zero (the default) uses 16 MiB.
+func (s *Server) SetBatchBodyLimit(n int64) {
+	s.batchMaxBytes = n
+}
+
+// batchRequest is the object form of an /ingest/batch body; the other form
+// is a bare array of events.
+type batchRequest struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+// batchResult is the outcome of one event of a batch. Status is the /ingest
+// success status (accepted, or queued/indexed with SetDetailedStatus),
+// dropped or sampled for an event skipped like /ingest skips it, or
+// rejected with a Reason.
+type batchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// batchItem is an event of a batch that was transformed and awaits
+// indexing.
+type batchItem struct {
+	index int
+	evt   hookevt.HookEvent
+	doc   store.Document
+	size  int
+}
+
+// handleIngestBatch serves POST /ingest/batch — several events in one
+// request, for senders flushing a buffer. The body is a JSON array of events
+// or {"events": [...]}. Each event goes through /ingest's validation and
+// checks on its own, and the transformed documents are indexed in one
+// BatchIndex call.
+// The response is 200 with a result per event, so partial failures are
+// visible; only a body that is not a batch at all fails as a whole.
+func (s *Server) handleIngestBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.ipAllowed(r) {
+		jsonError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Index") != "" {
+		jsonError(w, "X-Index is not supported on /ingest/batch", http.StatusBadRequest)
+		return
+	}
+	defer s.observeDuration("/ingest/batch", time.Now())
+
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "ingest_batch", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	if s.backlogExceeded(r) {
+		s.throttle(w)
+		return
+	}
+
+	limit := s.batchMaxBytes
+	if limit <= 0 {
+		limit = defaultBatchMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		s.errors.Add(1)
+		jsonError(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > limit {
+		s.errors.Add(1)
+		jsonError(w, fmt.Sprintf("batch exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	events, err := decodeBatch(body)
+	if err != nil {
+		s.errors.Add(1)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.Int("batch_size", len(events)))
+
+	results := make([]batchResult, len(events))
+	rejected := 0
+	reject := func(i int, reason string) {
+		s.errors.Add(1)
+		results[i].Status, results[i].Reason = "rejected", reason
+		rejected++
+	}
+	var items []batchItem
+	for i, raw := range events {
+		results[i].Index = i
+		evt, err := DecodeEvent(raw, s.defaultHookType)
+		if err != nil {
+			reject(i, err.Error())
+			continue
+		}
+		skipped, err := s.screen(&evt)
+		if err != nil {
+			reject(i, err.Error())
+			continue
+		}
+		if skipped != "" {
+			results[i].Status = skipped
+			continue
+		}
+		doc, err := s.batchDocument(evt, r)
+		if pe, ok := err.(*panicError); ok {
+			s.recordPanic(pe, raw)
+			err = errors.New("internal error")
+		}
+		if errors.Is(err, store.ErrSampledOut) {
+			s.sampled.Add(1)
+			results[i].Status = "sampled"
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, errUnmarshalable) {
+				s.unmarshalable.Add(1)
+			}
+			reject(i, err.Error())
+			continue
+		}
+		if s.storeRawBody {
+			doc.RawBody = store.CompressRawBody(raw)
+		}
+		items = append(items, batchItem{index: i, evt: evt, doc: doc, size: len(raw)})
+	}
+
+	status, _ := s.successStatus()
+	accepted := 0
+	for k, err := range s.indexBatch(ctx, items) {
+		item := items[k]
+		if err != nil {
+			reject(item.index, "indexing failed")
+			continue
+		}
+		s.indexed(item.evt, item.doc, item.size)
+		results[item.index].Status, results[item.index].ID = status, item.doc.ID
+		accepted++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted": accepted,
+		"rejected": rejected,
+		"results":  results,
+	})
+}
+
+// decodeBatch splits an /ingest/batch body into its events, undecoded.
+func decodeBatch(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("empty body")
+	}
+	var events []json.RawMessage
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &events); err != nil {
+			return nil, errors.New("invalid JSON")
+		}
+	} else {
+		var req batchRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, errors.New("invalid JSON: want an array of events or {\"events\": [...]}")
+		}
+		events = req.Events
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no events")
+	}
+	return events, nil
+}
+
+// batchDocument transforms one event of a batch as /ingest does, recovering
+// a panic as a *panicError.
+func (s *Server) batchDocument(evt hookevt.HookEvent, r *http.Request) (doc store.Document, err error) {
+	defer recoverPanic(&err)
+	if doc, err = s.toDocument(evt, r, true); err != nil {
+		return doc, err
+	}
+	return doc, s.checkJSON(doc)
+}
+
+// indexBatch indexes the items' documents in one BatchIndex call and returns
+// an error per item: nil for all of them, or the call's error for every
+// item. A panic is recovered and fails every item.
+func (s *Server) indexBatch(ctx context.Context, items []batchItem) []error {
+	errs := make([]error, len(items))
+	if len(items) == 0 {
+		return errs
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "index")
+	defer span.End()
+
+	docs := make([]store.Document, len(items))
+	for k, item := range items {
+		docs[k] = item.doc
+	}
+	err := func() (err error) {
+		defer recoverPanic(&err)
+		return s.store.BatchIndex(ctx, docs)
+	}()
+	if pe, ok := err.(*panicError); ok {
+		s.recordPanic(pe, nil)
+	}
+	for k := range errs {
+		errs[k] = err
+	}
+	return errs
+}
@@ -0,0 +1,156 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+// batchIndexStore is a mockStore that records each BatchIndex call.
+type batchIndexStore struct {
+	mockStore
+	batches [][]store.Document
+	err     error
+}
+
+func (b *batchIndexStore) BatchIndex(ctx context.Context, docs []store.Document) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.batches = append(b.batches, docs)
+	return nil
+}
+
+type batchResponse struct {
+	Accepted int           `json:"accepted"`
+	Rejected int           `json:"rejected"`
+	Results  []batchResult `json:"results"`
+}
+
+func postBatch(t *testing.T, srv *Server, body string) (int, batchResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(body)))
+	var resp batchResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response %s: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestHandleIngestBatch_PerItemStatus(t *testing.T) {
+	t.Parallel()
+	bs := &batchIndexStore{}
+	srv := New(bs)
+	var seen []IngestEvent
+	srv.SetOnIngest(func(e IngestEvent) { seen = append(seen, e) })
+
+	code, resp := postBatch(t, srv, `[
+		{"hook_type":"PreToolUse","data":{"tool_name":"Bash"}},
+		{"data":{}},
+		"not an event",
+		{"hook_type":"Stop","data":{}}
+	]`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if resp.Accepted != 2 || resp.Rejected != 2 || len(resp.Results) != 4 {
+		t.Fatalf("response = %+v", resp)
+	}
+	for i, want := range []string{"accepted", "rejected", "rejected", "accepted"} {
+		r := resp.Results[i]
+		if r.Index != i || r.Status != want {
+			t.Errorf("result %d = %+v, want status %s", i, r, want)
+		}
+		if want == "accepted" && r.ID == "" {
+			t.Errorf("result %d has no id", i)
+		}
+	}
+	if resp.Results[1].Reason != "missing hook_type" || resp.Results[2].Reason != "invalid JSON" {
+		t.Errorf("reasons = %q, %q", resp.Results[1].Reason, resp.Results[2].Reason)
+	}
+
+	if len(bs.batches) != 1 || len(bs.batches[0]) != 2 || len(bs.docs) != 0 {
+		t.Errorf("batches %d, Index calls %d; want one BatchIndex of 2", len(bs.batches), len(bs.docs))
+	}
+	if srv.ingested.Load() != 2 || srv.errors.Load() != 2 || len(seen) != 2 {
+		t.Errorf("ingested %d, errors %d, callbacks %d; want 2, 2, 2", srv.ingested.Load(), srv.errors.Load(), len(seen))
+	}
+}
+
+func TestHandleIngestBatch_ObjectForm(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetSamplingRates(map[string]float64{"Notification": 0})
+
+	code, resp := postBatch(t, srv, `{"events":[
+		{"hook_type":"Stop","data":{}},
+		{"hook_type":"Notification","data":{}}
+	]}`)
+	if code != http.StatusOK || resp.Accepted != 1 || resp.Rejected != 0 {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	if resp.Results[1].Status != "sampled" {
+		t.Errorf("result 1 = %+v, want sampled", resp.Results[1])
+	}
+	// Sampled-out events are not part of the batch.
+	if len(ms.docs) != 1 {
+		t.Errorf("indexed %d docs, want 1", len(ms.docs))
+	}
+}
+
+func TestHandleIngestBatch_IndexFailure(t *testing.T) {
+	t.Parallel()
+	bs := &batchIndexStore{err: errors.New("down")}
+	srv := New(bs)
+
+	_, resp := postBatch(t, srv, `[{"hook_type":"Stop","data":{}},{"hook_type":"Stop","data":{}}]`)
+	if resp.Accepted != 0 || resp.Rejected != 2 {
+		t.Fatalf("response = %+v", resp)
+	}
+	for _, r := range resp.Results {
+		if r.Status != "rejected" || r.Reason != "indexing failed" || r.ID != "" {
+			t.Errorf("result = %+v", r)
+		}
+	}
+	if srv.ingested.Load() != 0 || srv.errors.Load() != 2 {
+		t.Errorf("ingested %d, errors %d", srv.ingested.Load(), srv.errors.Load())
+	}
+}
+
+func TestHandleIngestBatch_BadRequests(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.SetBatchBodyLimit(64)
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"empty", ``, http.StatusBadRequest},
+		{"no events", `[]`, http.StatusBadRequest},
+		{"object without events", `{"hook_type":"Stop"}`, http.StatusBadRequest},
+		{"invalid", `[{`, http.StatusBadRequest},
+		{"too large", `[` + strings.Repeat(`{"hook_type":"Stop"},`, 10) + `{}]`, http.StatusRequestEntityTooLarge},
+	} {
+		if code, _ := postBatch(t, srv, tc.body); code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, code, tc.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ingest/batch", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", w.Code)
+	}
+}
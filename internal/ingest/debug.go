@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"time"
+
+	"hooks-store/internal/store"
+)
+
+// debugHealthTimeout bounds the backend probe in /admin/debug.
+const debugHealthTimeout = 2 * time.Second
+
+// SetDiagnostics records build and configuration details reported by
+// /admin/debug. config maps flag names to values and must already have
+// secrets redacted.
+func (s *Server) SetDiagnostics(version string, config map[string]string) {
+	s.version = version
+	s.config = config
+}
+
+// handleAdminDebug serves GET /admin/debug — one JSON dump of what a support
+// ticket needs: version, Go runtime, uptime, goroutines, memory, the
+// configured flags (as given to SetDiagnostics), and a backend health probe
+// (store.HealthChecker; "unknown" if the store has none).
+func (s *Server) handleAdminDebug(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	backend := map[string]interface{}{"status": "unknown"}
+	if hc, ok := store.As[store.HealthChecker](s.store); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), debugHealthTimeout)
+		err := hc.Healthy(ctx)
+		cancel()
+		if err != nil {
+			backend = map[string]interface{}{"status": "unhealthy", "error": err.Error()}
+		} else {
+			backend = map[string]interface{}{"status": "healthy"}
+		}
+	}
+
+	config := s.config
+	if config == nil {
+		config = map[string]string{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"version":        s.version,
+		"go_version":     runtime.Version(),
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"num_cpu":        runtime.NumCPU(),
+		"started_at":     s.startedAt.UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"memory": map[string]interface{}{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"heap_objects":      mem.HeapObjects,
+			"num_gc":            mem.NumGC,
+		},
+		"config":  config,
+		"backend": backend,
+	})
+}
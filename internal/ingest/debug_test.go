@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// healthStore is a mockStore that implements store.HealthChecker.
+type healthStore struct {
+	mockStore
+	err error
+}
+
+func (h *healthStore) Healthy(ctx context.Context) error { return h.err }
+
+type debugResponse struct {
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	Uptime    int64             `json:"uptime_seconds"`
+	Routines  int               `json:"goroutines"`
+	Memory    map[string]uint64 `json:"memory"`
+	Config    map[string]string `json:"config"`
+	Backend   struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"backend"`
+}
+
+func adminDebug(t *testing.T, srv *Server, token string) (int, debugResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/admin/debug", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	var resp debugResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestAdminDebug(t *testing.T) {
+	t.Parallel()
+	srv := New(&healthStore{})
+	srv.SetAdminToken("secret")
+	srv.SetDiagnostics("1.2.3", map[string]string{"port": "9800", "meili-key": "[redacted]"})
+
+	code, resp := adminDebug(t, srv, "secret")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if resp.Version != "1.2.3" || resp.GoVersion == "" {
+		t.Errorf("version = %q, go_version = %q", resp.Version, resp.GoVersion)
+	}
+	if resp.Routines <= 0 || resp.Memory["sys_bytes"] == 0 {
+		t.Errorf("goroutines = %d, memory = %v", resp.Routines, resp.Memory)
+	}
+	if resp.Config["port"] != "9800" || resp.Config["meili-key"] != "[redacted]" {
+		t.Errorf("config = %v", resp.Config)
+	}
+	if resp.Backend.Status != "healthy" {
+		t.Errorf("backend = %+v, want healthy", resp.Backend)
+	}
+}
+
+func TestAdminDebug_Backend(t *testing.T) {
+	t.Parallel()
+
+	sick := New(&healthStore{err: errors.New("connection refused")})
+	sick.SetAdminToken("secret")
+	if _, resp := adminDebug(t, sick, "secret"); resp.Backend.Status != "unhealthy" || resp.Backend.Error != "connection refused" {
+		t.Errorf("failing store: backend = %+v", resp.Backend)
+	}
+
+	plain := New(&mockStore{})
+	plain.SetAdminToken("secret")
+	if _, resp := adminDebug(t, plain, "secret"); resp.Backend.Status != "unknown" {
+		t.Errorf("plain store: backend = %+v, want unknown", resp.Backend)
+	}
+}
+
+func TestAdminDebug_Auth(t *testing.T) {
+	t.Parallel()
+	srv := New(&healthStore{})
+	srv.SetAdminToken("secret")
+	if code, _ := adminDebug(t, srv, ""); code != http.StatusUnauthorized {
+		t.Errorf("missing token: status = %d, want 401", code)
+	}
+	if code, _ := adminDebug(t, srv, "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", code)
+	}
+}
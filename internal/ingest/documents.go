@@ -0,0 +1,182 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hooks-store/internal/store"
+)
+
+// patchRequest is the body of PATCH /documents/{id}.
+type patchRequest struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// defaultContextWindow and maxContextWindow bound the before and after
+// parameters of GET /documents/{id}/context.
+const (
+	defaultContextWindow = 5
+	maxContextWindow     = 100
+)
+
+// handleDocument serves /documents/{id}: GET fetches the document, PATCH
+// merges data into it. GET /documents/{id}/context fetches its neighbors.
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/documents/")
+	if base, ok := strings.CutSuffix(id, "/context"); ok && base != "" && !strings.Contains(base, "/") {
+		if r.Method != http.MethodGet {
+			jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.getDocumentContext(w, r, base)
+		return
+	}
+	if id == "" || strings.Contains(id, "/") {
+		jsonError(w, "invalid document id", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		s.getDocument(w, r, id)
+		return
+	}
+	s.patchDocument(w, r, id)
+}
+
+// getDocument serves GET /documents/{id} via store.DocumentGetter. raw_body
+// is left out unless include_raw=true, in which case it holds the exact
+// request body (base64 in JSON), decompressed.
+func (s *Server) getDocument(w http.ResponseWriter, r *http.Request, id string) {
+	dg, ok := store.As[store.DocumentGetter](s.store)
+	if !ok {
+		jsonError(w, "document lookup not supported by store", http.StatusNotImplemented)
+		return
+	}
+	includeRaw := false
+	if v := r.URL.Query().Get("include_raw"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			jsonError(w, "invalid include_raw", http.StatusBadRequest)
+			return
+		}
+		includeRaw = b
+	}
+
+	doc, err := dg.GetDocument(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		jsonError(w, "document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		jsonError(w, "lookup failed", http.StatusServiceUnavailable)
+		return
+	}
+	if !includeRaw || doc.RawBody == nil {
+		doc.RawBody = nil
+	} else if doc.RawBody, err = store.DecompressRawBody(doc.RawBody); err != nil {
+		jsonError(w, "stored raw body is corrupt", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, doc)
+}
+
+// getDocumentContext serves GET /documents/{id}/context?before=&after= via
+// store.ContextReader: the document with up to before and after events of
+// its session around it, oldest first, for reconstructing the timeline
+// around an interesting event. Both default to 5; 0 skips that side.
+func (s *Server) getDocumentContext(w http.ResponseWriter, r *http.Request, id string) {
+	cr, ok := store.As[store.ContextReader](s.store)
+	if !ok {
+		jsonError(w, "document context not supported by store", http.StatusNotImplemented)
+		return
+	}
+	params := r.URL.Query()
+	before, err := parseContextWindow(params.Get("before"))
+	if err != nil {
+		jsonError(w, "before "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	after, err := parseContextWindow(params.Get("after"))
+	if err != nil {
+		jsonError(w, "after "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ec, err := cr.EventContext(r.Context(), id, before, after)
+	if errors.Is(err, store.ErrNotFound) {
+		jsonError(w, "document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		jsonError(w, "query failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, ec)
+}
+
+// parseContextWindow parses a before or after parameter, defaulting to
+// defaultContextWindow and rejecting values outside 0..maxContextWindow.
+func parseContextWindow(v string) (int, error) {
+	if v == "" {
+		return defaultContextWindow, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > maxContextWindow {
+		return 0, fmt.Errorf("must be between 0 and %d", maxContextWindow)
+	}
+	return n, nil
+}
+
+// patchDocument serves PATCH /documents/{id} — merges additional data fields
+// into an existing document (the second phase of a two-phase event) via
+// store.Updater. Derived fields are recomputed from the merged data.
+func (s *Server) patchDocument(w http.ResponseWriter, r *http.Request, id string) {
+	up, ok := store.As[store.Updater](s.store)
+	if !ok {
+		jsonError(w, "updates not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyLen+1))
+	if err != nil {
+		jsonError(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBodyLen {
+		jsonError(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := checkJSONDepth(body, maxJSONDepth); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req patchRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Data) == 0 {
+		jsonError(w, "data is required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := up.Update(r.Context(), id, req.Data)
+	if errors.Is(err, store.ErrNotFound) {
+		jsonError(w, "document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.errors.Add(1)
+		jsonError(w, "update failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "updated", "id": doc.ID})
+}
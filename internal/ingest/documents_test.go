@@ -0,0 +1,238 @@
+package ingest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+// updateStore is a mockStore that implements store.Updater over a fixed set
+// of existing document IDs.
+type updateStore struct {
+	mockStore
+	existing map[string]bool
+	lastID   string
+	lastData map[string]interface{}
+}
+
+func (u *updateStore) Update(ctx context.Context, id string, data map[string]interface{}) (store.Document, error) {
+	if !u.existing[id] {
+		return store.Document{}, fmt.Errorf("get document %s: %w", id, store.ErrNotFound)
+	}
+	u.lastID, u.lastData = id, data
+	return store.Document{ID: id}, nil
+}
+
+// getStore is a mockStore that implements store.DocumentGetter over the
+// documents it has indexed.
+type getStore struct {
+	mockStore
+}
+
+func (g *getStore) GetDocument(ctx context.Context, id string) (store.Document, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, doc := range g.docs {
+		if doc.ID == id {
+			return doc, nil
+		}
+	}
+	return store.Document{}, fmt.Errorf("get document %s: %w", id, store.ErrNotFound)
+}
+
+// contextStore is a mockStore that implements store.ContextReader, recording
+// the requested window.
+type contextStore struct {
+	mockStore
+	before, after int
+}
+
+func (c *contextStore) EventContext(ctx context.Context, id string, before, after int) (store.EventContext, error) {
+	if id != "doc-1" {
+		return store.EventContext{}, fmt.Errorf("get document %s: %w", id, store.ErrNotFound)
+	}
+	c.before, c.after = before, after
+	return store.EventContext{
+		Before: []store.Document{{ID: "doc-0"}},
+		Event:  store.Document{ID: id},
+		After:  []store.Document{},
+	}, nil
+}
+
+func getDocument(t *testing.T, srv *Server, path string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var doc map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&doc)
+	return w.Code, doc
+}
+
+func patch(srv *Server, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleDocument_Patch(t *testing.T) {
+	t.Parallel()
+	us := &updateStore{existing: map[string]bool{"doc-1": true}}
+	srv := New(us)
+
+	w := patch(srv, "/documents/doc-1", `{"data":{"error":"exit status 1"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if us.lastID != "doc-1" || us.lastData["error"] != "exit status 1" {
+		t.Errorf("Update(%q, %v)", us.lastID, us.lastData)
+	}
+}
+
+func TestHandleDocument_NotFound(t *testing.T) {
+	t.Parallel()
+	srv := New(&updateStore{})
+
+	if w := patch(srv, "/documents/nope", `{"data":{"a":"b"}}`); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleDocument_BadRequests(t *testing.T) {
+	t.Parallel()
+	srv := New(&updateStore{existing: map[string]bool{"doc-1": true}})
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/documents/", `{"data":{"a":"b"}}`, http.StatusBadRequest},
+		{"/documents/a/b", `{"data":{"a":"b"}}`, http.StatusBadRequest},
+		{"/documents/doc-1", `{}`, http.StatusBadRequest},
+		{"/documents/doc-1", `not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := patch(srv, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("PATCH %s %s: status = %d, want %d", tt.path, tt.body, w.Code, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/documents/doc-1", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status = %d, want 405", w.Code)
+	}
+}
+
+func TestHandleDocument_GetRawBody(t *testing.T) {
+	t.Parallel()
+	gs := &getStore{}
+	srv := New(gs)
+	srv.SetStoreRawBody(true)
+
+	// Key order and spacing the parsed map would lose.
+	body := `{"hook_type":"Stop",  "timestamp":"2026-02-25T14:30:00Z", "data":{"z":1,"a":2}}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("ingest status = %d, want 202", w.Code)
+	}
+	id := gs.docs[0].ID
+	if got, _ := store.DecompressRawBody(gs.docs[0].RawBody); string(got) != body {
+		t.Errorf("stored raw body = %q, want the request body", got)
+	}
+
+	code, doc := getDocument(t, srv, "/documents/"+id)
+	if code != http.StatusOK || doc["id"] != id || doc["hook_type"] != "Stop" {
+		t.Fatalf("GET: status %d, doc %v", code, doc)
+	}
+	if _, ok := doc["raw_body"]; ok {
+		t.Error("raw_body returned without include_raw")
+	}
+
+	code, doc = getDocument(t, srv, "/documents/"+id+"?include_raw=true")
+	raw, _ := doc["raw_body"].(string)
+	if got, _ := base64.StdEncoding.DecodeString(raw); code != http.StatusOK || string(got) != body {
+		t.Errorf("include_raw: status %d, raw_body = %q, want the exact request body", code, got)
+	}
+
+	if code, _ := getDocument(t, srv, "/documents/"+id+"?include_raw=maybe"); code != http.StatusBadRequest {
+		t.Errorf("invalid include_raw: status = %d, want 400", code)
+	}
+	if code, _ := getDocument(t, srv, "/documents/nope"); code != http.StatusNotFound {
+		t.Errorf("missing: status = %d, want 404", code)
+	}
+}
+
+func TestHandleDocument_RawBodyOff(t *testing.T) {
+	t.Parallel()
+	gs := &getStore{}
+	srv := New(gs)
+
+	body := `{"hook_type":"Stop","data":{}}`
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+	if gs.docs[0].RawBody != nil {
+		t.Error("raw body stored without SetStoreRawBody")
+	}
+	if _, doc := getDocument(t, srv, "/documents/"+gs.docs[0].ID+"?include_raw=true"); doc["raw_body"] != nil {
+		t.Errorf("raw_body = %v, want absent", doc["raw_body"])
+	}
+}
+
+func TestHandleDocument_NotSupported(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+
+	if w := patch(srv, "/documents/doc-1", `{"data":{"a":"b"}}`); w.Code != http.StatusNotImplemented {
+		t.Errorf("PATCH: status = %d, want 501", w.Code)
+	}
+	if code, _ := getDocument(t, srv, "/documents/doc-1"); code != http.StatusNotImplemented {
+		t.Errorf("GET: status = %d, want 501", code)
+	}
+}
+
+func TestHandleDocument_Context(t *testing.T) {
+	t.Parallel()
+	cs := &contextStore{}
+	srv := New(cs)
+
+	code, resp := getDocument(t, srv, "/documents/doc-1/context")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if cs.before != 5 || cs.after != 5 {
+		t.Errorf("window = %d/%d, want the 5/5 default", cs.before, cs.after)
+	}
+	if before, _ := resp["before"].([]interface{}); len(before) != 1 || resp["event"].(map[string]interface{})["id"] != "doc-1" {
+		t.Errorf("response = %v", resp)
+	}
+	if after, ok := resp["after"].([]interface{}); !ok || len(after) != 0 {
+		t.Errorf("after = %v, want an empty list", resp["after"])
+	}
+
+	if code, _ := getDocument(t, srv, "/documents/doc-1/context?before=0&after=100"); code != http.StatusOK || cs.before != 0 || cs.after != 100 {
+		t.Errorf("explicit window: status %d, window %d/%d", code, cs.before, cs.after)
+	}
+	for _, path := range []string{"/documents/doc-1/context?before=-1", "/documents/doc-1/context?after=101", "/documents/doc-1/context?after=x", "/documents/a/b/context"} {
+		if code, _ := getDocument(t, srv, path); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, code)
+		}
+	}
+	if code, _ := getDocument(t, srv, "/documents/nope/context"); code != http.StatusNotFound {
+		t.Errorf("missing: status = %d, want 404", code)
+	}
+	if w := patch(srv, "/documents/doc-1/context", `{"data":{"a":"b"}}`); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PATCH: status = %d, want 405", w.Code)
+	}
+	if code, _ := getDocument(t, New(&mockStore{}), "/documents/doc-1/context"); code != http.StatusNotImplemented {
+		t.Errorf("unsupported store: status = %d, want 501", code)
+	}
+}
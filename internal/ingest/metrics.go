@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"time"
+
+	"hooks-store/internal/metrics"
+)
+
+// Metric names the ingest path reports to its metrics.Sink, with the label
+// each carries.
+const (
+	metricIngested  = "hooks_store_ingested_total"          // counter by hook_type
+	metricDuration  = "hooks_store_ingest_duration_seconds" // histogram by route
+	metricClockSkew = "hooks_store_clock_skew_seconds"      // histogram by direction
+	metricMaxSkew   = "hooks_store_max_clock_skew_seconds"  // gauge, unlabeled
+)
+
+// clockSkewBuckets are the skew histogram's upper bounds in seconds, from
+// network jitter up to a misconfigured time zone.
+var clockSkewBuckets = []float64{.1, .5, 1, 5, 30, 60, 300, 900, 3600, 86400}
+
+// newPrometheusSink declares the ingest metrics in reg, served at /metrics.
+func newPrometheusSink(reg *metrics.Registry) *metrics.PrometheusSink {
+	p := metrics.NewPrometheusSink(reg)
+	p.DeclareHistogram(metricClockSkew,
+		"Distance between event timestamps and server receive time, by direction (ahead = sender clock in the future).",
+		"direction", clockSkewBuckets)
+	p.DeclareGauge(metricMaxSkew,
+		"Signed clock skew of the largest magnitude seen (positive = sender ahead).", "")
+	p.DeclareCounter(metricIngested, "Events indexed, by hook type.", "hook_type")
+	p.DeclareHistogram(metricDuration, "Time to handle an ingest request, whatever its outcome, by route.", "route", nil)
+	return p
+}
+
+// SetMetricsSink sends the ingest path's measurements — events indexed by
+// hook type, request latency, clock skew — to sink instead of /metrics, for
+// backends such as StatsD. /metrics keeps serving the store's metrics, and
+// the ingest metrics with no samples. Nil discards the measurements
+// (metrics.Nop). Call before serving.
+func (s *Server) SetMetricsSink(sink metrics.Sink) {
+	if sink == nil {
+		sink = metrics.Nop
+	}
+	s.sink = sink
+}
+
+// observeDuration records the latency of a request to route that started at
+// start. Call it deferred.
+func (s *Server) observeDuration(route string, start time.Time) {
+	s.sink.ObserveHistogram(metricDuration, route, time.Since(start).Seconds())
+}
@@ -0,0 +1,114 @@
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hooks-store/internal/metrics"
+)
+
+// recordingSink is a metrics.Sink that records every measurement as
+// "kind name{label}".
+type recordingSink struct {
+	mu     sync.Mutex
+	events []string
+	values map[string]float64
+}
+
+func (r *recordingSink) record(kind, name, label string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := kind + " " + name + "{" + label + "}"
+	r.events = append(r.events, key)
+	if r.values == nil {
+		r.values = map[string]float64{}
+	}
+	r.values[key] = v
+}
+
+func (r *recordingSink) IncCounter(name, label string) { r.record("counter", name, label, 1) }
+func (r *recordingSink) ObserveHistogram(name, label string, v float64) {
+	r.record("histogram", name, label, v)
+}
+func (r *recordingSink) SetGauge(name, label string, v float64) { r.record("gauge", name, label, v) }
+
+func (r *recordingSink) count(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e == key {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSetMetricsSink(t *testing.T) {
+	t.Parallel()
+	sink := &recordingSink{}
+	srv := New(&mockStore{})
+	srv.SetMetricsSink(sink)
+
+	ahead := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	for _, body := range []string{
+		`{"hook_type":"Stop","timestamp":"` + ahead + `","data":{}}`,
+		`{"hook_type":"Stop","data":{}}`,
+		`{"data":{}}`, // rejected: no hook_type
+	} {
+		srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+	}
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest/batch",
+		strings.NewReader(`[{"hook_type":"PreToolUse","data":{}}]`)))
+
+	for key, want := range map[string]int{
+		"counter hooks_store_ingested_total{Stop}":                     2,
+		"counter hooks_store_ingested_total{PreToolUse}":               1,
+		"histogram hooks_store_ingest_duration_seconds{/ingest}":       3,
+		"histogram hooks_store_ingest_duration_seconds{/ingest/batch}": 1,
+		"histogram hooks_store_clock_skew_seconds{ahead}":              1,
+		"gauge hooks_store_max_clock_skew_seconds{}":                   1,
+	} {
+		if got := sink.count(key); got != want {
+			t.Errorf("%s recorded %d times, want %d", key, got, want)
+		}
+	}
+	if v := sink.values["gauge hooks_store_max_clock_skew_seconds{}"]; v < 50 || v > 70 {
+		t.Errorf("max skew gauge = %v, want about 60", v)
+	}
+
+	// The measurements went to the sink, not /metrics.
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(w.Body.String(), "hooks_store_ingested_total{") {
+		t.Errorf("/metrics has ingest samples with a custom sink:\n%s", w.Body.String())
+	}
+}
+
+func TestPrometheusSink_Default(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest",
+		strings.NewReader(`{"hook_type":"Stop","data":{}}`)))
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`hooks_store_ingested_total{hook_type="Stop"} 1`,
+		`hooks_store_ingest_duration_seconds_count{route="/ingest"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("/metrics missing %q:\n%s", want, w.Body.String())
+		}
+	}
+
+	// Nil discards.
+	srv.SetMetricsSink(nil)
+	if srv.sink != metrics.Nop {
+		t.Errorf("sink = %T, want metrics.Nop", srv.sink)
+	}
+}
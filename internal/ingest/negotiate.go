@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hooks-store/internal/store"
+)
+
+// responseFormat is a representation a read endpoint can produce.
+type responseFormat int
+
+const (
+	formatJSON   responseFormat = iota
+	formatNDJSON                // one document per line, for streaming
+	formatCSV                   // flat columns, for spreadsheets
+)
+
+// mediaTypes maps recognized Accept media types to formats.
+var mediaTypes = map[string]responseFormat{
+	"application/json":     formatJSON,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+	"text/csv":             formatCSV,
+}
+
+// negotiateFormat picks the response format from the Accept header: the
+// recognized media type with the highest q-value, earliest first on ties.
+// JSON is the default when nothing recognized is acceptable.
+func negotiateFormat(r *http.Request) responseFormat {
+	type candidate struct {
+		f responseFormat
+		q float64
+	}
+	var cands []candidate
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(part, ";")
+		f, ok := mediaTypes[strings.ToLower(strings.TrimSpace(params[0]))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			if v, found := strings.CutPrefix(strings.TrimSpace(p), "q="); found {
+				if n, err := strconv.ParseFloat(v, 64); err == nil {
+					q = n
+				}
+			}
+		}
+		if q > 0 {
+			cands = append(cands, candidate{f, q})
+		}
+	}
+	if len(cands) == 0 {
+		return formatJSON
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].q > cands[j].q })
+	return cands[0].f
+}
+
+// writeDocuments writes docs in the format negotiated from r's Accept header.
+// JSON responses write envelope (which should contain docs) unchanged; NDJSON
+// and CSV write only the documents, so any other envelope field a client
+// needs (e.g. a pagination cursor) must be sent as a header by the caller.
+// With fields, NDJSON lines carry only those keys (see projectDocuments; the
+// caller projects the JSON envelope itself); CSV columns are fixed.
+func writeDocuments(w http.ResponseWriter, r *http.Request, envelope interface{}, docs []store.Document, fields []string) {
+	w.Header().Add("Vary", "Accept")
+	switch negotiateFormat(r) {
+	case formatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		if len(fields) > 0 {
+			for _, d := range projectDocuments(docs, fields) {
+				enc.Encode(d)
+			}
+			return
+		}
+		for _, d := range docs {
+			enc.Encode(d)
+		}
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		cw.Write(csvColumns)
+		for _, d := range docs {
+			cw.Write(csvRow(d))
+		}
+		cw.Flush()
+	default:
+		writeJSON(w, envelope)
+	}
+}
+
+// projectDocuments reduces each document to its id, timestamp_unix, and
+// fields keys, so a field selection doesn't come back padded with the zero
+// values of every non-omitempty Document field.
+func projectDocuments(docs []store.Document, fields []string) []map[string]interface{} {
+	keep := map[string]bool{"id": true, "timestamp_unix": true}
+	for _, f := range fields {
+		keep[f] = true
+	}
+	out := make([]map[string]interface{}, 0, len(docs))
+	for _, d := range docs {
+		raw, _ := json.Marshal(d)
+		var m map[string]interface{}
+		json.Unmarshal(raw, &m)
+		for k := range m {
+			if !keep[k] {
+				delete(m, k)
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// csvColumns are the Document fields exported as CSV, in column order.
+// data and data_flat are omitted: they don't flatten into cells usefully.
+var csvColumns = []string{
+	"id", "timestamp", "timestamp_unix", "hook_type", "session_id", "tool_name",
+	"project_dir", "cwd", "permission_mode", "file_path", "error_message", "prompt",
+	"input_tokens", "output_tokens", "cache_read_tokens", "cache_create_tokens",
+	"total_tokens", "cost_usd", "teammate_id", "teammate_name", "has_claude_md",
+	"is_subagent", "parent_session_id",
+}
+
+// csvRow returns d's values in csvColumns order.
+func csvRow(d store.Document) []string {
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+	return []string{
+		d.ID, d.Timestamp, itoa(d.TimestampUnix), d.HookType, d.SessionID, d.ToolName,
+		d.ProjectDir, d.Cwd, d.PermissionMode, d.FilePath, d.ErrorMessage, d.Prompt,
+		itoa(d.InputTokens), itoa(d.OutputTokens), itoa(d.CacheReadTokens), itoa(d.CacheCreateTokens),
+		itoa(d.TotalTokens), strconv.FormatFloat(d.CostUSD, 'f', -1, 64), d.TeammateID, d.TeammateName,
+		strconv.FormatBool(d.HasClaudeMD), strconv.FormatBool(d.IsSubagent), d.ParentSessionID,
+	}
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		accept string
+		want   responseFormat
+	}{
+		{"", formatJSON},
+		{"*/*", formatJSON},
+		{"text/html", formatJSON},
+		{"application/x-ndjson", formatNDJSON},
+		{"application/ndjson", formatNDJSON},
+		{"text/csv", formatCSV},
+		{"TEXT/CSV; charset=utf-8", formatCSV},
+		{"application/json, text/csv", formatJSON},
+		{"application/json;q=0.5, text/csv", formatCSV},
+		{"text/csv;q=0, application/x-ndjson;q=0.1", formatNDJSON},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		if got := negotiateFormat(req); got != tt.want {
+			t.Errorf("Accept %q = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestHandleSearch_Formats(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{search: store.SearchResult{
+		Hits:       []store.Document{{ID: "a", HookType: "Stop", Prompt: "x, \"y\""}, {ID: "b", CostUSD: 0.25}},
+		NextCursor: "next",
+	}}
+	srv := New(qs)
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=x", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Header().Get("X-Next-Cursor") != "next" {
+			t.Errorf("%s: X-Next-Cursor = %q", accept, w.Header().Get("X-Next-Cursor"))
+		}
+		return w
+	}
+
+	w := get("application/x-ndjson")
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("ndjson Content-Type = %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var doc store.Document
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &doc) != nil || doc.ID != "b" {
+		t.Errorf("ndjson body = %q", w.Body.String())
+	}
+
+	w = get("text/csv")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("csv Content-Type = %q", ct)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "id" || rows[1][0] != "a" || rows[1][11] != "x, \"y\"" {
+		t.Errorf("csv rows = %q", rows)
+	}
+
+	w = get("application/json")
+	var res store.SearchResult
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil || len(res.Hits) != 2 || res.NextCursor != "next" {
+		t.Errorf("json result = %+v (%v)", res, err)
+	}
+}
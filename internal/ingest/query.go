@@ -0,0 +1,277 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"hooks-store/internal/store"
+)
+
+const (
+	defaultQueryLimit = 20
+	maxQueryLimit     = 1000
+)
+
+// handleCosts serves GET /costs?min_cost=&from=&to=&limit= — the most
+// expensive events above a cost threshold, with their summed cost.
+func (s *Server) handleCosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cr, ok := store.As[store.CostReporter](s.store)
+	if !ok {
+		jsonError(w, "cost queries not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	params := r.URL.Query()
+	q := store.CostQuery{}
+	if v := params.Get("min_cost"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			jsonError(w, "invalid min_cost", http.StatusBadRequest)
+			return
+		}
+		q.MinCost = f
+	}
+	var err error
+	if q.From, err = parseTimeParam(params.Get("from")); err != nil {
+		jsonError(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.To, err = parseTimeParam(params.Get("to")); err != nil {
+		jsonError(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.Limit, err = parseLimit(params.Get("limit")); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := cr.TopCosts(r.Context(), q)
+	if err != nil {
+		jsonError(w, "query failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, report)
+}
+
+// handleDistinct serves GET /distinct?field=[&limit=] — distinct values with
+// counts for any filterable attribute of the main index, the limit most
+// frequent when limit is set (up to the store's facet maximum).
+func (s *Server) handleDistinct(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dv, ok := store.As[store.DistinctValuer](s.store)
+	if !ok {
+		jsonError(w, "distinct queries not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	field := r.URL.Query().Get("field")
+	if !store.IsFilterable(field) {
+		jsonError(w, fmt.Sprintf("field %q is not filterable", field), http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			jsonError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	values, err := dv.DistinctValues(r.Context(), field, limit)
+	if errors.Is(err, store.ErrFacetLimit) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, "query failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"field":  field,
+		"values": values,
+	})
+}
+
+// handleOverview serves GET /overview — event count, time span, and the
+// hook_type, tool_name, project_dir, and permission_mode distributions, in
+// one store query, for a dashboard landing page.
+func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	or, ok := store.As[store.OverviewReporter](s.store)
+	if !ok {
+		jsonError(w, "overview not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	ov, err := or.Overview(r.Context())
+	if err != nil {
+		jsonError(w, "query failed", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, ov)
+}
+
+// handleSearch serves GET /search?q=&hook_type=&session_id=&filter=&limit=
+// &cursor=&offset=&fields= — full-text search, newest first. hook_type and
+// session_id match exactly and are ANDed with filter. fields
+// (comma-separated) trims each hit to those keys plus id and
+// timestamp_unix; data_flat is never returned. Pass the returned
+// next_cursor (or X-Next-Cursor header) back as cursor to get the
+// following page; it is omitted on the last page. With q, hits are ranked
+// by relevance, so no cursor is returned and one is rejected; such pages
+// are reached by offset instead, with next_offset (or X-Next-Offset)
+// giving the following one. offset without q is rejected in favor of the
+// cursor. Accept selects JSON, NDJSON, or CSV (see writeDocuments).
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sr, ok := store.As[store.Searcher](s.store)
+	if !ok {
+		jsonError(w, "search not supported by store", http.StatusNotImplemented)
+		return
+	}
+
+	params := r.URL.Query()
+	if params.Get("q") == "" && params.Has("offset") {
+		jsonError(w, "offset needs q; without q pass next_cursor back as cursor", http.StatusBadRequest)
+		return
+	}
+	if params.Get("q") != "" && params.Has("cursor") {
+		// Hits for q are ranked by relevance, not time, so a timestamp
+		// cursor would skip some of them.
+		jsonError(w, "cursor cannot be combined with q", http.StatusBadRequest)
+		return
+	}
+	p := store.SearchParams{
+		Query:     params.Get("q"),
+		Filter:    params.Get("filter"),
+		Cursor:    params.Get("cursor"),
+		HookType:  params.Get("hook_type"),
+		SessionID: params.Get("session_id"),
+	}
+	displayed := store.DisplayedAttributes()
+	for _, f := range strings.Split(params.Get("fields"), ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if !slices.Contains(displayed, f) {
+			jsonError(w, fmt.Sprintf("unknown field %q", f), http.StatusBadRequest)
+			return
+		}
+		p.Fields = append(p.Fields, f)
+	}
+	if p.Filter != "" {
+		if err := store.ValidateFilter(p.Filter); err != nil {
+			jsonError(w, "invalid filter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// limit and offset are bounded by the store's MaxTotalHits
+	// (store.ErrSearchWindow), not maxQueryLimit.
+	var err error
+	p.Limit = defaultQueryLimit
+	if v := params.Get("limit"); v != "" {
+		if p.Limit, err = strconv.Atoi(v); err != nil || p.Limit < 1 {
+			jsonError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("offset"); v != "" {
+		if p.Offset, err = strconv.Atoi(v); err != nil || p.Offset < 0 {
+			jsonError(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := sr.Search(r.Context(), p)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		jsonError(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrUnknownField) || errors.Is(err, store.ErrInvalidOffset) || errors.Is(err, store.ErrSearchWindow) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, "query failed", http.StatusServiceUnavailable)
+		return
+	}
+	if result.Hits == nil {
+		result.Hits = []store.Document{}
+	}
+	// Also sent as headers so NDJSON and CSV clients can paginate.
+	if result.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", result.NextCursor)
+	}
+	if result.NextOffset > 0 {
+		w.Header().Set("X-Next-Offset", strconv.Itoa(result.NextOffset))
+	}
+	var envelope interface{} = result
+	if len(p.Fields) > 0 {
+		projected := map[string]interface{}{"hits": projectDocuments(result.Hits, p.Fields)}
+		if result.NextCursor != "" {
+			projected["next_cursor"] = result.NextCursor
+		}
+		if result.NextOffset > 0 {
+			projected["next_offset"] = result.NextOffset
+		}
+		envelope = projected
+	}
+	writeDocuments(w, r, envelope, result.Hits, p.Fields)
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or unix seconds and returns
+// unix seconds. An empty value returns 0 (unbounded).
+func parseTimeParam(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("want RFC 3339 or unix seconds")
+	}
+	return t.Unix(), nil
+}
+
+// parseLimit parses a limit query parameter, defaulting to defaultQueryLimit
+// and rejecting values outside 1..maxQueryLimit.
+func parseLimit(v string) (int, error) {
+	if v == "" {
+		return defaultQueryLimit, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxQueryLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+	}
+	return n, nil
+}
+
+// writeJSON writes v as a 200 JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,371 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+// queryStore is a mockStore that also implements the optional query
+// interfaces, recording the last query it received.
+type queryStore struct {
+	mockStore
+	lastCost store.CostQuery
+	costs    []store.Document
+
+	lastField string
+	lastLimit int
+	distinct  []store.DistinctValue
+
+	lastSearch store.SearchParams
+	search     store.SearchResult
+
+	overview store.Overview
+}
+
+func (q *queryStore) TopCosts(ctx context.Context, cq store.CostQuery) (store.CostReport, error) {
+	q.lastCost = cq
+	report := store.CostReport{Documents: q.costs}
+	for _, d := range q.costs {
+		report.TotalUSD += d.CostUSD
+	}
+	return report, nil
+}
+
+func (q *queryStore) DistinctValues(ctx context.Context, field string, limit int) ([]store.DistinctValue, error) {
+	q.lastField, q.lastLimit = field, limit
+	if limit > 500 {
+		return nil, fmt.Errorf("%w: %d > 500", store.ErrFacetLimit, limit)
+	}
+	return q.distinct, nil
+}
+
+func (q *queryStore) Overview(ctx context.Context) (store.Overview, error) {
+	return q.overview, nil
+}
+
+func (q *queryStore) Search(ctx context.Context, p store.SearchParams) (store.SearchResult, error) {
+	q.lastSearch = p
+	if p.Cursor == "bad" {
+		return store.SearchResult{}, fmt.Errorf("decode: %w", store.ErrInvalidCursor)
+	}
+	if p.Limit > store.DefaultMaxTotalHits || p.Offset >= store.DefaultMaxTotalHits {
+		return store.SearchResult{}, fmt.Errorf("limit %d offset %d: %w", p.Limit, p.Offset, store.ErrSearchWindow)
+	}
+	return q.search, nil
+}
+
+func TestHandleCosts(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{costs: []store.Document{
+		{ID: "a", CostUSD: 0.5},
+		{ID: "b", CostUSD: 0.25},
+	}}
+	srv := New(qs)
+
+	req := httptest.NewRequest(http.MethodGet, "/costs?min_cost=0.001&from=2026-02-25T00:00:00Z&limit=5", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if qs.lastCost.MinCost != 0.001 {
+		t.Errorf("MinCost = %v, want 0.001", qs.lastCost.MinCost)
+	}
+	if qs.lastCost.From != 1771977600 {
+		t.Errorf("From = %d, want 1771977600", qs.lastCost.From)
+	}
+	if qs.lastCost.Limit != 5 {
+		t.Errorf("Limit = %d, want 5", qs.lastCost.Limit)
+	}
+
+	var resp store.CostReport
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Documents) != 2 {
+		t.Errorf("documents = %d, want 2", len(resp.Documents))
+	}
+	if resp.TotalUSD != 0.75 {
+		t.Errorf("total_usd = %v, want 0.75", resp.TotalUSD)
+	}
+}
+
+func TestHandleCosts_InvalidParams(t *testing.T) {
+	t.Parallel()
+	srv := New(&queryStore{})
+
+	for _, q := range []string{"min_cost=abc", "min_cost=-1", "from=yesterday", "limit=0", "limit=100000"} {
+		req := httptest.NewRequest(http.MethodGet, "/costs?"+q, nil)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestHandleCosts_NotSupported(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+
+	req := httptest.NewRequest(http.MethodGet, "/costs", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", w.Code)
+	}
+}
+
+func TestHandleDistinct(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{distinct: []store.DistinctValue{
+		{Value: "/home/u/a", Count: 7},
+		{Value: "/home/u/b", Count: 2},
+	}}
+	srv := New(qs)
+
+	req := httptest.NewRequest(http.MethodGet, "/distinct?field=project_dir", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if qs.lastField != "project_dir" {
+		t.Errorf("field = %q, want project_dir", qs.lastField)
+	}
+	var resp struct {
+		Field  string                `json:"field"`
+		Values []store.DistinctValue `json:"values"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Field != "project_dir" || len(resp.Values) != 2 || resp.Values[0].Count != 7 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleDistinct_Limit(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{}
+	srv := New(qs)
+
+	tests := []struct {
+		query     string
+		want      int
+		wantLimit int
+	}{
+		{"field=file_path", http.StatusOK, 0},
+		{"field=file_path&limit=20", http.StatusOK, 20},
+		{"field=file_path&limit=500", http.StatusOK, 500},
+		{"field=file_path&limit=501", http.StatusBadRequest, 501}, // above the store maximum
+		{"field=file_path&limit=0", http.StatusBadRequest, -1},
+		{"field=file_path&limit=-3", http.StatusBadRequest, -1},
+		{"field=file_path&limit=lots", http.StatusBadRequest, -1},
+	}
+	for _, tt := range tests {
+		qs.lastLimit = -1
+		req := httptest.NewRequest(http.MethodGet, "/distinct?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.query, w.Code, tt.want)
+		}
+		if qs.lastLimit != tt.wantLimit {
+			t.Errorf("%s: store limit = %d, want %d", tt.query, qs.lastLimit, tt.wantLimit)
+		}
+	}
+}
+
+func TestHandleDistinct_NotFilterable(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{}
+	srv := New(qs)
+
+	for _, q := range []string{"", "field=data_flat", "field=prompt"} {
+		req := httptest.NewRequest(http.MethodGet, "/distinct?"+q, nil)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", q, w.Code)
+		}
+	}
+	if qs.lastField != "" {
+		t.Errorf("store should not be queried for invalid fields, got %q", qs.lastField)
+	}
+}
+
+func TestHandleOverview(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{overview: store.Overview{
+		Total: 9,
+		From:  1772000000,
+		To:    1772003600,
+		Facets: map[string][]store.DistinctValue{
+			"hook_type": {{Value: "PreToolUse", Count: 6}, {Value: "Stop", Count: 3}},
+		},
+	}}
+	srv := New(qs)
+
+	req := httptest.NewRequest(http.MethodGet, "/overview", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp store.Overview
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(resp, qs.overview) {
+		t.Errorf("response = %+v, want %+v", resp, qs.overview)
+	}
+
+	// Stores without OverviewReporter get 501.
+	w = httptest.NewRecorder()
+	New(&mockStore{}).Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/overview", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("plain store: status = %d, want 501", w.Code)
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{search: store.SearchResult{
+		Hits:       []store.Document{{ID: "a"}, {ID: "b"}},
+		NextCursor: "next",
+	}}
+	srv := New(qs)
+
+	req := httptest.NewRequest(http.MethodGet, "/search?filter=hook_type+%3D+Stop&limit=2&cursor=abc", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	want := store.SearchParams{Filter: "hook_type = Stop", Limit: 2, Cursor: "abc"}
+	if !reflect.DeepEqual(qs.lastSearch, want) {
+		t.Errorf("params = %+v, want %+v", qs.lastSearch, want)
+	}
+	var got store.SearchResult
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Hits) != 2 || got.NextCursor != "next" {
+		t.Errorf("result = %+v", got)
+	}
+}
+
+func TestHandleSearch_QueryOffset(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{search: store.SearchResult{
+		Hits:       []store.Document{{ID: "a"}, {ID: "b"}},
+		NextOffset: 6,
+	}}
+	srv := New(qs)
+
+	req := httptest.NewRequest(http.MethodGet, "/search?q=timeout&filter=tool_name+%3D+Bash&hook_type=Stop&session_id=s1&limit=2&offset=4", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	want := store.SearchParams{Query: "timeout", Filter: "tool_name = Bash", Limit: 2, Offset: 4, HookType: "Stop", SessionID: "s1"}
+	if !reflect.DeepEqual(qs.lastSearch, want) {
+		t.Errorf("params = %+v, want %+v", qs.lastSearch, want)
+	}
+	if h := w.Header().Get("X-Next-Offset"); h != "6" {
+		t.Errorf("X-Next-Offset = %q, want 6", h)
+	}
+	var got store.SearchResult
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Hits) != 2 || got.NextOffset != 6 || got.NextCursor != "" {
+		t.Errorf("result = %+v", got)
+	}
+}
+
+func TestHandleSearch_Fields(t *testing.T) {
+	t.Parallel()
+	qs := &queryStore{search: store.SearchResult{
+		Hits: []store.Document{{ID: "a", HookType: "Stop", TimestampUnix: 100, SessionID: "s1"}},
+	}}
+	srv := New(qs)
+
+	req := httptest.NewRequest(http.MethodGet, "/search?fields=hook_type,+data", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := strings.Join(qs.lastSearch.Fields, ","); got != "hook_type,data" {
+		t.Errorf("Fields = %s, want hook_type,data", got)
+	}
+	var got struct {
+		Hits []map[string]interface{} `json:"hits"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Hits) != 1 {
+		t.Fatalf("hits = %v", got.Hits)
+	}
+	// Only the requested keys plus id and timestamp_unix; data is nil here
+	// so it is present as null.
+	for k := range got.Hits[0] {
+		switch k {
+		case "id", "timestamp_unix", "hook_type", "data":
+		default:
+			t.Errorf("unexpected key %q in projected hit", k)
+		}
+	}
+	if got.Hits[0]["hook_type"] != "Stop" {
+		t.Errorf("hook_type = %v, want Stop", got.Hits[0]["hook_type"])
+	}
+
+	// NDJSON lines are projected the same way.
+	req = httptest.NewRequest(http.MethodGet, "/search?fields=hook_type", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if line := strings.TrimSpace(w.Body.String()); line != `{"hook_type":"Stop","id":"a","timestamp_unix":100}` {
+		t.Errorf("NDJSON line = %s", line)
+	}
+}
+
+func TestHandleSearch_InvalidParams(t *testing.T) {
+	t.Parallel()
+	srv := New(&queryStore{})
+
+	for _, q := range []string{"limit=0", "limit=x", "limit=10001", "q=timeout&offset=10000", "offset=20", "q=timeout&offset=-1", "q=timeout&offset=x", "filter=secret+%3D+1", "cursor=bad", "q=timeout&cursor=abc", "fields=data_flat", "fields=hook_type,secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/search?"+q, nil)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/search?q=x", nil)
+	w := httptest.NewRecorder()
+	New(&mockStore{}).Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unsupported store: status = %d, want 501", w.Code)
+	}
+}
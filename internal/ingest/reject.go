@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// panicError is returned by transformAndIndex when the transform or the
+// store panicked, so one malformed event cannot take the server down.
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// recoverPanic converts a recovered panic into a *panicError in *err. Call
+// it deferred.
+func recoverPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = &panicError{value: v, stack: debug.Stack()}
+	}
+}
+
+// recordPanic counts a recovered panic in panics, logs it with its stack,
+// and writes body to the reject log if one is set. The caller counts the
+// failed event in errors.
+func (s *Server) recordPanic(pe *panicError, body []byte) {
+	s.panics.Add(1)
+	fmt.Fprintf(os.Stderr, "Error: recovered ingest %v\n%s", pe.value, pe.stack)
+	if s.rejectLog != nil {
+		s.rejectLog.write(pe.Error(), body)
+	}
+}
+
+// rejectLog is a dead-letter file: one JSON line per event that could not be
+// processed, with the raw request body so it can be inspected or replayed.
+type rejectLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type rejectRecord struct {
+	Time   string `json:"time"`
+	Reason string `json:"reason"`
+	Body   string `json:"body"`
+}
+
+func (l *rejectLog) write(reason string, body []byte) {
+	line, err := json.Marshal(rejectRecord{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Reason: reason,
+		Body:   string(body),
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: reject log: %v\n", err)
+	}
+}
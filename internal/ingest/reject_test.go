@@ -0,0 +1,95 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/hookevt"
+	"hooks-store/internal/store"
+)
+
+// panicHookType has a registered transform with an unchecked type assertion,
+// like a careless plugin would, so a non-string "label" panics.
+const panicHookType = "RejectTestPanic"
+
+func init() {
+	store.RegisterTransform(panicHookType, func(doc *store.Document, evt hookevt.HookEvent) {
+		doc.Tags = append(doc.Tags, evt.Data["label"].(string))
+	})
+}
+
+func TestHandleIngest_PanicRecovered(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	var rejects bytes.Buffer
+	srv.SetRejectLog(&rejects)
+
+	body := `{"hook_type":"` + panicHookType + `","timestamp":"2026-02-25T14:30:00Z","data":{"label":42}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if srv.ErrCount().Load() != 1 {
+		t.Errorf("errors = %d, want 1", srv.ErrCount().Load())
+	}
+	if len(ms.docs) != 0 {
+		t.Errorf("expected no indexed docs, got %d", len(ms.docs))
+	}
+
+	var rec rejectRecord
+	if err := json.Unmarshal(rejects.Bytes(), &rec); err != nil {
+		t.Fatalf("reject log line %q: %v", rejects.String(), err)
+	}
+	if rec.Body != body {
+		t.Errorf("reject body = %q, want the raw request body", rec.Body)
+	}
+	if !strings.HasPrefix(rec.Reason, "panic: ") {
+		t.Errorf("reject reason = %q, want panic: ...", rec.Reason)
+	}
+
+	// The server keeps serving.
+	good := `{"hook_type":"` + panicHookType + `","timestamp":"2026-02-25T14:30:00Z","data":{"label":"ok"}}`
+	req = httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(good))
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("follow-up status = %d, want 202", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/stats", nil)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	var stats map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats["panics"] != float64(1) {
+		t.Errorf("stats panics = %v, want 1", stats["panics"])
+	}
+}
+
+func TestHandleIngest_StorePanicRecovered(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{indexFn: func(ctx context.Context, doc store.Document) error {
+		var m map[string]int
+		m["boom"]++ // nil map write
+		return nil
+	}}
+	srv := New(ms) // no reject log: only stderr
+
+	body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+}
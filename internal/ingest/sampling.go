@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+)
+
+// SetSamplingRates replaces the per-hook-type sampling rates. A rate is the
+// fraction of that hook type's events that is indexed, in [0, 1]; hook types
+// without a rate are always indexed. Sampled-out events are answered 202 with
+// status "sampled" and counted as sampled in /stats. Safe to call while
+// serving: the map is swapped atomically, so /ingest never takes a lock.
+func (s *Server) SetSamplingRates(rates map[string]float64) error {
+	if err := validateRates(rates); err != nil {
+		return err
+	}
+	m := make(map[string]float64, len(rates))
+	for hookType, rate := range rates {
+		m[hookType] = rate
+	}
+	s.sampling.Store(&m)
+	return nil
+}
+
+// SamplingRates returns a copy of the current per-hook-type sampling rates.
+func (s *Server) SamplingRates() map[string]float64 {
+	m := make(map[string]float64)
+	if p := s.sampling.Load(); p != nil {
+		for hookType, rate := range *p {
+			m[hookType] = rate
+		}
+	}
+	return m
+}
+
+func validateRates(rates map[string]float64) error {
+	for hookType, rate := range rates {
+		if hookType == "" {
+			return fmt.Errorf("empty hook type")
+		}
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("rate for %s must be between 0 and 1, got %v", hookType, rate)
+		}
+	}
+	return nil
+}
+
+// sampledOut reports whether an event of hookType is dropped by sampling.
+func (s *Server) sampledOut(hookType string) bool {
+	p := s.sampling.Load()
+	if p == nil {
+		return false
+	}
+	rate, ok := (*p)[hookType]
+	if !ok || rate >= 1 {
+		return false
+	}
+	return rand.Float64() >= rate
+}
+
+// handleAdminSampling serves GET /admin/sampling — the current rates — and
+// POST /admin/sampling, whose body is a JSON object of hook_type → rate that
+// replaces them (an empty object turns sampling off).
+func (s *Server) handleAdminSampling(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyLen))
+		if err != nil {
+			jsonError(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		var rates map[string]float64
+		if err := json.Unmarshal(body, &rates); err != nil {
+			jsonError(w, "invalid JSON: expected an object of hook_type to rate", http.StatusBadRequest)
+			return
+		}
+		if err := s.SetSamplingRates(rates); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"rates": s.SamplingRates()})
+}
@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func adminSampling(srv *Server, method, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/admin/sampling", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminSampling(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetAdminToken("secret")
+
+	w := adminSampling(srv, http.MethodGet, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"rates":{}`) {
+		t.Fatalf("initial GET: %d %s", w.Code, w.Body.String())
+	}
+
+	w = adminSampling(srv, http.MethodPost, `{"PreToolUse":0,"PostToolUse":1}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST: status = %d, body %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	w = adminSampling(srv, http.MethodGet, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Rates) != 2 || resp.Rates["PreToolUse"] != 0 || resp.Rates["PostToolUse"] != 1 {
+		t.Errorf("rates = %v", resp.Rates)
+	}
+
+	// The new rates apply to the next ingest without a restart.
+	for _, tc := range []struct {
+		hookType, status string
+	}{
+		{"PreToolUse", "sampled"},
+		{"PostToolUse", "accepted"},
+		{"Stop", "accepted"}, // no rate: always kept
+	} {
+		body := `{"hook_type":"` + tc.hookType + `","data":{}}`
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+
+		var got map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&got)
+		if w.Code != http.StatusAccepted || got["status"] != tc.status {
+			t.Errorf("%s: got %d %v, want 202 %s", tc.hookType, w.Code, got["status"], tc.status)
+		}
+	}
+	if len(ms.docs) != 2 {
+		t.Errorf("indexed %d docs, want 2", len(ms.docs))
+	}
+	if srv.sampled.Load() != 1 {
+		t.Errorf("sampled = %d, want 1", srv.sampled.Load())
+	}
+
+	// An empty object turns sampling off.
+	if w := adminSampling(srv, http.MethodPost, `{}`); w.Code != http.StatusOK {
+		t.Fatalf("reset: status = %d", w.Code)
+	}
+	if srv.sampledOut("PreToolUse") {
+		t.Error("PreToolUse still sampled after reset")
+	}
+}
+
+func TestAdminSampling_BadRequests(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.SetAdminToken("secret")
+	if err := srv.SetSamplingRates(map[string]float64{"Stop": 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		method, body string
+		want         int
+	}{
+		{http.MethodPost, `not json`, http.StatusBadRequest},
+		{http.MethodPost, `[0.5]`, http.StatusBadRequest},
+		{http.MethodPost, `{"Stop":1.5}`, http.StatusBadRequest},
+		{http.MethodPost, `{"Stop":-0.1}`, http.StatusBadRequest},
+		{http.MethodPost, `{"":0.5}`, http.StatusBadRequest},
+		{http.MethodDelete, ``, http.StatusMethodNotAllowed},
+	} {
+		if w := adminSampling(srv, tc.method, tc.body); w.Code != tc.want {
+			t.Errorf("%s %q: status = %d, want %d", tc.method, tc.body, w.Code, tc.want)
+		}
+	}
+	// A rejected update leaves the rates alone.
+	if got := srv.SamplingRates(); len(got) != 1 || got["Stop"] != 0.5 {
+		t.Errorf("rates = %v, want unchanged", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sampling", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: status = %d, want 401", w.Code)
+	}
+}
+
+func TestSampledOut_Rate(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	if err := srv.SetSamplingRates(map[string]float64{"PreToolUse": 0.25}); err != nil {
+		t.Fatal(err)
+	}
+	kept := 0
+	const n = 10000
+	for i := 0; i < n; i++ {
+		if !srv.sampledOut("PreToolUse") {
+			kept++
+		}
+	}
+	if kept < n/5 || kept > n*3/10 {
+		t.Errorf("kept %d of %d at rate 0.25", kept, n)
+	}
+}
@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"hooks-store/internal/hookevt"
+	"hooks-store/internal/store"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// handleSchema serves JSON Schemas for the HookEvent wire format accepted by
+// /ingest and the stored Document/PromptDocument returned by read endpoints.
+// They are generated from the Go structs on each request, so they cannot
+// drift from the code.
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Only hook_type is enforced on ingest (and not even that with a
+	// default hook type); a missing timestamp decodes as the zero time.
+	// Unknown top-level keys are ignored rather than rejected.
+	hookEvent := structSchema(reflect.TypeOf(hookevt.HookEvent{}), "HookEvent")
+	if s.defaultHookType == "" {
+		hookEvent["required"] = []string{"hook_type"}
+	} else {
+		delete(hookEvent, "required")
+	}
+	delete(hookEvent, "additionalProperties")
+
+	schemas := map[string]map[string]interface{}{
+		"hook_event":      hookEvent,
+		"document":        structSchema(reflect.TypeOf(store.Document{}), "Document"),
+		"prompt_document": structSchema(reflect.TypeOf(store.PromptDocument{}), "PromptDocument"),
+	}
+	for _, schema := range schemas {
+		schema["$schema"] = jsonSchemaDraft
+	}
+	writeJSON(w, schemas)
+}
+
+// structSchema builds an object schema from t's exported JSON fields. Fields
+// without omitempty are listed as required, matching what the encoder always
+// emits.
+func structSchema(t reflect.Type, title string) map[string]interface{} {
+	properties := make(map[string]interface{}, t.NumField())
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"title":                title,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema maps a Go field type to its JSON Schema.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes []byte as a base64 string.
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		return structSchema(t, t.Name())
+	default:
+		return map[string]interface{}{} // any value
+	}
+}
@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hooks-store/internal/hookevt"
+	"hooks-store/internal/store"
+)
+
+type schemaResponse map[string]struct {
+	Schema     string                            `json:"$schema"`
+	Title      string                            `json:"title"`
+	Type       string                            `json:"type"`
+	Properties map[string]map[string]interface{} `json:"properties"`
+	Required   []string                          `json:"required"`
+}
+
+func getSchema(t *testing.T, srv *Server) schemaResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/schema", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp schemaResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestHandleSchema(t *testing.T) {
+	t.Parallel()
+	resp := getSchema(t, New(&mockStore{}))
+
+	evt := resp["hook_event"]
+	if evt.Schema != jsonSchemaDraft || evt.Type != "object" {
+		t.Errorf("hook_event $schema/type = %q/%q", evt.Schema, evt.Type)
+	}
+	if got := evt.Properties["timestamp"]["format"]; got != "date-time" {
+		t.Errorf("timestamp format = %v, want date-time", got)
+	}
+	if len(evt.Required) != 1 || evt.Required[0] != "hook_type" {
+		t.Errorf("hook_event required = %v, want [hook_type]", evt.Required)
+	}
+
+	doc := resp["document"]
+	for field, want := range map[string]string{
+		"id":             "string",
+		"timestamp_unix": "integer",
+		"cost_usd":       "number",
+		"is_subagent":    "boolean",
+		"tags":           "array",
+		"raw_body":       "string",
+		"data":           "object",
+	} {
+		if got := doc.Properties[field]["type"]; got != want {
+			t.Errorf("document %s type = %v, want %s", field, got, want)
+		}
+	}
+	required := map[string]bool{}
+	for _, f := range doc.Required {
+		required[f] = true
+	}
+	if !required["id"] || required["tags"] {
+		t.Errorf("document required = %v, want id but not tags", doc.Required)
+	}
+
+	if _, ok := resp["prompt_document"].Properties["prompt_length"]; !ok {
+		t.Error("prompt_document should describe prompt_length")
+	}
+}
+
+// TestHandleSchema_MatchesEncoding guards against drift: every key the
+// encoder emits for a fully populated value must be described.
+func TestHandleSchema_MatchesEncoding(t *testing.T) {
+	t.Parallel()
+	resp := getSchema(t, New(&mockStore{}))
+
+	doc := store.HookEventToDocument(hookevt.HookEvent{
+		HookType:  "PreToolUse",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"session_id": "s1", "tool_name": "Read", "cwd": "/tmp",
+			"tags": []interface{}{"a"}, "turn": 3.0,
+		},
+	})
+	doc.Source = "ci"
+	for name, v := range map[string]interface{}{
+		"hook_event":      hookevt.HookEvent{HookType: "Stop", Timestamp: time.Now(), Data: map[string]interface{}{}},
+		"document":        doc,
+		"prompt_document": store.DocumentToPromptDocument(doc),
+	} {
+		raw, _ := json.Marshal(v)
+		var keys map[string]interface{}
+		json.Unmarshal(raw, &keys)
+		for k := range keys {
+			if _, ok := resp[name].Properties[k]; !ok {
+				t.Errorf("%s: encoded key %q missing from schema", name, k)
+			}
+		}
+	}
+}
+
+func TestHandleSchema_DefaultHookType(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.SetDefaultHookType("Legacy")
+
+	if got := getSchema(t, srv)["hook_event"].Required; len(got) != 0 {
+		t.Errorf("hook_event required = %v, want none with a default hook type", got)
+	}
+}
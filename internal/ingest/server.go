@@ -2,30 +2,54 @@ package ingest
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"hooks-store/internal/hookevt"
+	"hooks-store/internal/metrics"
 	"hooks-store/internal/store"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	maxBodyLen   = 1 << 20 // 1 MiB — matches the monitor's limit.
 	maxJSONDepth = 100
+
+	// tracerName identifies ingest spans. The tracer is looked up per request
+	// so a provider installed after New (e.g. by tracing.Setup) is honored.
+	tracerName = "hooks-store/internal/ingest"
 )
 
 // IngestEvent is a lightweight value type carrying only the fields the TUI needs.
 // It decouples the TUI from the full hookevt.HookEvent / store.Document types.
+// It is also the JSON frame streamed to /events subscribers.
 type IngestEvent struct {
-	HookType  string
-	ToolName  string
-	SessionID string
-	BodySize  int
-	Timestamp time.Time
+	HookType  string    `json:"hook_type"`
+	ToolName  string    `json:"tool_name,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	BodySize  int       `json:"body_size"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Usage extracted from the event, zero when it carries none.
+	CostUSD     float64 `json:"cost_usd,omitempty"`
+	TotalTokens int64   `json:"total_tokens,omitempty"`
 }
 
 // Server is the HTTP ingest server for receiving hook events from the monitor.
@@ -36,6 +60,125 @@ type Server struct {
 	errors    atomic.Int64
 	lastEvent atomic.Value // stores time.Time
 	onIngest  func(IngestEvent)
+
+	// defaultHookType is applied to events that omit hook_type.
+	// Empty means such events are rejected with 400.
+	defaultHookType string
+
+	// knownHookTypes, if set, is the only hook types accepted; others are
+	// rejected with 422 (SetKnownHookTypes). Nil accepts any.
+	knownHookTypes   map[string]bool
+	unknownHookTypes atomic.Int64
+
+	// Backlog shedding: when the store reports at least backlogLimit pending
+	// tasks, ingest answers 503 + Retry-After. The store is polled at most
+	// once per backlogRefresh; requests in between read the cached value.
+	backlogLimit     int64
+	backlogRefresh   time.Duration
+	backlogMu        sync.Mutex   // held by the single goroutine refreshing
+	backlogCheckedAt atomic.Int64 // unix nanos of the last refresh
+	backlogPending   atomic.Int64
+	throttled        atomic.Int64
+
+	// Future-dated events: timestamps beyond now+maxFutureSkew are clamped
+	// to now, or rejected with 422 if rejectFuture is set. 0 disables.
+	maxFutureSkew time.Duration
+	rejectFuture  bool
+	futureDated   atomic.Int64
+
+	// Expired events: timestamps before now minus the event's project
+	// retention window are dropped (202, not indexed), or rejected with 422
+	// if rejectExpired is set. A zero window disables the check.
+	retention     store.RetentionPolicy
+	rejectExpired bool
+	expired       atomic.Int64
+
+	// sampling holds the per-hook-type sampling rates (SetSamplingRates),
+	// swapped whole so the ingest path reads it without locking.
+	sampling atomic.Pointer[map[string]float64]
+	sampled  atomic.Int64
+
+	// IP allowlist for /ingest: only clients in allowCIDRs are accepted
+	// (empty allows all). Requests from trustedProxies are attributed to
+	// their X-Forwarded-For client.
+	allowCIDRs     []netip.Prefix
+	trustedProxies []netip.Prefix
+
+	// slowThreshold: /ingest requests taking longer are logged to slowOut
+	// (stderr when nil). 0 disables.
+	slowThreshold time.Duration
+	slowOut       io.Writer
+
+	// streamReadLimit caps the total bytes a client may send over one
+	// streaming connection (/ws). 0 is unlimited.
+	streamReadLimit int64
+
+	// adminToken guards /admin/* endpoints. Empty disables them.
+	adminToken string
+
+	// Diagnostics for /admin/debug (SetDiagnostics).
+	startedAt time.Time
+	version   string
+	config    map[string]string
+
+	// transformOpts is passed to store.TransformEvent.
+	transformOpts store.TransformOptions
+
+	// detailedStatus makes a successful /ingest report how far the document
+	// got (SetDetailedStatus); queued is set when the store indexes
+	// asynchronously.
+	detailedStatus bool
+	queued         bool
+
+	// validateJSON re-marshals each document before indexing and rejects
+	// it with 422 if that fails (SetValidateJSON).
+	validateJSON  bool
+	unmarshalable atomic.Int64
+
+	// batchMaxBytes caps the /ingest/batch body (SetBatchBodyLimit);
+	// 0 means defaultBatchMaxBytes.
+	batchMaxBytes int64
+
+	// storeRawBody keeps each request body, gzipped, as the document's
+	// raw_body (SetStoreRawBody).
+	storeRawBody bool
+
+	// sourceLabel is stamped on every document as its source, unless the
+	// request carries an X-Source header.
+	sourceLabel string
+
+	// allowedIndexes are the index names a request may select with the
+	// X-Index header. Empty rejects every X-Index request.
+	allowedIndexes []string
+
+	// auditLog, if set, records every successfully indexed document.
+	// A failed audit write is counted but does not fail the ingest.
+	auditLog    *store.AuditLog
+	auditErrors atomic.Int64
+
+	// tee, if set, receives every successfully indexed document as one
+	// JSON line. teeMu keeps concurrent requests' lines whole.
+	tee   io.Writer
+	teeMu sync.Mutex
+
+	// rejectLog, if set, receives the raw body of events whose transform or
+	// index panicked. The panic is recovered either way.
+	rejectLog *rejectLog
+	panics    atomic.Int64
+
+	// Clock skew: how far each event's timestamp is from the time the
+	// server received it, observed before any future-skew clamping.
+	// The histogram is labeled by direction; maxSkew holds the signed skew
+	// (positive = sender ahead) of the largest magnitude seen, in nanos.
+	maxSkew atomic.Int64
+
+	// hub streams every ingested event to /events subscribers.
+	hub eventHub
+
+	// metrics is served at /metrics. sink receives the ingest path's
+	// measurements; by default it declares them in metrics.
+	metrics *metrics.Registry
+	sink    metrics.Sink
 }
 
 // SetOnIngest registers a callback invoked after each successful ingest.
@@ -44,6 +187,177 @@ func (s *Server) SetOnIngest(fn func(IngestEvent)) {
 	s.onIngest = fn
 }
 
+// SetDefaultHookType sets the hook_type applied to events that arrive without
+// one (legacy senders). An empty value restores the default: reject with 400.
+func (s *Server) SetDefaultHookType(hookType string) {
+	s.defaultHookType = hookType
+}
+
+// SetKnownHookTypes turns on strict hook types: events whose hook_type is not
+// in types (typically hookevt.KnownHookTypes plus any custom ones) are
+// rejected with 422 and counted as unknown_hook_type in /stats, so a sender's
+// typo does not create a new hook type in the index. Empty (the default)
+// accepts any non-empty hook_type. /transform is not checked.
+func (s *Server) SetKnownHookTypes(types []string) {
+	if len(types) == 0 {
+		s.knownHookTypes = nil
+		return
+	}
+	s.knownHookTypes = make(map[string]bool, len(types))
+	for _, t := range types {
+		s.knownHookTypes[t] = true
+	}
+}
+
+// SetBacklogLimit enables load shedding when the store's indexing backlog
+// reaches limit pending tasks. The backlog is refreshed at most once per
+// refresh interval. Has no effect if limit <= 0 or the store does not
+// implement store.BacklogReporter.
+func (s *Server) SetBacklogLimit(limit int64, refresh time.Duration) {
+	s.backlogLimit = limit
+	s.backlogRefresh = refresh
+}
+
+// SetMaxFutureSkew bounds how far in the future an event timestamp may be.
+// Events beyond now+skew are clamped to the receive time, or rejected with
+// 422 when reject is true. Either way they are counted as future_dated in
+// /stats. A zero skew disables the check.
+func (s *Server) SetMaxFutureSkew(skew time.Duration, reject bool) {
+	s.maxFutureSkew = skew
+	s.rejectFuture = reject
+}
+
+// SetDetailedStatus changes the success response of /ingest from 202
+// {"status":"accepted"} to a 200 whose body tells the durability level
+// apart: {"status":"queued"} when the store only enqueues documents (a
+// store.AsyncIndexer reporting true, e.g. MeiliSearch), else
+// {"status":"indexed"}, the document having been stored before the response.
+func (s *Server) SetDetailedStatus(on bool) {
+	s.detailedStatus = on
+	if ai, ok := store.As[store.AsyncIndexer](s.store); ok {
+		s.queued = ai.IndexesAsync()
+	}
+}
+
+// SetRetention stops events already older than the retention window from
+// being indexed, since retention would purge them anyway. Events timestamped
+// before now-window are answered 202 with status "dropped", or rejected with
+// 422 when reject is true; either way they are counted as expired in /stats.
+// Events without a timestamp are kept. A zero window disables the check.
+func (s *Server) SetRetention(window time.Duration, reject bool) {
+	s.SetRetentionPolicy(store.RetentionPolicy{Default: window}, reject)
+}
+
+// SetRetentionPolicy is SetRetention with per-project windows: an event is
+// checked against the window of its _monitor.project_dir, as sent, falling
+// back to p.Default.
+func (s *Server) SetRetentionPolicy(p store.RetentionPolicy, reject bool) {
+	s.retention = p
+	s.rejectExpired = reject
+}
+
+// SetIPAllowlist restricts /ingest to clients whose address is in allow;
+// others get 403. An empty allow (the default) accepts everyone. When the
+// TCP peer is in trustedProxies, the client address is taken from
+// X-Forwarded-For instead.
+func (s *Server) SetIPAllowlist(allow, trustedProxies []netip.Prefix) {
+	s.allowCIDRs = allow
+	s.trustedProxies = trustedProxies
+}
+
+// SetSlowRequestThreshold logs a warning with the duration, hook type, and
+// document id of every /ingest request that takes longer than threshold,
+// whatever its outcome. Zero (the default) disables it.
+func (s *Server) SetSlowRequestThreshold(threshold time.Duration) {
+	s.slowThreshold = threshold
+}
+
+// SetStreamReadLimit caps the total bytes a client may send over one /ws
+// connection, across all its messages; past it the client gets an error frame
+// and the connection is closed. Each message is still limited on its own by
+// the WebSocket read limit. Zero (the default) is unlimited.
+func (s *Server) SetStreamReadLimit(n int64) {
+	s.streamReadLimit = n
+}
+
+// SetAdminToken sets the bearer token required by /admin/* endpoints.
+// An empty token (the default) disables them.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetTransformOptions sets the options used to transform incoming events
+// into documents.
+func (s *Server) SetTransformOptions(opts store.TransformOptions) {
+	s.transformOpts = opts
+}
+
+// SetValidateJSON makes /ingest marshal each document to JSON before indexing
+// it. A document that cannot be marshaled (e.g. a NaN float a registered
+// transform or custom stage put in data) is rejected with 422 and counted as
+// unmarshalable in /stats, instead of failing later in the backend, where
+// MeiliSearch would only report it as a failed task. Off by default: it costs
+// one extra marshal per event.
+func (s *Server) SetValidateJSON(on bool) {
+	s.validateJSON = on
+}
+
+// SetStoreRawBody makes /ingest keep the exact request body, gzipped, in each
+// document's raw_body, for lossless reconstruction if parsing or transforms
+// go wrong. Bodies are already capped at the 1 MiB ingest limit. raw_body is
+// not searched or returned by /search; GET /documents/{id}?include_raw=true
+// hands it back.
+func (s *Server) SetStoreRawBody(on bool) {
+	s.storeRawBody = on
+}
+
+// SetSourceLabel sets the source stamped on every ingested document, e.g.
+// "laptop" or "ci". A request's X-Source header overrides it. Empty (the
+// default) leaves documents without a source unless the header is sent.
+func (s *Server) SetSourceLabel(label string) {
+	s.sourceLabel = label
+}
+
+// SetIndexAllowlist sets the index names a request may route its document to
+// with an X-Index header; other names get 400. Requests without the header
+// use the store's default index. Empty (the default) rejects any X-Index.
+// The store must support named indexes (store.SupportsTargetIndex),
+// otherwise X-Index requests get 501. Decorators such as RoutingStore still
+// apply to documents routed this way.
+func (s *Server) SetIndexAllowlist(names []string) {
+	s.allowedIndexes = names
+}
+
+// SetAuditLog records every successfully indexed document to a. Nil (the
+// default) disables the audit log.
+func (s *Server) SetAuditLog(a *store.AuditLog) {
+	s.auditLog = a
+}
+
+// SetTee writes every successfully indexed document to w as NDJSON, for
+// piping into other tools. Writes are serialized so lines never interleave;
+// a failed write only warns. Nil (the default) disables it.
+func (s *Server) SetTee(w io.Writer) {
+	s.tee = w
+}
+
+// SetRejectLog writes the raw body of every event whose processing panicked
+// to w as NDJSON ({"time","reason","body"}). Nil (the default) only logs the
+// panic to stderr. Writes are serialized.
+func (s *Server) SetRejectLog(w io.Writer) {
+	if w == nil {
+		s.rejectLog = nil
+		return
+	}
+	s.rejectLog = &rejectLog{w: w}
+}
+
+// CloseStreams ends every live /events and /ws stream and refuses new ones. Call it
+// before http.Server.Shutdown, which otherwise waits for streams to end.
+func (s *Server) CloseStreams() {
+	s.hub.close()
+}
+
 // ErrCount returns the atomic error counter for direct reads by the TUI.
 func (s *Server) ErrCount() *atomic.Int64 {
 	return &s.errors
@@ -51,11 +365,31 @@ func (s *Server) ErrCount() *atomic.Int64 {
 
 // New creates a new ingest Server wired to the given EventStore.
 func New(s store.EventStore) *Server {
-	srv := &Server{store: s}
+	srv := &Server{store: s, metrics: metrics.NewRegistry(), startedAt: time.Now()}
+	srv.sink = newPrometheusSink(srv.metrics)
+	if mp, ok := store.As[store.MetricsProvider](s); ok {
+		srv.metrics.Register(mp.Metrics()...)
+	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ingest", srv.handleIngest)
+	mux.HandleFunc("/ingest/batch", srv.handleIngestBatch)
 	mux.HandleFunc("/health", srv.handleHealth)
 	mux.HandleFunc("/stats", srv.handleStats)
+	mux.Handle("/metrics", srv.metrics.Handler())
+	mux.HandleFunc("/costs", srv.handleCosts)
+	mux.HandleFunc("/distinct", srv.handleDistinct)
+	mux.HandleFunc("/overview", srv.handleOverview)
+	mux.HandleFunc("/search", srv.handleSearch)
+	mux.HandleFunc("/schema", srv.handleSchema)
+	mux.HandleFunc("/transform", srv.handleTransform)
+	mux.HandleFunc("/events", srv.handleEvents)
+	mux.HandleFunc("/ws", srv.handleWS)
+	mux.HandleFunc("/documents/", srv.handleDocument)
+	mux.HandleFunc("/admin/delete", srv.requireAdmin(srv.handleAdminDelete))
+	mux.HandleFunc("/admin/clear", srv.requireAdmin(srv.handleAdminClear))
+	mux.HandleFunc("/admin/settings", srv.requireAdmin(srv.handleAdminSettings))
+	mux.HandleFunc("/admin/debug", srv.requireAdmin(srv.handleAdminDebug))
+	mux.HandleFunc("/admin/sampling", srv.requireAdmin(srv.handleAdminSampling))
 	srv.mux = mux
 	return srv
 }
@@ -71,71 +405,385 @@ func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.ipAllowed(r) {
+		jsonError(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	defer s.observeDuration("/ingest", time.Now())
+
+	var hookType, docID string
+	if s.slowThreshold > 0 {
+		start := time.Now()
+		defer func() { s.logSlow(time.Since(start), hookType, docID) }()
+	}
+
+	target := strings.TrimSpace(r.Header.Get("X-Index"))
+	if target != "" {
+		if !slices.Contains(s.allowedIndexes, target) {
+			s.errors.Add(1)
+			jsonError(w, fmt.Sprintf("index %q is not allowed", target), http.StatusBadRequest)
+			return
+		}
+		if !store.SupportsTargetIndex(s.store) {
+			jsonError(w, "X-Index is not supported by this store", http.StatusNotImplemented)
+			return
+		}
+	}
+
+	// Continue the sender's trace if it sent a traceparent header.
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "ingest", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	if s.backlogExceeded(r) {
+		s.throttle(w)
+		return
+	}
+
 	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyLen+1))
 	if err != nil {
 		s.errors.Add(1)
 		jsonError(w, "failed to read body", http.StatusBadRequest)
 		return
 	}
-	if len(body) > maxBodyLen {
+	evt, err := DecodeEvent(body, s.defaultHookType)
+	if err != nil {
 		s.errors.Add(1)
-		jsonError(w, "body too large", http.StatusRequestEntityTooLarge)
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		jsonError(w, err.Error(), status)
 		return
 	}
-	if len(body) == 0 {
+	hookType = evt.HookType
+
+	skipped, err := s.screen(&evt)
+	if err != nil {
 		s.errors.Add(1)
-		jsonError(w, "empty body", http.StatusBadRequest)
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if skipped != "" {
+		writeSkipped(w, skipped)
 		return
 	}
 
-	if err := checkJSONDepth(body, maxJSONDepth); err != nil {
+	span.SetAttributes(
+		attribute.String("hook_type", evt.HookType),
+		attribute.Int("body_size", len(body)),
+	)
+
+	var raw []byte
+	if s.storeRawBody {
+		raw = body
+	}
+	doc, err := s.transformAndIndex(ctx, span, evt, r, target, raw)
+	docID = doc.ID
+	if pe, ok := err.(*panicError); ok {
+		span.SetStatus(codes.Error, pe.Error())
 		s.errors.Add(1)
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		s.recordPanic(pe, body)
+		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-
-	var evt hookevt.HookEvent
-	if err := json.Unmarshal(body, &evt); err != nil {
+	if errors.Is(err, store.ErrSampledOut) {
+		s.sampled.Add(1)
+		writeSkipped(w, "sampled")
+		return
+	}
+	if errors.Is(err, errUnmarshalable) {
+		span.SetStatus(codes.Error, err.Error())
 		s.errors.Add(1)
-		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		s.unmarshalable.Add(1)
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
-
-	if evt.HookType == "" {
+	var stageErr *store.StageError
+	if errors.As(err, &stageErr) {
+		span.SetStatus(codes.Error, err.Error())
 		s.errors.Add(1)
-		jsonError(w, "missing hook_type", http.StatusBadRequest)
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
 		return
 	}
-
-	doc := store.HookEventToDocument(evt)
-
-	if err := s.store.Index(r.Context(), doc); err != nil {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		s.errors.Add(1)
 		jsonError(w, "indexing failed", http.StatusServiceUnavailable)
 		return
 	}
 
+	s.indexed(evt, doc, len(body))
+
+	status, code := s.successStatus()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"id":     doc.ID,
+	})
+}
+
+// screen runs the checks an event passes before it is transformed: an
+// unknown hook type is rejected in strict mode, clock skew is observed, a
+// future timestamp is clamped (evt is updated) or rejected, and an event
+// past its retention window is skipped. It returns the status reported for
+// a skipped event ("dropped"), or an error for a rejected one; "" and nil
+// mean index it. Counters other than errors are updated here. Sampling is
+// not a check here but the transform's sample stage (see toDocument).
+func (s *Server) screen(evt *hookevt.HookEvent) (string, error) {
+	if s.knownHookTypes != nil && !s.knownHookTypes[evt.HookType] {
+		s.unknownHookTypes.Add(1)
+		return "", fmt.Errorf("unknown hook_type %q", evt.HookType)
+	}
+	s.observeSkew(evt.Timestamp, time.Now())
+
+	if s.maxFutureSkew > 0 {
+		now := time.Now()
+		if evt.Timestamp.After(now.Add(s.maxFutureSkew)) {
+			s.futureDated.Add(1)
+			if s.rejectFuture {
+				return "", errors.New("timestamp too far in the future")
+			}
+			evt.Timestamp = now
+		}
+	}
+
+	if window := s.retention.For(monitorProjectDir(*evt, s.transformOpts.NormalizePaths)); window > 0 && !evt.Timestamp.IsZero() && evt.Timestamp.Before(time.Now().Add(-window)) {
+		s.expired.Add(1)
+		if s.rejectExpired {
+			return "", errors.New("timestamp older than retention window")
+		}
+		return "dropped", nil
+	}
+	return "", nil
+}
+
+// writeSkipped answers 202 with the status of a skipped event.
+func writeSkipped(w http.ResponseWriter, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status})
+}
+
+// indexed records a successfully indexed document: counters, the audit log
+// and tee, the onIngest callback, and /events subscribers. bodySize is the
+// size of the event as received.
+func (s *Server) indexed(evt hookevt.HookEvent, doc store.Document, bodySize int) {
 	s.ingested.Add(1)
+	s.sink.IncCounter(metricIngested, evt.HookType)
 	s.lastEvent.Store(time.Now())
 
+	if s.auditLog != nil {
+		if err := s.auditLog.Write(doc); err != nil {
+			s.auditErrors.Add(1)
+			fmt.Fprintf(os.Stderr, "Warning: audit log: %v\n", err)
+		}
+	}
+	if s.tee != nil {
+		s.writeTee(doc)
+	}
+
+	toolName, _ := evt.Data["tool_name"].(string)
+	ie := IngestEvent{
+		HookType:  evt.HookType,
+		ToolName:  toolName,
+		SessionID: doc.SessionID, // hashed with TransformOptions.SessionIDKey
+		BodySize:  bodySize,
+		Timestamp: evt.Timestamp,
+
+		CostUSD:     doc.CostUSD,
+		TotalTokens: doc.TotalTokens,
+	}
 	if s.onIngest != nil {
-		toolName, _ := evt.Data["tool_name"].(string)
-		sessionID, _ := evt.Data["session_id"].(string)
-		s.onIngest(IngestEvent{
-			HookType:  evt.HookType,
-			ToolName:  toolName,
-			SessionID: sessionID,
-			BodySize:  len(body),
-			Timestamp: evt.Timestamp,
-		})
+		s.onIngest(ie)
 	}
+	s.hub.publish(ie)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "accepted",
-		"id":     doc.ID,
-	})
+// successStatus returns the status and HTTP code of a successful ingest
+// (see SetDetailedStatus).
+func (s *Server) successStatus() (string, int) {
+	if !s.detailedStatus {
+		return "accepted", http.StatusAccepted
+	}
+	if s.queued {
+		return "queued", http.StatusOK
+	}
+	return "indexed", http.StatusOK
+}
+
+// transformAndIndex converts evt to a document and indexes it, into the
+// target index when target is non-empty. A non-nil raw is kept compressed as
+// the document's raw_body. A panic in
+// either step (e.g. an unchecked type assertion in a registered transform
+// meeting an unexpected payload) is recovered and returned as a *panicError.
+func (s *Server) transformAndIndex(ctx context.Context, span trace.Span, evt hookevt.HookEvent, r *http.Request, target string, raw []byte) (doc store.Document, err error) {
+	defer recoverPanic(&err)
+
+	err = func() error {
+		_, transformSpan := otel.Tracer(tracerName).Start(ctx, "transform")
+		defer transformSpan.End()
+		doc, err = s.toDocument(evt, r, true)
+		if raw != nil {
+			doc.RawBody = store.CompressRawBody(raw)
+		}
+		return err
+	}()
+	if err != nil {
+		return doc, err
+	}
+	span.SetAttributes(attribute.String("doc_id", doc.ID))
+	if err := s.checkJSON(doc); err != nil {
+		return doc, err
+	}
+
+	indexCtx, indexSpan := otel.Tracer(tracerName).Start(ctx, "index")
+	defer indexSpan.End()
+	if target != "" {
+		indexSpan.SetAttributes(attribute.String("index", target))
+		ti, _ := store.As[store.TargetIndexer](s.store)
+		return doc, ti.IndexInto(indexCtx, target, doc)
+	}
+	return doc, s.store.Index(indexCtx, doc)
+}
+
+// monitorProjectDir returns the project_dir the monitor attached to evt, or
+// "" if none. With normalize (TransformOptions.NormalizePaths) it goes
+// through store.NormalizePath, so it compares like the stored document's.
+func monitorProjectDir(evt hookevt.HookEvent, normalize bool) string {
+	monitor, _ := evt.Data["_monitor"].(map[string]interface{})
+	dir, _ := monitor["project_dir"].(string)
+	if normalize {
+		dir = store.NormalizePath(dir)
+	}
+	return dir
+}
+
+// errUnmarshalable is returned by transformAndIndex, with SetValidateJSON,
+// for a document that cannot be marshaled to JSON.
+var errUnmarshalable = errors.New("document is not valid JSON")
+
+// checkJSON returns an errUnmarshalable error if SetValidateJSON is on and
+// doc cannot be marshaled.
+func (s *Server) checkJSON(doc store.Document) error {
+	if !s.validateJSON {
+		return nil
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		return fmt.Errorf("%w: %v", errUnmarshalable, err)
+	}
+	return nil
+}
+
+// toDocument runs the configured transform on evt and stamps the source:
+// the X-Source header when set, else the server's source label. With sample
+// the sample stage applies the sampling rates, failing a dropped event with
+// store.ErrSampledOut; /transform previews without it.
+func (s *Server) toDocument(evt hookevt.HookEvent, r *http.Request, sample bool) (store.Document, error) {
+	opts := s.transformOpts
+	if sample {
+		opts.Sample = func(hookType string) bool { return !s.sampledOut(hookType) }
+	}
+	doc, err := store.TransformEvent(evt, opts)
+	if err != nil {
+		return doc, err
+	}
+	doc.Source = s.sourceLabel
+	if src := strings.TrimSpace(r.Header.Get("X-Source")); src != "" {
+		doc.Source = src
+	}
+	return doc, nil
+}
+
+// writeTee writes doc to the tee writer as one JSON line.
+func (s *Server) writeTee(doc store.Document) {
+	line, err := json.Marshal(doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tee: encode %s: %v\n", doc.ID, err)
+		return
+	}
+	line = append(line, '\n')
+	s.teeMu.Lock()
+	defer s.teeMu.Unlock()
+	if _, err := s.tee.Write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tee: %v\n", err)
+	}
+}
+
+// observeSkew records how far ts is from received in the clock skew
+// histogram and keeps the largest magnitude for /stats. Events without a
+// timestamp are skipped. Delivery delay also counts as "behind", so that
+// side includes retried and replayed events, not only slow clocks.
+func (s *Server) observeSkew(ts, received time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	skew := ts.Sub(received)
+	direction := "ahead"
+	if skew < 0 {
+		direction = "behind"
+	}
+	s.sink.ObserveHistogram(metricClockSkew, direction, skew.Abs().Seconds())
+	for {
+		cur := s.maxSkew.Load()
+		if skew.Abs() <= time.Duration(cur).Abs() {
+			return
+		}
+		if s.maxSkew.CompareAndSwap(cur, int64(skew)) {
+			s.sink.SetGauge(metricMaxSkew, "", skew.Seconds())
+			return
+		}
+	}
+}
+
+// logSlow warns about an /ingest request slower than the threshold. hookType
+// and docID are empty when the request failed before they were known.
+func (s *Server) logSlow(elapsed time.Duration, hookType, docID string) {
+	if elapsed <= s.slowThreshold {
+		return
+	}
+	out := s.slowOut
+	if out == nil {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "Warning: slow ingest request: %s (threshold %s) hook_type=%q id=%q\n",
+		elapsed.Round(time.Millisecond), s.slowThreshold, hookType, docID)
+}
+
+// throttle answers a request shed because of the indexing backlog: 503 with
+// a Retry-After of the backlog refresh interval.
+func (s *Server) throttle(w http.ResponseWriter) {
+	s.throttled.Add(1)
+	retry := int(s.backlogRefresh.Round(time.Second) / time.Second)
+	if retry < 1 {
+		retry = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	jsonError(w, "indexing backlog, retry later", http.StatusServiceUnavailable)
+}
+
+// backlogExceeded reports whether the cached store backlog is at or above the
+// configured limit, refreshing the cache when it is older than backlogRefresh.
+// Only one request refreshes at a time; concurrent requests use the cached
+// value rather than waiting. A failed refresh keeps the last known value.
+func (s *Server) backlogExceeded(r *http.Request) bool {
+	if s.backlogLimit <= 0 {
+		return false
+	}
+	br, ok := store.As[store.BacklogReporter](s.store)
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(time.Unix(0, s.backlogCheckedAt.Load())) >= s.backlogRefresh && s.backlogMu.TryLock() {
+		if b, err := br.Backlog(r.Context()); err == nil {
+			s.backlogPending.Store(b.PendingTasks)
+		}
+		s.backlogCheckedAt.Store(now.UnixNano())
+		s.backlogMu.Unlock()
+	}
+	return s.backlogPending.Load() >= s.backlogLimit
 }
 
 // jsonError writes a JSON error response with the correct Content-Type.
@@ -164,8 +812,25 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := map[string]interface{}{
-		"ingested": s.ingested.Load(),
-		"errors":   s.errors.Load(),
+		"ingested":      s.ingested.Load(),
+		"errors":        s.errors.Load(),
+		"throttled":     s.throttled.Load(),
+		"future_dated":  s.futureDated.Load(),
+		"expired":       s.expired.Load(),
+		"sampled":       s.sampled.Load(),
+		"panics":        s.panics.Load(),
+		"unmarshalable": s.unmarshalable.Load(),
+
+		"unknown_hook_type": s.unknownHookTypes.Load(),
+
+		"max_clock_skew_seconds": time.Duration(s.maxSkew.Load()).Seconds(),
+	}
+	resp["stream_subscribers"], resp["stream_dropped"] = s.hub.stats()
+	if pr, ok := store.As[store.PromptsErrorReporter](s.store); ok {
+		resp["prompts_write_errors"] = pr.PromptsWriteErrors()
+	}
+	if s.auditLog != nil {
+		resp["audit_errors"] = s.auditErrors.Load()
 	}
 
 	if last := s.lastEvent.Load(); last != nil {
@@ -178,6 +843,36 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// ErrBodyTooLarge is returned by DecodeEvent for a body over the 1 MiB limit.
+var ErrBodyTooLarge = errors.New("body too large")
+
+// DecodeEvent applies /ingest's validation to one request body — size limit,
+// JSON depth limit, well-formed JSON, and a hook_type (defaultHookType fills
+// a missing one, as with SetDefaultHookType) — and returns the decoded event.
+// Error messages are the ones /ingest responds with.
+func DecodeEvent(body []byte, defaultHookType string) (hookevt.HookEvent, error) {
+	var evt hookevt.HookEvent
+	if len(body) > maxBodyLen {
+		return evt, ErrBodyTooLarge
+	}
+	if len(body) == 0 {
+		return evt, errors.New("empty body")
+	}
+	if err := checkJSONDepth(body, maxJSONDepth); err != nil {
+		return evt, err
+	}
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return evt, errors.New("invalid JSON")
+	}
+	if evt.HookType == "" {
+		evt.HookType = defaultHookType
+	}
+	if evt.HookType == "" {
+		return evt, errors.New("missing hook_type")
+	}
+	return evt, nil
+}
+
 // checkJSONDepth scans raw JSON tokens to reject payloads that exceed maxDepth
 // nesting levels.
 func checkJSONDepth(data []byte, maxDepth int) error {
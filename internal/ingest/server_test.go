@@ -1,18 +1,31 @@
 package ingest
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
+	"hooks-store/internal/hookevt"
+	"hooks-store/internal/metrics"
 	"hooks-store/internal/store"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // mockStore is a test double for store.EventStore.
@@ -32,8 +45,31 @@ func (m *mockStore) Index(ctx context.Context, doc store.Document) error {
 	return nil
 }
 
+// BatchIndex indexes each document through Index, so indexFn sees them all,
+// and stops at the first failure.
+func (m *mockStore) BatchIndex(ctx context.Context, docs []store.Document) error {
+	for _, doc := range docs {
+		if err := m.Index(ctx, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *mockStore) Close() error { return nil }
 
+// backlogStore is a mockStore that also implements store.BacklogReporter.
+type backlogStore struct {
+	mockStore
+	pending int64
+	calls   atomic.Int64
+}
+
+func (b *backlogStore) Backlog(ctx context.Context) (store.Backlog, error) {
+	b.calls.Add(1)
+	return store.Backlog{IsIndexing: b.pending > 0, PendingTasks: b.pending}, nil
+}
+
 func TestHandleIngest_Success(t *testing.T) {
 	t.Parallel()
 	ms := &mockStore{}
@@ -111,6 +147,31 @@ func TestHandleIngest_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestHandleIngest_NonObjectData(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+
+	for _, data := range []string{`"oops"`, `[1,2]`, `null`} {
+		body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":` + data + `}`
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Errorf("data %s: status = %d, want 202", data, w.Code)
+		}
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if len(ms.docs) != 3 {
+		t.Fatalf("indexed %d docs, want 3", len(ms.docs))
+	}
+	if got := ms.docs[0].Data[hookevt.RawDataKey]; got != "oops" {
+		t.Errorf("string data: Data[_raw] = %#v, want \"oops\"", got)
+	}
+}
+
 func TestHandleIngest_MissingHookType(t *testing.T) {
 	t.Parallel()
 	srv := New(&mockStore{})
@@ -125,6 +186,53 @@ func TestHandleIngest_MissingHookType(t *testing.T) {
 	}
 }
 
+func TestHandleIngest_DefaultHookType(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetDefaultHookType("LegacyEvent")
+
+	body := `{"timestamp":"2026-02-25T14:30:00Z","data":{"tool_name":"Read"}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if len(ms.docs) != 1 {
+		t.Fatalf("expected 1 indexed doc, got %d", len(ms.docs))
+	}
+	if ms.docs[0].HookType != "LegacyEvent" {
+		t.Errorf("doc HookType = %q, want LegacyEvent", ms.docs[0].HookType)
+	}
+}
+
+func TestHandleIngest_DefaultHookType_ExplicitWins(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetDefaultHookType("LegacyEvent")
+
+	body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.docs[0].HookType != "Stop" {
+		t.Errorf("doc HookType = %q, want Stop", ms.docs[0].HookType)
+	}
+}
+
 func TestHandleIngest_BodyTooLarge(t *testing.T) {
 	t.Parallel()
 	srv := New(&mockStore{})
@@ -159,6 +267,268 @@ func TestHandleIngest_StoreError(t *testing.T) {
 	}
 }
 
+func TestHandleIngest_BacklogExceeded(t *testing.T) {
+	t.Parallel()
+	bs := &backlogStore{pending: 20}
+	srv := New(bs)
+	srv.SetBacklogLimit(10, 3*time.Second)
+
+	body := `{"hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Retry-After = %q, want 3", got)
+	}
+	if len(bs.docs) != 0 {
+		t.Errorf("expected no indexed docs while throttled, got %d", len(bs.docs))
+	}
+}
+
+func TestHandleIngest_BacklogBelowLimit(t *testing.T) {
+	t.Parallel()
+	bs := &backlogStore{pending: 5}
+	srv := New(bs)
+	srv.SetBacklogLimit(10, time.Second)
+
+	body := `{"hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("status = %d, want 202", w.Code)
+	}
+}
+
+func TestHandleIngest_BacklogCached(t *testing.T) {
+	t.Parallel()
+	bs := &backlogStore{pending: 0}
+	srv := New(bs)
+	srv.SetBacklogLimit(10, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		body := `{"hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+	}
+
+	if got := bs.calls.Load(); got != 1 {
+		t.Errorf("Backlog calls = %d, want 1 (cached within refresh interval)", got)
+	}
+}
+
+func TestHandleIngest_FutureSkew_Clamp(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetMaxFutureSkew(time.Minute, false)
+
+	before := time.Now()
+	body := `{"hook_type":"PreToolUse","timestamp":"2099-01-01T00:00:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if len(ms.docs) != 1 {
+		t.Fatalf("expected 1 indexed doc, got %d", len(ms.docs))
+	}
+	got := time.Unix(ms.docs[0].TimestampUnix, 0)
+	if got.Before(before.Truncate(time.Second)) || got.After(time.Now()) {
+		t.Errorf("timestamp = %v, want clamped to receive time", got)
+	}
+	if srv.futureDated.Load() != 1 {
+		t.Errorf("futureDated = %d, want 1", srv.futureDated.Load())
+	}
+}
+
+func TestHandleIngest_FutureSkew_Reject(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetMaxFutureSkew(time.Minute, true)
+
+	body := `{"hook_type":"PreToolUse","timestamp":"2099-01-01T00:00:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", w.Code)
+	}
+	if len(ms.docs) != 0 {
+		t.Errorf("expected no indexed docs, got %d", len(ms.docs))
+	}
+	if srv.futureDated.Load() != 1 {
+		t.Errorf("futureDated = %d, want 1", srv.futureDated.Load())
+	}
+}
+
+func TestHandleIngest_Retention_Drop(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetRetention(24*time.Hour, false)
+
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for _, tc := range []struct {
+		body   string
+		status string
+	}{
+		{`{"hook_type":"PreToolUse","timestamp":"` + old + `","data":{}}`, "dropped"},
+		{`{"hook_type":"PreToolUse","timestamp":"` + recent + `","data":{}}`, "accepted"},
+		{`{"hook_type":"PreToolUse","data":{}}`, "accepted"}, // no timestamp: kept
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusAccepted || resp["status"] != tc.status {
+			t.Errorf("%s: got %d %v, want 202 %s", tc.body, w.Code, resp["status"], tc.status)
+		}
+	}
+	if len(ms.docs) != 2 {
+		t.Errorf("indexed %d docs, want 2", len(ms.docs))
+	}
+	if srv.expired.Load() != 1 || srv.errors.Load() != 0 {
+		t.Errorf("expired, errors = %d, %d; want 1, 0", srv.expired.Load(), srv.errors.Load())
+	}
+}
+
+func TestHandleIngest_Retention_PerProject(t *testing.T) {
+	t.Parallel()
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	// \keep\ once JSON-decoded: the override matches it only when paths
+	// are normalized, as the stored project_dir is then /keep too.
+	const backslashed = `\\keep\\`
+	for _, normalize := range []bool{false, true} {
+		ms := &mockStore{}
+		srv := New(ms)
+		srv.SetTransformOptions(store.TransformOptions{NormalizePaths: normalize})
+		srv.SetRetentionPolicy(store.RetentionPolicy{
+			Default:  24 * time.Hour,
+			Projects: map[string]time.Duration{"/keep": 30 * 24 * time.Hour},
+		}, false)
+
+		backslashedStatus := "dropped"
+		if normalize {
+			backslashedStatus = "accepted"
+		}
+		for _, tc := range []struct {
+			project string
+			status  string
+		}{
+			{"/keep", "accepted"},
+			{backslashed, backslashedStatus},
+			{"/other", "dropped"},
+		} {
+			body := `{"hook_type":"Stop","timestamp":"` + old + `","data":{"_monitor":{"project_dir":"` + tc.project + `"}}}`
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+			var resp map[string]interface{}
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp["status"] != tc.status {
+				t.Errorf("normalize=%v %s: status %v, want %s", normalize, tc.project, resp["status"], tc.status)
+			}
+		}
+		// Every kept document is stored under the override's key.
+		for _, doc := range ms.docs {
+			if doc.ProjectDir != "/keep" {
+				t.Errorf("normalize=%v: stored project_dir %q, want /keep", normalize, doc.ProjectDir)
+			}
+		}
+	}
+}
+
+func TestHandleIngest_KnownHookTypes(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetKnownHookTypes(append(slices.Clone(hookevt.KnownHookTypes), "Custom"))
+
+	for _, tc := range []struct {
+		hookType string
+		want     int
+	}{
+		{"PreToolUse", http.StatusAccepted},
+		{"Custom", http.StatusAccepted},
+		{"PreTooluse", http.StatusUnprocessableEntity},
+	} {
+		body := `{"hook_type":"` + tc.hookType + `","data":{}}`
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.hookType, w.Code, tc.want)
+		}
+	}
+	if len(ms.docs) != 2 || srv.unknownHookTypes.Load() != 1 || srv.errors.Load() != 1 {
+		t.Errorf("indexed %d, unknown %d, errors %d; want 2, 1, 1", len(ms.docs), srv.unknownHookTypes.Load(), srv.errors.Load())
+	}
+
+	// Permissive again.
+	srv.SetKnownHookTypes(nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Anything","data":{}}`)))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("permissive: status = %d, want 202", w.Code)
+	}
+}
+
+func TestHandleIngest_Retention_Reject(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetRetention(24*time.Hour, true)
+
+	body := `{"hook_type":"PreToolUse","timestamp":"2001-01-01T00:00:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", w.Code)
+	}
+	if len(ms.docs) != 0 {
+		t.Errorf("expected no indexed docs, got %d", len(ms.docs))
+	}
+	if srv.expired.Load() != 1 {
+		t.Errorf("expired = %d, want 1", srv.expired.Load())
+	}
+}
+
+func TestHandleIngest_FutureSkew_WithinSkew(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetMaxFutureSkew(time.Hour, true)
+
+	ts := time.Now().Add(30 * time.Minute).UTC().Format(time.RFC3339)
+	body := `{"hook_type":"PreToolUse","timestamp":"` + ts + `","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	if srv.futureDated.Load() != 0 {
+		t.Errorf("futureDated = %d, want 0", srv.futureDated.Load())
+	}
+}
+
 func TestHandleIngest_DeepJSON(t *testing.T) {
 	t.Parallel()
 	srv := New(&mockStore{})
@@ -182,6 +552,61 @@ func TestHandleIngest_DeepJSON(t *testing.T) {
 	}
 }
 
+// TestHandleIngest_TraceContext swaps the global tracer provider, so it must
+// not run in parallel with other tests.
+func TestHandleIngest_TraceContext(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	srv := New(&mockStore{})
+	body := `{"hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+
+	var ingestSpan sdktrace.ReadOnlySpan
+	names := map[string]bool{}
+	for _, s := range rec.Ended() {
+		names[s.Name()] = true
+		if s.Name() == "ingest" {
+			ingestSpan = s
+		}
+	}
+	for _, want := range []string{"ingest", "transform", "index"} {
+		if !names[want] {
+			t.Errorf("missing span %q", want)
+		}
+	}
+	if ingestSpan == nil {
+		t.Fatal("no ingest span recorded")
+	}
+	if got := ingestSpan.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want propagated from traceparent", got)
+	}
+	attrs := map[string]string{}
+	for _, kv := range ingestSpan.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["hook_type"] != "PreToolUse" {
+		t.Errorf("hook_type attribute = %q, want PreToolUse", attrs["hook_type"])
+	}
+	if attrs["body_size"] != fmt.Sprint(len(body)) {
+		t.Errorf("body_size attribute = %q, want %d", attrs["body_size"], len(body))
+	}
+}
+
 func TestHandleHealth(t *testing.T) {
 	t.Parallel()
 	srv := New(&mockStore{})
@@ -332,3 +757,588 @@ func TestHandleIngest_ErrorContentType(t *testing.T) {
 		})
 	}
 }
+
+// promptsStore is a mockStore that reports a prompts write error count.
+type promptsStore struct {
+	mockStore
+}
+
+func (p *promptsStore) PromptsWriteErrors() int64 { return 3 }
+
+func TestHandleStats_PromptsWriteErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		store store.EventStore
+		want  interface{}
+	}{
+		{&promptsStore{}, float64(3)},
+		{&mockStore{}, nil},
+	} {
+		srv := New(tt.store)
+		req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["prompts_write_errors"] != tt.want {
+			t.Errorf("%T: prompts_write_errors = %v, want %v", tt.store, resp["prompts_write_errors"], tt.want)
+		}
+	}
+}
+
+func TestHandleIngest_SourceLabel(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetSourceLabel("laptop")
+
+	body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	for _, header := range []string{"", "ci"} {
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		if header != "" {
+			req.Header.Set("X-Source", header)
+		}
+		srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if len(ms.docs) != 2 {
+		t.Fatalf("expected 2 indexed docs, got %d", len(ms.docs))
+	}
+	if ms.docs[0].Source != "laptop" {
+		t.Errorf("default Source = %q, want laptop", ms.docs[0].Source)
+	}
+	if ms.docs[1].Source != "ci" {
+		t.Errorf("X-Source Source = %q, want ci", ms.docs[1].Source)
+	}
+}
+
+// targetStore is a mockStore that also implements store.TargetIndexer,
+// recording the index each document was routed to.
+type targetStore struct {
+	mockStore
+	targets []string
+}
+
+func (ts *targetStore) IndexInto(ctx context.Context, index string, doc store.Document) error {
+	ts.mu.Lock()
+	ts.targets = append(ts.targets, index)
+	ts.docs = append(ts.docs, doc)
+	ts.mu.Unlock()
+	return nil
+}
+
+func TestHandleIngest_XIndex(t *testing.T) {
+	t.Parallel()
+	ts := &targetStore{}
+	srv := New(ts)
+	srv.SetIndexAllowlist([]string{"team-a", "team-b"})
+
+	body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"", http.StatusAccepted},
+		{"team-a", http.StatusAccepted},
+		{" team-b ", http.StatusAccepted},
+		{"team-c", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		if tt.header != "" {
+			req.Header.Set("X-Index", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("X-Index %q: status = %d, want %d", tt.header, rec.Code, tt.want)
+		}
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.docs) != 3 {
+		t.Fatalf("indexed %d docs, want 3", len(ts.docs))
+	}
+	if want := []string{"team-a", "team-b"}; !slices.Equal(ts.targets, want) {
+		t.Errorf("targets = %v, want %v", ts.targets, want)
+	}
+}
+
+func TestHandleIngest_XIndexUnsupported(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetIndexAllowlist([]string{"team-a"})
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+	req.Header.Set("X-Index", "team-a")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d, want 501", rec.Code)
+	}
+	if len(ms.docs) != 0 {
+		t.Errorf("indexed %d docs, want 0", len(ms.docs))
+	}
+
+	// A decorator's own IndexInto does not make a plain store support it.
+	srv = New(store.NewFirstSeenStore(ms, 0))
+	srv.SetIndexAllowlist([]string{"team-a"})
+	req = httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+	req.Header.Set("X-Index", "team-a")
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("decorated: status = %d, want 501", rec.Code)
+	}
+}
+
+func TestHandleIngest_XIndexDecorated(t *testing.T) {
+	t.Parallel()
+	ts := &targetStore{}
+	srv := New(store.NewFirstSeenStore(ts, 0))
+	srv.SetIndexAllowlist([]string{"team-a"})
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{"session_id":"s1"}}`))
+	req.Header.Set("X-Index", "team-a")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.docs) != 1 || !slices.Equal(ts.targets, []string{"team-a"}) {
+		t.Fatalf("docs = %d, targets = %v; want one into team-a", len(ts.docs), ts.targets)
+	}
+	if !ts.docs[0].SessionFirstSeen {
+		t.Error("SessionFirstSeen not stamped: X-Index bypassed the decorator")
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for a writer and a reader goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHandleIngest_Tee(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{indexFn: func(ctx context.Context, doc store.Document) error {
+		if doc.HookType == "Fail" {
+			return fmt.Errorf("down")
+		}
+		return nil
+	}}
+	srv := New(ms)
+	var out syncBuffer
+	srv.SetTee(&out)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"hook_type":"PreToolUse","data":{"tool_name":"Bash","n":%d,"pad":%q}}`, i, strings.Repeat("x", 4096))
+			srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		}(i)
+	}
+	wg.Wait()
+	// Not indexed, so not teed.
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Fail","data":{}}`)))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 20 {
+		t.Fatalf("got %d lines, want 20", len(lines))
+	}
+	seen := map[float64]bool{}
+	for _, line := range lines {
+		var doc store.Document
+		if err := json.Unmarshal([]byte(line), &doc); err != nil {
+			t.Fatalf("line is not a document: %v", err)
+		}
+		if doc.HookType != "PreToolUse" || doc.ToolName != "Bash" || doc.ID == "" {
+			t.Errorf("teed doc = %+v", doc)
+		}
+		seen[doc.Data["n"].(float64)] = true
+	}
+	if len(seen) != 20 {
+		t.Errorf("distinct events = %d, want 20", len(seen))
+	}
+}
+
+func TestHandleIngest_SlowRequestLog(t *testing.T) {
+	t.Parallel()
+	var delay atomic.Int64
+	ms := &mockStore{indexFn: func(ctx context.Context, doc store.Document) error {
+		time.Sleep(time.Duration(delay.Load()))
+		return nil
+	}}
+	srv := New(ms)
+	out := &syncBuffer{}
+	srv.slowOut = out
+	srv.SetSlowRequestThreshold(20 * time.Millisecond)
+
+	ingest := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		var resp struct{ ID string }
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp.ID
+	}
+
+	ingest()
+	if got := out.String(); got != "" {
+		t.Errorf("fast request logged: %q", got)
+	}
+
+	delay.Store(int64(40 * time.Millisecond))
+	id := ingest()
+	got := out.String()
+	if !strings.Contains(got, "slow ingest request") || !strings.Contains(got, `hook_type="Stop"`) || !strings.Contains(got, id) {
+		t.Errorf("slow request log = %q, want hook type and id %s", got, id)
+	}
+}
+
+func TestHandleIngest_IPAllowlist(t *testing.T) {
+	t.Parallel()
+	allow, err := ParsePrefixes([]string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.5"})
+	if err != nil {
+		t.Fatalf("ParsePrefixes: %v", err)
+	}
+	proxies, err := ParsePrefixes([]string{"172.16.0.1"})
+	if err != nil {
+		t.Fatalf("ParsePrefixes: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		want       int
+	}{
+		{"allowed range", "10.1.2.3:5000", "", http.StatusAccepted},
+		{"allowed single address", "192.168.1.5:5000", "", http.StatusAccepted},
+		{"allowed IPv6", "[2001:db8::1]:5000", "", http.StatusAccepted},
+		{"blocked", "192.168.1.6:5000", "", http.StatusForbidden},
+		{"untrusted peer XFF ignored", "192.168.1.6:5000", "10.1.2.3", http.StatusForbidden},
+		{"trusted proxy, allowed client", "172.16.0.1:5000", "8.8.8.8, 10.1.2.3", http.StatusAccepted},
+		{"trusted proxy, blocked client", "172.16.0.1:5000", "10.1.2.3, 8.8.8.8", http.StatusForbidden},
+		{"trusted proxy, bad XFF", "172.16.0.1:5000", "garbage", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv := New(&mockStore{})
+			srv.SetIPAllowlist(allow, proxies)
+
+			req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleIngest_IPAllowlist_Empty(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.SetIPAllowlist(nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop","data":{}}`))
+	req.RemoteAddr = "203.0.113.9:5000"
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("status = %d, want 202 with an empty allowlist", w.Code)
+	}
+}
+
+func TestParsePrefixes_Invalid(t *testing.T) {
+	t.Parallel()
+	if _, err := ParsePrefixes([]string{"10.0.0.0/8", "not-a-cidr"}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestHandleIngest_AuditLog(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	audit, err := store.OpenAuditLog(path, false, []string{"id", "hook_type"})
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	defer audit.Close()
+
+	failing := false
+	ms := &mockStore{indexFn: func(ctx context.Context, doc store.Document) error {
+		if failing {
+			return fmt.Errorf("meili down")
+		}
+		return nil
+	}}
+	srv := New(ms)
+	srv.SetAuditLog(audit)
+
+	for _, fail := range []bool{false, true} {
+		failing = fail
+		body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":{}}`
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("audit lines = %d, want 1 (failed index not recorded): %s", len(lines), raw)
+	}
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("decode audit line: %v", err)
+	}
+	if rec["hook_type"] != "Stop" || rec["id"] == nil || len(rec) != 2 {
+		t.Errorf("audit record = %v", rec)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	var stats map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats["audit_errors"] != float64(0) {
+		t.Errorf("audit_errors = %v, want 0", stats["audit_errors"])
+	}
+}
+
+// metricsStore is a mockStore that also implements store.MetricsProvider.
+type metricsStore struct {
+	mockStore
+	hist *metrics.Histogram
+}
+
+func (m *metricsStore) Metrics() []metrics.Metric { return []metrics.Metric{m.hist} }
+
+func TestHandleMetrics(t *testing.T) {
+	t.Parallel()
+	ms := &metricsStore{hist: metrics.NewHistogram("store_seconds", "Store latency.", "index", nil)}
+	ms.hist.Observe("main", 0.2)
+	srv := New(ms)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `store_seconds_count{index="main"} 1`) {
+		t.Errorf("body = %s, want store histogram", w.Body.String())
+	}
+}
+
+func TestHandleIngest_ClockSkew(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-10 * time.Second), now.Add(2 * time.Hour), now.Add(-time.Minute)} {
+		body := `{"hook_type":"Stop","timestamp":"` + ts.Format(time.RFC3339Nano) + `","data":{}}`
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want 202", w.Code)
+		}
+	}
+	// No timestamp: not observed.
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"hook_type":"Stop"}`)))
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`hooks_store_clock_skew_seconds_count{direction="ahead"} 1`,
+		`hooks_store_clock_skew_seconds_count{direction="behind"} 2`,
+		`hooks_store_clock_skew_seconds_bucket{direction="behind",le="30"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var stats map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&stats)
+	if max, _ := stats["max_clock_skew_seconds"].(float64); max < 7190 || max > 7200 {
+		t.Errorf("max_clock_skew_seconds = %v, want about 7200", stats["max_clock_skew_seconds"])
+	}
+}
+
+func TestHandleIngest_OnIngestUsage(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	var got IngestEvent
+	srv.SetOnIngest(func(evt IngestEvent) { got = evt })
+
+	body := `{"hook_type":"Stop","timestamp":"2026-02-25T14:30:00Z","data":{"input_tokens":1500,"output_tokens":500,"total_cost_usd":0.0042}}`
+	req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.CostUSD != 0.0042 || got.TotalTokens != 2000 {
+		t.Errorf("IngestEvent usage = ($%v, %d tokens), want ($0.0042, 2000)", got.CostUSD, got.TotalTokens)
+	}
+}
+
+func TestHandleIngest_StageError(t *testing.T) {
+	t.Parallel()
+	store.RegisterStage("ingest-test-reject", func(opts store.TransformOptions) store.TransformStage {
+		return func(doc *store.Document, evt hookevt.HookEvent) error {
+			if doc.ToolName == "Forbidden" {
+				return fmt.Errorf("tool not allowed")
+			}
+			return nil
+		}
+	})
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetTransformOptions(store.TransformOptions{Stages: []string{"extract-fields", "ingest-test-reject"}})
+
+	for _, tc := range []struct {
+		tool string
+		want int
+	}{
+		{"Forbidden", http.StatusUnprocessableEntity},
+		{"Write", http.StatusAccepted},
+	} {
+		body := `{"hook_type":"PreToolUse","data":{"tool_name":"` + tc.tool + `"}}`
+		for _, path := range []string{"/ingest", "/transform"} {
+			want := tc.want
+			if path == "/transform" && want == http.StatusAccepted {
+				want = http.StatusOK
+			}
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+			if w.Code != want {
+				t.Errorf("%s %s: status = %d, want %d", path, tc.tool, w.Code, want)
+			}
+			if want == http.StatusUnprocessableEntity && !strings.Contains(w.Body.String(), "ingest-test-reject") {
+				t.Errorf("%s: body %s does not name the stage", path, w.Body.String())
+			}
+		}
+	}
+	if len(ms.docs) != 1 || srv.errors.Load() != 1 {
+		t.Errorf("indexed %d docs, errors %d; want 1, 1", len(ms.docs), srv.errors.Load())
+	}
+}
+
+func TestHandleIngest_ValidateJSON(t *testing.T) {
+	t.Parallel()
+	store.RegisterStage("ingest-test-nan", func(opts store.TransformOptions) store.TransformStage {
+		return func(doc *store.Document, evt hookevt.HookEvent) error {
+			if doc.ToolName == "Bad" {
+				doc.Data = map[string]interface{}{"ratio": math.NaN()}
+			}
+			return nil
+		}
+	})
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetTransformOptions(store.TransformOptions{Stages: []string{"extract-fields", "ingest-test-nan"}})
+	post := func(tool string) *httptest.ResponseRecorder {
+		body := `{"hook_type":"PreToolUse","data":{"tool_name":"` + tool + `"}}`
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		return w
+	}
+
+	// Off by default: the document reaches the store.
+	if w := post("Bad"); w.Code != http.StatusAccepted || len(ms.docs) != 1 {
+		t.Fatalf("without validation: status %d, %d docs; want 202, 1", w.Code, len(ms.docs))
+	}
+
+	srv.SetValidateJSON(true)
+	w := post("Bad")
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "not valid JSON") {
+		t.Errorf("status = %d, body %s; want 422 naming the marshal failure", w.Code, w.Body.String())
+	}
+	if w := post("Write"); w.Code != http.StatusAccepted {
+		t.Errorf("valid document: status = %d, want 202", w.Code)
+	}
+	if len(ms.docs) != 2 || srv.unmarshalable.Load() != 1 || srv.errors.Load() != 1 {
+		t.Errorf("indexed %d docs, unmarshalable %d, errors %d; want 2, 1, 1",
+			len(ms.docs), srv.unmarshalable.Load(), srv.errors.Load())
+	}
+
+	var stats map[string]interface{}
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	if stats["unmarshalable"] != float64(1) {
+		t.Errorf("stats unmarshalable = %v, want 1", stats["unmarshalable"])
+	}
+}
+
+// asyncStore is a mockStore that reports asynchronous indexing.
+type asyncStore struct {
+	mockStore
+}
+
+func (a *asyncStore) IndexesAsync() bool { return true }
+
+func TestHandleIngest_DetailedStatus(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		es       store.EventStore
+		detailed bool
+		code     int
+		status   string
+	}{
+		{"default", &mockStore{}, false, http.StatusAccepted, "accepted"},
+		{"sync store", &mockStore{}, true, http.StatusOK, "indexed"},
+		{"async store", &asyncStore{}, true, http.StatusOK, "queued"},
+		{"wrapped async store", store.NewFirstSeenStore(&asyncStore{}, 10), true, http.StatusOK, "queued"},
+		{"async store, default", &asyncStore{}, false, http.StatusAccepted, "accepted"},
+	} {
+		srv := New(tc.es)
+		srv.SetDetailedStatus(tc.detailed)
+		body := `{"hook_type":"Stop","data":{}}`
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != tc.code || resp["status"] != tc.status || resp["id"] == "" {
+			t.Errorf("%s: got %d %v, want %d %s", tc.name, w.Code, resp, tc.code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// streamBuffer is how many events each live subscriber may fall behind
+	// before further events are dropped for it.
+	streamBuffer = 64
+
+	// sseHeartbeat is how often an idle /events stream sends a comment line,
+	// keeping proxies from timing the connection out.
+	sseHeartbeat = 15 * time.Second
+)
+
+// eventHub fans ingested events out to live subscribers (/events, /ws). Each
+// subscriber has its own buffered channel and filter; publish never blocks,
+// so a slow client loses events instead of stalling ingestion.
+type eventHub struct {
+	mu      sync.Mutex
+	subs    map[*subscriber]struct{}
+	closed  bool
+	dropped int64
+}
+
+// subscriber is one live client of the hub.
+type subscriber struct {
+	ch     chan IngestEvent
+	filter streamFilter // guarded by eventHub.mu
+}
+
+// streamFilter selects the events a subscriber receives. Empty fields match
+// anything; the zero value passes every event.
+type streamFilter struct {
+	SessionID string `json:"session_id,omitempty"`
+	HookType  string `json:"hook_type,omitempty"`
+	ToolName  string `json:"tool_name,omitempty"`
+}
+
+func (f streamFilter) matches(evt IngestEvent) bool {
+	return (f.SessionID == "" || f.SessionID == evt.SessionID) &&
+		(f.HookType == "" || f.HookType == evt.HookType) &&
+		(f.ToolName == "" || f.ToolName == evt.ToolName)
+}
+
+// subscribe registers a new subscriber. Its channel is closed by unsubscribe
+// or when the hub closes; on a closed hub it is returned already closed.
+func (h *eventHub) subscribe() *subscriber {
+	sub := &subscriber{ch: make(chan IngestEvent, streamBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(sub.ch)
+		return sub
+	}
+	if h.subs == nil {
+		h.subs = make(map[*subscriber]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe removes sub and closes its channel. Safe to call after close.
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// setFilter replaces sub's filter; it applies to events published after it
+// returns.
+func (h *eventHub) setFilter(sub *subscriber, f streamFilter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub.filter = f
+}
+
+// publish delivers evt to every subscriber whose filter matches and that has
+// room in its buffer, and counts a drop for each matching one without.
+func (h *eventHub) publish(evt IngestEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.filter.matches(evt) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+			h.dropped++
+		}
+	}
+}
+
+// close ends every subscription and rejects new ones.
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// stats returns the current subscriber count and total dropped events.
+func (h *eventHub) stats() (subscribers int, dropped int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs), h.dropped
+}
+
+// handleEvents serves GET /events — a Server-Sent Events stream with one
+// `data:` frame (a JSON IngestEvent) per ingested event. Events a client is
+// too slow to take are dropped for that client only.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonError(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	// The stream outlives the server's WriteTimeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	sub := s.hub.subscribe()
+	defer s.hub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case evt, ok := <-sub.ch:
+			if !ok {
+				return // server shutting down
+			}
+			data, err := json.Marshal(evt)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
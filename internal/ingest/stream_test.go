@@ -0,0 +1,137 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFrame returns the payload of the next `data:` line, skipping comments
+// and blank lines.
+func readFrame(t *testing.T, sc *bufio.Scanner) string {
+	t.Helper()
+	for sc.Scan() {
+		line := sc.Text()
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			return data
+		}
+	}
+	t.Fatalf("stream ended: %v", sc.Err())
+	return ""
+}
+
+// openStream connects to /events and waits for the initial comment, so the
+// subscription is registered before the caller ingests.
+func openStream(t *testing.T, url string) (*http.Response, *bufio.Scanner) {
+	t.Helper()
+	resp, err := http.Get(url + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	sc := bufio.NewScanner(resp.Body)
+	if !sc.Scan() || !strings.HasPrefix(sc.Text(), ":") {
+		t.Fatalf("want initial comment, got %q", sc.Text())
+	}
+	return resp, sc
+}
+
+func TestHandleEvents_Stream(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	defer srv.CloseStreams() // ends the streams so ts.Close can return
+
+	_, sc1 := openStream(t, ts.URL)
+	_, sc2 := openStream(t, ts.URL)
+
+	body := `{"hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00Z","data":{"tool_name":"Write","session_id":"s1"}}`
+	resp, err := http.Post(ts.URL+"/ingest", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for i, sc := range []*bufio.Scanner{sc1, sc2} {
+		var evt IngestEvent
+		if err := json.Unmarshal([]byte(readFrame(t, sc)), &evt); err != nil {
+			t.Fatalf("subscriber %d: %v", i, err)
+		}
+		if evt.HookType != "PreToolUse" || evt.ToolName != "Write" || evt.SessionID != "s1" {
+			t.Errorf("subscriber %d got %+v", i, evt)
+		}
+		if evt.BodySize != len(body) {
+			t.Errorf("subscriber %d BodySize = %d, want %d", i, evt.BodySize, len(body))
+		}
+	}
+}
+
+func TestHandleEvents_CloseStreams(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	_, sc := openStream(t, ts.URL)
+	srv.CloseStreams()
+
+	done := make(chan struct{})
+	go func() {
+		for sc.Scan() {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after CloseStreams")
+	}
+}
+
+func TestHandleEvents_MethodNotAllowed(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	req := httptest.NewRequest(http.MethodPost, "/events", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+}
+
+func TestEventHub_DropsForSlowSubscriber(t *testing.T) {
+	t.Parallel()
+	var h eventHub
+	slow := h.subscribe()
+	fast := h.subscribe()
+
+	for i := 0; i < streamBuffer+10; i++ {
+		h.publish(IngestEvent{HookType: "Stop"})
+		<-fast.ch // fast keeps up throughout
+	}
+
+	if n := len(slow.ch); n != streamBuffer {
+		t.Errorf("slow buffered = %d, want %d", n, streamBuffer)
+	}
+	subs, dropped := h.stats()
+	if subs != 2 || dropped != 10 {
+		t.Errorf("stats = (%d, %d), want (2, 10)", subs, dropped)
+	}
+
+	h.unsubscribe(slow)
+	h.unsubscribe(slow) // idempotent
+	if subs, _ := h.stats(); subs != 1 {
+		t.Errorf("subscribers after unsubscribe = %d, want 1", subs)
+	}
+}
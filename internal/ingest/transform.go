@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"hooks-store/internal/store"
+)
+
+// handleTransform previews what /ingest would store for a HookEvent: the body
+// gets the same validation (including the default hook type), then the
+// configured transform and source label, and the resulting Document is
+// returned. Nothing is indexed, counted, or published, so hook authors can
+// iterate on their payloads against a live server.
+func (s *Server) handleTransform(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyLen+1))
+	if err != nil {
+		jsonError(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	evt, err := DecodeEvent(body, s.defaultHookType)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		jsonError(w, err.Error(), status)
+		return
+	}
+
+	var doc store.Document
+	err = func() (err error) {
+		defer recoverPanic(&err)
+		doc, err = s.toDocument(evt, r, false)
+		return err
+	}()
+	if pe, ok := err.(*panicError); ok {
+		fmt.Fprintf(os.Stderr, "Error: recovered transform %v\n%s", pe.value, pe.stack)
+		jsonError(w, pe.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, doc)
+}
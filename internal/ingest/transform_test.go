@@ -0,0 +1,92 @@
+package ingest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hooks-store/internal/store"
+)
+
+func postTransform(t *testing.T, srv *Server, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/transform", strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleTransform(t *testing.T) {
+	t.Parallel()
+	ms := &mockStore{}
+	srv := New(ms)
+	srv.SetSourceLabel("laptop")
+	var ingested int
+	srv.SetOnIngest(func(IngestEvent) { ingested++ })
+
+	body := `{"hook_type":"PostToolUse","timestamp":"2026-02-25T14:30:00Z","data":{"session_id":"s1","tool_name":"Bash","tool_input":{"command":"ls"}}}`
+	w := postTransform(t, srv, body, http.Header{"X-Source": {"ci"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	var doc store.Document
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.HookType != "PostToolUse" || doc.ToolName != "Bash" || doc.SessionID != "s1" {
+		t.Errorf("doc = %+v, want PostToolUse/Bash/s1", doc)
+	}
+	if doc.ID == "" || doc.TimestampUnix != 1772029800 {
+		t.Errorf("id = %q, timestamp_unix = %d", doc.ID, doc.TimestampUnix)
+	}
+	if doc.Source != "ci" {
+		t.Errorf("source = %q, want X-Source ci", doc.Source)
+	}
+
+	// Nothing is stored, counted, or published.
+	if len(ms.docs) != 0 || ingested != 0 {
+		t.Errorf("indexed %d docs, onIngest called %d times; want none", len(ms.docs), ingested)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	var stats map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats["ingested"] != float64(0) || stats["errors"] != float64(0) {
+		t.Errorf("stats = %v, want no ingested or errors", stats)
+	}
+}
+
+func TestHandleTransform_Invalid(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"get", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"empty body", http.MethodPost, "", http.StatusBadRequest},
+		{"invalid json", http.MethodPost, "{", http.StatusBadRequest},
+		{"missing hook_type", http.MethodPost, `{"data":{}}`, http.StatusBadRequest},
+		{"too large", http.MethodPost, `{"hook_type":"x","data":{"s":"` + strings.Repeat("a", maxBodyLen) + `"}}`, http.StatusRequestEntityTooLarge},
+		{"panicking transform", http.MethodPost, `{"hook_type":"` + panicHookType + `","data":{"label":42}}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/transform", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// wsWriteTimeout bounds each frame write, so a stalled client cannot pin its
+// handler.
+const wsWriteTimeout = 10 * time.Second
+
+// wsFrame is one server→client WebSocket message. Type is "event" (Event
+// set), "filter" (Filter set: the filter now in effect), or "error".
+type wsFrame struct {
+	Type   string        `json:"type"`
+	Event  *IngestEvent  `json:"event,omitempty"`
+	Filter *streamFilter `json:"filter,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// wsRequest is one client→server WebSocket message. Filter replaces the
+// connection's filter; an empty object clears it.
+type wsRequest struct {
+	Filter *streamFilter `json:"filter"`
+}
+
+// handleWS serves GET /ws — a WebSocket carrying the same live events as
+// /events, wrapped as {"type":"event","event":{...}}. The client narrows the
+// stream by sending {"filter":{"session_id":..,"hook_type":..,"tool_name":..}};
+// the server confirms with {"type":"filter",...} and applies it to every
+// later event. Unparseable messages get {"type":"error"} and are otherwise
+// ignored.
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The connection outlives the server's read and write timeouts.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return // Accept has already written the error response
+	}
+	defer c.CloseNow()
+
+	sub := s.hub.subscribe()
+	defer s.hub.unsubscribe(sub)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		s.readWSRequests(ctx, c, sub)
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case evt, ok := <-sub.ch:
+			if !ok {
+				c.Close(websocket.StatusGoingAway, "server shutting down")
+				return
+			}
+			if err := writeWS(ctx, c, wsFrame{Type: "event", Event: &evt}); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			pingCtx, pingCancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err := c.Ping(pingCtx)
+			pingCancel()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// readWSRequests applies client filter messages until the connection fails
+// or ctx ends. Once the client has sent more than the stream read limit in
+// total, it gets an error frame and the connection is closed with a policy
+// violation.
+func (s *Server) readWSRequests(ctx context.Context, c *websocket.Conn, sub *subscriber) {
+	var total int64
+	for {
+		_, data, err := c.Read(ctx)
+		if err != nil {
+			return
+		}
+		total += int64(len(data))
+		if s.streamReadLimit > 0 && total > s.streamReadLimit {
+			writeWS(ctx, c, wsFrame{Type: "error", Error: "stream byte limit exceeded"})
+			c.Close(websocket.StatusPolicyViolation, "stream byte limit exceeded")
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil || req.Filter == nil {
+			if writeWS(ctx, c, wsFrame{Type: "error", Error: `want {"filter": {...}}`}) != nil {
+				return
+			}
+			continue
+		}
+		s.hub.setFilter(sub, *req.Filter)
+		if writeWS(ctx, c, wsFrame{Type: "filter", Filter: req.Filter}) != nil {
+			return
+		}
+	}
+}
+
+// writeWS sends one frame, giving up after wsWriteTimeout.
+func writeWS(ctx context.Context, c *websocket.Conn, f wsFrame) error {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, c, f)
+}
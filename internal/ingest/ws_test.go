@@ -0,0 +1,195 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+func dialWS(t *testing.T, ctx context.Context, url string) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(url, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.CloseNow() })
+	return c
+}
+
+func postEvent(t *testing.T, url, body string) {
+	t.Helper()
+	resp, err := http.Post(url+"/ingest", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("ingest status = %d", resp.StatusCode)
+	}
+}
+
+func readWSFrame(t *testing.T, ctx context.Context, c *websocket.Conn) wsFrame {
+	t.Helper()
+	var f wsFrame
+	if err := wsjson.Read(ctx, c, &f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// waitSubscribers blocks until the hub has n subscribers, so events posted
+// afterwards reach every connection.
+func waitSubscribers(t *testing.T, srv *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if got, _ := srv.hub.stats(); got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscribers never reached %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandleWS_Filter(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	defer srv.CloseStreams()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	all := dialWS(t, ctx, ts.URL)
+	focused := dialWS(t, ctx, ts.URL)
+	waitSubscribers(t, srv, 2)
+
+	if err := wsjson.Write(ctx, focused, map[string]any{"filter": map[string]string{"session_id": "s2"}}); err != nil {
+		t.Fatal(err)
+	}
+	ack := readWSFrame(t, ctx, focused)
+	if ack.Type != "filter" || ack.Filter == nil || ack.Filter.SessionID != "s2" {
+		t.Fatalf("ack = %+v, want filter for s2", ack)
+	}
+
+	postEvent(t, ts.URL, `{"hook_type":"PreToolUse","data":{"session_id":"s1"}}`)
+	postEvent(t, ts.URL, `{"hook_type":"Stop","data":{"session_id":"s2"}}`)
+
+	for _, want := range []string{"s1", "s2"} {
+		f := readWSFrame(t, ctx, all)
+		if f.Type != "event" || f.Event.SessionID != want {
+			t.Errorf("unfiltered got %+v, want event for %s", f, want)
+		}
+	}
+	f := readWSFrame(t, ctx, focused)
+	if f.Type != "event" || f.Event.SessionID != "s2" || f.Event.HookType != "Stop" {
+		t.Errorf("filtered got %+v, want Stop for s2", f)
+	}
+}
+
+func TestHandleWS_InvalidRequest(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	defer srv.CloseStreams()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := dialWS(t, ctx, ts.URL)
+
+	if err := c.Write(ctx, websocket.MessageText, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if f := readWSFrame(t, ctx, c); f.Type != "error" {
+		t.Errorf("got %+v, want error frame", f)
+	}
+	// The connection stays usable.
+	if err := wsjson.Write(ctx, c, map[string]any{"filter": map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if f := readWSFrame(t, ctx, c); f.Type != "filter" {
+		t.Errorf("got %+v, want filter ack", f)
+	}
+}
+
+func TestHandleWS_StreamReadLimit(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	srv.SetStreamReadLimit(80)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	defer srv.CloseStreams()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := dialWS(t, ctx, ts.URL)
+
+	// 31 bytes per message: the first two fit, the third crosses 80.
+	msg := map[string]any{"filter": map[string]string{"session_id": "s1"}}
+	for i := 0; i < 2; i++ {
+		if err := wsjson.Write(ctx, c, msg); err != nil {
+			t.Fatal(err)
+		}
+		if f := readWSFrame(t, ctx, c); f.Type != "filter" {
+			t.Fatalf("message %d: got %+v, want filter ack", i+1, f)
+		}
+	}
+	if err := wsjson.Write(ctx, c, msg); err != nil {
+		t.Fatal(err)
+	}
+	if f := readWSFrame(t, ctx, c); f.Type != "error" || !strings.Contains(f.Error, "limit") {
+		t.Errorf("got %+v, want limit error frame", f)
+	}
+	_, _, err := c.Read(ctx)
+	if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+		t.Errorf("read err = %v, want close status policy violation", err)
+	}
+	// The server cleaned up the subscription.
+	waitSubscribers(t, srv, 0)
+}
+
+func TestHandleWS_CloseStreams(t *testing.T) {
+	t.Parallel()
+	srv := New(&mockStore{})
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := dialWS(t, ctx, ts.URL)
+	waitSubscribers(t, srv, 1)
+	srv.CloseStreams()
+
+	_, _, err := c.Read(ctx)
+	if websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("read err = %v, want close status going away", err)
+	}
+}
+
+func TestEventHub_Filter(t *testing.T) {
+	t.Parallel()
+	var h eventHub
+	sub := h.subscribe()
+	h.setFilter(sub, streamFilter{HookType: "Stop"})
+
+	h.publish(IngestEvent{HookType: "PreToolUse"})
+	h.publish(IngestEvent{HookType: "Stop", SessionID: "s1"})
+	if n := len(sub.ch); n != 1 {
+		t.Fatalf("buffered = %d, want 1", n)
+	}
+	if evt := <-sub.ch; evt.SessionID != "s1" {
+		t.Errorf("got %+v", evt)
+	}
+	if _, dropped := h.stats(); dropped != 0 {
+		t.Errorf("dropped = %d; filtered events are not drops", dropped)
+	}
+}
@@ -0,0 +1,227 @@
+// Package metrics implements the small subset of Prometheus metric types
+// hooks-store exposes at /metrics, written in the Prometheus text format.
+// It avoids a dependency on the Prometheus client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram upper bounds in seconds, matching the
+// Prometheus client's defaults.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metric is anything that can write itself in the Prometheus text format.
+type Metric interface {
+	WritePrometheus(w io.Writer)
+}
+
+// Registry collects metrics for exposition. Safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []Metric
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds metrics to the registry, in exposition order.
+func (r *Registry) Register(ms ...Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, ms...)
+}
+
+// WritePrometheus writes every registered metric.
+func (r *Registry) WritePrometheus(w io.Writer) {
+	r.mu.Lock()
+	ms := append([]Metric(nil), r.metrics...)
+	r.mu.Unlock()
+	for _, m := range ms {
+		m.WritePrometheus(w)
+	}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+// Histogram is a Prometheus histogram partitioned by a single label.
+// Safe for concurrent use.
+type Histogram struct {
+	name, help, label string
+	buckets           []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries is one label value's observations. counts are per bucket,
+// not cumulative; the +Inf bucket is count.
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram returns a histogram named name with one label, label, and the
+// given bucket upper bounds (sorted ascending; nil for DefaultBuckets).
+func NewHistogram(name, help, label string, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &Histogram{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+}
+
+// Observe records v for the series with the given label value.
+func (h *Histogram) Observe(labelValue string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// WritePrometheus writes the histogram with cumulative buckets, series
+// sorted by label value. A histogram with no observations writes only its
+// HELP and TYPE lines.
+func (h *Histogram) WritePrometheus(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	values := make([]string, 0, len(h.series))
+	for v := range h.series {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		s := h.series[v]
+		lv := escapeLabel(v)
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s=\"%s\",le=\"%s\"} %d\n", h.name, h.label, lv, formatFloat(le), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=\"%s\",le=\"+Inf\"} %d\n", h.name, h.label, lv, s.count)
+		fmt.Fprintf(w, "%s_sum{%s=\"%s\"} %s\n", h.name, h.label, lv, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s=\"%s\"} %d\n", h.name, h.label, lv, s.count)
+	}
+}
+
+// formatFloat formats f as Prometheus expects (shortest representation).
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value for the text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// Counter is a Prometheus counter partitioned by a single label, or
+// unlabeled when label is "". Safe for concurrent use.
+type Counter struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+// NewCounter returns a counter named name with one label, label ("" for
+// none).
+func NewCounter(name, help, label string) *Counter {
+	return &Counter{name: name, help: help, label: label, series: map[string]float64{}}
+}
+
+// Add adds delta to the series with the given label value.
+func (c *Counter) Add(labelValue string, delta float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.series[labelValue] += delta
+}
+
+// WritePrometheus writes the counter, series sorted by label value.
+func (c *Counter) WritePrometheus(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeScalar(w, c.name, c.help, "counter", c.label, c.series)
+}
+
+// Gauge is a Prometheus gauge partitioned by a single label, or unlabeled
+// when label is "". Safe for concurrent use.
+type Gauge struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+// NewGauge returns a gauge named name with one label, label ("" for none).
+func NewGauge(name, help, label string) *Gauge {
+	return &Gauge{name: name, help: help, label: label, series: map[string]float64{}}
+}
+
+// Set sets the series with the given label value to v.
+func (g *Gauge) Set(labelValue string, v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series[labelValue] = v
+}
+
+// WritePrometheus writes the gauge, series sorted by label value.
+func (g *Gauge) WritePrometheus(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeScalar(w, g.name, g.help, "gauge", g.label, g.series)
+}
+
+// writeScalar writes a single-value metric type. An unlabeled metric writes
+// its "" series without braces.
+func writeScalar(w io.Writer, name, help, typ, label string, series map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	values := make([]string, 0, len(series))
+	for v := range series {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		if label == "" {
+			fmt.Fprintf(w, "%s %s\n", name, formatFloat(series[v]))
+			continue
+		}
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %s\n", name, label, escapeLabel(v), formatFloat(series[v]))
+	}
+}
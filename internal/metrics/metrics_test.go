@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHistogram_WritePrometheus(t *testing.T) {
+	t.Parallel()
+	h := NewHistogram("test_seconds", "Test latency.", "index", []float64{0.1, 1})
+	h.Observe("main", 0.05)
+	h.Observe("main", 0.5)
+	h.Observe("main", 3)
+	h.Observe(`odd"name`, 0.1) // le is inclusive
+
+	var b strings.Builder
+	h.WritePrometheus(&b)
+	want := `# HELP test_seconds Test latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{index="main",le="0.1"} 1
+test_seconds_bucket{index="main",le="1"} 2
+test_seconds_bucket{index="main",le="+Inf"} 3
+test_seconds_sum{index="main"} 3.55
+test_seconds_count{index="main"} 3
+test_seconds_bucket{index="odd\"name",le="0.1"} 1
+test_seconds_bucket{index="odd\"name",le="1"} 1
+test_seconds_bucket{index="odd\"name",le="+Inf"} 1
+test_seconds_sum{index="odd\"name"} 0.1
+test_seconds_count{index="odd\"name"} 1
+`
+	if b.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHistogram_Concurrent(t *testing.T) {
+	t.Parallel()
+	h := NewHistogram("c_seconds", "c", "index", nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Observe("main", 0.01)
+		}()
+	}
+	wg.Wait()
+
+	var b strings.Builder
+	h.WritePrometheus(&b)
+	if !strings.Contains(b.String(), `c_seconds_count{index="main"} 50`) {
+		t.Errorf("output = %s, want count 50", b.String())
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	r.Register(NewHistogram("a_seconds", "A.", "index", nil), NewHistogram("b_seconds", "B.", "index", nil))
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := w.Body.String()
+	if a, b := strings.Index(body, "# TYPE a_seconds"), strings.Index(body, "# TYPE b_seconds"); a < 0 || b < a {
+		t.Errorf("body = %s, want a then b", body)
+	}
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", w.Code)
+	}
+}
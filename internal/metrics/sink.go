@@ -0,0 +1,100 @@
+package metrics
+
+import "sync"
+
+// Sink receives the measurements hooks-store makes while serving, so they
+// can be sent somewhere other than /metrics (StatsD, logs, a test). Metrics
+// are identified by name and carry at most one label; labelValue is "" for
+// unlabeled metrics. Implementations must be safe for concurrent use and
+// must not block.
+type Sink interface {
+	IncCounter(name, labelValue string)
+	ObserveHistogram(name, labelValue string, v float64)
+	SetGauge(name, labelValue string, v float64)
+}
+
+// Nop is a Sink that discards every measurement.
+var Nop Sink = nopSink{}
+
+type nopSink struct{}
+
+func (nopSink) IncCounter(name, labelValue string)                  {}
+func (nopSink) ObserveHistogram(name, labelValue string, v float64) {}
+func (nopSink) SetGauge(name, labelValue string, v float64)         {}
+
+// PrometheusSink is a Sink backed by metrics in a Registry, for /metrics.
+// Metrics must be declared before use, since Prometheus needs their help
+// text, label name, and buckets; measurements of an undeclared name, or of a
+// name declared as another type, are dropped. Safe for concurrent use.
+type PrometheusSink struct {
+	reg *Registry
+
+	mu         sync.RWMutex
+	counters   map[string]*Counter
+	histograms map[string]*Histogram
+	gauges     map[string]*Gauge
+}
+
+// NewPrometheusSink returns a sink registering its metrics in reg.
+func NewPrometheusSink(reg *Registry) *PrometheusSink {
+	return &PrometheusSink{
+		reg:        reg,
+		counters:   map[string]*Counter{},
+		histograms: map[string]*Histogram{},
+		gauges:     map[string]*Gauge{},
+	}
+}
+
+// DeclareCounter registers a counter (see NewCounter).
+func (p *PrometheusSink) DeclareCounter(name, help, label string) {
+	c := NewCounter(name, help, label)
+	p.mu.Lock()
+	p.counters[name] = c
+	p.mu.Unlock()
+	p.reg.Register(c)
+}
+
+// DeclareHistogram registers a histogram (see NewHistogram).
+func (p *PrometheusSink) DeclareHistogram(name, help, label string, buckets []float64) {
+	h := NewHistogram(name, help, label, buckets)
+	p.mu.Lock()
+	p.histograms[name] = h
+	p.mu.Unlock()
+	p.reg.Register(h)
+}
+
+// DeclareGauge registers a gauge (see NewGauge).
+func (p *PrometheusSink) DeclareGauge(name, help, label string) {
+	g := NewGauge(name, help, label)
+	p.mu.Lock()
+	p.gauges[name] = g
+	p.mu.Unlock()
+	p.reg.Register(g)
+}
+
+func (p *PrometheusSink) IncCounter(name, labelValue string) {
+	p.mu.RLock()
+	c := p.counters[name]
+	p.mu.RUnlock()
+	if c != nil {
+		c.Add(labelValue, 1)
+	}
+}
+
+func (p *PrometheusSink) ObserveHistogram(name, labelValue string, v float64) {
+	p.mu.RLock()
+	h := p.histograms[name]
+	p.mu.RUnlock()
+	if h != nil {
+		h.Observe(labelValue, v)
+	}
+}
+
+func (p *PrometheusSink) SetGauge(name, labelValue string, v float64) {
+	p.mu.RLock()
+	g := p.gauges[name]
+	p.mu.RUnlock()
+	if g != nil {
+		g.Set(labelValue, v)
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrometheusSink(t *testing.T) {
+	t.Parallel()
+	reg := NewRegistry()
+	p := NewPrometheusSink(reg)
+	p.DeclareCounter("events_total", "Events.", "kind")
+	p.DeclareGauge("skew_seconds", "Skew.", "")
+	p.DeclareHistogram("latency_seconds", "Latency.", "route", []float64{1})
+
+	p.IncCounter("events_total", "a")
+	p.IncCounter("events_total", "a")
+	p.IncCounter("events_total", `b"`)
+	p.SetGauge("skew_seconds", "", -2.5)
+	p.SetGauge("skew_seconds", "", 3)
+	p.ObserveHistogram("latency_seconds", "/x", 0.5)
+
+	// Undeclared names and mismatched types are dropped.
+	p.IncCounter("unknown_total", "a")
+	p.SetGauge("events_total", "a", 100)
+	p.ObserveHistogram("skew_seconds", "", 1)
+
+	var b strings.Builder
+	reg.WritePrometheus(&b)
+	want := `# HELP events_total Events.
+# TYPE events_total counter
+events_total{kind="a"} 2
+events_total{kind="b\""} 1
+# HELP skew_seconds Skew.
+# TYPE skew_seconds gauge
+skew_seconds 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/x",le="1"} 1
+latency_seconds_bucket{route="/x",le="+Inf"} 1
+latency_seconds_sum{route="/x"} 0.5
+latency_seconds_count{route="/x"} 1
+`
+	if b.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestNop(t *testing.T) {
+	t.Parallel()
+	// Nop accepts anything without panicking.
+	Nop.IncCounter("a", "")
+	Nop.ObserveHistogram("b", "x", 1)
+	Nop.SetGauge("c", "", 2)
+}
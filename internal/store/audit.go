@@ -0,0 +1,198 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditLog appends each successfully indexed Document to a local NDJSON file,
+// one line per document. It is an independent record of what was ingested:
+// the file is only ever appended to, never rewritten, and is unaffected by
+// deletes or updates in the searchable store.
+type AuditLog struct {
+	mu     sync.Mutex
+	f      *os.File
+	fsync  bool
+	fields []string
+
+	// Flush policy (AuditOptions.FlushCount/FlushInterval): when buf is
+	// set, lines are buffered and flushed+fsynced every flushCount
+	// records or every flushInterval, whichever comes first.
+	buf        *bufio.Writer
+	flushCount int
+	pending    int
+	flushErr   error // from a background flush, returned by the next Write
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// AuditOptions tunes OpenAuditLogWithOptions. The zero value writes every
+// line straight to the file without fsync.
+type AuditOptions struct {
+	// Fsync flushes every Write to disk before returning.
+	Fsync bool
+
+	// Fields limits each line to these JSON field names of Document.
+	// Empty records the whole document.
+	Fields []string
+
+	// FlushCount buffers lines and flushes and fsyncs them every
+	// FlushCount records. Zero disables the count boundary.
+	FlushCount int
+
+	// FlushInterval buffers lines and flushes and fsyncs any pending ones
+	// every FlushInterval. Zero disables the time boundary. With both set,
+	// whichever boundary comes first flushes. Buffered lines are lost if
+	// the process dies before a flush; Close flushes them.
+	FlushInterval time.Duration
+}
+
+// OpenAuditLog opens (or creates) path in append mode. With fsync, every
+// Write is flushed to disk before returning. A non-empty fields limits each
+// line to those JSON field names of Document; empty records the whole
+// document.
+func OpenAuditLog(path string, fsync bool, fields []string) (*AuditLog, error) {
+	return OpenAuditLogWithOptions(path, AuditOptions{Fsync: fsync, Fields: fields})
+}
+
+// OpenAuditLogWithOptions is OpenAuditLog with a flush policy.
+func OpenAuditLogWithOptions(path string, opts AuditOptions) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	a := &AuditLog{f: f, fsync: opts.Fsync, fields: opts.Fields}
+	if opts.FlushCount > 0 || opts.FlushInterval > 0 {
+		a.buf = bufio.NewWriterSize(f, 64<<10)
+		a.flushCount = opts.FlushCount
+	}
+	if opts.FlushInterval > 0 {
+		a.stop = make(chan struct{})
+		a.done = make(chan struct{})
+		go a.flushLoop(opts.FlushInterval)
+	}
+	return a, nil
+}
+
+// Write appends doc as one JSON line. Safe for concurrent use; lines are
+// never interleaved. With a flush policy the line may only be buffered; an
+// error from an earlier background flush is returned here.
+func (a *AuditLog) Write(doc Document) error {
+	line, err := a.encode(doc)
+	if err != nil {
+		return fmt.Errorf("encode audit record %s: %w", doc.ID, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.buf != nil {
+		return a.writeBuffered(doc.ID, line)
+	}
+	if _, err := a.f.Write(line); err != nil {
+		return fmt.Errorf("write audit record %s: %w", doc.ID, err)
+	}
+	if a.fsync {
+		if err := a.f.Sync(); err != nil {
+			return fmt.Errorf("sync audit log: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeBuffered buffers line and flushes at the count boundary. Callers
+// hold a.mu.
+func (a *AuditLog) writeBuffered(id string, line []byte) error {
+	if err := a.flushErr; err != nil {
+		a.flushErr = nil
+		return err
+	}
+	if _, err := a.buf.Write(line); err != nil {
+		return fmt.Errorf("write audit record %s: %w", id, err)
+	}
+	a.pending++
+	if a.flushCount > 0 && a.pending >= a.flushCount {
+		return a.flush()
+	}
+	return nil
+}
+
+// flush writes buffered lines to the file and fsyncs it. Callers hold a.mu.
+func (a *AuditLog) flush() error {
+	if a.pending == 0 {
+		return nil
+	}
+	if err := a.buf.Flush(); err != nil {
+		return fmt.Errorf("flush audit log: %w", err)
+	}
+	a.pending = 0
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("sync audit log: %w", err)
+	}
+	return nil
+}
+
+// flushLoop flushes pending lines every interval until Close.
+func (a *AuditLog) flushLoop(interval time.Duration) {
+	defer close(a.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-t.C:
+			a.mu.Lock()
+			if err := a.flush(); err != nil {
+				a.flushErr = err
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// encode marshals doc, or the configured subset of its fields, followed by
+// a newline.
+func (a *AuditLog) encode(doc Document) ([]byte, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.fields) > 0 {
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &all); err != nil {
+			return nil, err
+		}
+		subset := make(map[string]json.RawMessage, len(a.fields))
+		for _, f := range a.fields {
+			if v, ok := all[f]; ok {
+				subset[f] = v
+			}
+		}
+		if raw, err = json.Marshal(subset); err != nil {
+			return nil, err
+		}
+	}
+	return append(raw, '\n'), nil
+}
+
+// Close flushes any buffered lines and closes the underlying file.
+func (a *AuditLog) Close() error {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var err error
+	if a.buf != nil {
+		err = a.flush()
+	}
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readAuditLines decodes every line of an audit log.
+func readAuditLines(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestAuditLog_Append(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+
+	// Reopening must append, not truncate.
+	for _, id := range []string{"a", "b"} {
+		a, err := OpenAuditLog(path, true, nil)
+		if err != nil {
+			t.Fatalf("OpenAuditLog: %v", err)
+		}
+		if err := a.Write(Document{ID: id, HookType: "Stop"}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		a.Close()
+	}
+
+	lines := readAuditLines(t, path)
+	if len(lines) != 2 || lines[0]["id"] != "a" || lines[1]["id"] != "b" {
+		t.Fatalf("lines = %v", lines)
+	}
+	if lines[0]["hook_type"] != "Stop" {
+		t.Errorf("full record missing hook_type: %v", lines[0])
+	}
+}
+
+func TestAuditLog_Fields(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	a, err := OpenAuditLog(path, false, []string{"id", "timestamp", "nonexistent"})
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	defer a.Close()
+	a.Write(Document{ID: "a", Timestamp: "2026-02-25T14:30:00Z", Prompt: "secret"})
+
+	lines := readAuditLines(t, path)
+	if len(lines) != 1 || len(lines[0]) != 2 || lines[0]["id"] != "a" || lines[0]["timestamp"] == nil {
+		t.Errorf("lines = %v, want only id and timestamp", lines)
+	}
+}
+
+func TestAuditLog_Concurrent(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	a, err := OpenAuditLog(path, false, nil)
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Write(Document{ID: "x", DataFlat: string(make([]byte, 4096))})
+		}()
+	}
+	wg.Wait()
+	a.Close()
+
+	if got := len(readAuditLines(t, path)); got != 50 {
+		t.Errorf("lines = %d, want 50", got)
+	}
+}
+
+func TestAuditLog_FlushCount(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	a, err := OpenAuditLogWithOptions(path, AuditOptions{FlushCount: 3})
+	if err != nil {
+		t.Fatalf("OpenAuditLogWithOptions: %v", err)
+	}
+
+	// Lines stay buffered until the third record, then all three land.
+	for i, id := range []string{"a", "b", "c", "d"} {
+		if err := a.Write(Document{ID: id}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		want := 0
+		if i >= 2 {
+			want = 3
+		}
+		if got := len(readAuditLines(t, path)); got != want {
+			t.Errorf("after %d writes: %d lines on disk, want %d", i+1, got, want)
+		}
+	}
+
+	// Close flushes the remainder.
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := len(readAuditLines(t, path)); got != 4 {
+		t.Errorf("after Close: %d lines, want 4", got)
+	}
+}
+
+func TestAuditLog_FlushInterval(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	a, err := OpenAuditLogWithOptions(path, AuditOptions{FlushCount: 100, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("OpenAuditLogWithOptions: %v", err)
+	}
+	defer a.Close()
+
+	if err := a.Write(Document{ID: "a"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(readAuditLines(t, path)) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("record not flushed by the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
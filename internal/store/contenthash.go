@@ -0,0 +1,26 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ContentHash returns the hex SHA-256 of data in canonical form, stored as
+// Document.ContentHash when TransformOptions.ContentHash is set. The
+// canonical form is encoding/json's compact encoding, which sorts map keys
+// at every level, so equal data hashes equally regardless of key order. Nil
+// data hashes like an empty map. To verify an exported document, recompute
+// ContentHash over its data field.
+func ContentHash(data map[string]interface{}) string {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	canonical, err := json.Marshal(data)
+	if err != nil {
+		// Decoded JSON always re-encodes; only values set in code can fail.
+		return ""
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
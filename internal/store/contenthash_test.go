@@ -0,0 +1,54 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"hooks-store/internal/hookevt"
+)
+
+func TestContentHash_KeyOrder(t *testing.T) {
+	t.Parallel()
+
+	decode := func(s string) map[string]interface{} {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			t.Fatalf("decode %s: %v", s, err)
+		}
+		return m
+	}
+	a := decode(`{"tool_name":"Bash","tool_input":{"command":"ls","timeout":5},"tags":["x","y"]}`)
+	b := decode(`{"tags":["x","y"],"tool_input":{"timeout":5,"command":"ls"},"tool_name":"Bash"}`)
+	if ha, hb := ContentHash(a), ContentHash(b); ha != hb || len(ha) != 64 {
+		t.Errorf("hashes differ or malformed: %s vs %s", ha, hb)
+	}
+
+	// Any change to a value, including array order, changes the hash.
+	c := decode(`{"tags":["y","x"],"tool_input":{"timeout":5,"command":"ls"},"tool_name":"Bash"}`)
+	if ContentHash(a) == ContentHash(c) {
+		t.Error("reordered array hashed the same")
+	}
+	if ContentHash(nil) != ContentHash(map[string]interface{}{}) {
+		t.Error("nil and empty data hashed differently")
+	}
+}
+
+func TestHookEventToDocument_ContentHash(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{HookType: "Stop", Timestamp: time.Now(), Data: map[string]interface{}{"session_id": "s1"}}
+	if doc := HookEventToDocument(evt); doc.ContentHash != "" {
+		t.Errorf("ContentHash = %q without the option, want empty", doc.ContentHash)
+	}
+	doc := HookEventToDocumentWithOptions(evt, TransformOptions{ContentHash: true})
+	if doc.ContentHash != ContentHash(doc.Data) {
+		t.Errorf("ContentHash = %q, want hash of stored data %q", doc.ContentHash, ContentHash(doc.Data))
+	}
+
+	// With session hashing, the hash covers the pseudonymized data.
+	hashed := HookEventToDocumentWithOptions(evt, TransformOptions{ContentHash: true, SessionIDKey: []byte("k")})
+	if hashed.ContentHash == doc.ContentHash || hashed.ContentHash != ContentHash(hashed.Data) {
+		t.Errorf("ContentHash = %q, want hash of pseudonymized data", hashed.ContentHash)
+	}
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// searchCursor marks where a newest-first search page ended: every hit after
+// it has timestamp_unix <= T, and the first Skip hits at exactly T were
+// already returned. Ties are sorted by id, so skipping them by offset keeps
+// the cursor a fixed size however many events share a second; only an event
+// ingested later into that past second could shift them. Keying on the
+// timestamp rather than an overall offset keeps pages consistent while new
+// events arrive.
+type searchCursor struct {
+	T    int64 `json:"t"`
+	Skip int   `json:"skip,omitempty"`
+}
+
+// encodeCursor serializes c as an opaque URL-safe token.
+func encodeCursor(c searchCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor parses a token produced by encodeCursor.
+func decodeCursor(token string) (searchCursor, error) {
+	var c searchCursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.Skip < 0 {
+		return c, fmt.Errorf("%w: negative skip", ErrInvalidCursor)
+	}
+	return c, nil
+}
+
+// nextCursor builds the cursor following a full page of hits sorted by
+// timestamp_unix desc. The skip count at the boundary timestamp carries over
+// from prev when the whole page shares prev's timestamp.
+func nextCursor(prev searchCursor, hits []Document) searchCursor {
+	last := hits[len(hits)-1].TimestampUnix
+	c := searchCursor{T: last}
+	if prev.T == last {
+		c.Skip = prev.Skip
+	}
+	for _, d := range hits {
+		if d.TimestampUnix == last {
+			c.Skip++
+		}
+	}
+	return c
+}
+
+// filter returns the MeiliSearch filter selecting hits at or before the
+// cursor; the caller skips the first c.Skip of them with an offset.
+func (c searchCursor) filter() string {
+	return "timestamp_unix <= " + strconv.FormatInt(c.T, 10)
+}
+
+// quoteFilterValue quotes s as a MeiliSearch filter string literal.
+func quoteFilterValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,39 @@
+package store
+
+import "testing"
+
+func TestNextCursor_CarriesBoundarySkip(t *testing.T) {
+	t.Parallel()
+
+	// A page entirely at the previous boundary adds to the earlier skip,
+	// otherwise the next page would return those hits again.
+	prev := searchCursor{T: 100, Skip: 1}
+	got := nextCursor(prev, []Document{{ID: "b", TimestampUnix: 100}, {ID: "c", TimestampUnix: 100}})
+	if got != (searchCursor{T: 100, Skip: 3}) {
+		t.Errorf("nextCursor = %+v, want T=100 Skip=3", got)
+	}
+
+	got = nextCursor(prev, []Document{{ID: "b", TimestampUnix: 100}, {ID: "c", TimestampUnix: 90}})
+	if got != (searchCursor{T: 90, Skip: 1}) {
+		t.Errorf("nextCursor = %+v, want T=90 Skip=1", got)
+	}
+}
+
+func TestCursor_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := searchCursor{T: 1772029800, Skip: 4}
+	out, err := decodeCursor(encodeCursor(in))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if out != in {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+	if want := "timestamp_unix <= 1772029800"; out.filter() != want {
+		t.Errorf("filter = %s, want %s", out.filter(), want)
+	}
+	if _, err := decodeCursor(encodeCursor(searchCursor{T: 1, Skip: -1})); err == nil {
+		t.Error("negative skip: err = nil")
+	}
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore implements EventStore by writing each Document as a JSON file at
+// dir/{YYYY-MM-DD}/{id}.json. It has no dependencies beyond the filesystem,
+// for air-gapped capture where events are processed later.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates the root directory if needed and returns a FileStore
+// writing under it.
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file store: empty directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Index writes doc to its own file. The file is written to a temporary name
+// and renamed into place, so readers never see a partial document and
+// concurrent writes of different documents do not interact.
+func (s *FileStore) Index(ctx context.Context, doc Document) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, err := s.path(doc)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("marshal document: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("rename %s: %w", path, err)
+	}
+	return nil
+}
+
+// path returns the file path for doc. The date directory comes from the
+// document's UTC timestamp; IDs containing path separators are rejected.
+func (s *FileStore) path(doc Document) (string, error) {
+	if doc.ID == "" || strings.ContainsAny(doc.ID, `/\`) || doc.ID == "." || doc.ID == ".." {
+		return "", fmt.Errorf("invalid document id %q", doc.ID)
+	}
+	date := "unknown"
+	if len(doc.Timestamp) >= len("2006-01-02") {
+		date = doc.Timestamp[:len("2006-01-02")]
+	}
+	return filepath.Join(s.dir, date, doc.ID+".json"), nil
+}
+
+// Healthy reports whether the root directory still exists and is a directory.
+func (s *FileStore) Healthy(ctx context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return fmt.Errorf("file store: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("file store: %s is not a directory", s.dir)
+	}
+	return nil
+}
+
+// BatchIndex writes each document to its own file, as Index does, stopping
+// at the first failure. Files written before it are kept.
+func (s *FileStore) BatchIndex(ctx context.Context, docs []Document) error {
+	for _, doc := range docs {
+		if err := s.Index(ctx, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op; every Index call leaves its file closed.
+func (s *FileStore) Close() error {
+	return nil
+}
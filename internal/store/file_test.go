@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Compile-time check that FileStore implements EventStore.
+var _ EventStore = (*FileStore)(nil)
+
+func TestFileStore_Index(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	doc := Document{
+		ID:        "doc-1",
+		HookType:  "PreToolUse",
+		Timestamp: "2026-02-25T14:30:00.000Z",
+		ToolName:  "Read",
+		Data:      map[string]interface{}{"tool_name": "Read"},
+	}
+	if err := fs.Index(context.Background(), doc); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "2026-02-25", "doc-1.json"))
+	if err != nil {
+		t.Fatalf("read document file: %v", err)
+	}
+	var got Document
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.ID != doc.ID || got.ToolName != "Read" || got.HookType != "PreToolUse" {
+		t.Errorf("got %+v, want %+v", got, doc)
+	}
+}
+
+func TestFileStore_BatchIndex(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	ts := "2026-02-25T14:30:00.000Z"
+	err = fs.BatchIndex(context.Background(), []Document{{ID: "a", Timestamp: ts}, {ID: "a/b", Timestamp: ts}, {ID: "c", Timestamp: ts}})
+	if err == nil {
+		t.Fatal("BatchIndex with an invalid id = nil, want error")
+	}
+	// Documents before the failure are written; the rest are not.
+	if _, err := os.Stat(filepath.Join(dir, "2026-02-25", "a.json")); err != nil {
+		t.Errorf("a.json: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2026-02-25", "c.json")); !os.IsNotExist(err) {
+		t.Errorf("c.json stat = %v, want not written", err)
+	}
+}
+
+func TestFileStore_InvalidID(t *testing.T) {
+	t.Parallel()
+
+	fs, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	for _, id := range []string{"", "..", "a/b", `a\b`} {
+		if err := fs.Index(context.Background(), Document{ID: id, Timestamp: "2026-02-25T00:00:00.000Z"}); err == nil {
+			t.Errorf("Index(id=%q) = nil, want error", id)
+		}
+	}
+}
+
+func TestFileStore_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc := Document{ID: fmt.Sprintf("doc-%d", i), Timestamp: "2026-02-25T14:30:00.000Z"}
+			if err := fs.Index(context.Background(), doc); err != nil {
+				t.Errorf("Index[%d]: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(filepath.Join(dir, "2026-02-25"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != n {
+		t.Errorf("got %d files, want %d (no temp files left behind)", len(entries), n)
+	}
+}
+
+func TestFileStore_Healthy(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if err := fs.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy = %v, want nil", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("remove dir: %v", err)
+	}
+	if err := fs.Healthy(context.Background()); err == nil {
+		t.Error("Healthy after removing dir = nil, want error")
+	}
+}
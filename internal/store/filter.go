@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// filterKeywords are MeiliSearch filter keywords that may appear where an
+// attribute name is not expected. Compared case-insensitively.
+var filterKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "TO": true,
+	"EXISTS": true, "IS": true, "NULL": true, "EMPTY": true,
+}
+
+// ValidateFilter checks that every attribute referenced by a MeiliSearch
+// filter expression is a filterable attribute of the main index. It does not
+// fully parse the grammar — MeiliSearch reports syntax errors itself — it only
+// locates the attribute position of each condition (start of expression, or
+// after "(", AND, OR) and rejects unknown names. NOT leaves the position
+// unchanged: as a prefix (NOT x = 1) the attribute still follows, and after
+// an attribute or IS (x NOT IN [...], x NOT EXISTS, x IS NOT NULL) it is part
+// of the operator.
+func ValidateFilter(filter string) error {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("empty filter")
+	}
+
+	expectAttr := true
+	listDepth := 0
+	for _, tok := range tokens {
+		switch {
+		case tok == "[":
+			listDepth++
+			continue
+		case tok == "]":
+			listDepth--
+			continue
+		case listDepth > 0:
+			continue
+		case tok == "(":
+			expectAttr = true
+			continue
+		}
+
+		upper := strings.ToUpper(tok)
+		if upper == "AND" || upper == "OR" {
+			if expectAttr {
+				return fmt.Errorf("unexpected %q in filter", tok)
+			}
+			expectAttr = true
+			continue
+		}
+		if upper == "NOT" {
+			continue
+		}
+		if !expectAttr {
+			continue
+		}
+		if filterKeywords[upper] {
+			return fmt.Errorf("unexpected %q in filter", tok)
+		}
+		name := strings.Trim(tok, `"'`)
+		if !IsFilterable(name) {
+			return fmt.Errorf("attribute %q is not filterable", name)
+		}
+		expectAttr = false
+	}
+	return nil
+}
+
+// tokenizeFilter splits a filter expression into words, quoted strings,
+// comparison operators, and the punctuation ( ) [ ] ,.
+func tokenizeFilter(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.IndexByte("()[],", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(filter) && filter[j] != c {
+				if filter[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(filter) {
+				return nil, fmt.Errorf("unterminated quote in filter")
+			}
+			tokens = append(tokens, filter[i:j+1])
+			i = j + 1
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			if j < len(filter) && filter[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, filter[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(filter) && strings.IndexByte(" \t\n\r()[],=!<>\"'", filter[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, filter[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
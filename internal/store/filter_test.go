@@ -0,0 +1,48 @@
+package store
+
+import "testing"
+
+func TestValidateFilter_Valid(t *testing.T) {
+	t.Parallel()
+
+	for _, f := range []string{
+		`session_id = "abc"`,
+		`hook_type = PreToolUse AND timestamp_unix < 1700000000`,
+		`(tool_name = Read OR tool_name = "Write") AND NOT cost_usd > 0.5`,
+		`hook_type IN [Stop, "SessionEnd"]`,
+		`timestamp_unix 100 TO 200`,
+		`project_dir EXISTS`,
+		`cwd IS NULL OR cwd IS EMPTY`,
+		`session_id != 'it''s'`,
+		`project_dir NOT EXISTS`,
+		`hook_type NOT IN [Stop, "SessionEnd"]`,
+		`cwd IS NOT NULL AND cwd IS NOT EMPTY`,
+		`NOT tool_name NOT IN [Read] OR NOT NOT exit_code = 0`,
+	} {
+		if err := ValidateFilter(f); err != nil {
+			t.Errorf("ValidateFilter(%q) = %v, want nil", f, err)
+		}
+	}
+}
+
+func TestValidateFilter_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, f := range []string{
+		``,
+		`   `,
+		`data_flat = x`,
+		`hook_type = Stop OR prompt = "secret"`,
+		`(unknown > 1)`,
+		`session_id = "unterminated`,
+		`AND hook_type = Stop`,
+		`secret NOT EXISTS`,
+		`hook_type = Stop AND NOT secret IS NOT NULL`,
+		`NOT AND hook_type = Stop`,
+		`id = "abc"`,
+	} {
+		if err := ValidateFilter(f); err == nil {
+			t.Errorf("ValidateFilter(%q) = nil, want error", f)
+		}
+	}
+}
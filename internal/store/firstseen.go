@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+
+	"hooks-store/internal/ttlcache"
+)
+
+// FirstSeenStore stamps SessionFirstSeen on the first event it sees for each
+// session ID, whatever its hook type, so sessions whose SessionStart was never
+// received (the service started mid-session) still have a start marker. Seen
+// sessions are remembered for up to maxSessions sessions, least recently
+// active forgotten first; a forgotten or pre-restart session is stamped again
+// on its next event. Everything else passes through to the wrapped store.
+type FirstSeenStore struct {
+	inner EventStore
+	seen  *ttlcache.Cache[string, struct{}] // session IDs, no TTL
+}
+
+// NewFirstSeenStore wraps inner. maxSessions <= 0 remembers every session.
+func NewFirstSeenStore(inner EventStore, maxSessions int) *FirstSeenStore {
+	return &FirstSeenStore{
+		inner: inner,
+		seen:  ttlcache.New[string, struct{}](maxSessions, 0),
+	}
+}
+
+// Unwrap returns the wrapped store.
+func (s *FirstSeenStore) Unwrap() EventStore { return s.inner }
+
+// Index stamps doc if it is its session's first and indexes it.
+func (s *FirstSeenStore) Index(ctx context.Context, doc Document) error {
+	s.apply(&doc)
+	return s.inner.Index(ctx, doc)
+}
+
+// IndexInto stamps doc like Index and writes it to the named index of the
+// wrapped store.
+func (s *FirstSeenStore) IndexInto(ctx context.Context, index string, doc Document) error {
+	s.apply(&doc)
+	return indexInto(ctx, s.inner, index, doc)
+}
+
+// BatchIndex stamps docs in order, so only the earliest event of a session
+// in the batch is marked, then indexes them as one batch.
+func (s *FirstSeenStore) BatchIndex(ctx context.Context, docs []Document) error {
+	stamped := make([]Document, len(docs))
+	for i, doc := range docs {
+		s.apply(&doc)
+		stamped[i] = doc
+	}
+	return s.inner.BatchIndex(ctx, stamped)
+}
+
+// Close closes the wrapped store.
+func (s *FirstSeenStore) Close() error { return s.inner.Close() }
+
+// Sessions returns how many sessions are remembered.
+func (s *FirstSeenStore) Sessions() int { return s.seen.Len() }
+
+// apply sets doc.SessionFirstSeen if its session has not been seen, and
+// marks the session as recently active either way.
+func (s *FirstSeenStore) apply(doc *Document) {
+	if doc.SessionID == "" {
+		return
+	}
+	if s.seen.Add(doc.SessionID, struct{}{}) {
+		doc.SessionFirstSeen = true
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFirstSeenStore_Orphaned(t *testing.T) {
+	t.Parallel()
+	inner := &memStore{}
+	s := NewFirstSeenStore(inner, 10)
+	ctx := context.Background()
+
+	// Session a was already running when the service started: no
+	// SessionStart, so its first observed event is a tool call.
+	for _, doc := range []Document{
+		{HookType: "PreToolUse", SessionID: "a"},
+		{HookType: "PostToolUse", SessionID: "a"},
+		{HookType: "SessionStart", SessionID: "b"},
+		{HookType: "Stop", SessionID: "a"},
+		{HookType: "UserPromptSubmit", SessionID: "b"},
+		{HookType: "Notification"}, // no session
+	} {
+		if err := s.Index(ctx, doc); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+	}
+	want := []bool{true, false, true, false, false, false}
+	for i, doc := range inner.docs {
+		if doc.SessionFirstSeen != want[i] {
+			t.Errorf("doc %d (%s %s): SessionFirstSeen = %v, want %v", i, doc.HookType, doc.SessionID, doc.SessionFirstSeen, want[i])
+		}
+	}
+}
+
+func TestFirstSeenStore_BatchIndex(t *testing.T) {
+	t.Parallel()
+	inner := &memStore{}
+	s := NewFirstSeenStore(inner, 10)
+
+	docs := []Document{
+		{HookType: "PreToolUse", SessionID: "s"},
+		{HookType: "Stop", SessionID: "s"},
+	}
+	if err := s.BatchIndex(context.Background(), docs); err != nil {
+		t.Fatalf("BatchIndex: %v", err)
+	}
+	if len(inner.docs) != 2 || !inner.docs[0].SessionFirstSeen || inner.docs[1].SessionFirstSeen {
+		t.Errorf("docs = %+v, want only the first stamped", inner.docs)
+	}
+	if docs[0].SessionFirstSeen {
+		t.Error("BatchIndex modified the caller's slice")
+	}
+}
+
+func TestFirstSeenStore_Bounds(t *testing.T) {
+	t.Parallel()
+	inner := &memStore{}
+	s := NewFirstSeenStore(inner, 2)
+	ctx := context.Background()
+
+	firstSeen := func(id string) bool {
+		if err := s.Index(ctx, Document{HookType: "Stop", SessionID: id}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		return inner.docs[len(inner.docs)-1].SessionFirstSeen
+	}
+	firstSeen("a")
+	firstSeen("b")
+	firstSeen("a") // a is now the most recently active
+	if !firstSeen("c") {
+		t.Error("new session c not stamped")
+	}
+	if s.Sessions() != 2 {
+		t.Errorf("Sessions = %d, want 2", s.Sessions())
+	}
+	if firstSeen("a") {
+		t.Error("session a stamped again although it was recently active")
+	}
+	if !firstSeen("b") {
+		t.Error("forgotten session b not stamped again")
+	}
+}
+
+func TestFirstSeenStore_Unwrap(t *testing.T) {
+	t.Parallel()
+	if _, ok := As[Searcher](NewFirstSeenStore(&searchMemStore{}, 0)); !ok {
+		t.Error("As[Searcher] through FirstSeenStore = false, want true")
+	}
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// errJSONLClosed is returned by writes to a closed JSONLStore.
+var errJSONLClosed = errors.New("jsonl store: closed")
+
+// JSONLStore implements EventStore by appending each Document as one JSON
+// line to a single file, for air-gapped capture where a FileStore's file per
+// event is unwieldy. Lines are written whole under a mutex, so concurrent
+// Index calls never interleave.
+type JSONLStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	buf  *bufio.Writer // nil once closed
+}
+
+// NewJSONLStore opens (or creates) path in append mode and returns a
+// JSONLStore writing to it. Existing lines are kept.
+func NewJSONLStore(path string) (*JSONLStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("jsonl store: empty path")
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("jsonl store: %w", err)
+	}
+	return &JSONLStore{path: path, f: f, buf: bufio.NewWriter(f)}, nil
+}
+
+// Index appends doc as one line and flushes it to the file before
+// returning.
+func (s *JSONLStore) Index(ctx context.Context, doc Document) error {
+	return s.BatchIndex(ctx, []Document{doc})
+}
+
+// BatchIndex appends docs, one line each, with a single flush. Every
+// document is marshaled first, so a batch with an unmarshalable document
+// writes nothing.
+func (s *JSONLStore) BatchIndex(ctx context.Context, docs []Document) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var lines []byte
+	for _, doc := range docs {
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("marshal document %s: %w", doc.ID, err)
+		}
+		lines = append(append(lines, data...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf == nil {
+		return errJSONLClosed
+	}
+	if _, err := s.buf.Write(lines); err != nil {
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	if err := s.buf.Flush(); err != nil {
+		return fmt.Errorf("write %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Healthy reports whether the store is open and its file still exists.
+func (s *JSONLStore) Healthy(ctx context.Context) error {
+	s.mu.Lock()
+	closed := s.buf == nil
+	s.mu.Unlock()
+	if closed {
+		return errJSONLClosed
+	}
+	if _, err := os.Stat(s.path); err != nil {
+		return fmt.Errorf("jsonl store: %w", err)
+	}
+	return nil
+}
+
+// Close flushes any buffered output and closes the file. Later writes fail.
+func (s *JSONLStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf == nil {
+		return nil
+	}
+	err := s.buf.Flush()
+	s.buf = nil
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
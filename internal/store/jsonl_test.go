@@ -0,0 +1,125 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Compile-time checks for the interfaces JSONLStore implements.
+var (
+	_ EventStore    = (*JSONLStore)(nil)
+	_ HealthChecker = (*JSONLStore)(nil)
+)
+
+// readJSONL decodes every line of path, failing the test on an invalid one.
+func readJSONL(t *testing.T, path string) []Document {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	var docs []Document
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var doc Document
+		if err := json.Unmarshal(sc.Bytes(), &doc); err != nil {
+			t.Fatalf("line %d is not a document: %v", len(docs)+1, err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	return docs
+}
+
+func TestJSONLStore_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	js, err := NewJSONLStore(path)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc := Document{ID: fmt.Sprintf("doc-%d", i), HookType: "PreToolUse", Data: map[string]interface{}{"i": i}}
+			if err := js.Index(context.Background(), doc); err != nil {
+				t.Errorf("Index[%d]: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := js.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	docs := readJSONL(t, path)
+	if len(docs) != n {
+		t.Fatalf("got %d lines, want %d", len(docs), n)
+	}
+	seen := map[string]bool{}
+	for _, doc := range docs {
+		seen[doc.ID] = true
+	}
+	if len(seen) != n {
+		t.Errorf("got %d distinct ids, want %d", len(seen), n)
+	}
+}
+
+func TestJSONLStore_AppendBatchAndClose(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	js, err := NewJSONLStore(path)
+	if err != nil {
+		t.Fatalf("NewJSONLStore: %v", err)
+	}
+	if err := js.Index(context.Background(), Document{ID: "a"}); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	js.Close()
+
+	// Reopening appends.
+	js, err = NewJSONLStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := js.BatchIndex(context.Background(), []Document{{ID: "b"}, {ID: "c"}}); err != nil {
+		t.Fatalf("BatchIndex: %v", err)
+	}
+	if err := js.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy: %v", err)
+	}
+	if err := js.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if docs := readJSONL(t, path); len(docs) != 3 || docs[0].ID != "a" || docs[2].ID != "c" {
+		t.Errorf("docs = %+v, want a, b, c", docs)
+	}
+
+	if err := js.Index(context.Background(), Document{ID: "d"}); err == nil {
+		t.Error("Index after Close = nil, want error")
+	}
+	if err := js.Healthy(context.Background()); err == nil {
+		t.Error("Healthy after Close = nil, want error")
+	}
+	if err := js.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := NewJSONLStore(""); err == nil {
+		t.Error("NewJSONLStore(\"\") = nil error")
+	}
+}
@@ -3,19 +3,237 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"hooks-store/internal/metrics"
+
 	"github.com/meilisearch/meilisearch-go"
 )
 
+// DefaultMaxTotalHits is how many hits a search can count, and so page
+// through, unless MeiliOptions.MaxTotalHits says otherwise.
+const DefaultMaxTotalHits = 10000
+
+// DefaultMaxValuesPerFacet is how many distinct values a facet search can
+// report per field unless MeiliOptions.MaxValuesPerFacet says otherwise.
+const DefaultMaxValuesPerFacet = 500
+
+// DefaultSettingsTimeout bounds index setup's wait for its settings tasks
+// unless MeiliOptions.SettingsTimeout says otherwise.
+const DefaultSettingsTimeout = 2 * time.Minute
+
+// mainFilterableAttributes are the filterable attributes of the main index.
+// Also used to validate user-supplied field names (see FilterableAttributes).
+var mainFilterableAttributes = []string{
+	"hook_type",
+	"session_id",
+	"tool_name",
+	"timestamp_unix",
+	"day",
+	"hour",
+	"has_claude_md",
+	"cost_usd",
+	"project_dir",
+	"permission_mode",
+	"file_path",
+	"cwd",
+	"teammate_id",
+	"teammate_name",
+	"success",
+	"exit_code",
+	"is_subagent",
+	"parent_session_id",
+	"claude_version",
+	"session_model",
+	"notification_response",
+	"source",
+	"content_hash",
+	"session_first_seen",
+	"tags",
+}
+
+// FilterableAttributes returns the main index's filterable attributes.
+// The returned slice is a copy and may be modified by the caller.
+func FilterableAttributes() []string {
+	return append([]string(nil), mainFilterableAttributes...)
+}
+
+// mainDisplayedAttributes are the Document fields MeiliSearch returns, in
+// struct order: everything except data_flat, which only exists to be
+// matched and would roughly double each hit. Derived from the struct so new
+// fields are displayed automatically. data stays displayed because Update
+// and the migrations read it back, and the documents API honours
+// displayedAttributes too — which is also why raw_body is displayed.
+var mainDisplayedAttributes = documentAttributes("data_flat")
+
+// searchAttributes are the fields Search returns: the displayed ones minus
+// raw_body, which only GetDocument hands out.
+var searchAttributes = documentAttributes("data_flat", "raw_body")
+
+// documentAttributes returns Document's JSON field names, minus exclude.
+func documentAttributes(exclude ...string) []string {
+	t := reflect.TypeOf(Document{})
+	var out []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && !slices.Contains(exclude, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// DisplayedAttributes returns the fields search results can carry (see
+// SearchParams.Fields). The returned slice is a copy.
+func DisplayedAttributes() []string {
+	return append([]string(nil), searchAttributes...)
+}
+
+// IsFilterable reports whether field is a filterable attribute of the main index.
+func IsFilterable(field string) bool {
+	for _, a := range mainFilterableAttributes {
+		if a == field {
+			return true
+		}
+	}
+	return false
+}
+
 // MeiliStore implements EventStore using MeiliSearch as the backend.
 // It is safe for concurrent use — the underlying SDK client is thread-safe.
 type MeiliStore struct {
-	client       meilisearch.ServiceManager
-	index        meilisearch.IndexManager
-	indexPrompts meilisearch.IndexManager // nil if prompts index disabled
+	client           meilisearch.ServiceManager
+	index            meilisearch.IndexManager
+	indexName        string
+	indexPrompts     meilisearch.IndexManager // nil if prompts index disabled
+	promptsIndexName string
+	transformOpts    TransformOptions
+	sourceLabel      string
+	migrateWorkers   int
+
+	// searchable and noCreateIndex are kept to set up target indexes the
+	// same way as the main index on first use by IndexInto.
+	searchable      []string
+	noCreateIndex   bool
+	limits          indexLimits
+	settingsTimeout time.Duration
+	targetsMu       sync.Mutex
+	targets         map[string]*targetSetup
+
+	strictPrompts      bool
+	skipEmptyPrompts   bool
+	promptsWriteErrors atomic.Int64
+
+	// enqueueLatency times AddDocuments calls by index ("main", "prompts",
+	// or "target" for IndexInto). Indexing is asynchronous, so this is the
+	// time MeiliSearch takes to accept the task, not to apply it.
+	enqueueLatency *metrics.Histogram
+}
+
+// defaultSearchableAttributes is the main index's searchable attribute order.
+// MeiliSearch's attribute ranking rule prefers matches in earlier attributes,
+// so a keyword in a prompt or error outranks an incidental data_flat match.
+var defaultSearchableAttributes = []string{
+	"prompt",
+	"error_message",
+	"tool_name",
+	"hook_type",
+	"session_id",
+	"data_flat",
+}
+
+// DefaultSearchableAttributes returns a copy of the default searchable
+// attribute order of the main index, highest ranking first.
+func DefaultSearchableAttributes() []string {
+	return append([]string(nil), defaultSearchableAttributes...)
+}
+
+// Prompt ranking modes for MeiliOptions.PromptRank.
+//
+// MeiliSearch's attribute rule only weighs attributes a query actually
+// matched, so an empty prompt on a non-prompt event never boosts it. What
+// prompt's position does decide is how prompt events compete: first, a prompt
+// match outranks an error or tool-name match on any other event.
+const (
+	PromptRankFirst = "first" // prompt is the highest-ranking attribute
+	PromptRankLast  = "last"  // prompt ranks just above data_flat
+	PromptRankOff   = "off"   // prompt is not searchable on its own; prompt text still matches through data_flat
+)
+
+// MeiliOptions tunes NewMeiliStoreWithOptions. The zero value reproduces
+// NewMeiliStore.
+type MeiliOptions struct {
+	// SearchableAttributes sets the main index's searchable attributes in
+	// ranking order, highest first. Empty uses DefaultSearchableAttributes.
+	SearchableAttributes []string
+
+	// PromptRank moves "prompt" within the searchable attributes: one of
+	// PromptRankFirst, PromptRankLast, or PromptRankOff. Empty keeps the
+	// order as configured.
+	PromptRank string
+
+	// Transform is used when Update recomputes derived fields. It should
+	// match the options the ingest server transforms events with.
+	Transform TransformOptions
+
+	// SourceLabel is stamped by MigrateDocuments onto documents that have
+	// no source yet, i.e. those indexed before source labels existed.
+	// Empty leaves them unlabeled.
+	SourceLabel string
+
+	// MigrateWorkers is how many pages MigrateDocuments, MigrateDataFlat,
+	// and MigratePrompts fetch and write concurrently. Zero or one keeps
+	// them sequential.
+	MigrateWorkers int
+
+	// NoCreateIndex assumes both indexes were provisioned and configured
+	// elsewhere: the constructor neither creates them nor updates their
+	// settings (so SearchableAttributes and PromptRank are ignored) and
+	// only checks that their documents are readable. For keys limited to
+	// document reads and writes.
+	NoCreateIndex bool
+
+	// PromptsOptional makes a prompts-index setup failure non-fatal: the
+	// store logs a warning and runs without the prompts index (as if
+	// promptsIndexName were empty) instead of failing construction.
+	PromptsOptional bool
+
+	// SkipEmptyPrompts keeps UserPromptSubmit events whose prompt is empty
+	// or whitespace out of the prompts index. They are still written to the
+	// main index.
+	SkipEmptyPrompts bool
+
+	// MaxTotalHits sets both indexes' pagination maxTotalHits: how many hits
+	// a search counts and can page through. Raising it makes deep searches
+	// slower; Search's cursor pagination is the better fit for very large
+	// result sets. Zero uses DefaultMaxTotalHits.
+	MaxTotalHits int64
+
+	// MaxValuesPerFacet sets both indexes' faceting maxValuesPerFacet: the
+	// most distinct values a facet search reports per field, and so the
+	// largest limit DistinctValues accepts. Zero uses
+	// DefaultMaxValuesPerFacet.
+	MaxValuesPerFacet int64
+
+	// StrictPrompts makes a failed prompts-index write fail the whole
+	// Index/Update call instead of logging a warning. Either way the
+	// failure is counted (PromptsWriteErrors).
+	StrictPrompts bool
+
+	// SettingsTimeout bounds the wait for an index's settings tasks, all of
+	// them together, so an overloaded MeiliSearch fails setup with an error
+	// instead of blocking startup (or a first IndexInto) forever. Zero uses
+	// DefaultSettingsTimeout.
+	SettingsTimeout time.Duration
 }
 
 // NewMeiliStore creates a MeiliStore connected to the given MeiliSearch instance.
@@ -24,6 +242,31 @@ type MeiliStore struct {
 // Waits for each settings task to complete before returning.
 // Returns an error if MeiliSearch is unreachable or index setup fails.
 func NewMeiliStore(endpoint, apiKey, indexName, promptsIndexName string) (*MeiliStore, error) {
+	return NewMeiliStoreWithOptions(endpoint, apiKey, indexName, promptsIndexName, MeiliOptions{})
+}
+
+// NewMeiliStoreWithOptions is NewMeiliStore with tuning options.
+func NewMeiliStoreWithOptions(endpoint, apiKey, indexName, promptsIndexName string, opts MeiliOptions) (*MeiliStore, error) {
+	searchable := opts.SearchableAttributes
+	if len(searchable) == 0 {
+		searchable = defaultSearchableAttributes
+	}
+	searchable, err := rankPrompt(searchable, opts.PromptRank)
+	if err != nil {
+		return nil, err
+	}
+	limits := indexLimits{maxTotalHits: opts.MaxTotalHits, maxValuesPerFacet: opts.MaxValuesPerFacet}
+	if limits.maxTotalHits == 0 {
+		limits.maxTotalHits = DefaultMaxTotalHits
+	}
+	if limits.maxValuesPerFacet == 0 {
+		limits.maxValuesPerFacet = DefaultMaxValuesPerFacet
+	}
+	settingsTimeout := opts.SettingsTimeout
+	if settingsTimeout == 0 {
+		settingsTimeout = DefaultSettingsTimeout
+	}
+
 	client := meilisearch.New(endpoint, meilisearch.WithAPIKey(apiKey))
 
 	// Health check — fail fast if MeiliSearch is down.
@@ -31,6 +274,61 @@ func NewMeiliStore(endpoint, apiKey, indexName, promptsIndexName string) (*Meili
 		return nil, fmt.Errorf("meilisearch at %s is not healthy", endpoint)
 	}
 
+	index := client.Index(indexName)
+	if opts.NoCreateIndex {
+		if err := requireIndex(index, indexName); err != nil {
+			return nil, err
+		}
+	} else if err := setupMainIndex(client, index, indexName, searchable, limits, settingsTimeout); err != nil {
+		return nil, err
+	}
+
+	var indexPrompts meilisearch.IndexManager
+	if promptsIndexName != "" {
+		if opts.NoCreateIndex {
+			indexPrompts = client.Index(promptsIndexName)
+			err = requireIndex(indexPrompts, promptsIndexName)
+		} else {
+			indexPrompts, err = setupPromptsIndex(client, promptsIndexName, limits, settingsTimeout)
+		}
+		if err != nil && !opts.PromptsOptional {
+			return nil, fmt.Errorf("prompts index: %w", err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: prompts index %q disabled: %v\n", promptsIndexName, err)
+			indexPrompts, promptsIndexName = nil, ""
+		}
+	}
+
+	return &MeiliStore{
+		client:           client,
+		index:            index,
+		indexName:        indexName,
+		indexPrompts:     indexPrompts,
+		promptsIndexName: promptsIndexName,
+		transformOpts:    opts.Transform,
+		sourceLabel:      opts.SourceLabel,
+		migrateWorkers:   opts.MigrateWorkers,
+		searchable:       searchable,
+		noCreateIndex:    opts.NoCreateIndex,
+		limits:           limits,
+		settingsTimeout:  settingsTimeout,
+		strictPrompts:    opts.StrictPrompts,
+		skipEmptyPrompts: opts.SkipEmptyPrompts,
+		enqueueLatency: metrics.NewHistogram("hooks_store_index_enqueue_seconds",
+			"Time for MeiliSearch to accept a document write, by index.", "index", nil),
+	}, nil
+}
+
+// setupMainIndex creates the main index if needed and applies its settings,
+// failing if the settings tasks take longer than timeout.
+func setupMainIndex(client meilisearch.ServiceManager, index meilisearch.IndexManager, indexName string, searchable []string, limits indexLimits, timeout time.Duration) error {
+	// Fail fast on a key scoped away from the index; otherwise the first
+	// sign is a confusing failed task at ingest time.
+	if err := checkIndexAccess(client, indexName); err != nil {
+		return err
+	}
+
 	// Ensure the index exists. CreateIndex is idempotent — if the index
 	// already exists, MeiliSearch returns a task that resolves to success.
 	_, err := client.CreateIndex(&meilisearch.IndexConfig{
@@ -38,115 +336,236 @@ func NewMeiliStore(endpoint, apiKey, indexName, promptsIndexName string) (*Meili
 		PrimaryKey: "id",
 	})
 	if err != nil {
-		return nil, fmt.Errorf("create index %q: %w", indexName, err)
+		return fmt.Errorf("create index %q: %w", indexName, err)
 	}
 
-	index := client.Index(indexName)
-
-	// Configure index settings for optimal search and filtering.
-	// These are idempotent — MeiliSearch merges settings on update.
-	// We wait for each task to ensure settings are applied before returning,
-	// which is required for migration to work correctly.
-	taskInfo, err := index.UpdateSearchableAttributes(&[]string{
-		"hook_type",
-		"tool_name",
-		"session_id",
-		"prompt",
-		"error_message",
-		"data_flat",
+	// Configure index settings for optimal search and filtering, waiting
+	// for each task so settings are applied before returning (required for
+	// migration). Settings that already match are skipped, so a restart
+	// with unchanged config enqueues no tasks.
+	// Searchable order matters: it drives the attribute ranking rule.
+	return applySettings(client, index, timeout, desiredSettings{
+		searchable:  searchable,
+		filterable:  mainFilterableAttributes,
+		indexLimits: limits,
+		sortable: []string{
+			"timestamp_unix",
+			"cost_usd",
+			"input_tokens",
+			"output_tokens",
+			"total_tokens",
+			"turn_number",
+			"exit_code",
+			"cost_per_k_token",
+			"id", // tie-breaker for stable search pagination
+		},
+		displayed: mainDisplayedAttributes,
 	})
-	if err != nil {
-		return nil, fmt.Errorf("update searchable attributes: %w", err)
-	}
-	if err := waitForSettingsTask(client, taskInfo, "searchable attributes"); err != nil {
-		return nil, err
+}
+
+// requireIndex checks that an index exists and that the key can read its
+// documents, for MeiliOptions.NoCreateIndex. It reads documents rather than
+// index info so a key limited to documents.* actions passes.
+func requireIndex(index meilisearch.IndexManager, indexName string) error {
+	var result meilisearch.DocumentsResult
+	err := index.GetDocuments(&meilisearch.DocumentsQuery{Limit: 1, Fields: []string{"id"}}, &result)
+	var merr *meilisearch.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &merr) && merr.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("index %q does not exist and index creation is disabled", indexName)
+	case errors.As(err, &merr) && (merr.StatusCode == http.StatusUnauthorized || merr.StatusCode == http.StatusForbidden):
+		return fmt.Errorf("meili key lacks access to index %q (HTTP %d: %s)", indexName, merr.StatusCode, merr.MeilisearchApiError.Message)
+	default:
+		return fmt.Errorf("check index %q: %w", indexName, err)
 	}
+}
 
-	// FilterableAttributes uses []interface{} per the SDK's API.
-	filterAttrs := []interface{}{
-		"hook_type",
-		"session_id",
-		"tool_name",
-		"timestamp_unix",
-		"has_claude_md",
-		"cost_usd",
-		"project_dir",
-		"permission_mode",
-		"file_path",
-		"cwd",
-	}
-	taskInfo, err = index.UpdateFilterableAttributes(&filterAttrs)
+// waitForSettingsTask waits for a settings update task to complete, or for
+// ctx to end.
+func waitForSettingsTask(ctx context.Context, client meilisearch.ServiceManager, taskInfo *meilisearch.TaskInfo, name string) error {
+	task, err := client.WaitForTaskWithContext(ctx, taskInfo.TaskUID, 500*time.Millisecond)
+	if ctx.Err() != nil {
+		return fmt.Errorf("wait for %s: task %d still %s: %w", name, taskInfo.TaskUID, taskStatus(task, taskInfo), ctx.Err())
+	}
 	if err != nil {
-		return nil, fmt.Errorf("update filterable attributes: %w", err)
+		return fmt.Errorf("wait for %s: %w", name, err)
 	}
-	if err := waitForSettingsTask(client, taskInfo, "filterable attributes"); err != nil {
-		return nil, err
+	if task.Status == meilisearch.TaskStatusFailed {
+		return fmt.Errorf("%s task failed: %s", name, task.Error.Message)
 	}
+	return nil
+}
 
-	taskInfo, err = index.UpdateSortableAttributes(&[]string{
-		"timestamp_unix",
-		"cost_usd",
-		"input_tokens",
-		"output_tokens",
-	})
-	if err != nil {
-		return nil, fmt.Errorf("update sortable attributes: %w", err)
+// taskStatus is the last known status of a task being waited for.
+func taskStatus(task *meilisearch.Task, info *meilisearch.TaskInfo) meilisearch.TaskStatus {
+	if task != nil {
+		return task.Status
 	}
-	if err := waitForSettingsTask(client, taskInfo, "sortable attributes"); err != nil {
-		return nil, err
+	return info.Status
+}
+
+// rankPrompt returns attrs with "prompt" placed according to rank (see
+// MeiliOptions.PromptRank). attrs is not modified.
+func rankPrompt(attrs []string, rank string) ([]string, error) {
+	if rank == "" {
+		return attrs, nil
+	}
+	rest := slices.DeleteFunc(slices.Clone(attrs), func(a string) bool { return a == "prompt" })
+	switch rank {
+	case PromptRankFirst:
+		return append([]string{"prompt"}, rest...), nil
+	case PromptRankLast:
+		if i := slices.Index(rest, "data_flat"); i >= 0 {
+			return slices.Insert(rest, i, "prompt"), nil
+		}
+		return append(rest, "prompt"), nil
+	case PromptRankOff:
+		return rest, nil
 	}
+	return nil, fmt.Errorf("invalid prompt rank %q (want %s, %s, or %s)", rank, PromptRankFirst, PromptRankLast, PromptRankOff)
+}
 
-	taskInfo, err = index.UpdatePagination(&meilisearch.Pagination{
-		MaxTotalHits: 10000,
-	})
+// indexLimits are the pagination and faceting caps, the same for every index
+// a store sets up.
+type indexLimits struct {
+	maxTotalHits      int64
+	maxValuesPerFacet int64
+}
+
+// desiredSettings is the configuration applySettings enforces on an index.
+type desiredSettings struct {
+	searchable []string // order matters: it drives the attribute ranking rule
+	filterable []string
+	sortable   []string
+	displayed  []string // nil leaves the index default (all fields)
+	indexLimits
+}
+
+// applySettings brings index's settings in line with want. It fetches the
+// current settings first and skips every update (and its task wait) that
+// would be a no-op. If the current settings can't be read — e.g. the index
+// was only just created — every setting is applied. The task waits share
+// one deadline, timeout from now.
+func applySettings(client meilisearch.ServiceManager, index meilisearch.IndexManager, timeout time.Duration, want desiredSettings) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	wait := func(taskInfo *meilisearch.TaskInfo, name string) error {
+		err := waitForSettingsTask(ctx, client, taskInfo, name)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("settings not applied within %s (is MeiliSearch overloaded?): %w", timeout, err)
+		}
+		return err
+	}
+
+	current, err := index.GetSettings()
 	if err != nil {
-		return nil, fmt.Errorf("update pagination: %w", err)
+		current = &meilisearch.Settings{}
 	}
-	if err := waitForSettingsTask(client, taskInfo, "pagination"); err != nil {
-		return nil, err
+
+	if !slices.Equal(current.SearchableAttributes, want.searchable) {
+		taskInfo, err := index.UpdateSearchableAttributes(&want.searchable)
+		if err != nil {
+			return fmt.Errorf("update searchable attributes: %w", err)
+		}
+		if err := wait(taskInfo, "searchable attributes"); err != nil {
+			return err
+		}
 	}
 
-	taskInfo, err = index.UpdateFaceting(&meilisearch.Faceting{
-		MaxValuesPerFacet: 500,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("update faceting: %w", err)
+	// Filterable and sortable attributes are unordered; MeiliSearch
+	// reports them sorted.
+	if !sameSet(current.FilterableAttributes, want.filterable) {
+		// FilterableAttributes uses []interface{} per the SDK's API.
+		filterAttrs := make([]interface{}, len(want.filterable))
+		for i, a := range want.filterable {
+			filterAttrs[i] = a
+		}
+		taskInfo, err := index.UpdateFilterableAttributes(&filterAttrs)
+		if err != nil {
+			return fmt.Errorf("update filterable attributes: %w", err)
+		}
+		if err := wait(taskInfo, "filterable attributes"); err != nil {
+			return err
+		}
 	}
-	if err := waitForSettingsTask(client, taskInfo, "faceting"); err != nil {
-		return nil, err
+
+	if !sameSet(current.SortableAttributes, want.sortable) {
+		taskInfo, err := index.UpdateSortableAttributes(&want.sortable)
+		if err != nil {
+			return fmt.Errorf("update sortable attributes: %w", err)
+		}
+		if err := wait(taskInfo, "sortable attributes"); err != nil {
+			return err
+		}
 	}
 
-	var indexPrompts meilisearch.IndexManager
-	if promptsIndexName != "" {
-		indexPrompts, err = setupPromptsIndex(client, promptsIndexName)
+	if want.displayed != nil && !sameSet(current.DisplayedAttributes, want.displayed) {
+		taskInfo, err := index.UpdateDisplayedAttributes(&want.displayed)
 		if err != nil {
-			return nil, fmt.Errorf("prompts index: %w", err)
+			return fmt.Errorf("update displayed attributes: %w", err)
+		}
+		if err := wait(taskInfo, "displayed attributes"); err != nil {
+			return err
 		}
 	}
 
-	return &MeiliStore{
-		client:       client,
-		index:        index,
-		indexPrompts: indexPrompts,
-	}, nil
+	if current.Pagination == nil || current.Pagination.MaxTotalHits != want.maxTotalHits {
+		taskInfo, err := index.UpdatePagination(&meilisearch.Pagination{
+			MaxTotalHits: want.maxTotalHits,
+		})
+		if err != nil {
+			return fmt.Errorf("update pagination: %w", err)
+		}
+		if err := wait(taskInfo, "pagination"); err != nil {
+			return err
+		}
+	}
+
+	if current.Faceting == nil || current.Faceting.MaxValuesPerFacet != want.maxValuesPerFacet {
+		taskInfo, err := index.UpdateFaceting(&meilisearch.Faceting{
+			MaxValuesPerFacet: want.maxValuesPerFacet,
+		})
+		if err != nil {
+			return fmt.Errorf("update faceting: %w", err)
+		}
+		if err := wait(taskInfo, "faceting"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// waitForSettingsTask waits for a settings update task to complete.
-func waitForSettingsTask(client meilisearch.ServiceManager, taskInfo *meilisearch.TaskInfo, name string) error {
-	task, err := client.WaitForTask(taskInfo.TaskUID, 500*time.Millisecond)
-	if err != nil {
-		return fmt.Errorf("wait for %s: %w", name, err)
+// sameSet reports whether a and b hold the same strings, ignoring order.
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	if task.Status == meilisearch.TaskStatusFailed {
-		return fmt.Errorf("%s task failed: %s", name, task.Error.Message)
+	sa, sb := slices.Clone(a), slices.Clone(b)
+	slices.Sort(sa)
+	slices.Sort(sb)
+	return slices.Equal(sa, sb)
+}
+
+// checkIndexAccess fetches the index info and reports a clear error if the
+// API key is rejected for it (401/403). A missing index is fine — it is
+// created next — and other failures are left to the setup calls that follow.
+func checkIndexAccess(client meilisearch.ServiceManager, indexName string) error {
+	_, err := client.Index(indexName).FetchInfo()
+	var merr *meilisearch.Error
+	if errors.As(err, &merr) && (merr.StatusCode == http.StatusUnauthorized || merr.StatusCode == http.StatusForbidden) {
+		return fmt.Errorf("meili key lacks access to index %q (HTTP %d: %s)", indexName, merr.StatusCode, merr.MeilisearchApiError.Message)
 	}
 	return nil
 }
 
 // setupPromptsIndex creates and configures the dedicated prompts index
-// with prompt-optimized settings. Follows the same waitForSettingsTask
-// pattern as NewMeiliStore.
-func setupPromptsIndex(client meilisearch.ServiceManager, indexName string) (meilisearch.IndexManager, error) {
+// with prompt-optimized settings, via applySettings like NewMeiliStore.
+func setupPromptsIndex(client meilisearch.ServiceManager, indexName string, limits indexLimits, timeout time.Duration) (meilisearch.IndexManager, error) {
+	if err := checkIndexAccess(client, indexName); err != nil {
+		return nil, err
+	}
 	_, err := client.CreateIndex(&meilisearch.IndexConfig{
 		Uid:        indexName,
 		PrimaryKey: "id",
@@ -156,122 +575,378 @@ func setupPromptsIndex(client meilisearch.ServiceManager, indexName string) (mei
 	}
 	index := client.Index(indexName)
 
-	// Searchable: prompt is the primary field — no data_flat noise.
-	taskInfo, err := index.UpdateSearchableAttributes(&[]string{
-		"prompt",
-		"session_id",
+	err = applySettings(client, index, timeout, desiredSettings{
+		// Searchable: prompt is the primary field — no data_flat noise.
+		searchable: []string{"prompt", "session_id"},
+		filterable: []string{
+			"session_id", "timestamp_unix", "project_dir",
+			"permission_mode", "has_claude_md", "cwd", "prompt_length",
+		},
+		sortable:    []string{"timestamp_unix", "prompt_length"},
+		indexLimits: limits,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("update searchable attributes: %w", err)
-	}
-	if err := waitForSettingsTask(client, taskInfo, "searchable attributes"); err != nil {
 		return nil, err
 	}
 
-	filterAttrs := []interface{}{
-		"session_id", "timestamp_unix", "project_dir",
-		"permission_mode", "has_claude_md", "cwd", "prompt_length",
-	}
-	taskInfo, err = index.UpdateFilterableAttributes(&filterAttrs)
+	return index, nil
+}
+
+// Index persists a Document to MeiliSearch. The SDK's AddDocuments call is
+// asynchronous — MeiliSearch returns a task ID immediately and indexes the
+// document in the background. This method returns an error only if the
+// enqueue request itself fails (e.g., network error, invalid document), or,
+// with StrictPrompts, if the prompts-index dual-write fails.
+func (s *MeiliStore) Index(ctx context.Context, doc Document) error {
+	pk := "id"
+	start := time.Now()
+	_, err := s.index.AddDocumentsWithContext(ctx, []Document{doc}, &meilisearch.DocumentOptions{
+		PrimaryKey: &pk,
+	})
+	s.enqueueLatency.Observe("main", time.Since(start).Seconds())
 	if err != nil {
-		return nil, fmt.Errorf("update filterable attributes: %w", err)
+		return fmt.Errorf("index document %s: %w", doc.ID, err)
 	}
-	if err := waitForSettingsTask(client, taskInfo, "filterable attributes"); err != nil {
-		return nil, err
+
+	// Dual-write UserPromptSubmit events to the dedicated prompts index.
+	if s.indexPrompts != nil && s.promptWanted(doc.HookType, doc.Prompt) {
+		promptDoc := DocumentToPromptDocument(doc)
+		start := time.Now()
+		_, err := s.indexPrompts.AddDocumentsWithContext(ctx, []PromptDocument{promptDoc}, &meilisearch.DocumentOptions{
+			PrimaryKey: &pk,
+		})
+		s.enqueueLatency.Observe("prompts", time.Since(start).Seconds())
+		if err := s.promptsWriteFailed(doc.ID, err); err != nil {
+			return err
+		}
 	}
 
-	taskInfo, err = index.UpdateSortableAttributes(&[]string{
-		"timestamp_unix", "prompt_length",
+	return nil
+}
+
+// IndexInto persists a Document to the named index instead of the main one.
+// The main index's name is the same as Index. Any other index is created
+// and configured like the main index (or, with NoCreateIndex, checked) the
+// first time it is used, and its handle is cached; a failed setup is retried
+// on the next call. Documents written this way are not dual-written to the
+// prompts index, which is shared across indexes.
+func (s *MeiliStore) IndexInto(ctx context.Context, name string, doc Document) error {
+	if name == s.indexName {
+		return s.Index(ctx, doc)
+	}
+	index, err := s.targetIndex(ctx, name)
+	if err != nil {
+		return err
+	}
+	pk := "id"
+	start := time.Now()
+	_, err = index.AddDocumentsWithContext(ctx, []Document{doc}, &meilisearch.DocumentOptions{
+		PrimaryKey: &pk,
 	})
+	s.enqueueLatency.Observe("target", time.Since(start).Seconds())
 	if err != nil {
-		return nil, fmt.Errorf("update sortable attributes: %w", err)
+		return fmt.Errorf("index document %s into %q: %w", doc.ID, name, err)
 	}
-	if err := waitForSettingsTask(client, taskInfo, "sortable attributes"); err != nil {
-		return nil, err
+	return nil
+}
+
+// targetSetup is one target index's first-use setup. done is closed when
+// it finishes; index and err are set before that.
+type targetSetup struct {
+	done  chan struct{}
+	index meilisearch.IndexManager
+	err   error
+}
+
+// targetIndex returns the cached handle for a target index, setting the
+// index up on first use. The lock only guards the map: the first caller for
+// a name runs the setup outside it, so writes to other target indexes are
+// not held up, and concurrent callers for the same name wait for that setup
+// (or ctx) instead of configuring the index again. A failed setup is
+// reported to its waiters and dropped, so the next call retries.
+func (s *MeiliStore) targetIndex(ctx context.Context, name string) (meilisearch.IndexManager, error) {
+	s.targetsMu.Lock()
+	t, ok := s.targets[name]
+	if !ok {
+		if s.targets == nil {
+			s.targets = map[string]*targetSetup{}
+		}
+		t = &targetSetup{done: make(chan struct{})}
+		s.targets[name] = t
+	}
+	s.targetsMu.Unlock()
+
+	if ok {
+		select {
+		case <-t.done:
+			return t.index, t.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
-	taskInfo, err = index.UpdatePagination(&meilisearch.Pagination{
-		MaxTotalHits: 10000,
-	})
+	index := s.client.Index(name)
+	var err error
+	if s.noCreateIndex {
+		err = requireIndex(index, name)
+	} else {
+		err = setupMainIndex(s.client, index, name, s.searchable, s.limits, s.settingsTimeout)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("update pagination: %w", err)
+		t.err = fmt.Errorf("target index: %w", err)
+		s.targetsMu.Lock()
+		delete(s.targets, name)
+		s.targetsMu.Unlock()
+	} else {
+		t.index = index
 	}
-	if err := waitForSettingsTask(client, taskInfo, "pagination"); err != nil {
-		return nil, err
+	close(t.done)
+	return t.index, t.err
+}
+
+// promptWanted reports whether an event belongs in the prompts index: every
+// UserPromptSubmit, except blank prompts under SkipEmptyPrompts.
+func (s *MeiliStore) promptWanted(hookType, prompt string) bool {
+	if hookType != "UserPromptSubmit" {
+		return false
 	}
+	return !s.skipEmptyPrompts || strings.TrimSpace(prompt) != ""
+}
 
-	taskInfo, err = index.UpdateFaceting(&meilisearch.Faceting{
-		MaxValuesPerFacet: 500,
+// BatchIndex persists docs with one AddDocuments call per index, with the
+// same prompts dual-write and failure handling as Index.
+func (s *MeiliStore) BatchIndex(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	pk := "id"
+	start := time.Now()
+	_, err := s.index.AddDocumentsWithContext(ctx, docs, &meilisearch.DocumentOptions{
+		PrimaryKey: &pk,
 	})
+	s.enqueueLatency.Observe("main", time.Since(start).Seconds())
 	if err != nil {
-		return nil, fmt.Errorf("update faceting: %w", err)
+		return fmt.Errorf("index %d documents: %w", len(docs), err)
 	}
-	if err := waitForSettingsTask(client, taskInfo, "faceting"); err != nil {
-		return nil, err
+
+	if s.indexPrompts == nil {
+		return nil
+	}
+	var prompts []PromptDocument
+	for _, doc := range docs {
+		if s.promptWanted(doc.HookType, doc.Prompt) {
+			prompts = append(prompts, DocumentToPromptDocument(doc))
+		}
 	}
+	if len(prompts) == 0 {
+		return nil
+	}
+	start = time.Now()
+	_, err = s.indexPrompts.AddDocumentsWithContext(ctx, prompts, &meilisearch.DocumentOptions{
+		PrimaryKey: &pk,
+	})
+	s.enqueueLatency.Observe("prompts", time.Since(start).Seconds())
+	return s.promptsWriteFailed(fmt.Sprintf("batch of %d", len(prompts)), err)
+}
 
-	return index, nil
+// promptsWriteFailed accounts for the result of a prompts-index write. A
+// failure is counted, then either returned (strict mode) or logged.
+func (s *MeiliStore) promptsWriteFailed(id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	s.promptsWriteErrors.Add(1)
+	if s.strictPrompts {
+		return fmt.Errorf("prompts index write %s: %w", id, err)
+	}
+	fmt.Fprintf(os.Stderr, "warning: prompts index write failed for %s: %v\n", id, err)
+	return nil
 }
 
-// Index persists a Document to MeiliSearch. The SDK's AddDocuments call is
-// asynchronous — MeiliSearch returns a task ID immediately and indexes the
-// document in the background. This method returns an error only if the
-// enqueue request itself fails (e.g., network error, invalid document).
-func (s *MeiliStore) Index(ctx context.Context, doc Document) error {
-	pk := "id"
-	_, err := s.index.AddDocumentsWithContext(ctx, []Document{doc}, &meilisearch.DocumentOptions{
-		PrimaryKey: &pk,
+// Metrics returns the store's Prometheus metrics (see MetricsProvider).
+func (s *MeiliStore) Metrics() []metrics.Metric {
+	return []metrics.Metric{s.enqueueLatency}
+}
+
+// PromptsWriteErrors returns how many prompts-index writes have failed.
+func (s *MeiliStore) PromptsWriteErrors() int64 {
+	return s.promptsWriteErrors.Load()
+}
+
+// Healthy reports whether MeiliSearch answers its health endpoint as
+// available.
+func (s *MeiliStore) Healthy(ctx context.Context) error {
+	h, err := s.client.HealthWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	if h.Status != "available" {
+		return fmt.Errorf("meilisearch status %q", h.Status)
+	}
+	return nil
+}
+
+// IndexesAsync reports true: Index returns once MeiliSearch has enqueued the
+// document, before it is searchable.
+func (s *MeiliStore) IndexesAsync() bool { return true }
+
+// Backlog reports whether the main index is currently indexing and how many
+// tasks targeting it are still enqueued or processing. Costs two HTTP calls,
+// so callers on a hot path should cache the result.
+func (s *MeiliStore) Backlog(ctx context.Context) (Backlog, error) {
+	stats, err := s.index.GetStatsWithContext(ctx)
+	if err != nil {
+		return Backlog{}, fmt.Errorf("get index stats: %w", err)
+	}
+	// Limit 1: only the total count is needed, not the task bodies.
+	tasks, err := s.client.GetTasksWithContext(ctx, &meilisearch.TasksQuery{
+		Limit:     1,
+		IndexUIDS: []string{s.indexName},
+		Statuses:  []meilisearch.TaskStatus{meilisearch.TaskStatusEnqueued, meilisearch.TaskStatusProcessing},
 	})
 	if err != nil {
-		return fmt.Errorf("index document %s: %w", doc.ID, err)
+		return Backlog{}, fmt.Errorf("get pending tasks: %w", err)
 	}
+	return Backlog{
+		IsIndexing:   stats.IsIndexing,
+		PendingTasks: tasks.Total,
+	}, nil
+}
 
-	// Dual-write UserPromptSubmit events to the dedicated prompts index.
-	if s.indexPrompts != nil && doc.HookType == "UserPromptSubmit" {
-		promptDoc := DocumentToPromptDocument(doc)
-		if _, err := s.indexPrompts.AddDocumentsWithContext(ctx, []PromptDocument{promptDoc}, &meilisearch.DocumentOptions{
+// GetDocument fetches one document from the main index, raw_body included.
+func (s *MeiliStore) GetDocument(ctx context.Context, id string) (Document, error) {
+	var doc Document
+	if err := s.index.GetDocumentWithContext(ctx, id, nil, &doc); err != nil {
+		var merr *meilisearch.Error
+		if errors.As(err, &merr) && merr.StatusCode == http.StatusNotFound {
+			return Document{}, fmt.Errorf("get document %s: %w", id, ErrNotFound)
+		}
+		return Document{}, fmt.Errorf("get document %s: %w", id, err)
+	}
+	return doc, nil
+}
+
+// Update merges data into the stored document id, recomputes its derived
+// fields, and writes it back as a partial update. UserPromptSubmit documents
+// are re-synced to the prompts index (same failure handling as Index). Returns a
+// wrapped ErrNotFound if the document does not exist — including when its
+// original Index task has not been applied yet.
+func (s *MeiliStore) Update(ctx context.Context, id string, data map[string]interface{}) (Document, error) {
+	doc, err := s.GetDocument(ctx, id)
+	if err != nil {
+		return Document{}, err
+	}
+
+	updated := MergeEventData(doc, data, s.transformOpts)
+	pk := "id"
+	if _, err := s.index.UpdateDocumentsWithContext(ctx, []Document{updated}, &meilisearch.DocumentOptions{
+		PrimaryKey: &pk,
+	}); err != nil {
+		return Document{}, fmt.Errorf("update document %s: %w", id, err)
+	}
+
+	if s.indexPrompts != nil && s.promptWanted(updated.HookType, updated.Prompt) {
+		promptDoc := DocumentToPromptDocument(updated)
+		_, err := s.indexPrompts.UpdateDocumentsWithContext(ctx, []PromptDocument{promptDoc}, &meilisearch.DocumentOptions{
 			PrimaryKey: &pk,
-		}); err != nil {
-			fmt.Fprintf(os.Stderr, "warning: prompts index write failed for %s: %v\n", doc.ID, err)
+		})
+		if err := s.promptsWriteFailed(id, err); err != nil {
+			return Document{}, err
 		}
 	}
+	return updated, nil
+}
 
-	return nil
+// GetSettings returns the live settings of the main index and, when enabled,
+// the prompts index, as MeiliSearch reports them.
+func (s *MeiliStore) GetSettings(ctx context.Context) ([]IndexSettings, error) {
+	names := []string{s.indexName}
+	indexes := []meilisearch.IndexManager{s.index}
+	if s.indexPrompts != nil {
+		names = append(names, s.promptsIndexName)
+		indexes = append(indexes, s.indexPrompts)
+	}
+	out := make([]IndexSettings, 0, len(indexes))
+	for i, idx := range indexes {
+		settings, err := idx.GetSettingsWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get settings %s: %w", names[i], err)
+		}
+		out = append(out, toIndexSettings(names[i], settings))
+	}
+	return out, nil
+}
+
+// toIndexSettings converts SDK settings to the store's IndexSettings.
+func toIndexSettings(uid string, settings *meilisearch.Settings) IndexSettings {
+	is := IndexSettings{
+		Index:                uid,
+		RankingRules:         settings.RankingRules,
+		SearchableAttributes: settings.SearchableAttributes,
+		FilterableAttributes: settings.FilterableAttributes,
+		SortableAttributes:   settings.SortableAttributes,
+		DisplayedAttributes:  settings.DisplayedAttributes,
+	}
+	if settings.Pagination != nil {
+		is.MaxTotalHits = settings.Pagination.MaxTotalHits
+	}
+	if settings.Faceting != nil {
+		is.MaxValuesPerFacet = settings.Faceting.MaxValuesPerFacet
+	}
+	return is
+}
+
+// migratableFields are the top-level fields MigrateDocuments backfills, and
+// so the fields MigrateField accepts.
+var migratableFields = []string{
+	"claude_version", "cost_per_k_token", "cwd", "day", "error_message",
+	"exit_code", "file_path", "has_claude_md", "hour", "is_subagent",
+	"notification_response", "parent_session_id", "permission_mode",
+	"project_dir", "prompt", "session_model", "source", "success", "tags",
+	"teammate_id", "teammate_name", "total_tokens", "turn_number",
 }
 
 // MigrateDocuments backfills top-level fields on all existing documents.
 // Reads documents in pages of batchSize, extracts fields from the nested
 // data map, and sends partial updates via UpdateDocuments (HTTP PUT merge).
+// Documents without a source get the store's SourceLabel, if set.
 // Returns (migrated count, error).
 func (s *MeiliStore) MigrateDocuments(ctx context.Context, batchSize int) (int, error) {
-	offset := int64(0)
-	total := 0
-
-	for {
-		if ctx.Err() != nil {
-			return total, ctx.Err()
-		}
-
-		var result meilisearch.DocumentsResult
-		err := s.index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
-			Offset: offset,
-			Limit:  int64(batchSize),
-			Fields: []string{"id", "data"},
-		}, &result)
-		if err != nil {
-			return total, fmt.Errorf("get documents at offset %d: %w", offset, err)
-		}
+	return s.migrateDocuments(ctx, batchSize, "")
+}
 
-		if len(result.Results) == 0 {
-			break
-		}
+// MigrateField is MigrateDocuments limited to one top-level field, for
+// backfilling a newly extracted field without rewriting the others. Only
+// documents that get a value for field are updated, and only with that
+// field. Returns the number of documents updated.
+func (s *MeiliStore) MigrateField(ctx context.Context, batchSize int, field string) (int, error) {
+	if !slices.Contains(migratableFields, field) {
+		return 0, fmt.Errorf("field %q is not backfilled by migrations (one of: %s)", field, strings.Join(migratableFields, ", "))
+	}
+	return s.migrateDocuments(ctx, batchSize, field)
+}
 
+// migrateDocuments implements MigrateDocuments, or MigrateField when only
+// is non-empty.
+func (s *MeiliStore) migrateDocuments(ctx context.Context, batchSize int, only string) (int, error) {
+	fields := []string{"id", "hook_type", "data", "source", "timestamp_unix", "day"}
+	page := func(ctx context.Context, offset int64, hits []meilisearch.Hit) (int, error) {
 		var updates []map[string]interface{}
-		for _, hit := range result.Results {
+		for _, hit := range hits {
 			partial, err := extractMigrationFields(hit)
 			if err != nil {
 				continue // skip unparseable documents
 			}
+			if _, labeled := hit["source"]; !labeled && s.sourceLabel != "" {
+				partial["source"] = s.sourceLabel
+			}
+			if only != "" {
+				v, ok := partial[only]
+				if !ok {
+					continue
+				}
+				partial = map[string]interface{}{"id": partial["id"], only: v}
+			}
 			if len(partial) > 1 { // more than just "id"
 				updates = append(updates, partial)
 			}
@@ -280,27 +955,132 @@ func (s *MeiliStore) MigrateDocuments(ctx context.Context, batchSize int) (int,
 		if len(updates) > 0 {
 			taskInfo, err := s.index.UpdateDocuments(updates, nil)
 			if err != nil {
-				return total, fmt.Errorf("update documents at offset %d: %w", offset, err)
+				return 0, fmt.Errorf("update documents at offset %d: %w", offset, err)
 			}
-			task, err := s.client.WaitForTask(taskInfo.TaskUID, 500*time.Millisecond)
+			task, err := s.client.WaitForTaskWithContext(ctx, taskInfo.TaskUID, 500*time.Millisecond)
 			if err != nil {
-				return total, fmt.Errorf("wait for update task at offset %d: %w", offset, err)
+				return 0, fmt.Errorf("wait for update task at offset %d: %w", offset, err)
 			}
 			if task.Status == meilisearch.TaskStatusFailed {
-				return total, fmt.Errorf("update task failed at offset %d: %s", offset, task.Error.Message)
+				return 0, fmt.Errorf("update task failed at offset %d: %s", offset, task.Error.Message)
 			}
 		}
+		if only != "" {
+			return len(updates), nil
+		}
+		return len(hits), nil
+	}
+	return s.migratePages(ctx, batchSize, fields, page, func(count int, scanned, total int64) {
+		if only != "" {
+			fmt.Printf("%s: updated %d so far (scanned %d/%d)\n", only, count, scanned, total)
+			return
+		}
+		fmt.Printf("Migrated %d/%d documents\n", count, total)
+	})
+}
 
-		total += len(result.Results)
-		fmt.Printf("Migrated %d/%d documents\n", total, result.Total)
-		offset += int64(batchSize)
+// migratePage handles one page of main-index documents for a migration and
+// returns how many of them count toward the migration's total.
+type migratePage func(ctx context.Context, offset int64, hits []meilisearch.Hit) (int, error)
+
+// migratePages reads the main index in pages of batchSize (only fields) and
+// passes each non-empty page to fn, calling progress after each page. It
+// returns the sum of fn's counts. With MeiliOptions.MigrateWorkers > 1 the
+// first page is read alone to learn the document total, then the remaining
+// pages are fetched and handled by that many workers at once, so fn must be
+// safe for concurrent use and honor ctx; the first error cancels the pages
+// not yet started and the in-flight ones' task waits, and is returned once
+// every worker has stopped.
+func (s *MeiliStore) migratePages(ctx context.Context, batchSize int, fields []string, fn migratePage, progress func(count int, scanned, total int64)) (int, error) {
+	var (
+		mu      sync.Mutex
+		count   int
+		scanned int64
+	)
+	// run handles the page at offset and reports the index total and
+	// whether the page was empty.
+	run := func(ctx context.Context, offset int64) (int64, bool, error) {
+		var result meilisearch.DocumentsResult
+		err := s.index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  int64(batchSize),
+			Fields: fields,
+		}, &result)
+		if err != nil {
+			return 0, false, fmt.Errorf("get documents at offset %d: %w", offset, err)
+		}
+		if len(result.Results) == 0 {
+			return result.Total, true, nil
+		}
+		n, err := fn(ctx, offset, result.Results)
+		if err != nil {
+			return result.Total, false, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		count += n
+		scanned += int64(len(result.Results))
+		progress(count, scanned, result.Total)
+		return result.Total, false, nil
+	}
 
-		if offset >= result.Total {
+	offset := int64(0)
+	var total int64
+	for {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		t, empty, err := run(ctx, offset)
+		if err != nil {
+			return count, err
+		}
+		offset += int64(batchSize)
+		if empty || offset >= t {
+			return count, nil
+		}
+		if s.migrateWorkers > 1 {
+			total = t
 			break
 		}
 	}
 
-	return total, nil
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	offsets := make(chan int64)
+	for i := 0; i < s.migrateWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for off := range offsets {
+				if _, _, err := run(ctx, off); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for ; offset < total; offset += int64(batchSize) {
+		select {
+		case offsets <- offset:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(offsets)
+	wg.Wait()
+
+	if firstErr != nil {
+		return count, firstErr
+	}
+	return count, ctx.Err()
 }
 
 // extractMigrationFields extracts top-level fields from a raw MeiliSearch hit.
@@ -318,6 +1098,22 @@ func extractMigrationFields(hit meilisearch.Hit) (map[string]interface{}, error)
 
 	partial := map[string]interface{}{"id": id}
 
+	// Backfill day/hour buckets from timestamp_unix when missing.
+	if _, ok := hit["day"]; !ok {
+		var ts int64
+		if raw, ok := hit["timestamp_unix"]; ok && json.Unmarshal(raw, &ts) == nil {
+			partial["day"], partial["hour"] = timeBuckets(time.Unix(ts, 0))
+		}
+	}
+
+	// Backfill the tool call outcome from the hook type.
+	var hookType string
+	if raw, ok := hit["hook_type"]; ok && json.Unmarshal(raw, &hookType) == nil {
+		if success := toolSuccess(hookType); success != nil {
+			partial["success"] = *success
+		}
+	}
+
 	// Extract the data map.
 	dataRaw, ok := hit["data"]
 	if !ok {
@@ -354,6 +1150,50 @@ func extractMigrationFields(hit meilisearch.Hit) (map[string]interface{}, error)
 	if cwd, ok := extractString(data, "cwd"); ok {
 		partial["cwd"] = cwd
 	}
+	id, name := extractTeammate(data)
+	if id != "" {
+		partial["teammate_id"] = id
+	}
+	if name != "" {
+		partial["teammate_name"] = name
+	}
+	if tags := extractTags(data); len(tags) > 0 {
+		partial["tags"] = tags
+	}
+	if turn := extractTurnNumber(data); turn > 0 {
+		partial["turn_number"] = turn
+	}
+	if code := extractExitCode(data); code != nil {
+		partial["exit_code"] = *code
+	}
+	if sub, parent := extractSubagent(data); sub {
+		partial["is_subagent"] = true
+		if parent != "" {
+			partial["parent_session_id"] = parent
+		}
+	}
+	if hookType == "Notification" {
+		if resp := extractNotificationResponse(data); resp != "" {
+			partial["notification_response"] = resp
+		}
+	}
+	if hookType == "SessionStart" {
+		version, model := extractSessionMeta(data)
+		if version != "" {
+			partial["claude_version"] = version
+		}
+		if model != "" {
+			partial["session_model"] = model
+		}
+	}
+	var tokens Document
+	extractTokenMetrics(&tokens, data)
+	if tokens.TotalTokens > 0 {
+		partial["total_tokens"] = tokens.TotalTokens
+	}
+	if tokens.CostPerKToken > 0 {
+		partial["cost_per_k_token"] = tokens.CostPerKToken
+	}
 
 	return partial, nil
 }
@@ -364,30 +1204,9 @@ func extractMigrationFields(hit meilisearch.Hit) (map[string]interface{}, error)
 // Idempotent: running twice produces functionally identical search behavior.
 // Returns (processed count, error).
 func (s *MeiliStore) MigrateDataFlat(ctx context.Context, batchSize int) (int, error) {
-	offset := int64(0)
-	total := 0
-
-	for {
-		if ctx.Err() != nil {
-			return total, ctx.Err()
-		}
-
-		var result meilisearch.DocumentsResult
-		err := s.index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
-			Offset: offset,
-			Limit:  int64(batchSize),
-			Fields: []string{"id", "data"},
-		}, &result)
-		if err != nil {
-			return total, fmt.Errorf("get documents at offset %d: %w", offset, err)
-		}
-
-		if len(result.Results) == 0 {
-			break
-		}
-
+	page := func(ctx context.Context, offset int64, hits []meilisearch.Hit) (int, error) {
 		var updates []map[string]interface{}
-		for _, hit := range result.Results {
+		for _, hit := range hits {
 			idRaw, ok := hit["id"]
 			if !ok {
 				continue
@@ -414,32 +1233,80 @@ func (s *MeiliStore) MigrateDataFlat(ctx context.Context, batchSize int) (int, e
 		if len(updates) > 0 {
 			taskInfo, err := s.index.UpdateDocuments(updates, nil)
 			if err != nil {
-				return total, fmt.Errorf("update data_flat at offset %d: %w", offset, err)
+				return 0, fmt.Errorf("update data_flat at offset %d: %w", offset, err)
 			}
-			task, err := s.client.WaitForTask(taskInfo.TaskUID, 500*time.Millisecond)
+			task, err := s.client.WaitForTaskWithContext(ctx, taskInfo.TaskUID, 500*time.Millisecond)
 			if err != nil {
-				return total, fmt.Errorf("wait for data_flat task at offset %d: %w", offset, err)
+				return 0, fmt.Errorf("wait for data_flat task at offset %d: %w", offset, err)
 			}
 			if task.Status == meilisearch.TaskStatusFailed {
-				return total, fmt.Errorf("data_flat task failed at offset %d: %s", offset, task.Error.Message)
+				return 0, fmt.Errorf("data_flat task failed at offset %d: %s", offset, task.Error.Message)
 			}
 		}
+		return len(hits), nil
+	}
+	return s.migratePages(ctx, batchSize, []string{"id", "data"}, page, func(count int, scanned, total int64) {
+		fmt.Printf("data_flat: migrated %d/%d documents\n", count, total)
+	})
+}
 
-		total += len(result.Results)
-		fmt.Printf("data_flat: migrated %d/%d documents\n", total, result.Total)
-		offset += int64(batchSize)
+// MigrateTimestamps repairs documents whose timestamp_unix disagrees with
+// their timestamp string, as written by older, inconsistent timestamp
+// handling: timestamp is reparsed and timestamp_unix rewritten from it where
+// they diverge (or timestamp_unix is missing). Documents with an unparseable
+// timestamp are left alone. Returns the number of documents corrected.
+func (s *MeiliStore) MigrateTimestamps(ctx context.Context, batchSize int) (int, error) {
+	page := func(ctx context.Context, offset int64, hits []meilisearch.Hit) (int, error) {
+		var updates []map[string]interface{}
+		for _, hit := range hits {
+			var id, timestamp string
+			if err := json.Unmarshal(hit["id"], &id); err != nil || id == "" {
+				continue
+			}
+			if err := json.Unmarshal(hit["timestamp"], &timestamp); err != nil {
+				continue
+			}
+			ts, err := time.Parse(time.RFC3339Nano, timestamp)
+			if err != nil {
+				continue
+			}
+			var unix json.Number
+			if raw, ok := hit["timestamp_unix"]; ok && json.Unmarshal(raw, &unix) == nil {
+				if n, err := unix.Int64(); err == nil && n == ts.Unix() {
+					continue
+				}
+			}
+			updates = append(updates, map[string]interface{}{
+				"id":             id,
+				"timestamp_unix": ts.Unix(),
+			})
+		}
 
-		if offset >= result.Total {
-			break
+		if len(updates) > 0 {
+			taskInfo, err := s.index.UpdateDocuments(updates, nil)
+			if err != nil {
+				return 0, fmt.Errorf("update timestamp_unix at offset %d: %w", offset, err)
+			}
+			task, err := s.client.WaitForTaskWithContext(ctx, taskInfo.TaskUID, 500*time.Millisecond)
+			if err != nil {
+				return 0, fmt.Errorf("wait for timestamp_unix task at offset %d: %w", offset, err)
+			}
+			if task.Status == meilisearch.TaskStatusFailed {
+				return 0, fmt.Errorf("timestamp_unix task failed at offset %d: %s", offset, task.Error.Message)
+			}
 		}
+		return len(updates), nil
 	}
-
-	return total, nil
+	return s.migratePages(ctx, batchSize, []string{"id", "timestamp", "timestamp_unix"}, page, func(count int, scanned, total int64) {
+		fmt.Printf("timestamps: corrected %d so far (scanned %d/%d)\n", count, scanned, total)
+	})
 }
 
 // MigratePrompts reads all documents from the main index, filters for
 // UserPromptSubmit events client-side, converts them to PromptDocuments,
-// and indexes them into the dedicated prompts index in batches.
+// and upserts them into the dedicated prompts index in batches. Writes are
+// UpdateDocuments merges, like the other migrations, so fields added to
+// existing prompt documents outside hooks-store survive a re-run.
 // Prerequisite: MigrateDocuments must run first so top-level fields are backfilled.
 // Returns early with (0, nil) if the prompts index is disabled.
 func (s *MeiliStore) MigratePrompts(ctx context.Context, batchSize int) (int, error) {
@@ -447,66 +1314,40 @@ func (s *MeiliStore) MigratePrompts(ctx context.Context, batchSize int) (int, er
 		return 0, nil
 	}
 
-	offset := int64(0)
-	total := 0
-
-	for {
-		if ctx.Err() != nil {
-			return total, ctx.Err()
-		}
-
-		var result meilisearch.DocumentsResult
-		err := s.index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
-			Offset: offset,
-			Limit:  int64(batchSize),
-			Fields: []string{"id", "hook_type", "timestamp", "timestamp_unix",
-				"session_id", "prompt", "cwd", "project_dir", "permission_mode", "has_claude_md"},
-		}, &result)
-		if err != nil {
-			return total, fmt.Errorf("get documents at offset %d: %w", offset, err)
-		}
-
-		if len(result.Results) == 0 {
-			break
-		}
-
+	fields := []string{"id", "hook_type", "timestamp", "timestamp_unix",
+		"session_id", "prompt", "prompt_length_original", "cwd", "project_dir",
+		"permission_mode", "has_claude_md"}
+	page := func(ctx context.Context, offset int64, hits []meilisearch.Hit) (int, error) {
 		var prompts []PromptDocument
-		for _, hit := range result.Results {
+		for _, hit := range hits {
 			pdoc, err := extractPromptMigrationFields(hit)
-			if err != nil || pdoc == nil {
+			if err != nil || pdoc == nil || !s.promptWanted(pdoc.HookType, pdoc.Prompt) {
 				continue
 			}
 			prompts = append(prompts, *pdoc)
 		}
 
 		if len(prompts) > 0 {
-			pk := "id"
-			taskInfo, err := s.indexPrompts.AddDocuments(prompts, &meilisearch.DocumentOptions{
-				PrimaryKey: &pk,
-			})
+			// Nil options: the index was created with primary key "id",
+			// and passing one makes the SDK write it onto the index handle
+			// shared by every worker.
+			taskInfo, err := s.indexPrompts.UpdateDocuments(prompts, nil)
 			if err != nil {
-				return total, fmt.Errorf("add prompts at offset %d: %w", offset, err)
+				return 0, fmt.Errorf("update prompts at offset %d: %w", offset, err)
 			}
-			task, err := s.client.WaitForTask(taskInfo.TaskUID, 500*time.Millisecond)
+			task, err := s.client.WaitForTaskWithContext(ctx, taskInfo.TaskUID, 500*time.Millisecond)
 			if err != nil {
-				return total, fmt.Errorf("wait for prompts task at offset %d: %w", offset, err)
+				return 0, fmt.Errorf("wait for prompts task at offset %d: %w", offset, err)
 			}
 			if task.Status == meilisearch.TaskStatusFailed {
-				return total, fmt.Errorf("prompts task failed at offset %d: %s", offset, task.Error.Message)
+				return 0, fmt.Errorf("prompts task failed at offset %d: %s", offset, task.Error.Message)
 			}
 		}
-
-		total += len(prompts)
-		fmt.Printf("Prompts: migrated %d so far (scanned %d/%d)\n",
-			total, offset+int64(len(result.Results)), result.Total)
-		offset += int64(batchSize)
-
-		if offset >= result.Total {
-			break
-		}
+		return len(prompts), nil
 	}
-
-	return total, nil
+	return s.migratePages(ctx, batchSize, fields, page, func(count int, scanned, total int64) {
+		fmt.Printf("Prompts: migrated %d so far (scanned %d/%d)\n", count, scanned, total)
+	})
 }
 
 // extractPromptMigrationFields extracts a PromptDocument from a raw
@@ -542,6 +1383,9 @@ func extractPromptMigrationFields(hit meilisearch.Hit) (*PromptDocument, error)
 	if raw, ok := hit["prompt"]; ok {
 		json.Unmarshal(raw, &pdoc.Prompt)
 	}
+	if raw, ok := hit["prompt_length_original"]; ok {
+		json.Unmarshal(raw, &pdoc.PromptLengthOriginal)
+	}
 	if raw, ok := hit["cwd"]; ok {
 		json.Unmarshal(raw, &pdoc.Cwd)
 	}
@@ -556,6 +1400,9 @@ func extractPromptMigrationFields(hit meilisearch.Hit) (*PromptDocument, error)
 	}
 
 	pdoc.PromptLength = len(pdoc.Prompt)
+	if pdoc.PromptLengthOriginal == 0 {
+		pdoc.PromptLengthOriginal = pdoc.PromptLength
+	}
 
 	return &pdoc, nil
 }
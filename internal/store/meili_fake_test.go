@@ -0,0 +1,117 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRequest is one request received by fakeMeili.
+type fakeRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// fakeMeili is a minimal in-process MeiliSearch API for exercising MeiliStore
+// setup without a real server. Every write is answered 202 with a new task
+// that GET /tasks/{uid} reports as succeeded. Paths matched by fail (method
+// + " " + path prefix) answer 500 instead; responses holds canned 200 bodies
+// keyed by method + " " + exact path. Paths matched by deny (same prefix
+// form) answer 403 as for an API key without access. With stuckTasks every
+// task stays processing, as on an overloaded server. search, if set, answers
+// POST /indexes/{uid}/search from the request body instead of responses.
+type fakeMeili struct {
+	mu         sync.Mutex
+	requests   []fakeRequest
+	nextTask   int64
+	fail       []string
+	deny       []string
+	responses  map[string]string
+	stuckTasks bool
+	search     func(body []byte) string
+}
+
+// newFakeMeili starts a fakeMeili and returns it with its base URL.
+func newFakeMeili(t *testing.T) (*fakeMeili, string) {
+	t.Helper()
+	f := &fakeMeili{}
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	return f, ts.URL
+}
+
+func (f *fakeMeili) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+	canned, hasCanned := f.responses[r.Method+" "+r.URL.Path]
+	failing, denied, stuck := false, false, f.stuckTasks
+	search := f.search
+	for _, prefix := range f.deny {
+		if strings.HasPrefix(r.Method+" "+r.URL.Path, prefix) {
+			denied = true
+		}
+	}
+	for _, prefix := range f.fail {
+		if strings.HasPrefix(r.Method+" "+r.URL.Path, prefix) {
+			failing = true
+		}
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case denied:
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"The provided API key is invalid.","code":"invalid_api_key","type":"auth","link":""}`)
+	case failing:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"message":"fake failure","code":"internal","type":"internal","link":""}`)
+	case hasCanned:
+		fmt.Fprint(w, canned)
+	case search != nil && r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/search"):
+		fmt.Fprint(w, search(body))
+	case r.URL.Path == "/health":
+		fmt.Fprint(w, `{"status":"available"}`)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/tasks/"):
+		uid, status := strings.TrimPrefix(r.URL.Path, "/tasks/"), "succeeded"
+		if stuck {
+			status = "processing"
+		}
+		fmt.Fprintf(w, `{"uid":%s,"status":"%s","type":"settingsUpdate"}`, uid, status)
+	case r.Method == http.MethodGet:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"not found","code":"not_found","type":"invalid_request","link":""}`)
+	default:
+		f.mu.Lock()
+		f.nextTask++
+		uid := f.nextTask
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, `{"taskUid":%d,"status":"enqueued","type":"settingsUpdate"}`, uid)
+	}
+}
+
+// body returns the JSON body of the last request matching method and path,
+// decoded into v. It fails the test if there is no such request.
+func (f *fakeMeili) body(t *testing.T, method, path string, v interface{}) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		req := f.requests[i]
+		if req.Method == method && req.Path == path {
+			if err := json.Unmarshal(req.Body, v); err != nil {
+				t.Fatalf("decode %s %s body: %v", method, path, err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no %s %s request received", method, path)
+}
@@ -0,0 +1,444 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// TopCosts returns the events with cost_usd above q.MinCost, sorted by cost
+// descending, relying on cost_usd being both filterable and sortable.
+func (s *MeiliStore) TopCosts(ctx context.Context, q CostQuery) (CostReport, error) {
+	filters := []string{"cost_usd > " + strconv.FormatFloat(q.MinCost, 'f', -1, 64)}
+	if q.From > 0 {
+		filters = append(filters, fmt.Sprintf("timestamp_unix >= %d", q.From))
+	}
+	if q.To > 0 {
+		filters = append(filters, fmt.Sprintf("timestamp_unix <= %d", q.To))
+	}
+
+	resp, err := s.index.SearchWithContext(ctx, "", &meilisearch.SearchRequest{
+		Filter:               strings.Join(filters, " AND "),
+		Sort:                 []string{"cost_usd:desc"},
+		Limit:                int64(q.Limit),
+		AttributesToRetrieve: searchAttributes,
+	})
+	if err != nil {
+		return CostReport{}, fmt.Errorf("search costs: %w", err)
+	}
+
+	docs, err := decodeHits(resp.Hits)
+	if err != nil {
+		return CostReport{}, err
+	}
+	report := CostReport{Documents: docs}
+	for _, d := range docs {
+		report.TotalUSD += d.CostUSD
+	}
+	return report, nil
+}
+
+// DistinctValues returns the distinct values of field with their event
+// counts, using a facet search, and keeps the limit most frequent (0 keeps
+// them all). MeiliSearch reports at most the index's MaxValuesPerFacet values,
+// so a larger limit fails with ErrFacetLimit. field must be filterable.
+func (s *MeiliStore) DistinctValues(ctx context.Context, field string, limit int) ([]DistinctValue, error) {
+	if !IsFilterable(field) {
+		return nil, fmt.Errorf("field %q is not filterable", field)
+	}
+	if int64(limit) > s.limits.maxValuesPerFacet {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFacetLimit, limit, s.limits.maxValuesPerFacet)
+	}
+	// Limit 1 with a single retrieved attribute: only the facets are wanted,
+	// and the SDK omits a zero limit.
+	resp, err := s.index.SearchWithContext(ctx, "", &meilisearch.SearchRequest{
+		Facets:               []string{field},
+		Limit:                1,
+		AttributesToRetrieve: []string{"id"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("facet search %s: %w", field, err)
+	}
+
+	dist, err := decodeFacetDistribution(resp.FacetDistribution)
+	if err != nil {
+		return nil, err
+	}
+	values := sortedDistinct(dist[field])
+	if limit > 0 && len(values) > limit {
+		values = values[:limit]
+	}
+	return values, nil
+}
+
+// overviewFacets are the fields whose distributions Overview reports.
+var overviewFacets = []string{"hook_type", "tool_name", "project_dir", "permission_mode"}
+
+// Overview reports the event count, time span, and overviewFacets
+// distributions with a single facet search. The total is the sum of the
+// hook_type counts (every event has one), which unlike the search's hit
+// count is not capped at MaxTotalHits. The span comes from timestamp_unix's
+// facet stats.
+func (s *MeiliStore) Overview(ctx context.Context) (Overview, error) {
+	// As in DistinctValues, limit 1 because the SDK omits a zero limit.
+	resp, err := s.index.SearchWithContext(ctx, "", &meilisearch.SearchRequest{
+		Facets:               append(slices.Clone(overviewFacets), "timestamp_unix"),
+		Limit:                1,
+		AttributesToRetrieve: []string{"id"},
+	})
+	if err != nil {
+		return Overview{}, fmt.Errorf("overview search: %w", err)
+	}
+
+	dist, err := decodeFacetDistribution(resp.FacetDistribution)
+	if err != nil {
+		return Overview{}, err
+	}
+	ov := Overview{Facets: make(map[string][]DistinctValue, len(overviewFacets))}
+	for _, field := range overviewFacets {
+		ov.Facets[field] = sortedDistinct(dist[field])
+	}
+	for _, c := range dist["hook_type"] {
+		ov.Total += c
+	}
+
+	if len(resp.FacetStats) > 0 {
+		var stats map[string]struct {
+			Min float64 `json:"min"`
+			Max float64 `json:"max"`
+		}
+		if err := json.Unmarshal(resp.FacetStats, &stats); err != nil {
+			return Overview{}, fmt.Errorf("decode facet stats: %w", err)
+		}
+		if ts, ok := stats["timestamp_unix"]; ok {
+			ov.From, ov.To = int64(ts.Min), int64(ts.Max)
+		}
+	}
+	return ov, nil
+}
+
+// Search returns one page of hits, newest first. Hits are sorted by
+// timestamp_unix then id so the order is stable, and the page boundary is
+// carried in an opaque cursor (see searchCursor) rather than an offset, so
+// events ingested between requests neither shift nor duplicate later pages.
+// NextCursor is set only when the page is full. With a Query, MeiliSearch's
+// ranking rules order hits by relevance before the sort, so the cursor's
+// newest-first boundary does not hold: a cursor is rejected with
+// ErrInvalidCursor and pages are reached by Offset instead, with NextOffset
+// set when the page is full. MeiliSearch returns no hits past the index's
+// MaxTotalHits, so a Limit above it or an Offset at or past it is
+// ErrSearchWindow, a page reaching it is cut short, and NextOffset is never
+// set to it.
+func (s *MeiliStore) Search(ctx context.Context, p SearchParams) (SearchResult, error) {
+	if p.Offset < 0 || (p.Offset > 0 && p.Query == "") {
+		return SearchResult{}, fmt.Errorf("%w: %d (an offset needs a query; page by cursor without one)", ErrInvalidOffset, p.Offset)
+	}
+	maxHits := int(s.limits.maxTotalHits)
+	if p.Limit > maxHits {
+		return SearchResult{}, fmt.Errorf("%w: limit %d above %d", ErrSearchWindow, p.Limit, maxHits)
+	}
+	var filters []string
+	if p.HookType != "" {
+		filters = append(filters, "hook_type = "+quoteFilterValue(p.HookType))
+	}
+	if p.SessionID != "" {
+		filters = append(filters, "session_id = "+quoteFilterValue(p.SessionID))
+	}
+	if p.Filter != "" {
+		if err := ValidateFilter(p.Filter); err != nil {
+			return SearchResult{}, err
+		}
+		filters = append(filters, "("+p.Filter+")")
+	}
+	var cur searchCursor
+	if p.Cursor != "" {
+		if p.Query != "" {
+			return SearchResult{}, fmt.Errorf("%w: not valid with a query, whose hits are ordered by relevance", ErrInvalidCursor)
+		}
+		var err error
+		if cur, err = decodeCursor(p.Cursor); err != nil {
+			return SearchResult{}, err
+		}
+		filters = append(filters, cur.filter())
+	}
+	offset := cur.Skip + p.Offset
+	if offset >= maxHits {
+		return SearchResult{}, fmt.Errorf("%w: offset %d not below %d", ErrSearchWindow, offset, maxHits)
+	}
+	limit := min(p.Limit, maxHits-offset)
+
+	req := &meilisearch.SearchRequest{
+		Sort:   []string{"timestamp_unix:desc", "id:asc"},
+		Limit:  int64(limit),
+		Offset: int64(offset),
+	}
+	if len(p.Fields) == 0 {
+		req.AttributesToRetrieve = searchAttributes
+	} else {
+		for _, f := range p.Fields {
+			if !slices.Contains(searchAttributes, f) {
+				return SearchResult{}, fmt.Errorf("%w: %q", ErrUnknownField, f)
+			}
+		}
+		// The next cursor is built from each hit's id and timestamp.
+		req.AttributesToRetrieve = append([]string{"id", "timestamp_unix"}, p.Fields...)
+	}
+	if len(filters) > 0 {
+		req.Filter = strings.Join(filters, " AND ")
+	}
+	resp, err := s.index.SearchWithContext(ctx, p.Query, req)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("search: %w", err)
+	}
+	docs, err := decodeHits(resp.Hits)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{Hits: docs}
+	if limit > 0 && len(docs) == limit {
+		if p.Query != "" {
+			if next := offset + len(docs); next < maxHits {
+				result.NextOffset = next
+			}
+		} else {
+			result.NextCursor = encodeCursor(nextCursor(cur, docs))
+		}
+	}
+	return result, nil
+}
+
+// maxSameSecond caps the events EventContext fetches that share the
+// target's timestamp_unix; past it, neighbors within that second may be
+// missed.
+const maxSameSecond = 1000
+
+// EventContext returns up to before and after events of the document's
+// session around it. timestamp_unix has second resolution and MeiliSearch
+// cannot compare ids in a filter, so events in the target's second are
+// fetched together and split at the target by id; earlier and later seconds
+// take one search each, nearest first.
+func (s *MeiliStore) EventContext(ctx context.Context, id string, before, after int) (EventContext, error) {
+	target, err := s.GetDocument(ctx, id)
+	if err != nil {
+		return EventContext{}, err
+	}
+	target.RawBody, target.DataFlat = nil, ""
+	ec := EventContext{Event: target, Before: []Document{}, After: []Document{}}
+	if target.SessionID == "" || (before <= 0 && after <= 0) {
+		return ec, nil
+	}
+
+	session := "session_id = " + quoteFilterValue(target.SessionID)
+	ts := target.TimestampUnix
+	same, err := s.sessionPage(ctx, fmt.Sprintf("%s AND timestamp_unix = %d", session, ts), "id:asc", maxSameSecond)
+	if err != nil {
+		return EventContext{}, err
+	}
+	i := slices.IndexFunc(same, func(d Document) bool { return d.ID == id })
+	if i < 0 {
+		// Not searchable yet (its task is still pending), or past
+		// maxSameSecond.
+		i = len(same)
+		same = append(same, target)
+	}
+
+	if before > 0 {
+		ec.Before = same[max(0, i-before):i]
+		if n := before - len(ec.Before); n > 0 {
+			older, err := s.sessionPage(ctx, fmt.Sprintf("%s AND timestamp_unix < %d", session, ts), "timestamp_unix:desc", n)
+			if err != nil {
+				return EventContext{}, err
+			}
+			slices.Reverse(older)
+			ec.Before = append(older, ec.Before...)
+		}
+	}
+	if after > 0 {
+		ec.After = slices.Clone(same[i+1 : min(len(same), i+1+after)])
+		if n := after - len(ec.After); n > 0 {
+			newer, err := s.sessionPage(ctx, fmt.Sprintf("%s AND timestamp_unix > %d", session, ts), "timestamp_unix:asc", n)
+			if err != nil {
+				return EventContext{}, err
+			}
+			ec.After = append(ec.After, newer...)
+		}
+	}
+	return ec, nil
+}
+
+// sessionPage returns up to limit documents matching filter, sorted by
+// order and then by id in the same direction.
+func (s *MeiliStore) sessionPage(ctx context.Context, filter, order string, limit int) ([]Document, error) {
+	sortBy := []string{order}
+	if order != "id:asc" {
+		sortBy = append(sortBy, "id:"+strings.TrimPrefix(order, "timestamp_unix:"))
+	}
+	resp, err := s.index.SearchWithContext(ctx, "", &meilisearch.SearchRequest{
+		Filter:               filter,
+		Sort:                 sortBy,
+		Limit:                int64(limit),
+		AttributesToRetrieve: searchAttributes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("context search: %w", err)
+	}
+	return decodeHits(resp.Hits)
+}
+
+// WarmUpResult is the latency of one index's warm-up search.
+type WarmUpResult struct {
+	Index   string
+	Latency time.Duration
+}
+
+// WarmUp issues a trivial search (empty query, limit 1) against the main
+// index and, if enabled, the prompts index, so the first real query after
+// startup doesn't pay MeiliSearch's cold-start cost. It stops at the first
+// failure, returning the results so far.
+func (s *MeiliStore) WarmUp(ctx context.Context) ([]WarmUpResult, error) {
+	indexes := []meilisearch.IndexManager{s.index}
+	names := []string{s.indexName}
+	if s.indexPrompts != nil {
+		indexes = append(indexes, s.indexPrompts)
+		names = append(names, s.promptsIndexName)
+	}
+
+	var results []WarmUpResult
+	for i, idx := range indexes {
+		start := time.Now()
+		if _, err := idx.SearchWithContext(ctx, "", &meilisearch.SearchRequest{Limit: 1}); err != nil {
+			return results, fmt.Errorf("warm-up search on %s: %w", names[i], err)
+		}
+		results = append(results, WarmUpResult{Index: names[i], Latency: time.Since(start)})
+	}
+	return results, nil
+}
+
+// CountByFilter returns how many documents match filter and how many the
+// index holds in total. Search counts stop at the index's MaxTotalHits, so a
+// filter reaching that cap is reported as matching everything.
+func (s *MeiliStore) CountByFilter(ctx context.Context, filter string) (int64, int64, error) {
+	stats, err := s.index.GetStatsWithContext(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get index stats: %w", err)
+	}
+	resp, err := s.index.SearchWithContext(ctx, "", &meilisearch.SearchRequest{
+		Filter:               filter,
+		HitsPerPage:          1,
+		Page:                 1,
+		AttributesToRetrieve: []string{"id"},
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("count by filter: %w", err)
+	}
+	matched := resp.TotalHits
+	if matched >= s.limits.maxTotalHits {
+		matched = stats.NumberOfDocuments
+	}
+	return matched, stats.NumberOfDocuments, nil
+}
+
+// DeleteByFilter deletes every document matching filter from the main index
+// and waits for the task to finish. The prompts index is left untouched.
+func (s *MeiliStore) DeleteByFilter(ctx context.Context, filter string) (DeleteResult, error) {
+	taskInfo, err := s.index.DeleteDocumentsByFilterWithContext(ctx, filter, nil)
+	if err != nil {
+		return DeleteResult{}, fmt.Errorf("delete by filter: %w", err)
+	}
+	return s.waitForDelete(ctx, s.indexName, taskInfo)
+}
+
+// Clear deletes every document from the main index and, if enabled, the
+// prompts index, waiting for each task. Index settings are kept.
+func (s *MeiliStore) Clear(ctx context.Context) ([]DeleteResult, error) {
+	type target struct {
+		name  string
+		index meilisearch.IndexManager
+	}
+	targets := []target{{s.indexName, s.index}}
+	if s.indexPrompts != nil {
+		targets = append(targets, target{s.promptsIndexName, s.indexPrompts})
+	}
+
+	var results []DeleteResult
+	for _, t := range targets {
+		taskInfo, err := t.index.DeleteAllDocumentsWithContext(ctx, nil)
+		if err != nil {
+			return results, fmt.Errorf("clear %s: %w", t.name, err)
+		}
+		result, err := s.waitForDelete(ctx, t.name, taskInfo)
+		results = append(results, result)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// waitForDelete waits for a document deletion task on index and reports it.
+// A failed task is returned as an error alongside its result.
+func (s *MeiliStore) waitForDelete(ctx context.Context, index string, taskInfo *meilisearch.TaskInfo) (DeleteResult, error) {
+	task, err := s.client.WaitForTaskWithContext(ctx, taskInfo.TaskUID, 500*time.Millisecond)
+	if err != nil {
+		return DeleteResult{Index: index}, fmt.Errorf("wait for delete on %s: %w", index, err)
+	}
+	result := DeleteResult{
+		Index:            index,
+		TaskUID:          task.UID,
+		Status:           string(task.Status),
+		DeletedDocuments: task.Details.DeletedDocuments,
+	}
+	if task.Status == meilisearch.TaskStatusFailed {
+		return result, fmt.Errorf("delete task on %s failed: %s", index, task.Error.Message)
+	}
+	return result, nil
+}
+
+// decodeFacetDistribution decodes a facetDistribution payload
+// (field → value → count). An empty payload yields an empty map.
+func decodeFacetDistribution(raw json.RawMessage) (map[string]map[string]int64, error) {
+	dist := map[string]map[string]int64{}
+	if len(raw) == 0 {
+		return dist, nil
+	}
+	if err := json.Unmarshal(raw, &dist); err != nil {
+		return nil, fmt.Errorf("decode facet distribution: %w", err)
+	}
+	return dist, nil
+}
+
+// sortedDistinct orders facet counts by count descending, then value.
+func sortedDistinct(counts map[string]int64) []DistinctValue {
+	values := make([]DistinctValue, 0, len(counts))
+	for v, c := range counts {
+		values = append(values, DistinctValue{Value: v, Count: c})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
+}
+
+// decodeHits converts raw search hits into Documents.
+func decodeHits(hits meilisearch.Hits) ([]Document, error) {
+	docs := make([]Document, 0, len(hits))
+	for _, hit := range hits {
+		var doc Document
+		if err := hit.DecodeInto(&doc); err != nil {
+			return nil, fmt.Errorf("decode hit: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSortedDistinct(t *testing.T) {
+	t.Parallel()
+
+	got := sortedDistinct(map[string]int64{"b": 2, "a": 2, "c": 9})
+	want := []DistinctValue{{"c", 9}, {"a", 2}, {"b", 2}}
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeFacetDistribution(t *testing.T) {
+	t.Parallel()
+
+	dist, err := decodeFacetDistribution(json.RawMessage(`{"tool_name":{"Read":3,"Bash":1}}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if dist["tool_name"]["Read"] != 3 {
+		t.Errorf("Read = %d, want 3", dist["tool_name"]["Read"])
+	}
+
+	empty, err := decodeFacetDistribution(nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("empty payload: got %v, %v", empty, err)
+	}
+}
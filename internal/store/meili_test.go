@@ -0,0 +1,1626 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// rawHit builds a meilisearch.Hit from a plain map, as the SDK would
+// return it from GetDocuments.
+func rawHit(t *testing.T, fields map[string]interface{}) meilisearch.Hit {
+	t.Helper()
+	hit := meilisearch.Hit{}
+	for k, v := range fields {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", k, err)
+		}
+		hit[k] = b
+	}
+	return hit
+}
+
+func TestExtractMigrationFields_Teammate(t *testing.T) {
+	t.Parallel()
+
+	hit := rawHit(t, map[string]interface{}{
+		"id": "doc-1",
+		"data": map[string]interface{}{
+			"teammate": map[string]interface{}{"id": "tm-1", "name": "planner"},
+		},
+	})
+
+	partial, err := extractMigrationFields(hit)
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if partial["teammate_id"] != "tm-1" {
+		t.Errorf("teammate_id = %v, want tm-1", partial["teammate_id"])
+	}
+	if partial["teammate_name"] != "planner" {
+		t.Errorf("teammate_name = %v, want planner", partial["teammate_name"])
+	}
+}
+
+func TestExtractMigrationFields_TotalTokens(t *testing.T) {
+	t.Parallel()
+
+	hit := rawHit(t, map[string]interface{}{
+		"id": "doc-1",
+		"data": map[string]interface{}{
+			"usage": map[string]interface{}{
+				"input_tokens":                float64(1000),
+				"output_tokens":               float64(250),
+				"cache_read_input_tokens":     float64(40),
+				"cache_creation_input_tokens": float64(10),
+			},
+			"total_cost_usd": 0.013,
+		},
+	})
+
+	partial, err := extractMigrationFields(hit)
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if partial["total_tokens"] != int64(1300) {
+		t.Errorf("total_tokens = %v, want 1300", partial["total_tokens"])
+	}
+	if v, _ := partial["cost_per_k_token"].(float64); math.Abs(v-0.01) > 1e-12 {
+		t.Errorf("cost_per_k_token = %v, want 0.01", partial["cost_per_k_token"])
+	}
+}
+
+func TestExtractMigrationFields_Subagent(t *testing.T) {
+	t.Parallel()
+
+	hit := rawHit(t, map[string]interface{}{
+		"id":   "doc-1",
+		"data": map[string]interface{}{"parent_session_id": "sess-main"},
+	})
+
+	partial, err := extractMigrationFields(hit)
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if partial["is_subagent"] != true || partial["parent_session_id"] != "sess-main" {
+		t.Errorf("partial = %v, want is_subagent and parent_session_id", partial)
+	}
+}
+
+func TestExtractMigrationFields_SessionMeta(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{"version": "2.0.14", "model": "claude-sonnet-4-5"}
+	partial, err := extractMigrationFields(rawHit(t, map[string]interface{}{"id": "doc-1", "hook_type": "SessionStart", "data": data}))
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if partial["claude_version"] != "2.0.14" || partial["session_model"] != "claude-sonnet-4-5" {
+		t.Errorf("partial = %v, want claude_version and session_model", partial)
+	}
+
+	partial, err = extractMigrationFields(rawHit(t, map[string]interface{}{"id": "doc-2", "hook_type": "PreToolUse", "data": data}))
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if _, ok := partial["claude_version"]; ok {
+		t.Errorf("PreToolUse partial = %v, want no claude_version", partial)
+	}
+}
+
+func TestExtractMigrationFields_NotificationResponse(t *testing.T) {
+	t.Parallel()
+
+	partial, err := extractMigrationFields(rawHit(t, map[string]interface{}{
+		"id": "doc-1", "hook_type": "Notification", "data": map[string]interface{}{"response": "Dismiss"},
+	}))
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if partial["notification_response"] != "dismiss" {
+		t.Errorf("partial = %v, want notification_response dismiss", partial)
+	}
+}
+
+func TestExtractMigrationFields_ExitCode(t *testing.T) {
+	t.Parallel()
+
+	partial, err := extractMigrationFields(rawHit(t, map[string]interface{}{
+		"id": "doc-1", "hook_type": "PostToolUse",
+		"data": map[string]interface{}{"tool_name": "Bash", "tool_response": map[string]interface{}{"exit_code": 0}},
+	}))
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if got, ok := partial["exit_code"]; !ok || got != int64(0) {
+		t.Errorf("partial = %v, want exit_code 0", partial)
+	}
+}
+
+func TestExtractMigrationFields_Success(t *testing.T) {
+	t.Parallel()
+
+	for hookType, want := range map[string]interface{}{
+		"PostToolUse":        true,
+		"PostToolUseFailure": false,
+		"PreToolUse":         nil,
+	} {
+		partial, err := extractMigrationFields(rawHit(t, map[string]interface{}{"id": "doc-1", "hook_type": hookType}))
+		if err != nil {
+			t.Fatalf("extractMigrationFields: %v", err)
+		}
+		if got, ok := partial["success"]; got != want || ok != (want != nil) {
+			t.Errorf("%s: success = %v (present %v), want %v", hookType, got, ok, want)
+		}
+	}
+}
+
+func TestExtractMigrationFields_TimeBuckets(t *testing.T) {
+	t.Parallel()
+
+	partial, err := extractMigrationFields(rawHit(t, map[string]interface{}{
+		"id":             "doc-1",
+		"timestamp_unix": 1772029800, // 2026-02-25T14:30:00Z
+	}))
+	if err != nil {
+		t.Fatalf("extractMigrationFields: %v", err)
+	}
+	if partial["day"] != "2026-02-25" || partial["hour"] != 14 {
+		t.Errorf("partial = %v, want day 2026-02-25 hour 14", partial)
+	}
+
+	// Already bucketed documents are left alone.
+	partial, _ = extractMigrationFields(rawHit(t, map[string]interface{}{
+		"id": "doc-2", "timestamp_unix": 1772029800, "day": "2026-02-25",
+	}))
+	if _, ok := partial["day"]; ok {
+		t.Errorf("partial = %v, want no day for bucketed document", partial)
+	}
+}
+
+func TestNewMeiliStore_SearchableAttributeOrder(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	if _, err := NewMeiliStore(url, "", "events", ""); err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+
+	var got []string
+	fake.body(t, "PUT", "/indexes/events/settings/searchable-attributes", &got)
+	want := DefaultSearchableAttributes()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("searchable attributes = %v, want %v", got, want)
+	}
+	// prompt and error_message must outrank the flattened data blob.
+	if got[0] != "prompt" || got[1] != "error_message" || got[len(got)-1] != "data_flat" {
+		t.Errorf("searchable order = %v, want prompt, error_message first and data_flat last", got)
+	}
+}
+
+func TestNewMeiliStoreWithOptions_SearchableAttributes(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	custom := []string{"error_message", "prompt", "data_flat"}
+	if _, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{SearchableAttributes: custom}); err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+
+	var got []string
+	fake.body(t, "PUT", "/indexes/events/settings/searchable-attributes", &got)
+	if strings.Join(got, ",") != strings.Join(custom, ",") {
+		t.Errorf("searchable attributes = %v, want %v", got, custom)
+	}
+}
+
+func TestNewMeiliStoreWithOptions_PromptRank(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rank   string
+		custom []string
+		want   string
+	}{
+		{"", nil, "prompt,error_message,tool_name,hook_type,session_id,data_flat"},
+		{PromptRankFirst, []string{"error_message", "prompt", "data_flat"}, "prompt,error_message,data_flat"},
+		{PromptRankLast, nil, "error_message,tool_name,hook_type,session_id,prompt,data_flat"},
+		{PromptRankLast, []string{"prompt", "error_message"}, "error_message,prompt"},
+		{PromptRankOff, nil, "error_message,tool_name,hook_type,session_id,data_flat"},
+	}
+	for _, tt := range tests {
+		fake, url := newFakeMeili(t)
+		opts := MeiliOptions{SearchableAttributes: tt.custom, PromptRank: tt.rank}
+		if _, err := NewMeiliStoreWithOptions(url, "", "events", "", opts); err != nil {
+			t.Fatalf("rank %q: %v", tt.rank, err)
+		}
+		var got []string
+		fake.body(t, "PUT", "/indexes/events/settings/searchable-attributes", &got)
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("rank %q, custom %v: searchable = %v, want %s", tt.rank, tt.custom, got, tt.want)
+		}
+	}
+
+	if _, err := NewMeiliStoreWithOptions("http://unused", "", "events", "", MeiliOptions{PromptRank: "middle"}); err == nil {
+		t.Error("invalid prompt rank: want error")
+	}
+	if got := DefaultSearchableAttributes(); got[0] != "prompt" {
+		t.Errorf("rankPrompt modified the defaults: %v", got)
+	}
+}
+
+func TestGetSettings(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"GET /indexes/events/settings": `{"rankingRules":["words","attribute"],"searchableAttributes":["prompt","data_flat"],` +
+			`"filterableAttributes":["hook_type"],"sortableAttributes":["timestamp_unix"],` +
+			`"pagination":{"maxTotalHits":10000},"faceting":{"maxValuesPerFacet":500}}`,
+		"GET /indexes/prompts/settings": `{"searchableAttributes":["prompt","session_id"]}`,
+	}
+
+	got, err := ms.GetSettings(context.Background())
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d indexes, want 2 (main + prompts)", len(got))
+	}
+	main := got[0]
+	if main.Index != "events" || main.MaxTotalHits != 10000 || main.MaxValuesPerFacet != 500 {
+		t.Errorf("main = %+v", main)
+	}
+	if strings.Join(main.SearchableAttributes, ",") != "prompt,data_flat" {
+		t.Errorf("main searchable = %v", main.SearchableAttributes)
+	}
+	if got[1].Index != "prompts" || strings.Join(got[1].SearchableAttributes, ",") != "prompt,session_id" {
+		t.Errorf("prompts = %+v", got[1])
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"GET /indexes/events/documents/doc-1": `{"id":"doc-1","hook_type":"PreToolUse","timestamp":"2026-02-25T14:30:00.000Z",` +
+			`"timestamp_unix":1772029800,"tool_name":"Bash","data":{"tool_name":"Bash","session_id":"s1"}}`,
+	}
+
+	got, err := ms.Update(context.Background(), "doc-1", map[string]interface{}{"error": "exit status 1"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got.ID != "doc-1" || got.ErrorMessage != "exit status 1" || got.SessionID != "s1" {
+		t.Errorf("updated = %+v", got)
+	}
+
+	var sent []Document
+	fake.body(t, "PUT", "/indexes/events/documents", &sent)
+	if len(sent) != 1 || sent[0].ID != "doc-1" || sent[0].ErrorMessage != "exit status 1" {
+		t.Errorf("partial update body = %+v", sent)
+	}
+}
+
+func TestUpdate_NotFound(t *testing.T) {
+	t.Parallel()
+
+	_, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	if _, err := ms.Update(context.Background(), "missing", map[string]interface{}{"a": "b"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetDocument(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	body := `{"hook_type":"Stop", "data":{}}`
+	raw, _ := json.Marshal(CompressRawBody([]byte(body)))
+	fake.responses = map[string]string{
+		"GET /indexes/events/documents/doc-1": `{"id":"doc-1","hook_type":"Stop","raw_body":` + string(raw) + `,"data":{}}`,
+	}
+
+	doc, err := ms.GetDocument(context.Background(), "doc-1")
+	if err != nil {
+		t.Fatalf("GetDocument: %v", err)
+	}
+	got, err := DecompressRawBody(doc.RawBody)
+	if doc.ID != "doc-1" || err != nil || string(got) != body {
+		t.Errorf("doc %s raw body = %q (%v), want %q", doc.ID, got, err, body)
+	}
+	if _, err := ms.GetDocument(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDocument(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEventContext(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	hit := func(id string, ts int) string {
+		return fmt.Sprintf(`{"id":%q,"session_id":"s1","timestamp_unix":%d}`, id, ts)
+	}
+	fake.responses = map[string]string{
+		"GET /indexes/events/documents/c":    `{"id":"c","session_id":"s1","timestamp_unix":100,"data_flat":"x"}`,
+		"GET /indexes/events/documents/lone": `{"id":"lone","timestamp_unix":100}`,
+	}
+	var searches []meilisearch.SearchRequest
+	fake.search = func(body []byte) string {
+		var req meilisearch.SearchRequest
+		json.Unmarshal(body, &req)
+		searches = append(searches, req)
+		filter, _ := req.Filter.(string)
+		switch {
+		case strings.HasSuffix(filter, "timestamp_unix = 100"):
+			return `{"hits":[` + hit("b", 100) + `,` + hit("c", 100) + `,` + hit("d", 100) + `]}`
+		case strings.HasSuffix(filter, "timestamp_unix < 100"):
+			return `{"hits":[` + hit("o2", 90) + `,` + hit("o1", 80) + `]}`
+		default:
+			return `{"hits":[` + hit("n1", 110) + `]}`
+		}
+	}
+
+	ec, err := ms.EventContext(context.Background(), "c", 3, 2)
+	if err != nil {
+		t.Fatalf("EventContext: %v", err)
+	}
+	ids := func(docs []Document) string {
+		var out []string
+		for _, d := range docs {
+			out = append(out, d.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	if ec.Event.ID != "c" || ec.Event.DataFlat != "" || ids(ec.Before) != "o1,o2,b" || ids(ec.After) != "d,n1" {
+		t.Errorf("context = %s [%s] %s, want o1,o2,b [c] d,n1", ids(ec.Before), ec.Event.ID, ids(ec.After))
+	}
+	if len(searches) != 3 {
+		t.Fatalf("%d searches, want 3", len(searches))
+	}
+	if s := searches[1]; s.Filter != `session_id = "s1" AND timestamp_unix < 100` || s.Limit != 2 || strings.Join(s.Sort, ",") != "timestamp_unix:desc,id:desc" {
+		t.Errorf("older search = %v %d %v", s.Filter, s.Limit, s.Sort)
+	}
+	if s := searches[2]; s.Limit != 1 || strings.Join(s.Sort, ",") != "timestamp_unix:asc,id:asc" {
+		t.Errorf("newer search = %v %d %v", s.Filter, s.Limit, s.Sort)
+	}
+
+	// Enough neighbors within the second: no further searches.
+	searches = nil
+	if ec, _ = ms.EventContext(context.Background(), "c", 1, 1); ids(ec.Before) != "b" || ids(ec.After) != "d" || len(searches) != 1 {
+		t.Errorf("context = %s / %s after %d searches, want b / d after 1", ids(ec.Before), ids(ec.After), len(searches))
+	}
+
+	// No session: just the event.
+	searches = nil
+	if ec, err = ms.EventContext(context.Background(), "lone", 5, 5); err != nil || ec.Event.ID != "lone" || len(ec.Before)+len(ec.After) != 0 || len(searches) != 0 {
+		t.Errorf("no session: %+v, %v after %d searches", ec, err, len(searches))
+	}
+	if _, err := ms.EventContext(context.Background(), "missing", 5, 5); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestIndex_PromptsWriteFailure(t *testing.T) {
+	t.Parallel()
+
+	doc := Document{ID: "p-1", HookType: "UserPromptSubmit", Prompt: "hello"}
+	for _, strict := range []bool{false, true} {
+		fake, url := newFakeMeili(t)
+		ms, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{StrictPrompts: strict})
+		if err != nil {
+			t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+		}
+		fake.fail = []string{"POST /indexes/prompts/documents"}
+
+		err = ms.Index(context.Background(), doc)
+		if strict && err == nil {
+			t.Error("strict: Index = nil, want prompts write error")
+		}
+		if !strict && err != nil {
+			t.Errorf("non-strict: Index = %v, want nil", err)
+		}
+		if got := ms.PromptsWriteErrors(); got != 1 {
+			t.Errorf("strict=%v: PromptsWriteErrors = %d, want 1", strict, got)
+		}
+	}
+}
+
+func TestIndex_SkipEmptyPrompts(t *testing.T) {
+	t.Parallel()
+
+	for _, skip := range []bool{false, true} {
+		fake, url := newFakeMeili(t)
+		ms, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{SkipEmptyPrompts: skip})
+		if err != nil {
+			t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+		}
+		fake.mu.Lock()
+		fake.requests = nil
+		fake.mu.Unlock()
+
+		ctx := context.Background()
+		if err := ms.Index(ctx, Document{ID: "blank", HookType: "UserPromptSubmit", Prompt: "  "}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		if err := ms.BatchIndex(ctx, []Document{{ID: "empty", HookType: "UserPromptSubmit"}}); err != nil {
+			t.Fatalf("BatchIndex: %v", err)
+		}
+
+		var main, prompts int
+		fake.mu.Lock()
+		for _, r := range fake.requests {
+			switch {
+			case r.Method == "POST" && r.Path == "/indexes/events/documents":
+				main++
+			case r.Method == "POST" && r.Path == "/indexes/prompts/documents":
+				prompts++
+			}
+		}
+		fake.mu.Unlock()
+		wantPrompts := 2
+		if skip {
+			wantPrompts = 0
+		}
+		if main != 2 || prompts != wantPrompts {
+			t.Errorf("skip=%v: main writes %d, prompts writes %d; want 2, %d", skip, main, prompts, wantPrompts)
+		}
+	}
+}
+
+func TestBatchIndex(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+
+	docs := []Document{
+		{ID: "a", HookType: "PreToolUse"},
+		{ID: "b", HookType: "UserPromptSubmit", Prompt: "hi"},
+		{ID: "c", HookType: "Stop"},
+	}
+	if err := ms.BatchIndex(context.Background(), docs); err != nil {
+		t.Fatalf("BatchIndex: %v", err)
+	}
+
+	var main []Document
+	fake.body(t, "POST", "/indexes/events/documents", &main)
+	if len(main) != 3 {
+		t.Errorf("main index batch = %d docs, want 3", len(main))
+	}
+	var prompts []PromptDocument
+	fake.body(t, "POST", "/indexes/prompts/documents", &prompts)
+	if len(prompts) != 1 || prompts[0].ID != "b" {
+		t.Errorf("prompts batch = %+v, want only b", prompts)
+	}
+}
+
+// TestBatchIndex_SingleEnqueue checks a large batch is one task per index,
+// not one per document.
+func TestBatchIndex_SingleEnqueue(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	docs := make([]Document, 500)
+	for i := range docs {
+		docs[i] = Document{ID: fmt.Sprintf("d%d", i), HookType: "PreToolUse"}
+		if i%5 == 0 {
+			docs[i].HookType, docs[i].Prompt = "UserPromptSubmit", "hi"
+		}
+	}
+	fake.mu.Lock()
+	fake.requests = nil // drop setup traffic
+	fake.mu.Unlock()
+
+	if err := ms.BatchIndex(context.Background(), docs); err != nil {
+		t.Fatalf("BatchIndex: %v", err)
+	}
+
+	calls := map[string]int{}
+	fake.mu.Lock()
+	for _, r := range fake.requests {
+		calls[r.Method+" "+r.Path]++
+	}
+	fake.mu.Unlock()
+	if calls["POST /indexes/events/documents"] != 1 || calls["POST /indexes/prompts/documents"] != 1 {
+		t.Errorf("document writes = %v, want one per index", calls)
+	}
+	var main []Document
+	fake.body(t, "POST", "/indexes/events/documents", &main)
+	var prompts []PromptDocument
+	fake.body(t, "POST", "/indexes/prompts/documents", &prompts)
+	if len(main) != 500 || len(prompts) != 100 {
+		t.Errorf("batches of %d and %d docs, want 500 and 100", len(main), len(prompts))
+	}
+}
+
+func TestSearch_Cursor(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[` +
+			`{"id":"a","hook_type":"Stop","timestamp_unix":200},` +
+			`{"id":"b","hook_type":"Stop","timestamp_unix":100},` +
+			`{"id":"c","hook_type":"Stop","timestamp_unix":100}]}`,
+	}
+
+	first, err := ms.Search(context.Background(), SearchParams{Filter: "hook_type = Stop", Limit: 3})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(first.Hits) != 3 || first.NextCursor == "" {
+		t.Fatalf("first page = %+v, want 3 hits and a cursor", first)
+	}
+
+	var req struct {
+		Filter string   `json:"filter"`
+		Sort   []string `json:"sort"`
+		Offset int      `json:"offset"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	if req.Filter != "(hook_type = Stop)" {
+		t.Errorf("first filter = %v", req.Filter)
+	}
+	if want := []string{"timestamp_unix:desc", "id:asc"}; strings.Join(req.Sort, ",") != strings.Join(want, ",") {
+		t.Errorf("sort = %v, want %v", req.Sort, want)
+	}
+
+	if _, err := ms.Search(context.Background(), SearchParams{Filter: "hook_type = Stop", Limit: 3, Cursor: first.NextCursor}); err != nil {
+		t.Fatalf("Search(cursor): %v", err)
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	want := `(hook_type = Stop) AND timestamp_unix <= 100`
+	if req.Filter != want || req.Offset != 2 {
+		t.Errorf("cursor filter = %v offset %d, want %s offset 2", req.Filter, req.Offset, want)
+	}
+
+	if _, err := ms.Search(context.Background(), SearchParams{HookType: "Stop", SessionID: `s"1`, Filter: "tool_name = Bash", Limit: 3}); err != nil {
+		t.Fatalf("Search(hook_type, session_id): %v", err)
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	want = `hook_type = "Stop" AND session_id = "s\"1" AND (tool_name = Bash)`
+	if req.Filter != want {
+		t.Errorf("field filter = %v, want %s", req.Filter, want)
+	}
+
+	fake.responses["POST /indexes/events/search"] = `{"hits":[{"id":"d","timestamp_unix":50}]}`
+	last, err := ms.Search(context.Background(), SearchParams{Limit: 3})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if last.NextCursor != "" {
+		t.Errorf("short page NextCursor = %q, want empty", last.NextCursor)
+	}
+}
+
+func TestSearch_QueryRankedByRelevance(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	// With q, ranking rules put the best match first whatever its age, so
+	// a timestamp cursor after this page would skip "old" (100 < 300).
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[` +
+			`{"id":"old","timestamp_unix":100},` +
+			`{"id":"new","timestamp_unix":300},` +
+			`{"id":"mid","timestamp_unix":200}]}`,
+	}
+
+	page, err := ms.Search(context.Background(), SearchParams{Query: "timeout", Limit: 3})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(page.Hits) != 3 || page.Hits[0].ID != "old" {
+		t.Errorf("hits = %+v, want relevance order kept", page.Hits)
+	}
+	if page.NextCursor != "" {
+		t.Errorf("NextCursor = %q, want none with a query", page.NextCursor)
+	}
+
+	if page.NextOffset != 3 {
+		t.Errorf("NextOffset = %d, want 3", page.NextOffset)
+	}
+
+	cursor := encodeCursor(searchCursor{T: 200})
+	if _, err := ms.Search(context.Background(), SearchParams{Query: "timeout", Limit: 3, Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor with query: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestSearch_QueryOffset(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[{"id":"c","timestamp_unix":100}]}`,
+	}
+
+	page, err := ms.Search(context.Background(), SearchParams{Query: "timeout", Limit: 2, Offset: 4})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var req struct {
+		Offset int `json:"offset"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	if req.Offset != 4 {
+		t.Errorf("offset = %d, want 4", req.Offset)
+	}
+	if page.NextOffset != 0 || page.NextCursor != "" {
+		t.Errorf("last page = %+v, want no next page", page)
+	}
+
+	for _, p := range []SearchParams{{Limit: 2, Offset: 4}, {Query: "timeout", Limit: 2, Offset: -1}} {
+		if _, err := ms.Search(context.Background(), p); !errors.Is(err, ErrInvalidOffset) {
+			t.Errorf("%+v: err = %v, want ErrInvalidOffset", p, err)
+		}
+	}
+}
+
+func TestSearch_MaxTotalHitsWindow(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{MaxTotalHits: 10})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[{"id":"i","timestamp_unix":100},{"id":"j","timestamp_unix":90}]}`,
+	}
+
+	// The last window page is cut to what MeiliSearch can return, and a
+	// full one there offers no next page.
+	page, err := ms.Search(context.Background(), SearchParams{Query: "timeout", Limit: 5, Offset: 8})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var req struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	if req.Limit != 2 || req.Offset != 8 {
+		t.Errorf("limit, offset = %d, %d; want 2, 8", req.Limit, req.Offset)
+	}
+	if page.NextOffset != 0 {
+		t.Errorf("NextOffset = %d, want none at MaxTotalHits", page.NextOffset)
+	}
+
+	for _, p := range []SearchParams{{Limit: 11}, {Query: "timeout", Limit: 1, Offset: 10}} {
+		if _, err := ms.Search(context.Background(), p); !errors.Is(err, ErrSearchWindow) {
+			t.Errorf("%+v: err = %v, want ErrSearchWindow", p, err)
+		}
+	}
+}
+
+func TestDistinctValues_Limit(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{MaxValuesPerFacet: 1000})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	for _, index := range []string{"events", "prompts"} {
+		var faceting meilisearch.Faceting
+		fake.body(t, "PATCH", "/indexes/"+index+"/settings/faceting", &faceting)
+		if faceting.MaxValuesPerFacet != 1000 {
+			t.Errorf("%s maxValuesPerFacet = %d, want 1000", index, faceting.MaxValuesPerFacet)
+		}
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[],"facetDistribution":{"file_path":{"/a":1,"/b":5,"/c":3}}}`,
+	}
+
+	all, err := ms.DistinctValues(context.Background(), "file_path", 0)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("limit 0: %+v, %v; want all 3 values", all, err)
+	}
+	top, err := ms.DistinctValues(context.Background(), "file_path", 2)
+	if err != nil {
+		t.Fatalf("limit 2: %v", err)
+	}
+	if len(top) != 2 || top[0].Value != "/b" || top[1].Value != "/c" {
+		t.Errorf("limit 2 = %+v, want /b and /c (most frequent)", top)
+	}
+	if _, err := ms.DistinctValues(context.Background(), "file_path", 1000); err != nil {
+		t.Errorf("limit at maximum: %v", err)
+	}
+	if _, err := ms.DistinctValues(context.Background(), "file_path", 1001); !errors.Is(err, ErrFacetLimit) {
+		t.Errorf("limit above maximum: err = %v, want ErrFacetLimit", err)
+	}
+}
+
+func TestOverview(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[{"id":"a"}],"estimatedTotalHits":1000,` +
+			`"facetDistribution":{"hook_type":{"Stop":3,"PreToolUse":6},"tool_name":{"Bash":4},"timestamp_unix":{"100":1,"900":8}},` +
+			`"facetStats":{"timestamp_unix":{"min":100,"max":900}}}`,
+	}
+
+	ov, err := ms.Overview(context.Background())
+	if err != nil {
+		t.Fatalf("Overview: %v", err)
+	}
+	if ov.Total != 9 || ov.From != 100 || ov.To != 900 {
+		t.Errorf("total/from/to = %d/%d/%d, want 9/100/900", ov.Total, ov.From, ov.To)
+	}
+	if got := ov.Facets["hook_type"]; len(got) != 2 || got[0] != (DistinctValue{Value: "PreToolUse", Count: 6}) {
+		t.Errorf("hook_type = %+v, want PreToolUse first", got)
+	}
+	if got, ok := ov.Facets["permission_mode"]; !ok || len(got) != 0 {
+		t.Errorf("permission_mode = %+v, %v; want present and empty", got, ok)
+	}
+	if _, ok := ov.Facets["timestamp_unix"]; ok {
+		t.Error("timestamp_unix distribution reported as a facet")
+	}
+
+	var req struct {
+		Facets []string `json:"facets"`
+		Limit  int64    `json:"limit"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	if want := []string{"hook_type", "tool_name", "project_dir", "permission_mode", "timestamp_unix"}; !slices.Equal(req.Facets, want) {
+		t.Errorf("facets = %v, want %v", req.Facets, want)
+	}
+	if req.Limit != 1 {
+		t.Errorf("limit = %d, want 1", req.Limit)
+	}
+}
+
+func TestSearch_InvalidInput(t *testing.T) {
+	t.Parallel()
+
+	_, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	if _, err := ms.Search(context.Background(), SearchParams{Limit: 1, Cursor: "not a cursor!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor = %v, want ErrInvalidCursor", err)
+	}
+	if _, err := ms.Search(context.Background(), SearchParams{Limit: 1, Filter: "secret = 1"}); err == nil {
+		t.Error("non-filterable attribute: err = nil")
+	}
+	for _, field := range []string{"data_flat", "secret"} {
+		if _, err := ms.Search(context.Background(), SearchParams{Limit: 1, Fields: []string{field}}); !errors.Is(err, ErrUnknownField) {
+			t.Errorf("fields=%s: err = %v, want ErrUnknownField", field, err)
+		}
+	}
+}
+
+func TestSearch_Fields(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{"POST /indexes/events/search": `{"hits":[]}`}
+
+	if _, err := ms.Search(context.Background(), SearchParams{Limit: 5}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var req struct {
+		AttributesToRetrieve []string `json:"attributesToRetrieve"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	if slices.Contains(req.AttributesToRetrieve, "raw_body") || !slices.Contains(req.AttributesToRetrieve, "data") {
+		t.Errorf("no fields: attributesToRetrieve = %v, want every displayed field but raw_body", req.AttributesToRetrieve)
+	}
+	if _, err := ms.Search(context.Background(), SearchParams{Limit: 5, Fields: []string{"raw_body"}}); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("raw_body field: err = %v, want ErrUnknownField", err)
+	}
+
+	if _, err := ms.Search(context.Background(), SearchParams{Limit: 5, Fields: []string{"hook_type", "data"}}); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var withFields struct {
+		AttributesToRetrieve []string `json:"attributesToRetrieve"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &withFields)
+	if got := strings.Join(withFields.AttributesToRetrieve, ","); got != "id,timestamp_unix,hook_type,data" {
+		t.Errorf("attributesToRetrieve = %s, want id,timestamp_unix,hook_type,data", got)
+	}
+}
+
+func TestTopCosts(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search": `{"hits":[{"id":"a","cost_usd":0.5},{"id":"b","cost_usd":0.25}]}`,
+	}
+
+	report, err := ms.TopCosts(context.Background(), CostQuery{MinCost: 0.1, From: 100, Limit: 2})
+	if err != nil {
+		t.Fatalf("TopCosts: %v", err)
+	}
+	if len(report.Documents) != 2 || report.TotalUSD != 0.75 {
+		t.Errorf("report = %+v, want 2 documents totaling 0.75", report)
+	}
+	var req struct {
+		Filter               string   `json:"filter"`
+		AttributesToRetrieve []string `json:"attributesToRetrieve"`
+	}
+	fake.body(t, "POST", "/indexes/events/search", &req)
+	if req.Filter != "cost_usd > 0.1 AND timestamp_unix >= 100" {
+		t.Errorf("filter = %q", req.Filter)
+	}
+	// raw_body is displayed for GET /documents/{id}?include_raw=true but
+	// must not come back with /costs.
+	if slices.Contains(req.AttributesToRetrieve, "raw_body") || !slices.Contains(req.AttributesToRetrieve, "cost_usd") {
+		t.Errorf("attributesToRetrieve = %v, want every displayed field but raw_body", req.AttributesToRetrieve)
+	}
+}
+
+func TestNewMeiliStore_DisplayedAttributes(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	if _, err := NewMeiliStore(url, "", "events", ""); err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	var displayed []string
+	fake.body(t, "PUT", "/indexes/events/settings/displayed-attributes", &displayed)
+	if slices.Contains(displayed, "data_flat") {
+		t.Error("data_flat should not be displayed")
+	}
+	for _, f := range []string{"id", "hook_type", "timestamp_unix", "data", "tags", "raw_body"} {
+		if !slices.Contains(displayed, f) {
+			t.Errorf("displayed attributes %v missing %s", displayed, f)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+
+	results, err := ms.Clear(context.Background())
+	if err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if len(results) != 2 || results[0].Index != "events" || results[1].Index != "prompts" {
+		t.Fatalf("results = %+v, want events then prompts", results)
+	}
+	if results[0].Status != "succeeded" {
+		t.Errorf("status = %q, want succeeded", results[0].Status)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	var cleared []string
+	for _, r := range fake.requests {
+		if r.Method == "DELETE" {
+			cleared = append(cleared, r.Path)
+		}
+	}
+	if strings.Join(cleared, ",") != "/indexes/events/documents,/indexes/prompts/documents" {
+		t.Errorf("DELETE requests = %v", cleared)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/search":  `{"hits":[]}`,
+		"POST /indexes/prompts/search": `{"hits":[]}`,
+	}
+
+	results, err := ms.WarmUp(context.Background())
+	if err != nil {
+		t.Fatalf("WarmUp: %v", err)
+	}
+	if len(results) != 2 || results[0].Index != "events" || results[1].Index != "prompts" {
+		t.Errorf("results = %+v, want events then prompts", results)
+	}
+
+	fake.fail = []string{"POST /indexes/prompts/search"}
+	results, err = ms.WarmUp(context.Background())
+	if err == nil || len(results) != 1 {
+		t.Errorf("failing prompts search: results = %+v, err = %v; want 1 result and an error", results, err)
+	}
+}
+
+func TestMigrateDocuments_SourceLabel(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{SourceLabel: "laptop"})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[{"id":"a","data":{}},{"id":"b","data":{},"source":"ci"}],` +
+			`"offset":0,"limit":100,"total":2}`,
+	}
+
+	if _, err := ms.MigrateDocuments(context.Background(), 100); err != nil {
+		t.Fatalf("MigrateDocuments: %v", err)
+	}
+	var sent []map[string]interface{}
+	fake.body(t, "PUT", "/indexes/events/documents", &sent)
+	if len(sent) != 1 || sent[0]["id"] != "a" || sent[0]["source"] != "laptop" {
+		t.Errorf("updates = %v, want only a labeled laptop", sent)
+	}
+}
+
+func TestMigrateField(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{SourceLabel: "laptop"})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[` +
+			`{"id":"a","hook_type":"PostToolUse","data":{"tool_name":"Bash","cwd":"/w","tool_response":{"exit_code":2}}},` +
+			`{"id":"b","hook_type":"PostToolUse","data":{"tool_name":"Read","cwd":"/w"}}],` +
+			`"offset":0,"limit":100,"total":2}`,
+	}
+
+	n, err := ms.MigrateField(context.Background(), 100, "exit_code")
+	if err != nil {
+		t.Fatalf("MigrateField: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("updated = %d, want 1", n)
+	}
+	var sent []map[string]interface{}
+	fake.body(t, "PUT", "/indexes/events/documents", &sent)
+	want := []map[string]interface{}{{"id": "a", "exit_code": float64(2)}}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("updates = %v, want %v (no other fields)", sent, want)
+	}
+
+	if _, err := ms.MigrateField(context.Background(), 100, "data_flat"); err == nil || !strings.Contains(err.Error(), "data_flat") {
+		t.Errorf("unknown field: err = %v", err)
+	}
+}
+
+func TestMigrateTimestamps(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	// 2026-02-25T14:30:00Z is 1772029800.
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[` +
+			`{"id":"ok","timestamp":"2026-02-25T14:30:00.000Z","timestamp_unix":1772029800},` +
+			`{"id":"skewed","timestamp":"2026-02-25T14:30:00.000Z","timestamp_unix":1772033400},` +
+			`{"id":"missing","timestamp":"2026-02-25T14:30:00.500Z"},` +
+			`{"id":"garbled","timestamp":"yesterday","timestamp_unix":1}],` +
+			`"offset":0,"limit":100,"total":4}`,
+	}
+
+	n, err := ms.MigrateTimestamps(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("MigrateTimestamps: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("corrected = %d, want 2", n)
+	}
+	var sent []map[string]interface{}
+	fake.body(t, "PUT", "/indexes/events/documents", &sent)
+	want := []map[string]interface{}{
+		{"id": "skewed", "timestamp_unix": float64(1772029800)},
+		{"id": "missing", "timestamp_unix": float64(1772029800)},
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("updates = %v, want %v", sent, want)
+	}
+}
+
+// TestMigratableFields keeps migratableFields in sync with what
+// extractMigrationFields (plus the source label) can produce.
+func TestMigratableFields(t *testing.T) {
+	t.Parallel()
+
+	produced := map[string]bool{"source": true}
+	for _, hit := range []map[string]interface{}{
+		{"id": "1", "hook_type": "PostToolUse", "timestamp_unix": 1772000000, "data": map[string]interface{}{
+			"tool_name": "Bash", "tool_input": map[string]interface{}{"file_path": "/f"}, "tool_response": map[string]interface{}{"exit_code": 1},
+			"error": "e", "permission_mode": "default", "cwd": "/w", "prompt": "p", "tags": []interface{}{"t"}, "turn": 3,
+			"_monitor":    map[string]interface{}{"project_dir": "/w", "has_claude_md": true},
+			"teammate_id": "t1", "teammate_name": "n", "parent_session_id": "s0",
+			"usage": map[string]interface{}{"input_tokens": 10, "output_tokens": 5}, "total_cost_usd": 0.1,
+		}},
+		{"id": "2", "hook_type": "SessionStart", "data": map[string]interface{}{"version": "2.0", "model": "m"}},
+		{"id": "3", "hook_type": "Notification", "data": map[string]interface{}{"response": "approve"}},
+	} {
+		partial, err := extractMigrationFields(rawHit(t, hit))
+		if err != nil {
+			t.Fatalf("extractMigrationFields: %v", err)
+		}
+		for k := range partial {
+			produced[k] = true
+		}
+	}
+	delete(produced, "id")
+	var got []string
+	for k := range produced {
+		got = append(got, k)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, migratableFields) {
+		t.Errorf("extractMigrationFields produces %v, migratableFields = %v", got, migratableFields)
+	}
+}
+
+func TestNewMeiliStoreWithOptions_SettingsTimeout(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	fake.stuckTasks = true
+	start := time.Now()
+	_, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{SettingsTimeout: 200 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("setup took %v, want it to abort soon after the timeout", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "within 200ms") || !strings.Contains(msg, "searchable attributes") {
+		t.Errorf("err = %q, want the timeout and the stuck setting named", msg)
+	}
+}
+
+func TestNewMeiliStore_SkipsMatchingSettings(t *testing.T) {
+	t.Parallel()
+
+	settings := func(searchable []string) string {
+		filterable := FilterableAttributes()
+		slices.Reverse(filterable) // order must not matter
+		raw, _ := json.Marshal(map[string]interface{}{
+			"searchableAttributes": searchable,
+			"filterableAttributes": filterable,
+			"sortableAttributes":   []string{"cost_per_k_token", "cost_usd", "exit_code", "id", "input_tokens", "output_tokens", "timestamp_unix", "total_tokens", "turn_number"},
+			"displayedAttributes":  mainDisplayedAttributes,
+			"pagination":           map[string]int{"maxTotalHits": DefaultMaxTotalHits},
+			"faceting":             map[string]int{"maxValuesPerFacet": DefaultMaxValuesPerFacet},
+		})
+		return string(raw)
+	}
+	settingsWrites := func(f *fakeMeili) []string {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var paths []string
+		for _, r := range f.requests {
+			if r.Method != "GET" && strings.HasPrefix(r.Path, "/indexes/events/settings") {
+				paths = append(paths, r.Path)
+			}
+		}
+		return paths
+	}
+
+	fake, url := newFakeMeili(t)
+	fake.responses = map[string]string{"GET /indexes/events/settings": settings(DefaultSearchableAttributes())}
+	if _, err := NewMeiliStore(url, "", "events", ""); err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	if got := settingsWrites(fake); len(got) != 0 {
+		t.Errorf("unchanged settings: writes = %v, want none", got)
+	}
+
+	// Same searchable set in a different order is a change.
+	reordered := DefaultSearchableAttributes()
+	slices.Reverse(reordered)
+	fake, url = newFakeMeili(t)
+	fake.responses = map[string]string{"GET /indexes/events/settings": settings(reordered)}
+	if _, err := NewMeiliStore(url, "", "events", ""); err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	if got := settingsWrites(fake); len(got) != 1 || got[0] != "/indexes/events/settings/searchable-attributes" {
+		t.Errorf("reordered searchable: writes = %v, want only searchable-attributes", got)
+	}
+}
+
+func TestNewMeiliStore_MaxTotalHits(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		opt, want int64
+	}{
+		{0, DefaultMaxTotalHits},
+		{50000, 50000},
+	} {
+		fake, url := newFakeMeili(t)
+		if _, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{MaxTotalHits: tt.opt}); err != nil {
+			t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+		}
+		for _, index := range []string{"events", "prompts"} {
+			var got meilisearch.Pagination
+			fake.body(t, "PATCH", "/indexes/"+index+"/settings/pagination", &got)
+			if got.MaxTotalHits != tt.want {
+				t.Errorf("MaxTotalHits %d: %s maxTotalHits = %d, want %d", tt.opt, index, got.MaxTotalHits, tt.want)
+			}
+		}
+	}
+}
+
+func TestIndex_EnqueueLatencyMetric(t *testing.T) {
+	t.Parallel()
+
+	_, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	ms.Index(context.Background(), Document{ID: "a", HookType: "Stop"})
+	ms.Index(context.Background(), Document{ID: "b", HookType: "UserPromptSubmit", Prompt: "hi"})
+
+	var b strings.Builder
+	for _, m := range ms.Metrics() {
+		m.WritePrometheus(&b)
+	}
+	for _, want := range []string{
+		`hooks_store_index_enqueue_seconds_count{index="main"} 2`,
+		`hooks_store_index_enqueue_seconds_count{index="prompts"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestNewMeiliStore_KeyLacksIndexAccess(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	fake.deny = []string{"GET /indexes/events"}
+	_, err := NewMeiliStore(url, "scoped-key", "events", "")
+	if err == nil || !strings.Contains(err.Error(), `key lacks access to index "events"`) {
+		t.Fatalf("err = %v, want key lacks access to index \"events\"", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, r := range fake.requests {
+		if r.Method == "POST" && r.Path == "/indexes" {
+			t.Error("index creation attempted after the access check failed")
+		}
+	}
+}
+
+func TestNewMeiliStoreWithOptions_NoCreateIndex(t *testing.T) {
+	t.Parallel()
+
+	// A documents-only key: index info is forbidden, documents are readable.
+	fake, url := newFakeMeili(t)
+	fake.deny = []string{"GET /indexes/"}
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch":  `{"results":[],"offset":0,"limit":1,"total":0}`,
+		"POST /indexes/prompts/documents/fetch": `{"results":[],"offset":0,"limit":1,"total":0}`,
+	}
+	if _, err := NewMeiliStoreWithOptions(url, "docs-key", "events", "prompts", MeiliOptions{NoCreateIndex: true}); err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.mu.Lock()
+	for _, r := range fake.requests {
+		if r.Path == "/indexes" || strings.Contains(r.Path, "/settings") {
+			t.Errorf("%s %s sent with NoCreateIndex", r.Method, r.Path)
+		}
+	}
+	fake.mu.Unlock()
+
+	// A missing (here: unreadable) index fails startup instead of being created.
+	fake2, url2 := newFakeMeili(t)
+	fake2.fail = []string{"POST /indexes/events/documents/fetch"}
+	if _, err := NewMeiliStoreWithOptions(url2, "docs-key", "events", "", MeiliOptions{NoCreateIndex: true}); err == nil || !strings.Contains(err.Error(), `index "events"`) {
+		t.Errorf("err = %v, want index \"events\" error", err)
+	}
+}
+
+func TestMeiliStore_IndexInto(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	fake.mu.Lock()
+	fake.requests = nil
+	fake.mu.Unlock()
+
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		doc := Document{ID: id, HookType: "UserPromptSubmit"}
+		if err := ms.IndexInto(ctx, "tenant", doc); err != nil {
+			t.Fatalf("IndexInto(tenant, %s): %v", id, err)
+		}
+	}
+	if err := ms.IndexInto(ctx, "events", Document{ID: "c"}); err != nil {
+		t.Fatalf("IndexInto(events): %v", err)
+	}
+
+	var creates, settings, tenantWrites, mainWrites, promptWrites int
+	fake.mu.Lock()
+	for _, r := range fake.requests {
+		switch {
+		case r.Method == "POST" && r.Path == "/indexes":
+			creates++
+		case strings.HasPrefix(r.Path, "/indexes/tenant/settings") && r.Method != "GET":
+			settings++
+		case r.Method == "POST" && r.Path == "/indexes/tenant/documents":
+			tenantWrites++
+		case r.Method == "POST" && r.Path == "/indexes/events/documents":
+			mainWrites++
+		case r.Method == "POST" && r.Path == "/indexes/prompts/documents":
+			promptWrites++
+		}
+	}
+	fake.mu.Unlock()
+	if creates != 1 || settings == 0 {
+		t.Errorf("tenant setup: %d creates, %d settings writes; want 1 create and settings applied once", creates, settings)
+	}
+	if tenantWrites != 2 || mainWrites != 1 {
+		t.Errorf("writes: tenant %d, events %d; want 2, 1", tenantWrites, mainWrites)
+	}
+	if promptWrites != 0 {
+		t.Errorf("prompts writes = %d, want 0 for target-index documents", promptWrites)
+	}
+}
+
+func TestMeiliStore_IndexInto_SlowSetup(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{SettingsTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.mu.Lock()
+	fake.stuckTasks = true
+	fake.mu.Unlock()
+
+	first := make(chan error, 1)
+	go func() { first <- ms.IndexInto(context.Background(), "slow", Document{ID: "a"}) }()
+	for started := false; !started; time.Sleep(time.Millisecond) {
+		ms.targetsMu.Lock()
+		_, started = ms.targets["slow"]
+		ms.targetsMu.Unlock()
+	}
+
+	// A second writer waits for the setup in progress, not for a lock, so
+	// its own deadline still applies.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := ms.IndexInto(ctx, "slow", Document{ID: "b"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting writer: err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waiting writer returned after %v, want its 50ms deadline", elapsed)
+	}
+
+	if err := <-first; err == nil {
+		t.Fatal("stuck setup: err = nil")
+	}
+	// The failed setup is dropped, so the next write retries it.
+	fake.mu.Lock()
+	fake.stuckTasks = false
+	fake.mu.Unlock()
+	if err := ms.IndexInto(context.Background(), "slow", Document{ID: "c"}); err != nil {
+		t.Errorf("retry after failed setup: %v", err)
+	}
+}
+
+func TestMeiliStore_IndexInto_NoCreateIndex(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[],"offset":0,"limit":1,"total":0}`,
+	}
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "", MeiliOptions{NoCreateIndex: true})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.fail = []string{"POST /indexes/missing/documents/fetch"}
+	if err := ms.IndexInto(context.Background(), "missing", Document{ID: "a"}); err == nil || !strings.Contains(err.Error(), `index "missing"`) {
+		t.Errorf("err = %v, want index \"missing\" error", err)
+	}
+}
+
+func TestNewMeiliStore_KeyLacksPromptsAccess(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	fake.deny = []string{"GET /indexes/prompts"}
+	if _, err := NewMeiliStore(url, "scoped-key", "events", "prompts"); err == nil || !strings.Contains(err.Error(), `index "prompts"`) {
+		t.Errorf("err = %v, want prompts access error", err)
+	}
+	// Optional prompts index degrades instead.
+	if _, err := NewMeiliStoreWithOptions(url, "scoped-key", "events", "prompts", MeiliOptions{PromptsOptional: true}); err != nil {
+		t.Errorf("optional prompts index: %v", err)
+	}
+}
+
+func TestNewMeiliStore_PromptsOptional(t *testing.T) {
+	t.Parallel()
+
+	for _, optional := range []bool{false, true} {
+		fake, url := newFakeMeili(t)
+		fake.fail = []string{"PUT /indexes/prompts/settings"}
+
+		ms, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{PromptsOptional: optional})
+		if !optional {
+			if err == nil {
+				t.Error("required prompts index: err = nil, want setup failure")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("optional prompts index: %v", err)
+		}
+
+		// Degraded: prompts are indexed in the main index only.
+		if err := ms.Index(context.Background(), Document{ID: "p-1", HookType: "UserPromptSubmit", Prompt: "hi"}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		fake.mu.Lock()
+		for _, r := range fake.requests {
+			if r.Method == "POST" && r.Path == "/indexes/prompts/documents" {
+				t.Error("document written to the disabled prompts index")
+			}
+		}
+		fake.mu.Unlock()
+	}
+}
+
+func TestMeiliStore_Healthy(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	if err := ms.Healthy(context.Background()); err != nil {
+		t.Errorf("Healthy = %v, want nil", err)
+	}
+
+	fake.mu.Lock()
+	fake.fail = []string{"GET /health"}
+	fake.mu.Unlock()
+	if err := ms.Healthy(context.Background()); err == nil {
+		t.Error("Healthy with failing /health = nil, want error")
+	}
+}
+
+func TestMigratePrompts_Workers(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{MigrateWorkers: 3})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	// The fake answers every page with the same two hits, one a prompt.
+	fake.mu.Lock()
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[{"id":"p","hook_type":"UserPromptSubmit","prompt":"hi"},` +
+			`{"id":"t","hook_type":"PreToolUse"}],"offset":0,"limit":2,"total":10}`,
+	}
+	fake.requests = nil
+	fake.mu.Unlock()
+
+	count, err := ms.MigratePrompts(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("MigratePrompts: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("count = %d, want 5 (one prompt per page)", count)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	offsets := map[int64]bool{}
+	adds := 0
+	for _, r := range fake.requests {
+		switch r.Method + " " + r.Path {
+		case "POST /indexes/events/documents/fetch":
+			var q struct {
+				Offset int64 `json:"offset"`
+			}
+			if err := json.Unmarshal(r.Body, &q); err != nil {
+				t.Fatalf("decode fetch: %v", err)
+			}
+			offsets[q.Offset] = true
+		case "PUT /indexes/prompts/documents":
+			adds++
+		}
+	}
+	if len(offsets) != 5 || !offsets[0] || !offsets[8] {
+		t.Errorf("fetched offsets = %v, want 0,2,4,6,8", offsets)
+	}
+	if adds != 5 {
+		t.Errorf("prompt upserts = %d, want 5", adds)
+	}
+}
+
+func TestMigratePrompts_TruncatedPrompt(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	// Indexed with --max-prompt-bytes 4: the stored prompt is cut, the
+	// original length kept beside it.
+	fake.mu.Lock()
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[{"id":"p","hook_type":"UserPromptSubmit",` +
+			`"prompt":"long","prompt_length_original":4096}],"offset":0,"limit":100,"total":1}`,
+	}
+	fake.requests = nil
+	fake.mu.Unlock()
+
+	if _, err := ms.MigratePrompts(context.Background(), 100); err != nil {
+		t.Fatalf("MigratePrompts: %v", err)
+	}
+	var fetch struct {
+		Fields []string `json:"fields"`
+	}
+	fake.body(t, "POST", "/indexes/events/documents/fetch", &fetch)
+	if !slices.Contains(fetch.Fields, "prompt_length_original") {
+		t.Errorf("fetched fields = %v, want prompt_length_original among them", fetch.Fields)
+	}
+	var prompts []PromptDocument
+	fake.body(t, "PUT", "/indexes/prompts/documents", &prompts)
+	if len(prompts) != 1 || prompts[0].PromptLength != 4 || prompts[0].PromptLengthOriginal != 4096 {
+		t.Errorf("prompts = %+v, want length 4 of original 4096", prompts)
+	}
+}
+
+func TestMigratePrompts_WorkersError(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStoreWithOptions(url, "", "events", "prompts", MeiliOptions{MigrateWorkers: 2})
+	if err != nil {
+		t.Fatalf("NewMeiliStoreWithOptions: %v", err)
+	}
+	fake.mu.Lock()
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[{"id":"p","hook_type":"UserPromptSubmit"}],"offset":0,"limit":1,"total":6}`,
+	}
+	fake.fail = []string{"PUT /indexes/prompts/documents"}
+	fake.mu.Unlock()
+
+	if _, err := ms.MigratePrompts(context.Background(), 1); err == nil {
+		t.Error("MigratePrompts with failing adds = nil, want error")
+	}
+}
+
+func TestMigrations_PreserveUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	fake, url := newFakeMeili(t)
+	ms, err := NewMeiliStore(url, "", "events", "prompts")
+	if err != nil {
+		t.Fatalf("NewMeiliStore: %v", err)
+	}
+	// A document augmented outside hooks-store with team_note.
+	fake.mu.Lock()
+	fake.responses = map[string]string{
+		"POST /indexes/events/documents/fetch": `{"results":[{"id":"a","hook_type":"UserPromptSubmit","prompt":"hi",` +
+			`"team_note":"keep me","data":{"prompt":"hi","cwd":"/p"}}],"offset":0,"limit":100,"total":1}`,
+	}
+	fake.requests = nil
+	fake.mu.Unlock()
+
+	ctx := context.Background()
+	if _, err := ms.MigrateDocuments(ctx, 100); err != nil {
+		t.Fatalf("MigrateDocuments: %v", err)
+	}
+	if _, err := ms.MigrateDataFlat(ctx, 100); err != nil {
+		t.Fatalf("MigrateDataFlat: %v", err)
+	}
+	if _, err := ms.MigratePrompts(ctx, 100); err != nil {
+		t.Fatalf("MigratePrompts: %v", err)
+	}
+
+	// MeiliSearch keeps fields a PUT (UpdateDocuments) omits, while a POST
+	// (AddDocuments) replaces the whole document. So every write must be a
+	// PUT that does not mention team_note.
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	writes := 0
+	for _, r := range fake.requests {
+		if !strings.HasSuffix(r.Path, "/documents") {
+			continue
+		}
+		writes++
+		if r.Method != "PUT" {
+			t.Errorf("%s %s replaces documents, want PUT merge", r.Method, r.Path)
+		}
+		if strings.Contains(string(r.Body), "team_note") {
+			t.Errorf("%s %s body mentions team_note: %s", r.Method, r.Path, r.Body)
+		}
+	}
+	if writes != 3 {
+		t.Errorf("document writes = %d, want 3 (one per migration)", writes)
+	}
+}
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"hooks-store/internal/hookevt"
+)
+
+// TransformStage is one step of the transform pipeline. It edits doc in
+// place; evt is the wire event, whose Data is the same map as doc.Data at
+// the start of the pipeline. A returned error stops the pipeline.
+type TransformStage func(doc *Document, evt hookevt.HookEvent) error
+
+// StageBuilder makes a stage for the given options, so a stage can read
+// settings such as MaxValueLen without taking them on every call.
+type StageBuilder func(opts TransformOptions) TransformStage
+
+// DefaultStages is the pipeline run when TransformOptions.Stages is empty.
+// It reproduces the transform as it was before stages existed (sample is a
+// no-op unless Sample is set, envelope unless FlatEnvelope is, and
+// strip-ansi and normalize-paths unless StripANSI and NormalizePaths are).
+// sample comes first so a dropped event costs no further work.
+var DefaultStages = []string{"sample", "envelope", "redact", "extract-fields", "strip-ansi", "normalize-paths", "enrich"}
+
+// ErrSampledOut is returned (wrapped in a *StageError) by the sample stage
+// for an event that sampling drops. It is a skip, not a failure: the
+// ingest server answers 202 "sampled" for it.
+var ErrSampledOut = errors.New("sampled out")
+
+var (
+	stagesMu sync.RWMutex
+	stages   = map[string]StageBuilder{
+		"sample":          sampleStage,
+		"envelope":        envelopeStage,
+		"redact":          redactStage,
+		"extract-fields":  extractFieldsStage,
+		"strip-ansi":      stripANSIStage,
+		"normalize-paths": normalizePathsStage,
+		"sanitize-utf8":   sanitizeUTF8Stage,
+		"enrich":          enrichStage,
+	}
+)
+
+// RegisterStage adds a named stage that TransformOptions.Stages can list.
+// Registering a name twice replaces the earlier builder. Safe for concurrent
+// use; typically called from init or main before serving.
+func RegisterStage(name string, build StageBuilder) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	stages[name] = build
+}
+
+// StageNames returns the names of every known stage, sorted.
+func StageNames() []string {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	names := make([]string, 0, len(stages))
+	for name := range stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateStages checks a pipeline before it is used: every name must be a
+// known stage and appear at most once.
+func ValidateStages(names []string) error {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := stages[name]; !ok {
+			return fmt.Errorf("unknown transform stage %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("transform stage %q listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ValidateRedaction checks a pipeline used with a SessionIDKey: redact must
+// be listed, and before extract-fields, which copies the raw session IDs
+// out of data into the filterable session_id and parent_session_id fields
+// and data_flat, where a later redact would not reach them. Empty names
+// means DefaultStages, which is valid.
+func ValidateRedaction(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	redact := slices.Index(names, "redact")
+	if redact < 0 {
+		return fmt.Errorf("the redact stage is missing")
+	}
+	if extract := slices.Index(names, "extract-fields"); extract >= 0 && extract < redact {
+		return fmt.Errorf("redact must run before extract-fields, which would copy raw session IDs into session_id and data_flat")
+	}
+	return nil
+}
+
+// ValidateStageAfter checks that a pipeline lists stage after the stage
+// named after, as strip-ansi and normalize-paths need, since they edit the
+// fields extract-fields derives. An empty pipeline (DefaultStages) is
+// valid.
+func ValidateStageAfter(names []string, stage, after string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	i := slices.Index(names, stage)
+	if i < 0 {
+		return fmt.Errorf("the %s stage is missing", stage)
+	}
+	if j := slices.Index(names, after); j < 0 || j > i {
+		return fmt.Errorf("%s must run after %s", stage, after)
+	}
+	return nil
+}
+
+// StageError is returned by TransformEvent when a stage fails.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("transform stage %s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error { return e.Err }
+
+// TransformEvent converts evt into a Document by running the stages named
+// in opts.Stages (DefaultStages when empty) in order. An unknown stage name
+// or a failing stage returns a *StageError along with the document built so
+// far.
+func TransformEvent(evt hookevt.HookEvent, opts TransformOptions) (Document, error) {
+	names := opts.Stages
+	if len(names) == 0 {
+		names = DefaultStages
+	}
+	doc := newDocument(evt)
+	for _, name := range names {
+		stagesMu.RLock()
+		build, ok := stages[name]
+		stagesMu.RUnlock()
+		if !ok {
+			return doc, &StageError{Stage: name, Err: fmt.Errorf("unknown stage")}
+		}
+		if err := build(opts)(&doc, evt); err != nil {
+			return doc, &StageError{Stage: name, Err: err}
+		}
+	}
+	return doc, nil
+}
+
+// sampleStage fails an event with ErrSampledOut when opts.Sample drops its
+// hook type. A no-op without opts.Sample.
+func sampleStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		if opts.Sample != nil && !opts.Sample(evt.HookType) {
+			return ErrSampledOut
+		}
+		return nil
+	}
+}
+
+// envelopeFields are the event fields the envelope stage looks for beside
+// data: the ones extract-fields reads from the top level of Data.
+var envelopeFields = []string{
+	"session_id", "tool_name", "tool_input", "tool_response", "prompt",
+	"error", "permission_mode", "cwd", "transcript_path", "parent_session_id",
+	"is_subagent", "tags", "_monitor",
+}
+
+// envelopeStage, with opts.FlatEnvelope, copies envelopeFields from the
+// event's top level (evt.Extra) into doc.Data where Data lacks them, so a
+// flat envelope is extracted like a nested one and the stored data keeps the
+// fields. Data is copied, never modified in place. A top-level value that is
+// not valid JSON is skipped.
+func envelopeStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		if !opts.FlatEnvelope || len(evt.Extra) == 0 {
+			return nil
+		}
+		var data map[string]interface{}
+		for _, key := range envelopeFields {
+			raw, ok := evt.Extra[key]
+			if !ok {
+				continue
+			}
+			if _, ok := doc.Data[key]; ok {
+				continue
+			}
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				continue
+			}
+			if data == nil {
+				data = make(map[string]interface{}, len(doc.Data)+len(envelopeFields))
+				for k, dv := range doc.Data {
+					data[k] = dv
+				}
+			}
+			data[key] = v
+		}
+		if data != nil {
+			doc.Data = data
+		}
+		return nil
+	}
+}
+
+// redactStage replaces session IDs in doc.Data with HashSessionID
+// pseudonyms when opts.SessionIDKey is set, so later stages never see the
+// raw IDs. It must run before extract-fields for the derived session fields
+// to be hashed too.
+func redactStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		if len(opts.SessionIDKey) > 0 {
+			doc.Data = hashSessionIDs(doc.Data, opts.SessionIDKey)
+		}
+		return nil
+	}
+}
+
+func extractFieldsStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		extractFields(doc, opts)
+		return nil
+	}
+}
+
+// stripANSIStage removes ANSI escape sequences from ErrorMessage and
+// rebuilds DataFlat from doc.Data without them. A no-op unless
+// opts.StripANSI is set; it must run after extract-fields.
+func stripANSIStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		if !opts.StripANSI {
+			return nil
+		}
+		doc.ErrorMessage = stripANSI(doc.ErrorMessage)
+		doc.DataFlat = extractStringValuesWithOptions(doc.Data, opts)
+		return nil
+	}
+}
+
+// normalizePathsStage rewrites FilePath, Cwd, and ProjectDir with
+// NormalizePath. A no-op unless opts.NormalizePaths is set; it must run
+// after extract-fields.
+func normalizePathsStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		if !opts.NormalizePaths {
+			return nil
+		}
+		doc.FilePath = NormalizePath(doc.FilePath)
+		doc.Cwd = NormalizePath(doc.Cwd)
+		doc.ProjectDir = NormalizePath(doc.ProjectDir)
+		return nil
+	}
+}
+
+// sanitizeUTF8Stage replaces invalid UTF-8 in the derived text fields with
+// U+FFFD. JSON-decoded input is already valid, so it is not in
+// DefaultStages; it guards documents built from other sources or edited by
+// custom stages.
+func sanitizeUTF8Stage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		for _, s := range []*string{
+			&doc.SessionID, &doc.ToolName, &doc.Prompt, &doc.FilePath,
+			&doc.ErrorMessage, &doc.ProjectDir, &doc.PermissionMode, &doc.Cwd,
+			&doc.TeammateID, &doc.TeammateName, &doc.ParentSessionID,
+			&doc.ClaudeVersion, &doc.SessionModel, &doc.NotificationResponse,
+			&doc.DataFlat,
+		} {
+			*s = strings.ToValidUTF8(*s, "�")
+		}
+		for i, tag := range doc.Tags {
+			doc.Tags[i] = strings.ToValidUTF8(tag, "�")
+		}
+		return nil
+	}
+}
+
+// enrichStage runs the per-hook-type transforms registered with
+// RegisterTransform. They see the event with the (possibly redacted)
+// doc.Data.
+func enrichStage(opts TransformOptions) TransformStage {
+	return func(doc *Document, evt hookevt.HookEvent) error {
+		evt.Data = doc.Data
+		applyTransforms(doc, evt)
+		return nil
+	}
+}
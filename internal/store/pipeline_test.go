@@ -0,0 +1,355 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"hooks-store/internal/hookevt"
+)
+
+func pipelineEvent(hookType string) hookevt.HookEvent {
+	return hookevt.HookEvent{
+		HookType:  hookType,
+		Timestamp: time.Date(2026, 2, 25, 14, 30, 0, 0, time.UTC),
+		Data: map[string]interface{}{
+			"session_id": "sess-1",
+			"tool_name":  "Bash",
+			"cwd":        `C:\work\`,
+			"error":      "\x1b[31mboom\x1b[0m",
+		},
+	}
+}
+
+func TestTransformEvent_DefaultMatchesHookEventToDocument(t *testing.T) {
+	t.Parallel()
+	opts := TransformOptions{StripANSI: true, NormalizePaths: true, ContentHash: true}
+	evt := pipelineEvent("PostToolUseFailure")
+
+	want := HookEventToDocumentWithOptions(evt, opts)
+	for _, stages := range [][]string{nil, DefaultStages} {
+		opts.Stages = stages
+		got, err := TransformEvent(evt, opts)
+		if err != nil {
+			t.Fatalf("stages %v: %v", stages, err)
+		}
+		got.ID = want.ID
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("stages %v:\n got %+v\nwant %+v", stages, got, want)
+		}
+	}
+}
+
+func TestRedactStage(t *testing.T) {
+	t.Parallel()
+	key := []byte("salt")
+	evt := pipelineEvent("Stop")
+
+	doc, err := TransformEvent(evt, TransformOptions{SessionIDKey: key, Stages: []string{"redact"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Data["session_id"] != HashSessionID(key, "sess-1") {
+		t.Errorf("data session_id = %v, want pseudonym", doc.Data["session_id"])
+	}
+	if doc.SessionID != "" {
+		t.Errorf("SessionID = %q, want empty without extract-fields", doc.SessionID)
+	}
+	if evt.Data["session_id"] != "sess-1" {
+		t.Error("redact modified the event's data")
+	}
+
+	// Redacting after extraction leaves the derived field raw: order matters.
+	doc, _ = TransformEvent(evt, TransformOptions{SessionIDKey: key, Stages: []string{"extract-fields", "redact"}})
+	if doc.SessionID != "sess-1" {
+		t.Errorf("SessionID = %q, want raw when redact runs last", doc.SessionID)
+	}
+}
+
+func TestValidateRedaction(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		stages []string
+		want   string // error substring; empty for valid
+	}{
+		{nil, ""},
+		{DefaultStages, ""},
+		{[]string{"redact"}, ""},
+		{[]string{"redact", "extract-fields"}, ""},
+		{[]string{"extract-fields"}, "missing"},
+		{[]string{"extract-fields", "redact"}, "before extract-fields"},
+		{[]string{"envelope", "extract-fields", "enrich", "redact"}, "before extract-fields"},
+	} {
+		err := ValidateRedaction(tc.stages)
+		if tc.want == "" && err != nil {
+			t.Errorf("%v: %v", tc.stages, err)
+		}
+		if tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("%v: err = %v, want %q", tc.stages, err, tc.want)
+		}
+	}
+}
+
+func TestValidateStageAfter(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		stages []string
+		want   string // error substring; empty for valid
+	}{
+		{nil, ""},
+		{DefaultStages, ""},
+		{[]string{"extract-fields", "strip-ansi"}, ""},
+		{[]string{"extract-fields"}, "missing"},
+		{[]string{"strip-ansi", "extract-fields"}, "after extract-fields"},
+		{[]string{"strip-ansi"}, "after extract-fields"},
+	} {
+		err := ValidateStageAfter(tc.stages, "strip-ansi", "extract-fields")
+		if tc.want == "" && err != nil {
+			t.Errorf("%v: %v", tc.stages, err)
+		}
+		if tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("%v: err = %v, want %q", tc.stages, err, tc.want)
+		}
+	}
+}
+
+func TestExtractFieldsStage(t *testing.T) {
+	t.Parallel()
+	evt := pipelineEvent("PostToolUseFailure")
+
+	doc, err := TransformEvent(evt, TransformOptions{StripANSI: true, Stages: []string{"extract-fields"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ANSI stripping is the strip-ansi stage's job.
+	if doc.SessionID != "sess-1" || doc.ToolName != "Bash" || doc.ErrorMessage != "\x1b[31mboom\x1b[0m" {
+		t.Errorf("derived fields = %q %q %q", doc.SessionID, doc.ToolName, doc.ErrorMessage)
+	}
+	if doc.DataFlat == "" {
+		t.Error("DataFlat empty")
+	}
+
+	doc, _ = TransformEvent(evt, TransformOptions{Stages: []string{"enrich"}})
+	if doc.SessionID != "" || doc.DataFlat != "" {
+		t.Errorf("without extract-fields got SessionID %q, DataFlat %q", doc.SessionID, doc.DataFlat)
+	}
+	if doc.HookType != "PostToolUseFailure" || doc.Day != "2026-02-25" || doc.ID == "" {
+		t.Errorf("base fields = %q %q %q", doc.HookType, doc.Day, doc.ID)
+	}
+}
+
+func TestSanitizeUTF8Stage(t *testing.T) {
+	t.Parallel()
+	RegisterStage("test-invalid-utf8", func(opts TransformOptions) TransformStage {
+		return func(doc *Document, evt hookevt.HookEvent) error {
+			doc.Prompt = "ok\xffok"
+			doc.Tags = []string{"t\xfe"}
+			return nil
+		}
+	})
+
+	doc, err := TransformEvent(pipelineEvent("UserPromptSubmit"), TransformOptions{
+		Stages: []string{"extract-fields", "test-invalid-utf8", "sanitize-utf8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Prompt != "ok\uFFFDok" || doc.Tags[0] != "t\uFFFD" {
+		t.Errorf("Prompt = %q, Tags = %q", doc.Prompt, doc.Tags)
+	}
+	if doc.ToolName != "Bash" {
+		t.Errorf("valid field changed: ToolName = %q", doc.ToolName)
+	}
+}
+
+func TestStripANSIStage(t *testing.T) {
+	t.Parallel()
+	evt := pipelineEvent("PostToolUseFailure")
+	stages := []string{"extract-fields", "strip-ansi"}
+
+	doc, err := TransformEvent(evt, TransformOptions{StripANSI: true, Stages: stages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ErrorMessage != "boom" {
+		t.Errorf("ErrorMessage = %q, want boom", doc.ErrorMessage)
+	}
+	if strings.Contains(doc.DataFlat, "\x1b") || !strings.Contains(doc.DataFlat, "boom") {
+		t.Errorf("DataFlat = %q, want escapes removed", doc.DataFlat)
+	}
+	if doc.Data["error"] != "\x1b[31mboom\x1b[0m" {
+		t.Errorf("data error = %q, want intact", doc.Data["error"])
+	}
+
+	doc, _ = TransformEvent(evt, TransformOptions{Stages: stages})
+	if doc.ErrorMessage != "\x1b[31mboom\x1b[0m" {
+		t.Errorf("without StripANSI ErrorMessage = %q, want raw", doc.ErrorMessage)
+	}
+}
+
+func TestNormalizePathsStage(t *testing.T) {
+	t.Parallel()
+	evt := pipelineEvent("Stop")
+	stages := []string{"extract-fields", "normalize-paths"}
+
+	doc, err := TransformEvent(evt, TransformOptions{NormalizePaths: true, Stages: stages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Cwd != "C:/work" {
+		t.Errorf("Cwd = %q, want C:/work", doc.Cwd)
+	}
+
+	doc, _ = TransformEvent(evt, TransformOptions{Stages: stages})
+	if doc.Cwd != `C:\work\` {
+		t.Errorf("without NormalizePaths Cwd = %q, want raw", doc.Cwd)
+	}
+	doc, _ = TransformEvent(evt, TransformOptions{NormalizePaths: true, Stages: []string{"extract-fields"}})
+	if doc.Cwd != `C:\work\` {
+		t.Errorf("without the stage Cwd = %q, want raw", doc.Cwd)
+	}
+}
+
+func TestSampleStage(t *testing.T) {
+	t.Parallel()
+	sample := func(hookType string) bool { return hookType != "PreToolUse" }
+
+	_, err := TransformEvent(pipelineEvent("PreToolUse"), TransformOptions{Sample: sample})
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != "sample" || !errors.Is(err, ErrSampledOut) {
+		t.Fatalf("err = %v, want a sample StageError wrapping ErrSampledOut", err)
+	}
+	doc, err := TransformEvent(pipelineEvent("Stop"), TransformOptions{Sample: sample})
+	if err != nil || doc.SessionID == "" {
+		t.Errorf("kept hook type: doc = %+v, err = %v", doc, err)
+	}
+	if _, err := TransformEvent(pipelineEvent("PreToolUse"), TransformOptions{}); err != nil {
+		t.Errorf("without Sample: %v", err)
+	}
+	if _, err := TransformEvent(pipelineEvent("PreToolUse"), TransformOptions{Sample: sample, Stages: []string{"extract-fields"}}); err != nil {
+		t.Errorf("without the stage: %v", err)
+	}
+}
+
+func TestEnrichStage(t *testing.T) {
+	t.Parallel()
+	key := []byte("salt")
+	RegisterTransform("TestEnrichStage", func(doc *Document, evt hookevt.HookEvent) {
+		doc.TeammateName, _ = extractString(evt.Data, "session_id")
+	})
+
+	doc, err := TransformEvent(pipelineEvent("TestEnrichStage"), TransformOptions{SessionIDKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.TeammateName != HashSessionID(key, "sess-1") {
+		t.Errorf("registered transform saw %q, want the redacted session ID", doc.TeammateName)
+	}
+
+	doc, _ = TransformEvent(pipelineEvent("TestEnrichStage"), TransformOptions{Stages: []string{"extract-fields"}})
+	if doc.TeammateName != "" {
+		t.Errorf("TeammateName = %q, want empty without enrich", doc.TeammateName)
+	}
+}
+
+func TestTransformEvent_StageError(t *testing.T) {
+	t.Parallel()
+	boom := errors.New("boom")
+	RegisterStage("test-fail", func(opts TransformOptions) TransformStage {
+		return func(doc *Document, evt hookevt.HookEvent) error { return boom }
+	})
+
+	doc, err := TransformEvent(pipelineEvent("Stop"), TransformOptions{Stages: []string{"extract-fields", "test-fail", "enrich"}})
+	var se *StageError
+	if !errors.As(err, &se) || se.Stage != "test-fail" || !errors.Is(err, boom) {
+		t.Fatalf("err = %v, want StageError for test-fail wrapping boom", err)
+	}
+	if doc.ToolName != "Bash" {
+		t.Errorf("ToolName = %q, want the fields extracted before the failure", doc.ToolName)
+	}
+
+	// HookEventToDocumentWithOptions drops the error and keeps the partial document.
+	if doc := HookEventToDocumentWithOptions(pipelineEvent("Stop"), TransformOptions{Stages: []string{"test-fail"}}); doc.HookType != "Stop" {
+		t.Errorf("HookType = %q", doc.HookType)
+	}
+
+	if _, err := TransformEvent(pipelineEvent("Stop"), TransformOptions{Stages: []string{"nope"}}); !errors.As(err, &se) || se.Stage != "nope" {
+		t.Errorf("unknown stage: err = %v", err)
+	}
+}
+
+func TestValidateStages(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		stages []string
+		want   string // error substring; empty for valid
+	}{
+		{nil, ""},
+		{DefaultStages, ""},
+		{[]string{"sanitize-utf8", "extract-fields"}, ""},
+		{[]string{"extract-fields", "bogus"}, `unknown transform stage "bogus"`},
+		{[]string{"redact", "redact"}, `"redact" listed twice`},
+	} {
+		err := ValidateStages(tc.stages)
+		if tc.want == "" && err != nil {
+			t.Errorf("%v: %v", tc.stages, err)
+		}
+		if tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+			t.Errorf("%v: err = %v, want %q", tc.stages, err, tc.want)
+		}
+	}
+	names := StageNames()
+	for _, name := range []string{"enrich", "extract-fields", "redact", "sanitize-utf8"} {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			t.Errorf("StageNames() = %v, missing %s", names, name)
+		}
+	}
+}
+
+func TestEnvelopeStage(t *testing.T) {
+	t.Parallel()
+	var evt hookevt.HookEvent
+	body := `{"hook_type":"PostToolUse","session_id":"s1","tool_name":"Bash","cwd":"/top","sender":"x","data":{"cwd":"/w"}}`
+	if err := json.Unmarshal([]byte(body), &evt); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := TransformEvent(evt, TransformOptions{FlatEnvelope: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.SessionID != "s1" || doc.ToolName != "Bash" {
+		t.Errorf("SessionID, ToolName = %q, %q; want lifted from the envelope", doc.SessionID, doc.ToolName)
+	}
+	if doc.Cwd != "/w" {
+		t.Errorf("Cwd = %q, want data's value to win", doc.Cwd)
+	}
+	if _, ok := doc.Data["sender"]; ok {
+		t.Error("unknown envelope key copied into data")
+	}
+	if doc.Data["tool_name"] != "Bash" || len(evt.Data) != 1 {
+		t.Errorf("doc.Data = %v, evt.Data = %v; want lifted fields stored, event untouched", doc.Data, evt.Data)
+	}
+
+	// Missing data, with redaction after the lift.
+	evt = hookevt.HookEvent{}
+	json.Unmarshal([]byte(`{"hook_type":"Stop","session_id":"s1"}`), &evt)
+	key := []byte("salt")
+	doc, _ = TransformEvent(evt, TransformOptions{FlatEnvelope: true, SessionIDKey: key})
+	if doc.SessionID != HashSessionID(key, "s1") {
+		t.Errorf("SessionID = %q, want the lifted ID redacted", doc.SessionID)
+	}
+
+	// Off by default.
+	doc, _ = TransformEvent(evt, TransformOptions{})
+	if doc.SessionID != "" || doc.Data != nil {
+		t.Errorf("without FlatEnvelope: SessionID %q, Data %v", doc.SessionID, doc.Data)
+	}
+}
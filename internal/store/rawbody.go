@@ -0,0 +1,31 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// CompressRawBody gzips a request body for Document.RawBody.
+func CompressRawBody(body []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body) // writes to a bytes.Buffer cannot fail
+	zw.Close()
+	return buf.Bytes()
+}
+
+// DecompressRawBody returns the exact bytes a Document.RawBody was made from.
+func DecompressRawBody(raw []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("raw body: %w", err)
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("raw body: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRawBody_RoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, body := range [][]byte{
+		[]byte(`{"hook_type":"Stop",  "data":{"b":1,"a":2}}` + "\n"),
+		bytes.Repeat([]byte(`{"x":"y"}`), 10000),
+		{},
+	} {
+		raw := CompressRawBody(body)
+		got, err := DecompressRawBody(raw)
+		if err != nil {
+			t.Fatalf("DecompressRawBody: %v", err)
+		}
+		if !bytes.Equal(got, body) {
+			t.Errorf("round trip of %d bytes changed the body", len(body))
+		}
+	}
+	if len(CompressRawBody(bytes.Repeat([]byte("a"), 10000))) > 1000 {
+		t.Error("repetitive body was not compressed")
+	}
+}
+
+func TestDecompressRawBody_Invalid(t *testing.T) {
+	t.Parallel()
+	if _, err := DecompressRawBody([]byte("not gzip")); err == nil {
+		t.Error("want error for non-gzip input")
+	}
+}
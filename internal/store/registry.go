@@ -0,0 +1,36 @@
+package store
+
+import (
+	"sync"
+
+	"hooks-store/internal/hookevt"
+)
+
+// TransformFunc post-processes a Document after the core extraction in
+// HookEventToDocument. It receives the original wire-format event so it can
+// derive additional fields from the raw data map.
+type TransformFunc func(doc *Document, evt hookevt.HookEvent)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string][]TransformFunc{}
+)
+
+// RegisterTransform registers fn to run on every Document of the given hook
+// type. Transforms for the same hook type run in registration order.
+// Safe for concurrent use; typically called from init or main before serving.
+func RegisterTransform(hookType string, fn TransformFunc) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[hookType] = append(transforms[hookType], fn)
+}
+
+// applyTransforms runs the registered transforms for doc.HookType.
+func applyTransforms(doc *Document, evt hookevt.HookEvent) {
+	transformsMu.RLock()
+	fns := transforms[doc.HookType]
+	transformsMu.RUnlock()
+	for _, fn := range fns {
+		fn(doc, evt)
+	}
+}
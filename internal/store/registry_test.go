@@ -0,0 +1,64 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"hooks-store/internal/hookevt"
+)
+
+func TestRegisterTransform(t *testing.T) {
+	t.Parallel()
+
+	// Unique hook type names keep parallel tests from seeing each other's transforms.
+	RegisterTransform("TestRegisterTransform", func(doc *Document, evt hookevt.HookEvent) {
+		if v, ok := extractString(evt.Data, "label"); ok {
+			doc.ErrorMessage = "label:" + v
+		}
+	})
+
+	doc := HookEventToDocument(hookevt.HookEvent{
+		HookType:  "TestRegisterTransform",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"label": "x"},
+	})
+	if doc.ErrorMessage != "label:x" {
+		t.Errorf("ErrorMessage = %q, want label:x", doc.ErrorMessage)
+	}
+}
+
+func TestRegisterTransform_Order(t *testing.T) {
+	t.Parallel()
+
+	RegisterTransform("TestRegisterTransform_Order", func(doc *Document, _ hookevt.HookEvent) {
+		doc.Prompt += "a"
+	})
+	RegisterTransform("TestRegisterTransform_Order", func(doc *Document, _ hookevt.HookEvent) {
+		doc.Prompt += "b"
+	})
+
+	doc := HookEventToDocument(hookevt.HookEvent{
+		HookType:  "TestRegisterTransform_Order",
+		Timestamp: time.Now(),
+	})
+	if doc.Prompt != "ab" {
+		t.Errorf("Prompt = %q, want ab (registration order)", doc.Prompt)
+	}
+}
+
+func TestRegisterTransform_OtherHookTypeUnaffected(t *testing.T) {
+	t.Parallel()
+
+	RegisterTransform("TestRegisterTransform_Other", func(doc *Document, _ hookevt.HookEvent) {
+		doc.ToolName = "mutated"
+	})
+
+	doc := HookEventToDocument(hookevt.HookEvent{
+		HookType:  "TestRegisterTransform_Unregistered",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"tool_name": "Read"},
+	})
+	if doc.ToolName != "Read" {
+		t.Errorf("ToolName = %q, want Read (no transform registered)", doc.ToolName)
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy says how long documents are kept: Projects[project_dir]
+// for a project with an override, Default for every other document
+// (including those without a project_dir). A zero duration keeps those
+// documents forever, so an override of 0 exempts a project from a global
+// Default.
+type RetentionPolicy struct {
+	Default  time.Duration
+	Projects map[string]time.Duration
+}
+
+// Enabled reports whether the policy expires anything.
+func (p RetentionPolicy) Enabled() bool {
+	if p.Default > 0 {
+		return true
+	}
+	for _, window := range p.Projects {
+		if window > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns the retention window of documents from projectDir.
+func (p RetentionPolicy) For(projectDir string) time.Duration {
+	if window, ok := p.Projects[projectDir]; ok {
+		return window
+	}
+	return p.Default
+}
+
+// PurgeFilters returns the delete filters of one purge at now: one per
+// project override with a non-zero window, in project order, then one for
+// every other project when Default is set. Documents without a timestamp
+// (timestamp_unix 0) never match.
+func (p RetentionPolicy) PurgeFilters(now time.Time) []string {
+	projects := make([]string, 0, len(p.Projects))
+	for dir := range p.Projects {
+		projects = append(projects, dir)
+	}
+	sort.Strings(projects)
+
+	var filters []string
+	quoted := make([]string, len(projects))
+	for i, dir := range projects {
+		quoted[i] = quoteFilterValue(dir)
+		if window := p.Projects[dir]; window > 0 {
+			filters = append(filters, fmt.Sprintf("project_dir = %s AND %s", quoted[i], olderThan(now.Add(-window))))
+		}
+	}
+	if p.Default > 0 {
+		filter := olderThan(now.Add(-p.Default))
+		if len(quoted) > 0 {
+			filter += " AND NOT project_dir IN [" + strings.Join(quoted, ", ") + "]"
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// olderThan matches documents timestamped before cutoff.
+func olderThan(cutoff time.Time) string {
+	return fmt.Sprintf("timestamp_unix > 0 AND timestamp_unix < %d", cutoff.Unix())
+}
+
+// PurgeExpired deletes every document older than its project's retention
+// window, as one DeleteByFilter pass per PurgeFilters entry, so each project
+// is purged on its own schedule. It stops at the first failed pass and
+// returns the results so far.
+func PurgeExpired(ctx context.Context, fd FilterDeleter, p RetentionPolicy, now time.Time) ([]DeleteResult, error) {
+	var results []DeleteResult
+	for _, filter := range p.PurgeFilters(now) {
+		result, err := fd.DeleteByFilter(ctx, filter)
+		if err != nil {
+			return results, fmt.Errorf("purge %q: %w", filter, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
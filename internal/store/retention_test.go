@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingDeleter is a FilterDeleter that records each filter and fails
+// once failAt filters have been deleted (never if negative).
+type recordingDeleter struct {
+	filters []string
+	failAt  int
+}
+
+func (d *recordingDeleter) CountByFilter(ctx context.Context, filter string) (int64, int64, error) {
+	return 0, 0, nil
+}
+
+func (d *recordingDeleter) DeleteByFilter(ctx context.Context, filter string) (DeleteResult, error) {
+	if d.failAt >= 0 && len(d.filters) == d.failAt {
+		return DeleteResult{}, errors.New("boom")
+	}
+	d.filters = append(d.filters, filter)
+	return DeleteResult{Status: "succeeded"}, nil
+}
+
+func TestPurgeExpired_PerProject(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1_800_000_000, 0)
+	day := 24 * time.Hour
+	p := RetentionPolicy{
+		Default: 30 * day,
+		Projects: map[string]time.Duration{
+			"/work/regulated": 365 * day,
+			"/work/scratch":   7 * day,
+			"/work/archive":   0, // kept forever
+		},
+	}
+
+	fd := &recordingDeleter{failAt: -1}
+	results, err := PurgeExpired(context.Background(), fd, p, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`project_dir = "/work/regulated" AND timestamp_unix > 0 AND timestamp_unix < 1768464000`,
+		`project_dir = "/work/scratch" AND timestamp_unix > 0 AND timestamp_unix < 1799395200`,
+		`timestamp_unix > 0 AND timestamp_unix < 1797408000 AND NOT project_dir IN ["/work/archive", "/work/regulated", "/work/scratch"]`,
+	}
+	if !reflect.DeepEqual(fd.filters, want) {
+		t.Errorf("filters:\n got %q\nwant %q", fd.filters, want)
+	}
+	if len(results) != len(want) {
+		t.Errorf("%d results, want %d", len(results), len(want))
+	}
+	for _, f := range fd.filters {
+		if err := ValidateFilter(f); err != nil {
+			t.Errorf("ValidateFilter(%q) = %v", f, err)
+		}
+	}
+
+	for dir, want := range map[string]time.Duration{"/work/regulated": 365 * day, "/work/archive": 0, "/elsewhere": 30 * day} {
+		if got := p.For(dir); got != want {
+			t.Errorf("For(%s) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestPurgeExpired_OverridesOnly(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1_800_000_000, 0)
+	p := RetentionPolicy{Projects: map[string]time.Duration{"/a": time.Hour, "/b": 2 * time.Hour}}
+	if !p.Enabled() {
+		t.Error("Enabled = false with project overrides")
+	}
+	if (RetentionPolicy{Projects: map[string]time.Duration{"/a": 0}}).Enabled() {
+		t.Error("Enabled = true with only zero windows")
+	}
+
+	// Without a default, other projects are never purged.
+	if got := p.PurgeFilters(now); len(got) != 2 {
+		t.Errorf("PurgeFilters = %q, want one per project", got)
+	}
+
+	// A failed pass stops the purge.
+	fd := &recordingDeleter{failAt: 1}
+	results, err := PurgeExpired(context.Background(), fd, p, now)
+	if err == nil || len(results) != 1 {
+		t.Errorf("results %d, err %v; want 1 result and an error", len(results), err)
+	}
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Document categories used for routing, as returned by DocumentCategory.
+// CategoryAll matches every document in a Route.
+const (
+	CategoryError  = "error"  // failed tool calls and events carrying an error message
+	CategoryPrompt = "prompt" // UserPromptSubmit
+	CategoryTool   = "tool"   // other events naming a tool
+	CategoryOther  = "other"  // everything else
+	CategoryAll    = "*"
+)
+
+// Categories lists the values a Route may match.
+var Categories = []string{CategoryError, CategoryPrompt, CategoryTool, CategoryOther, CategoryAll}
+
+// DocumentCategory classifies doc for routing. The first matching category
+// wins: error, prompt, tool, other.
+func DocumentCategory(doc Document) string {
+	switch {
+	case doc.ErrorMessage != "" || (doc.Success != nil && !*doc.Success):
+		return CategoryError
+	case doc.HookType == "UserPromptSubmit":
+		return CategoryPrompt
+	case doc.ToolName != "":
+		return CategoryTool
+	}
+	return CategoryOther
+}
+
+// Route sends every document of one category to an additional store.
+type Route struct {
+	Category string // one of Categories
+	Name     string // identifies the route in warnings, e.g. "file:/var/lib/errors"
+	Store    EventStore
+}
+
+// Unwrapper is implemented by stores that wrap another. As follows it to find
+// capabilities the wrapper itself lacks, so a wrapper that edits or copies
+// documents must also implement every optional write capability
+// (TargetIndexer) or writes through As bypass it.
+type Unwrapper interface {
+	Unwrap() EventStore
+}
+
+// SupportsTargetIndex reports whether es can write to a named index. The
+// decorators implement IndexInto themselves so their logic applies, which
+// makes As[TargetIndexer] succeed whatever they wrap; what matters is the
+// store at the end of the Unwrap chain.
+func SupportsTargetIndex(es EventStore) bool {
+	for {
+		u, ok := es.(Unwrapper)
+		if !ok {
+			break
+		}
+		es = u.Unwrap()
+	}
+	_, ok := es.(TargetIndexer)
+	return ok
+}
+
+// indexInto writes doc to the named index of inner, for decorators'
+// IndexInto.
+func indexInto(ctx context.Context, inner EventStore, index string, doc Document) error {
+	ti, ok := As[TargetIndexer](inner)
+	if !ok {
+		return fmt.Errorf("index %q: %w", index, ErrTargetIndexUnsupported)
+	}
+	return ti.IndexInto(ctx, index, doc)
+}
+
+// As reports whether es, or a store it wraps (see Unwrapper), implements T,
+// returning the first that does. Use it instead of a type assertion when
+// discovering optional capabilities.
+func As[T any](es EventStore) (T, bool) {
+	for es != nil {
+		if t, ok := es.(T); ok {
+			return t, true
+		}
+		u, ok := es.(Unwrapper)
+		if !ok {
+			break
+		}
+		es = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// RoutingStore indexes every document into a primary store and, in parallel,
+// into each route whose category matches — e.g. a durable file copy of error
+// events alongside the searchable bulk in MeiliSearch. A failing store never
+// blocks the others, as every target is written. A primary failure is
+// returned, so callers count it like any store error; a route failure is
+// only logged. Queries, updates, and deletes go to the primary store only
+// (via Unwrap).
+type RoutingStore struct {
+	primary EventStore
+	routes  []Route
+}
+
+// NewRoutingStore wraps primary with routes. It rejects unknown categories.
+func NewRoutingStore(primary EventStore, routes []Route) (*RoutingStore, error) {
+	for _, r := range routes {
+		if !slices.Contains(Categories, r.Category) {
+			return nil, fmt.Errorf("route %s: unknown category %q (want one of %v)", r.Name, r.Category, Categories)
+		}
+	}
+	return &RoutingStore{primary: primary, routes: routes}, nil
+}
+
+// Unwrap returns the primary store.
+func (s *RoutingStore) Unwrap() EventStore { return s.primary }
+
+// Index writes doc to the primary store and every matching route.
+func (s *RoutingStore) Index(ctx context.Context, doc Document) error {
+	return s.dispatch(s.targets(DocumentCategory(doc)), func(es EventStore) error {
+		return es.Index(ctx, doc)
+	})
+}
+
+// IndexInto writes doc to the named index of the primary store and, as with
+// Index, to every matching route.
+func (s *RoutingStore) IndexInto(ctx context.Context, index string, doc Document) error {
+	targets := s.targets(DocumentCategory(doc))
+	targets[0].store = namedIndex{EventStore: s.primary, index: index}
+	return s.dispatch(targets, func(es EventStore) error {
+		return es.Index(ctx, doc)
+	})
+}
+
+// namedIndex redirects Index to one named index of the wrapped store.
+type namedIndex struct {
+	EventStore
+	index string
+}
+
+func (n namedIndex) Index(ctx context.Context, doc Document) error {
+	return indexInto(ctx, n.EventStore, n.index, doc)
+}
+
+// BatchIndex splits docs by target and indexes each store's share as one
+// batch.
+func (s *RoutingStore) BatchIndex(ctx context.Context, docs []Document) error {
+	shares := make(map[int][]Document) // target index (0 = primary) → docs
+	for _, doc := range docs {
+		cat := DocumentCategory(doc)
+		shares[0] = append(shares[0], doc)
+		for i, r := range s.routes {
+			if r.Category == CategoryAll || r.Category == cat {
+				shares[i+1] = append(shares[i+1], doc)
+			}
+		}
+	}
+	var targets []target
+	for i, t := range s.targets(CategoryAll) {
+		if len(shares[i]) > 0 {
+			t.docs = shares[i]
+			targets = append(targets, t)
+		}
+	}
+	return s.dispatchBatch(targets, func(es EventStore, docs []Document) error {
+		return es.BatchIndex(ctx, docs)
+	})
+}
+
+// Close closes the primary store and every route store.
+func (s *RoutingStore) Close() error {
+	errs := []error{s.primary.Close()}
+	for _, r := range s.routes {
+		errs = append(errs, r.Store.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// target is one store a write goes to; docs is its share of a batch.
+type target struct {
+	name    string
+	store   EventStore
+	docs    []Document
+	primary bool
+}
+
+// targets returns the primary store followed by the routes matching
+// category (every route for CategoryAll, so indices line up with s.routes).
+func (s *RoutingStore) targets(category string) []target {
+	ts := []target{{name: "primary", store: s.primary, primary: true}}
+	for _, r := range s.routes {
+		if category == CategoryAll || r.Category == CategoryAll || r.Category == category {
+			ts = append(ts, target{name: r.Name, store: r.Store})
+		}
+	}
+	return ts
+}
+
+func (s *RoutingStore) dispatch(targets []target, write func(EventStore) error) error {
+	return s.dispatchBatch(targets, func(es EventStore, _ []Document) error { return write(es) })
+}
+
+// dispatchBatch runs write against every target concurrently. It returns
+// the primary's error, if any, and logs route failures as warnings.
+func (s *RoutingStore) dispatchBatch(targets []target, write func(EventStore, []Document) error) error {
+	errs := make([]error, len(targets))
+	if len(targets) == 1 {
+		errs[0] = write(targets[0].store, targets[0].docs)
+	} else {
+		var wg sync.WaitGroup
+		for i, t := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = write(t.store, t.docs)
+			}()
+		}
+		wg.Wait()
+	}
+
+	var primaryErr error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case targets[i].primary:
+			primaryErr = fmt.Errorf("route %s: %w", targets[i].name, err)
+		default:
+			fmt.Fprintf(os.Stderr, "warning: route %s: %v\n", targets[i].name, err)
+		}
+	}
+	return primaryErr
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memStore records indexed documents and can be made to fail.
+type memStore struct {
+	mu     sync.Mutex
+	docs   []Document
+	fail   bool
+	closed bool
+}
+
+func (m *memStore) Index(ctx context.Context, doc Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("down")
+	}
+	m.docs = append(m.docs, doc)
+	return nil
+}
+
+func (m *memStore) BatchIndex(ctx context.Context, docs []Document) error {
+	for _, doc := range docs {
+		if err := m.Index(ctx, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memStore) Close() error { m.closed = true; return nil }
+
+// searchMemStore adds the Searcher capability.
+type searchMemStore struct{ memStore }
+
+func (m *searchMemStore) Search(ctx context.Context, p SearchParams) (SearchResult, error) {
+	return SearchResult{}, nil
+}
+
+func TestDocumentCategory(t *testing.T) {
+	t.Parallel()
+	failed := false
+	tests := []struct {
+		doc  Document
+		want string
+	}{
+		{Document{HookType: "PostToolUseFailure", ToolName: "Bash", Success: &failed}, CategoryError},
+		{Document{HookType: "Notification", ErrorMessage: "boom"}, CategoryError},
+		{Document{HookType: "UserPromptSubmit"}, CategoryPrompt},
+		{Document{HookType: "PreToolUse", ToolName: "Read"}, CategoryTool},
+		{Document{HookType: "SessionStart"}, CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := DocumentCategory(tt.doc); got != tt.want {
+			t.Errorf("DocumentCategory(%s) = %q, want %q", tt.doc.HookType, got, tt.want)
+		}
+	}
+}
+
+func TestRoutingStore_Index(t *testing.T) {
+	t.Parallel()
+	primary, errs, all := &memStore{}, &memStore{}, &memStore{}
+	rs, err := NewRoutingStore(primary, []Route{
+		{Category: CategoryError, Name: "errors", Store: errs},
+		{Category: CategoryAll, Name: "mirror", Store: all},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	rs.Index(ctx, Document{ID: "1", HookType: "PreToolUse", ToolName: "Read"})
+	rs.Index(ctx, Document{ID: "2", HookType: "Notification", ErrorMessage: "boom"})
+
+	if len(primary.docs) != 2 || len(all.docs) != 2 || len(errs.docs) != 1 || errs.docs[0].ID != "2" {
+		t.Errorf("primary %d, mirror %d, errors %v; want 2, 2, [2]", len(primary.docs), len(all.docs), errs.docs)
+	}
+
+	// A failing route is only logged: the write succeeds with the primary.
+	errs.fail = true
+	if err := rs.Index(ctx, Document{ID: "3", ErrorMessage: "boom"}); err != nil {
+		t.Errorf("route failure: %v, want nil", err)
+	}
+	if len(primary.docs) != 3 || len(all.docs) != 3 {
+		t.Errorf("primary %d, mirror %d; want 3, 3", len(primary.docs), len(all.docs))
+	}
+
+	// A failing primary does not block the routes, but is reported so the
+	// failed searchable write is counted.
+	errs.fail, primary.fail = false, true
+	if err := rs.Index(ctx, Document{ID: "4", ErrorMessage: "boom"}); err == nil {
+		t.Error("primary failed: want error")
+	}
+	if len(errs.docs) != 2 || len(all.docs) != 4 {
+		t.Errorf("errors %d, mirror %d; want 2, 4", len(errs.docs), len(all.docs))
+	}
+	if err := rs.BatchIndex(ctx, []Document{{ID: "5"}}); err == nil {
+		t.Error("primary failed in a batch: want error")
+	}
+
+	if err := rs.Close(); err != nil || !primary.closed || !errs.closed || !all.closed {
+		t.Errorf("Close = %v; closed %v %v %v", err, primary.closed, errs.closed, all.closed)
+	}
+}
+
+func TestRoutingStore_BatchIndex(t *testing.T) {
+	t.Parallel()
+	primary := &memStore{}
+	dir := t.TempDir()
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, _ := NewRoutingStore(primary, []Route{{Category: CategoryPrompt, Name: "file:" + dir, Store: fs}})
+
+	docs := []Document{
+		{ID: "a", HookType: "UserPromptSubmit", Timestamp: "2026-02-25T14:30:00.000Z"},
+		{ID: "b", HookType: "PreToolUse", ToolName: "Read", Timestamp: "2026-02-25T14:30:00.000Z"},
+	}
+	if err := rs.BatchIndex(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(primary.docs) != 2 {
+		t.Errorf("primary got %d docs, want 2", len(primary.docs))
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if len(files) != 1 || filepath.Base(files[0]) != "a.json" {
+		t.Errorf("file route wrote %v, want only a.json", files)
+	}
+}
+
+// targetMemStore adds the TargetIndexer capability, recording each
+// document's index in Document.Source.
+type targetMemStore struct{ memStore }
+
+func (m *targetMemStore) IndexInto(ctx context.Context, index string, doc Document) error {
+	doc.Source = index
+	return m.Index(ctx, doc)
+}
+
+func TestDecorators_IndexInto(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	start := Document{ID: "1", HookType: "SessionStart", SessionID: "s1", ProjectDir: "/p"}
+	later := Document{ID: "2", HookType: "Stop", SessionID: "s1", ErrorMessage: "boom"}
+
+	primary, errs := &targetMemStore{}, &memStore{}
+	rs, _ := NewRoutingStore(primary, []Route{{Category: CategoryError, Name: "errors", Store: errs}})
+	s := NewFirstSeenStore(NewSessionContextStore(rs, 0, 0), 0)
+	if !SupportsTargetIndex(s) {
+		t.Fatal("SupportsTargetIndex = false through decorators of a TargetIndexer")
+	}
+	ti, _ := As[TargetIndexer](s)
+	for _, doc := range []Document{start, later} {
+		if err := ti.IndexInto(ctx, "team-a", doc); err != nil {
+			t.Fatalf("IndexInto: %v", err)
+		}
+	}
+
+	if len(primary.docs) != 2 || primary.docs[1].Source != "team-a" {
+		t.Fatalf("primary docs = %+v, want both in team-a", primary.docs)
+	}
+	if !primary.docs[0].SessionFirstSeen || primary.docs[1].SessionFirstSeen {
+		t.Error("FirstSeenStore not applied to IndexInto")
+	}
+	if primary.docs[1].ProjectDir != "/p" {
+		t.Error("SessionContextStore not applied to IndexInto")
+	}
+	if len(errs.docs) != 1 || errs.docs[0].ID != "2" {
+		t.Errorf("error route docs = %+v, want the failed event", errs.docs)
+	}
+
+	plain := NewFirstSeenStore(&memStore{}, 0)
+	if SupportsTargetIndex(plain) {
+		t.Error("SupportsTargetIndex on a decorated plain store = true")
+	}
+	if err := plain.IndexInto(ctx, "team-a", start); !errors.Is(err, ErrTargetIndexUnsupported) {
+		t.Errorf("IndexInto over a plain store = %v, want ErrTargetIndexUnsupported", err)
+	}
+}
+
+func TestNewRoutingStore_UnknownCategory(t *testing.T) {
+	t.Parallel()
+	if _, err := NewRoutingStore(&memStore{}, []Route{{Category: "errors", Name: "x", Store: &memStore{}}}); err == nil {
+		t.Error("want error for unknown category")
+	}
+}
+
+func TestAs(t *testing.T) {
+	t.Parallel()
+	primary := &searchMemStore{}
+	rs, _ := NewRoutingStore(primary, nil)
+
+	if sr, ok := As[Searcher](rs); !ok || sr != Searcher(primary) {
+		t.Errorf("As[Searcher] through the router = %v, %v; want the primary", sr, ok)
+	}
+	if ti, ok := As[TargetIndexer](rs); !ok || ti != TargetIndexer(rs) {
+		t.Error("As[TargetIndexer] should find the router itself, not bypass routing")
+	}
+	if _, ok := As[Clearer](rs); ok {
+		t.Error("As[Clearer]: neither store implements it")
+	}
+	if _, ok := As[Searcher](&memStore{}); ok {
+		t.Error("As[Searcher] on a plain store: want false")
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"hooks-store/internal/ttlcache"
+)
+
+// sessionContext is what SessionContextStore remembers from a SessionStart.
+type sessionContext struct {
+	projectDir    string
+	hasClaudeMD   bool
+	claudeVersion string
+	sessionModel  string
+}
+
+// SessionContextStore copies session-wide context from each session's
+// SessionStart event onto the session's later events: project_dir and
+// has_claude_md (only reliably present on SessionStart) when the event has
+// no project_dir, and claude_version and session_model when unset. Contexts
+// are kept for up to maxSessions sessions, least recently used evicted
+// first, and expire ttl after the session's last event. Everything else
+// passes through to the wrapped store.
+type SessionContextStore struct {
+	inner    EventStore
+	now      func() time.Time
+	sessions *ttlcache.Cache[string, sessionContext] // session ID → context
+}
+
+// NewSessionContextStore wraps inner. maxSessions <= 0 or ttl <= 0 disables
+// that bound.
+func NewSessionContextStore(inner EventStore, maxSessions int, ttl time.Duration) *SessionContextStore {
+	s := &SessionContextStore{inner: inner, now: time.Now}
+	s.sessions = ttlcache.NewWithClock[string, sessionContext](maxSessions, ttl, func() time.Time { return s.now() })
+	return s
+}
+
+// Unwrap returns the wrapped store.
+func (s *SessionContextStore) Unwrap() EventStore { return s.inner }
+
+// Index enriches doc from its session's context (or records the context,
+// for SessionStart) and indexes it.
+func (s *SessionContextStore) Index(ctx context.Context, doc Document) error {
+	s.apply(&doc)
+	return s.inner.Index(ctx, doc)
+}
+
+// IndexInto enriches doc like Index and writes it to the named index of the
+// wrapped store.
+func (s *SessionContextStore) IndexInto(ctx context.Context, index string, doc Document) error {
+	s.apply(&doc)
+	return indexInto(ctx, s.inner, index, doc)
+}
+
+// BatchIndex enriches docs in order, so a SessionStart earlier in the batch
+// applies to later events, then indexes them as one batch.
+func (s *SessionContextStore) BatchIndex(ctx context.Context, docs []Document) error {
+	enriched := make([]Document, len(docs))
+	for i, doc := range docs {
+		s.apply(&doc)
+		enriched[i] = doc
+	}
+	return s.inner.BatchIndex(ctx, enriched)
+}
+
+// Close closes the wrapped store.
+func (s *SessionContextStore) Close() error { return s.inner.Close() }
+
+// Sessions returns how many session contexts are cached.
+func (s *SessionContextStore) Sessions() int { return s.sessions.Len() }
+
+// apply records doc's context if it is a SessionStart, else fills doc's
+// missing fields from its session's cached context, which also restarts the
+// context's TTL.
+func (s *SessionContextStore) apply(doc *Document) {
+	if doc.SessionID == "" {
+		return
+	}
+	if doc.HookType == "SessionStart" {
+		s.sessions.Set(doc.SessionID, sessionContext{
+			projectDir:    doc.ProjectDir,
+			hasClaudeMD:   doc.HasClaudeMD,
+			claudeVersion: doc.ClaudeVersion,
+			sessionModel:  doc.SessionModel,
+		})
+		return
+	}
+
+	sc, ok := s.sessions.Get(doc.SessionID)
+	if !ok {
+		return
+	}
+	if doc.ProjectDir == "" && sc.projectDir != "" {
+		doc.ProjectDir = sc.projectDir
+		doc.HasClaudeMD = sc.hasClaudeMD
+	}
+	if doc.ClaudeVersion == "" {
+		doc.ClaudeVersion = sc.claudeVersion
+	}
+	if doc.SessionModel == "" {
+		doc.SessionModel = sc.sessionModel
+	}
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"hooks-store/internal/hookevt"
+)
+
+func TestSessionContextStore_Enriches(t *testing.T) {
+	t.Parallel()
+	inner := &memStore{}
+	s := NewSessionContextStore(inner, 10, time.Hour)
+	ctx := context.Background()
+
+	start := HookEventToDocument(hookevt.HookEvent{HookType: "SessionStart", Timestamp: time.Now(), Data: map[string]interface{}{
+		"session_id": "sess-1",
+		"source":     "startup",
+		"version":    "2.0.14",
+		"model":      "claude-sonnet-4-5",
+		"_monitor":   map[string]interface{}{"project_dir": "/home/u/p", "has_claude_md": true},
+	}})
+	bare := HookEventToDocument(hookevt.HookEvent{HookType: "PreToolUse", Timestamp: time.Now(), Data: map[string]interface{}{
+		"session_id": "sess-1",
+		"tool_name":  "Read",
+	}})
+	other := HookEventToDocument(hookevt.HookEvent{HookType: "PreToolUse", Timestamp: time.Now(), Data: map[string]interface{}{
+		"session_id": "sess-2",
+	}})
+	own := bare
+	own.ProjectDir = "/elsewhere"
+
+	for _, doc := range []Document{start, bare, other, own} {
+		if err := s.Index(ctx, doc); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+	}
+
+	got := inner.docs[1]
+	if got.ProjectDir != "/home/u/p" || !got.HasClaudeMD || got.ClaudeVersion != "2.0.14" || got.SessionModel != "claude-sonnet-4-5" {
+		t.Errorf("bare event = %+v, want session context copied", got)
+	}
+	if inner.docs[2].ProjectDir != "" {
+		t.Errorf("other session got project_dir %q", inner.docs[2].ProjectDir)
+	}
+	if inner.docs[3].ProjectDir != "/elsewhere" || inner.docs[3].HasClaudeMD {
+		t.Errorf("event with its own project_dir = %+v, want it kept", inner.docs[3])
+	}
+}
+
+func TestSessionContextStore_BatchIndex(t *testing.T) {
+	t.Parallel()
+	inner := &memStore{}
+	s := NewSessionContextStore(inner, 10, time.Hour)
+
+	docs := []Document{
+		{HookType: "SessionStart", SessionID: "s", ProjectDir: "/p"},
+		{HookType: "Stop", SessionID: "s"},
+	}
+	if err := s.BatchIndex(context.Background(), docs); err != nil {
+		t.Fatalf("BatchIndex: %v", err)
+	}
+	if len(inner.docs) != 2 || inner.docs[1].ProjectDir != "/p" {
+		t.Errorf("docs = %+v, want the Stop event enriched", inner.docs)
+	}
+	if docs[1].ProjectDir != "" {
+		t.Error("BatchIndex modified the caller's slice")
+	}
+}
+
+func TestSessionContextStore_Bounds(t *testing.T) {
+	t.Parallel()
+	inner := &memStore{}
+	s := NewSessionContextStore(inner, 2, time.Hour)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Index(ctx, Document{HookType: "SessionStart", SessionID: id, ProjectDir: "/" + id}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+	}
+	if s.Sessions() != 2 {
+		t.Errorf("Sessions = %d, want 2", s.Sessions())
+	}
+	enriched := func(id string) string {
+		if err := s.Index(ctx, Document{HookType: "Stop", SessionID: id}); err != nil {
+			t.Fatalf("Index: %v", err)
+		}
+		return inner.docs[len(inner.docs)-1].ProjectDir
+	}
+	if got := enriched("a"); got != "" {
+		t.Errorf("evicted session a enriched with %q", got)
+	}
+	if got := enriched("b"); got != "/b" {
+		t.Errorf("session b = %q, want /b", got)
+	}
+
+	// Each event extends the TTL; a quiet session expires.
+	now = now.Add(50 * time.Minute)
+	if got := enriched("b"); got != "/b" {
+		t.Errorf("session b after 50m = %q, want /b", got)
+	}
+	now = now.Add(50 * time.Minute)
+	if got := enriched("b"); got != "/b" {
+		t.Errorf("session b 50m after its last event = %q, want /b", got)
+	}
+	if got := enriched("c"); got != "" {
+		t.Errorf("session c idle 100m = %q, want expired", got)
+	}
+	if s.Sessions() != 1 {
+		t.Errorf("Sessions = %d, want 1 after expiry", s.Sessions())
+	}
+}
+
+func TestSessionContextStore_Unwrap(t *testing.T) {
+	t.Parallel()
+	inner := &searchMemStore{}
+	if _, ok := As[Searcher](NewSessionContextStore(inner, 0, 0)); !ok {
+		t.Error("As[Searcher] through SessionContextStore = false, want true")
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// hashedSessionPrefix marks a session ID already replaced by HashSessionID,
+// so re-transforming stored data (MergeEventData) does not hash it twice.
+const hashedSessionPrefix = "hs_"
+
+// HashSessionID returns the stable pseudonym stored in place of a raw
+// session ID when TransformOptions.SessionIDKey is set: "hs_" plus the first
+// 16 bytes of HMAC-SHA256(key, id), hex-encoded. Empty and already hashed IDs
+// are returned unchanged.
+func HashSessionID(key []byte, id string) string {
+	if id == "" || strings.HasPrefix(id, hashedSessionPrefix) {
+		return id
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return hashedSessionPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// hashSessionIDs returns a copy of data in which the raw session_id and
+// parent_session_id values are replaced by their HashSessionID pseudonyms
+// wherever they occur in a string value — including inside others, such as
+// transcript_path, whose file name is the session ID. data is not modified.
+func hashSessionIDs(data map[string]interface{}, key []byte) map[string]interface{} {
+	var pairs []string
+	for _, field := range []string{"session_id", "parent_session_id"} {
+		if id, ok := extractString(data, field); ok {
+			if hashed := HashSessionID(key, id); hashed != id {
+				pairs = append(pairs, id, hashed)
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return data
+	}
+	return replaceStrings(data, strings.NewReplacer(pairs...)).(map[string]interface{})
+}
+
+// replaceStrings deep-copies v, applying r to every string value. Map keys
+// are kept as they are.
+func replaceStrings(v interface{}, r *strings.Replacer) interface{} {
+	switch val := v.(type) {
+	case string:
+		return r.Replace(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = replaceStrings(child, r)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = replaceStrings(child, r)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"hooks-store/internal/hookevt"
+)
+
+func TestHashSessionID(t *testing.T) {
+	t.Parallel()
+	key := []byte("salt")
+
+	a1, a2 := HashSessionID(key, "sess-a"), HashSessionID(key, "sess-a")
+	b := HashSessionID(key, "sess-b")
+	if a1 != a2 {
+		t.Errorf("same session hashed to %q and %q", a1, a2)
+	}
+	if a1 == b {
+		t.Errorf("different sessions both hashed to %q", a1)
+	}
+	if !strings.HasPrefix(a1, "hs_") || len(a1) != len("hs_")+32 || strings.Contains(a1, "sess-a") {
+		t.Errorf("hash = %q, want hs_ + 32 hex chars", a1)
+	}
+	if other := HashSessionID([]byte("other salt"), "sess-a"); other == a1 {
+		t.Error("different salts gave the same hash")
+	}
+	if again := HashSessionID(key, a1); again != a1 {
+		t.Errorf("rehashing %q = %q, want unchanged", a1, again)
+	}
+	if HashSessionID(key, "") != "" {
+		t.Error("empty session ID was hashed")
+	}
+}
+
+func TestHookEventToDocument_HashSessionIDs(t *testing.T) {
+	t.Parallel()
+	opts := TransformOptions{SessionIDKey: []byte("salt")}
+	data := map[string]interface{}{
+		"session_id":        "sess-a",
+		"parent_session_id": "sess-main",
+		"transcript_path":   "/home/u/.claude/projects/p/sess-a.jsonl",
+		"tool_input":        map[string]interface{}{"command": "echo sess-a"},
+	}
+
+	doc := HookEventToDocumentWithOptions(hookevt.HookEvent{HookType: "PreToolUse", Timestamp: time.Now(), Data: data}, opts)
+	want := HashSessionID(opts.SessionIDKey, "sess-a")
+	if doc.SessionID != want || doc.ParentSessionID != HashSessionID(opts.SessionIDKey, "sess-main") {
+		t.Errorf("(SessionID, ParentSessionID) = (%q, %q), want hashes", doc.SessionID, doc.ParentSessionID)
+	}
+	if doc.Data["session_id"] != want || doc.Data["transcript_path"] != "/home/u/.claude/projects/p/"+want+".jsonl" {
+		t.Errorf("data = %v, want raw IDs replaced", doc.Data)
+	}
+	if strings.Contains(doc.DataFlat, "sess-a") || strings.Contains(doc.DataFlat, "sess-main") {
+		t.Errorf("DataFlat = %q, still contains a raw session ID", doc.DataFlat)
+	}
+	if data["session_id"] != "sess-a" {
+		t.Error("caller's data was modified")
+	}
+
+	// Re-transforming stored data (as PATCH does) keeps the same hash.
+	merged := MergeEventData(doc, map[string]interface{}{"note": "x"}, opts)
+	if merged.SessionID != want {
+		t.Errorf("after MergeEventData SessionID = %q, want %q", merged.SessionID, want)
+	}
+
+	// Same session, later event: same pseudonym, so events still group.
+	next := HookEventToDocumentWithOptions(hookevt.HookEvent{HookType: "Stop", Timestamp: time.Now(),
+		Data: map[string]interface{}{"session_id": "sess-a"}}, opts)
+	if next.SessionID != want {
+		t.Errorf("later event SessionID = %q, want %q", next.SessionID, want)
+	}
+}
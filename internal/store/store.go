@@ -1,46 +1,304 @@
 package store
 
-import "context"
+import (
+	"context"
+	"errors"
+
+	"hooks-store/internal/metrics"
+)
 
 // Document is the MeiliSearch-ready representation of a hook event.
 // Fields are chosen for optimal search, filter, and sort operations.
 type Document struct {
-	ID            string                 `json:"id"`
-	HookType      string                 `json:"hook_type"`
-	Timestamp     string                 `json:"timestamp"`
-	TimestampUnix int64                  `json:"timestamp_unix"`
-	SessionID     string                 `json:"session_id,omitempty"`
-	ToolName          string                 `json:"tool_name,omitempty"`
-	HasClaudeMD       bool                   `json:"has_claude_md"`
-	InputTokens       int64                  `json:"input_tokens,omitempty"`
-	OutputTokens      int64                  `json:"output_tokens,omitempty"`
-	CacheReadTokens   int64                  `json:"cache_read_tokens,omitempty"`
-	CacheCreateTokens int64                  `json:"cache_create_tokens,omitempty"`
-	CostUSD           float64                `json:"cost_usd,omitempty"`
-	Prompt            string                 `json:"prompt,omitempty"`
-	FilePath          string                 `json:"file_path,omitempty"`
-	ErrorMessage      string                 `json:"error_message,omitempty"`
-	ProjectDir        string                 `json:"project_dir,omitempty"`
-	PermissionMode    string                 `json:"permission_mode,omitempty"`
-	Cwd               string                 `json:"cwd,omitempty"`
-	DataFlat          string                 `json:"data_flat"`
-	Data          map[string]interface{} `json:"data"`
+	ID                   string                 `json:"id"`
+	HookType             string                 `json:"hook_type"`
+	Timestamp            string                 `json:"timestamp"`
+	TimestampUnix        int64                  `json:"timestamp_unix"`
+	Day                  string                 `json:"day"`  // UTC date bucket, e.g. 2026-02-25
+	Hour                 int                    `json:"hour"` // UTC hour bucket, 0-23
+	SessionID            string                 `json:"session_id,omitempty"`
+	ToolName             string                 `json:"tool_name,omitempty"`
+	HasClaudeMD          bool                   `json:"has_claude_md"`
+	InputTokens          int64                  `json:"input_tokens,omitempty"`
+	OutputTokens         int64                  `json:"output_tokens,omitempty"`
+	CacheReadTokens      int64                  `json:"cache_read_tokens,omitempty"`
+	CacheCreateTokens    int64                  `json:"cache_create_tokens,omitempty"`
+	TotalTokens          int64                  `json:"total_tokens,omitempty"`
+	CostUSD              float64                `json:"cost_usd,omitempty"`
+	CostPerKToken        float64                `json:"cost_per_k_token,omitempty"` // cost_usd per 1,000 total_tokens; 0 when either is 0
+	Prompt               string                 `json:"prompt,omitempty"`
+	PromptLengthOriginal int                    `json:"prompt_length_original,omitempty"` // untruncated prompt bytes; set only when MaxPromptBytes truncated Prompt
+	FilePath             string                 `json:"file_path,omitempty"`
+	ErrorMessage         string                 `json:"error_message,omitempty"`
+	ProjectDir           string                 `json:"project_dir,omitempty"`
+	PermissionMode       string                 `json:"permission_mode,omitempty"`
+	Cwd                  string                 `json:"cwd,omitempty"`
+	TeammateID           string                 `json:"teammate_id,omitempty"`
+	TeammateName         string                 `json:"teammate_name,omitempty"`
+	Success              *bool                  `json:"success,omitempty"`   // tool call outcome: set for PostToolUse (true) and PostToolUseFailure (false) only
+	ExitCode             *int64                 `json:"exit_code,omitempty"` // Bash tool calls only, when the result carries one
+	IsSubagent           bool                   `json:"is_subagent"`
+	ParentSessionID      string                 `json:"parent_session_id,omitempty"`
+	ClaudeVersion        string                 `json:"claude_version,omitempty"`        // CLI version, from SessionStart events only
+	SessionModel         string                 `json:"session_model,omitempty"`         // session's default model, from SessionStart events only
+	NotificationResponse string                 `json:"notification_response,omitempty"` // user's answer to a Notification, when the payload carries one
+	Tags                 []string               `json:"tags,omitempty"`                  // user-defined labels from data.tags
+	TurnNumber           int64                  `json:"turn_number,omitempty"`           // conversation turn within the session, when the payload carries one
+	Source               string                 `json:"source,omitempty"`                // ingestion source label (--source-label / X-Source)
+	ContentHash          string                 `json:"content_hash,omitempty"`          // ContentHash(Data), with TransformOptions.ContentHash
+	SessionFirstSeen     bool                   `json:"session_first_seen,omitempty"`    // first event FirstSeenStore saw for the session
+	RawBody              []byte                 `json:"raw_body,omitempty"`              // gzipped request body (CompressRawBody), with --store-raw-body; not searched or returned by Search
+	DataFlat             string                 `json:"data_flat,omitempty"`             // search text only; not returned by MeiliSearch
+	Data                 map[string]interface{} `json:"data"`
 }
 
 // PromptDocument is a lean MeiliSearch document for the dedicated prompts index.
 // Stores only UserPromptSubmit events with prompt-specific derived fields.
 type PromptDocument struct {
-	ID             string `json:"id"`
-	HookType       string `json:"hook_type"`
-	Timestamp      string `json:"timestamp"`
-	TimestampUnix  int64  `json:"timestamp_unix"`
-	SessionID      string `json:"session_id,omitempty"`
-	Prompt         string `json:"prompt"`
-	PromptLength   int    `json:"prompt_length"`
-	Cwd            string `json:"cwd,omitempty"`
-	ProjectDir     string `json:"project_dir,omitempty"`
-	PermissionMode string `json:"permission_mode,omitempty"`
-	HasClaudeMD    bool   `json:"has_claude_md"`
+	ID                   string `json:"id"`
+	HookType             string `json:"hook_type"`
+	Timestamp            string `json:"timestamp"`
+	TimestampUnix        int64  `json:"timestamp_unix"`
+	SessionID            string `json:"session_id,omitempty"`
+	Prompt               string `json:"prompt"`
+	PromptLength         int    `json:"prompt_length"`
+	PromptLengthOriginal int    `json:"prompt_length_original"` // before MaxPromptBytes truncation; equals PromptLength when untruncated
+	Cwd                  string `json:"cwd,omitempty"`
+	ProjectDir           string `json:"project_dir,omitempty"`
+	PermissionMode       string `json:"permission_mode,omitempty"`
+	HasClaudeMD          bool   `json:"has_claude_md"`
+}
+
+// Backlog describes indexing work the backend has accepted but not yet applied.
+type Backlog struct {
+	IsIndexing   bool  `json:"is_indexing"`
+	PendingTasks int64 `json:"pending_tasks"`
+}
+
+// BacklogReporter is implemented by stores that can report their indexing
+// backlog. It is optional — callers type-assert an EventStore to discover it.
+type BacklogReporter interface {
+	Backlog(ctx context.Context) (Backlog, error)
+}
+
+// CostQuery selects events for a cost report. Zero From/To mean unbounded.
+type CostQuery struct {
+	MinCost float64 // exclusive lower bound on cost_usd
+	From    int64   // inclusive lower bound on timestamp_unix
+	To      int64   // inclusive upper bound on timestamp_unix
+	Limit   int
+}
+
+// CostReport holds the most expensive matching events, highest cost first.
+// TotalUSD is the sum of cost_usd over the returned documents.
+type CostReport struct {
+	Documents []Document `json:"documents"`
+	TotalUSD  float64    `json:"total_usd"`
+}
+
+// CostReporter is implemented by stores that can rank events by cost.
+type CostReporter interface {
+	TopCosts(ctx context.Context, q CostQuery) (CostReport, error)
+}
+
+// DistinctValue is one distinct value of a field and how many events carry it.
+type DistinctValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// DistinctValuer is implemented by stores that can enumerate the distinct
+// values of a filterable field, most frequent first. limit caps how many are
+// returned (0 for the store's maximum); a limit above that maximum fails with
+// ErrFacetLimit.
+type DistinctValuer interface {
+	DistinctValues(ctx context.Context, field string, limit int) ([]DistinctValue, error)
+}
+
+// ErrFacetLimit is returned (wrapped) for a DistinctValues limit above the
+// store's maximum.
+var ErrFacetLimit = errors.New("facet limit exceeds the configured maximum")
+
+// Overview summarizes the whole store for a dashboard: the event count, the
+// time span covered, and value distributions of a few fields. From and To are
+// unix seconds of the oldest and newest event, 0 when the store is empty.
+type Overview struct {
+	Total  int64                      `json:"total"`
+	From   int64                      `json:"from"`
+	To     int64                      `json:"to"`
+	Facets map[string][]DistinctValue `json:"facets"`
+}
+
+// OverviewReporter is implemented by stores that can summarize all events
+// in one query.
+type OverviewReporter interface {
+	Overview(ctx context.Context) (Overview, error)
+}
+
+// DeleteResult reports the outcome of a bulk delete.
+type DeleteResult struct {
+	Index            string `json:"index,omitempty"`
+	TaskUID          int64  `json:"task_uid"`
+	Status           string `json:"status"`
+	DeletedDocuments int64  `json:"deleted_documents"`
+}
+
+// FilterDeleter is implemented by stores that can delete every document
+// matching a filter expression. CountByFilter lets callers preview how much
+// of the store a filter covers before committing to the delete.
+type FilterDeleter interface {
+	CountByFilter(ctx context.Context, filter string) (matched, total int64, err error)
+	DeleteByFilter(ctx context.Context, filter string) (DeleteResult, error)
+}
+
+// MetricsProvider is implemented by stores that expose Prometheus metrics;
+// the ingest server serves them at /metrics.
+type MetricsProvider interface {
+	Metrics() []metrics.Metric
+}
+
+// Clearer is implemented by stores that can delete every document they hold,
+// for resetting dev and test environments. Clear returns one result per
+// index it emptied.
+type Clearer interface {
+	Clear(ctx context.Context) ([]DeleteResult, error)
+}
+
+// TargetIndexer is implemented by stores that can write a document to a
+// named index other than their default, for per-request index overrides.
+// Callers are responsible for restricting which names may be used.
+type TargetIndexer interface {
+	IndexInto(ctx context.Context, index string, doc Document) error
+}
+
+// ErrTargetIndexUnsupported is returned (wrapped) by a decorator's IndexInto
+// when the store it wraps cannot write to a named index.
+var ErrTargetIndexUnsupported = errors.New("store does not support named indexes")
+
+// HealthChecker is implemented by stores that can probe their backend, for
+// diagnostics. Healthy returns nil when the backend is usable.
+type HealthChecker interface {
+	Healthy(ctx context.Context) error
+}
+
+// IndexSettings is the live configuration of one index, as the backend
+// reports it.
+type IndexSettings struct {
+	Index                string   `json:"index"`
+	RankingRules         []string `json:"ranking_rules"`
+	SearchableAttributes []string `json:"searchable_attributes"`
+	FilterableAttributes []string `json:"filterable_attributes"`
+	SortableAttributes   []string `json:"sortable_attributes"`
+	DisplayedAttributes  []string `json:"displayed_attributes"`
+	MaxTotalHits         int64    `json:"max_total_hits"`
+	MaxValuesPerFacet    int64    `json:"max_values_per_facet"`
+}
+
+// SettingsReporter is implemented by stores that can report the live
+// settings of their indexes, for diagnosing search behavior.
+type SettingsReporter interface {
+	GetSettings(ctx context.Context) ([]IndexSettings, error)
+}
+
+// SearchParams describes one page of a full-text search, newest first.
+type SearchParams struct {
+	Query  string
+	Filter string // optional MeiliSearch filter; must pass ValidateFilter
+	Limit  int
+	Cursor string // opaque token from a previous SearchResult.NextCursor
+
+	// Offset skips this many hits. Only valid with Query, whose hits are
+	// ranked by relevance so a cursor cannot page them; without a Query
+	// page by Cursor instead.
+	Offset int
+
+	// HookType and SessionID, when set, restrict hits to that exact value,
+	// ANDed with Filter; they need no quoting by the caller.
+	HookType  string
+	SessionID string
+
+	// Fields limits each hit to these DisplayedAttributes (id and
+	// timestamp_unix are always included for the cursor). Empty returns
+	// every displayed field.
+	Fields []string
+}
+
+// SearchResult is one page of search hits. NextCursor (without a Query) or
+// NextOffset (with one) is empty on the last page.
+type SearchResult struct {
+	Hits       []Document `json:"hits"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	NextOffset int        `json:"next_offset,omitempty"`
+}
+
+// Searcher is implemented by stores that support cursor-paginated search.
+// It is a capability rather than an EventStore method: the file backends
+// cannot search, and /search answers 501 when As finds no Searcher, like
+// the other query endpoints.
+type Searcher interface {
+	Search(ctx context.Context, p SearchParams) (SearchResult, error)
+}
+
+// ErrInvalidCursor is returned (wrapped) for a malformed search cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidOffset is returned (wrapped) for a negative search offset or
+// one given without a query.
+var ErrInvalidOffset = errors.New("invalid offset")
+
+// ErrSearchWindow is returned (wrapped) for a search limit above the
+// store's MaxTotalHits, or an offset at or past it, where MeiliSearch
+// returns no hits.
+var ErrSearchWindow = errors.New("search window exceeds max total hits")
+
+// ErrUnknownField is returned (wrapped) for a requested field that search
+// results cannot carry.
+var ErrUnknownField = errors.New("unknown field")
+
+// ErrNotFound is returned (wrapped) when a document does not exist.
+var ErrNotFound = errors.New("document not found")
+
+// Updater is implemented by stores that can merge additional data fields
+// into an existing document (two-phase events, e.g. a PreToolUse followed by
+// its outcome). It returns the updated document.
+type Updater interface {
+	Update(ctx context.Context, id string, data map[string]interface{}) (Document, error)
+}
+
+// AsyncIndexer is implemented by stores whose Index only enqueues the
+// document for the backend to index later (MeiliSearch tasks). Stores
+// without it are taken to have stored the document when Index returns.
+type AsyncIndexer interface {
+	IndexesAsync() bool
+}
+
+// DocumentGetter is implemented by stores that can fetch one document by ID.
+// It returns an error wrapping ErrNotFound when no document has that ID.
+type DocumentGetter interface {
+	GetDocument(ctx context.Context, id string) (Document, error)
+}
+
+// EventContext is the local timeline around one document: the events of its
+// session just before and after it, each list oldest first. Before and After
+// are empty for a document without a session_id.
+type EventContext struct {
+	Before []Document `json:"before"`
+	Event  Document   `json:"event"`
+	After  []Document `json:"after"`
+}
+
+// ContextReader is implemented by stores that can fetch the events
+// surrounding a document in its session, ordered by timestamp_unix then id
+// as Search orders them. It returns an error wrapping ErrNotFound when no
+// document has that ID.
+type ContextReader interface {
+	EventContext(ctx context.Context, id string, before, after int) (EventContext, error)
+}
+
+// PromptsErrorReporter is implemented by stores that dual-write prompts to a
+// secondary index and count the writes that failed there.
+type PromptsErrorReporter interface {
+	PromptsWriteErrors() int64
 }
 
 // EventStore is the storage port for persisting hook event documents.
@@ -50,6 +308,11 @@ type EventStore interface {
 	// is unreachable or the operation fails.
 	Index(ctx context.Context, doc Document) error
 
+	// BatchIndex persists docs in as few store operations as the backend
+	// allows (MeiliSearch: one task per index), for /ingest/batch and
+	// --replay. Its error applies to the whole batch.
+	BatchIndex(ctx context.Context, docs []Document) error
+
 	// Close releases any resources held by the store.
 	Close() error
 }
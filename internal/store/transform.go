@@ -1,17 +1,88 @@
 package store
 
 import (
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"hooks-store/internal/hookevt"
 
 	"github.com/google/uuid"
 )
 
+// TransformOptions tunes HookEventToDocumentWithOptions. The zero value
+// reproduces HookEventToDocument.
+type TransformOptions struct {
+	// Stages names the pipeline stages to run, in order (see
+	// ValidateStages). Empty runs DefaultStages.
+	Stages []string
+
+	// FlatEnvelope makes the envelope stage copy known event fields (see
+	// envelopeFields) from the envelope's top level into Data when Data
+	// lacks them, for senders that do not nest them under data.
+	FlatEnvelope bool
+
+	// MaxValueLen caps each string leaf value (in bytes) before it is joined
+	// into DataFlat. Data is left intact. 0 means unlimited.
+	MaxValueLen int
+
+	// StripANSI removes ANSI escape sequences (terminal colors, cursor
+	// movement) from string values in DataFlat and from ErrorMessage, in
+	// the strip-ansi stage. Data is left intact.
+	StripANSI bool
+
+	// NormalizePaths rewrites FilePath, Cwd, and ProjectDir with forward
+	// slashes and no trailing slash, in the normalize-paths stage, so
+	// Windows and Unix paths group together. Data is left intact.
+	NormalizePaths bool
+
+	// MaxPromptBytes truncates Prompt (and so the prompts index copy) to
+	// this many bytes, recording the untruncated length in
+	// PromptLengthOriginal. Data keeps the full text. 0 means unlimited.
+	MaxPromptBytes int
+
+	// SessionIDKey, when non-empty, is the HMAC key for replacing
+	// session_id and parent_session_id with HashSessionID pseudonyms, in
+	// Data as well as the derived fields. Events of one session still
+	// share an ID, but the raw one is never stored.
+	SessionIDKey []byte
+
+	// ContentHash stores ContentHash(Data) on each document, for finding
+	// byte-identical events and checking re-exports. It hashes Data as
+	// stored, i.e. after SessionIDKey hashing.
+	ContentHash bool
+
+	// Sample, when set, reports whether an event of the given hook type is
+	// kept; the sample stage fails the others with ErrSampledOut. The ingest
+	// server sets it from its sampling rates. Nil keeps every event.
+	Sample func(hookType string) bool
+}
+
+// ansiPattern matches CSI sequences (ESC [ ... final byte), OSC sequences
+// (ESC ] ... BEL or ESC \), and the remaining two-byte ESC sequences.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
 // HookEventToDocument transforms a wire-format HookEvent into a
 // MeiliSearch-ready Document with derived fields for search and filtering.
 func HookEventToDocument(evt hookevt.HookEvent) Document {
+	return HookEventToDocumentWithOptions(evt, TransformOptions{})
+}
+
+// HookEventToDocumentWithOptions is HookEventToDocument with tuning options.
+// It runs the same pipeline as TransformEvent; the built-in stages cannot
+// fail, so a stage error (only possible from a RegisterStage stage listed in
+// opts.Stages) is dropped and the document built so far is returned.
+func HookEventToDocumentWithOptions(evt hookevt.HookEvent, opts TransformOptions) Document {
+	doc, _ := TransformEvent(evt, opts)
+	return doc
+}
+
+// newDocument returns the document every pipeline starts from: a fresh ID,
+// the hook type, timestamp fields, and the raw data.
+func newDocument(evt hookevt.HookEvent) Document {
 	doc := Document{
 		ID:            uuid.New().String(),
 		HookType:      evt.HookType,
@@ -19,44 +90,56 @@ func HookEventToDocument(evt hookevt.HookEvent) Document {
 		TimestampUnix: evt.Timestamp.Unix(),
 		Data:          evt.Data,
 	}
+	doc.Day, doc.Hour = timeBuckets(evt.Timestamp)
+	return doc
+}
+
+// extractFields is the extract-fields stage: it derives every top-level
+// search and filter field from doc.Data, applying the string options.
+func extractFields(doc *Document, opts TransformOptions) {
+	data := doc.Data
 
 	// Extract top-level fields commonly used for filtering.
-	if sid, ok := extractString(evt.Data, "session_id"); ok {
+	if sid, ok := extractString(data, "session_id"); ok {
 		doc.SessionID = sid
 	}
-	if tn, ok := extractString(evt.Data, "tool_name"); ok {
+	if tn, ok := extractString(data, "tool_name"); ok {
 		doc.ToolName = tn
 	}
 
 	// Extract prompt text (UserPromptSubmit events).
-	if p, ok := extractString(evt.Data, "prompt"); ok {
+	if p, ok := extractString(data, "prompt"); ok {
+		if opts.MaxPromptBytes > 0 && len(p) > opts.MaxPromptBytes {
+			doc.PromptLengthOriginal = len(p)
+			p = truncateUTF8(p, opts.MaxPromptBytes)
+		}
 		doc.Prompt = p
 	}
 
 	// Extract file_path from tool_input (Read/Edit/Write/Glob events).
-	if ti, ok := extractNestedMap(evt.Data, "tool_input"); ok {
+	if ti, ok := extractNestedMap(data, "tool_input"); ok {
 		if fp, ok := extractString(ti, "file_path"); ok {
 			doc.FilePath = fp
 		}
 	}
 
 	// Extract error message (PostToolUseFailure events).
-	if em, ok := extractString(evt.Data, "error"); ok {
+	if em, ok := extractString(data, "error"); ok {
 		doc.ErrorMessage = em
 	}
 
 	// Extract permission_mode.
-	if pm, ok := extractString(evt.Data, "permission_mode"); ok {
+	if pm, ok := extractString(data, "permission_mode"); ok {
 		doc.PermissionMode = pm
 	}
 
 	// Extract working directory (present on all events).
-	if cwd, ok := extractString(evt.Data, "cwd"); ok {
+	if cwd, ok := extractString(data, "cwd"); ok {
 		doc.Cwd = cwd
 	}
 
 	// Extract CLAUDE.md flag from _monitor metadata (set by hook-client).
-	if monitor, ok := extractNestedMap(evt.Data, "_monitor"); ok {
+	if monitor, ok := extractNestedMap(data, "_monitor"); ok {
 		if hasMD, ok := extractBool(monitor, "has_claude_md"); ok {
 			doc.HasClaudeMD = hasMD
 		}
@@ -65,53 +148,141 @@ func HookEventToDocument(evt hookevt.HookEvent) Document {
 		}
 	}
 
+	// Extract teammate identity (TeammateIdle/TaskCompleted events).
+	doc.TeammateID, doc.TeammateName = extractTeammate(data)
+
+	// Derive the tool call outcome from the hook type.
+	doc.Success = toolSuccess(doc.HookType)
+
+	// Extract subagent context (events emitted inside a subagent).
+	doc.IsSubagent, doc.ParentSessionID = extractSubagent(data)
+
+	// Extract CLI version and default model (SessionStart events).
+	if doc.HookType == "SessionStart" {
+		doc.ClaudeVersion, doc.SessionModel = extractSessionMeta(data)
+	}
+
+	// Extract the user's answer to a notification (Notification events).
+	if doc.HookType == "Notification" {
+		doc.NotificationResponse = extractNotificationResponse(data)
+	}
+
+	// Extract user-defined tags (data.tags array).
+	doc.Tags = extractTags(data)
+
+	// Extract the exit code of Bash tool calls.
+	doc.ExitCode = extractExitCode(data)
+
+	// Extract conversation turn number, for in-session ordering.
+	doc.TurnNumber = extractTurnNumber(data)
+
 	// Extract token/cost metrics from the event data.
-	extractTokenMetrics(&doc, evt.Data)
+	extractTokenMetrics(doc, data)
 
 	// Extract leaf string values for full-text search.
 	// MeiliSearch indexes string fields for search — nested maps are not traversed.
 	// Using values-only extraction eliminates JSON key noise from search tokens.
-	doc.DataFlat = extractStringValues(evt.Data)
+	// ANSI stripping is left to the strip-ansi stage.
+	flat := opts
+	flat.StripANSI = false
+	doc.DataFlat = extractStringValuesWithOptions(data, flat)
 
-	return doc
+	if opts.ContentHash {
+		doc.ContentHash = ContentHash(data)
+	}
+}
+
+// MergeEventData merges data into doc's raw data (top-level keys in data
+// replace existing ones) and recomputes every derived field with opts. The
+// document keeps its ID, hook type, timestamp, and source.
+func MergeEventData(doc Document, data map[string]interface{}, opts TransformOptions) Document {
+	merged := make(map[string]interface{}, len(doc.Data)+len(data))
+	for k, v := range doc.Data {
+		merged[k] = v
+	}
+	for k, v := range data {
+		merged[k] = v
+	}
+
+	ts, err := time.Parse("2006-01-02T15:04:05.000Z", doc.Timestamp)
+	if err != nil {
+		ts = time.Unix(doc.TimestampUnix, 0)
+	}
+	updated := HookEventToDocumentWithOptions(hookevt.HookEvent{
+		HookType:  doc.HookType,
+		Timestamp: ts,
+		Data:      merged,
+	}, opts)
+	updated.ID = doc.ID
+	updated.Source = doc.Source
+	return updated
 }
 
 // DocumentToPromptDocument converts a Document to a PromptDocument for the
 // dedicated prompts index. Only meaningful for UserPromptSubmit events.
 func DocumentToPromptDocument(doc Document) PromptDocument {
+	original := doc.PromptLengthOriginal
+	if original == 0 {
+		original = len(doc.Prompt)
+	}
 	return PromptDocument{
-		ID:             doc.ID,
-		HookType:       doc.HookType,
-		Timestamp:      doc.Timestamp,
-		TimestampUnix:  doc.TimestampUnix,
-		SessionID:      doc.SessionID,
-		Prompt:         doc.Prompt,
-		PromptLength:   len(doc.Prompt),
-		Cwd:            doc.Cwd,
-		ProjectDir:     doc.ProjectDir,
-		PermissionMode: doc.PermissionMode,
-		HasClaudeMD:    doc.HasClaudeMD,
+		ID:                   doc.ID,
+		HookType:             doc.HookType,
+		Timestamp:            doc.Timestamp,
+		TimestampUnix:        doc.TimestampUnix,
+		SessionID:            doc.SessionID,
+		Prompt:               doc.Prompt,
+		PromptLength:         len(doc.Prompt),
+		PromptLengthOriginal: original,
+		Cwd:                  doc.Cwd,
+		ProjectDir:           doc.ProjectDir,
+		PermissionMode:       doc.PermissionMode,
+		HasClaudeMD:          doc.HasClaudeMD,
 	}
 }
 
+// NormalizePath converts backslashes to forward slashes and strips trailing
+// slashes, keeping a bare root ("/" or "C:/") intact. It is the form the
+// normalize-paths stage stores, so project_dir keys compared against stored
+// documents (retention overrides) go through it too.
+func NormalizePath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	for len(p) > 1 && strings.HasSuffix(p, "/") {
+		if len(p) == 3 && p[1] == ':' {
+			break // drive root
+		}
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
 // extractStringValues recursively extracts string leaf values from a data map,
 // skipping all keys and non-string values (numbers, bools, null).
 // Returns a space-separated string suitable for full-text search indexing.
 func extractStringValues(data map[string]interface{}) string {
+	return extractStringValuesWithOptions(data, TransformOptions{})
+}
+
+// extractStringValuesWithOptions is extractStringValues with per-value limits
+// from opts applied to each leaf before joining.
+func extractStringValuesWithOptions(data map[string]interface{}, opts TransformOptions) string {
 	if data == nil {
 		return ""
 	}
 	var values []string
-	collectStringValues(data, &values)
+	collectStringValues(data, &values, opts)
 	return strings.Join(values, " ")
 }
 
 // collectStringValues is the recursive helper for extractStringValues.
-func collectStringValues(v interface{}, values *[]string) {
+func collectStringValues(v interface{}, values *[]string, opts TransformOptions) {
 	switch val := v.(type) {
 	case string:
+		if opts.StripANSI {
+			val = stripANSI(val)
+		}
 		if val != "" {
-			*values = append(*values, val)
+			*values = append(*values, truncateUTF8(val, opts.MaxValueLen))
 		}
 	case map[string]interface{}:
 		keys := make([]string, 0, len(val))
@@ -120,16 +291,44 @@ func collectStringValues(v interface{}, values *[]string) {
 		}
 		sort.Strings(keys)
 		for _, k := range keys {
-			collectStringValues(val[k], values)
+			collectStringValues(val[k], values, opts)
 		}
 	case []interface{}:
 		for _, elem := range val {
-			collectStringValues(elem, values)
+			collectStringValues(elem, values, opts)
 		}
 	// float64, bool, nil — skip (not useful for text search)
 	}
 }
 
+// stripANSI removes ANSI escape sequences from s.
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// truncateUTF8 shortens s to at most max bytes without splitting a multi-byte
+// rune. max <= 0 means no limit.
+func truncateUTF8(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// timeBuckets returns the UTC day (YYYY-MM-DD) and hour of t, for faceting
+// events by date without histogram math. Always UTC, so buckets don't depend
+// on the sender's or server's zone.
+func timeBuckets(t time.Time) (day string, hour int) {
+	t = t.UTC()
+	return t.Format("2006-01-02"), t.Hour()
+}
+
 // extractString retrieves a string value from a JSON-unmarshaled map.
 // Returns ("", false) if the key is missing or the value is not a string.
 func extractString(data map[string]interface{}, key string) (string, bool) {
@@ -172,6 +371,197 @@ func extractNestedMap(data map[string]interface{}, key string) (map[string]inter
 	return m, ok
 }
 
+// extractTeammate returns the teammate ID and name from the event data.
+// Accepts flat keys (teammate_id/teammate_name), a nested "teammate" map
+// (id/name), and agent_id/agent_name as a fallback. Either value may be empty.
+func extractTeammate(data map[string]interface{}) (id, name string) {
+	id, _ = extractString(data, "teammate_id")
+	name, _ = extractString(data, "teammate_name")
+	if tm, ok := extractNestedMap(data, "teammate"); ok {
+		if id == "" {
+			id, _ = extractString(tm, "id")
+		}
+		if name == "" {
+			name, _ = extractString(tm, "name")
+		}
+	}
+	if id == "" {
+		id, _ = extractString(data, "agent_id")
+	}
+	if name == "" {
+		name, _ = extractString(data, "agent_name")
+	}
+	return id, name
+}
+
+// toolSuccess returns the tool call outcome implied by hookType: true for
+// PostToolUse, false for PostToolUseFailure, nil for every other event.
+func toolSuccess(hookType string) *bool {
+	var ok bool
+	switch hookType {
+	case "PostToolUse":
+		ok = true
+	case "PostToolUseFailure":
+		ok = false
+	default:
+		return nil
+	}
+	return &ok
+}
+
+// extractSubagent reports whether the event came from a subagent, and the
+// session ID of its parent when known. An explicit is_subagent flag wins; a
+// non-empty parent_session_id alone also marks the event as a subagent's.
+func extractSubagent(data map[string]interface{}) (isSubagent bool, parentSessionID string) {
+	parentSessionID, _ = extractString(data, "parent_session_id")
+	if b, ok := extractBool(data, "is_subagent"); ok {
+		return b, parentSessionID
+	}
+	return parentSessionID != "", parentSessionID
+}
+
+// extractSessionMeta returns the Claude Code version and default model from
+// a SessionStart payload. The version is read from version or
+// claude_version, then _monitor.claude_version (set by hook-client); the
+// model from model, either a plain string or an object with an id.
+func extractSessionMeta(data map[string]interface{}) (version, model string) {
+	for _, key := range []string{"version", "claude_version"} {
+		if v, ok := extractString(data, key); ok && v != "" {
+			version = v
+			break
+		}
+	}
+	if version == "" {
+		if monitor, ok := extractNestedMap(data, "_monitor"); ok {
+			version, _ = extractString(monitor, "claude_version")
+		}
+	}
+	if m, ok := extractString(data, "model"); ok {
+		model = m
+	} else if m, ok := extractNestedMap(data, "model"); ok {
+		model, _ = extractString(m, "id")
+	}
+	return version, model
+}
+
+// notificationResponseKeys are the payload keys a Notification's user
+// response may arrive under, in priority order.
+var notificationResponseKeys = []string{"notification_response", "user_response", "response", "decision", "action"}
+
+// extractNotificationResponse returns the user's response to a Notification
+// (e.g. "approve" or "dismiss" for a permission prompt), lowercased and
+// trimmed. It checks notificationResponseKeys at the top level, then inside
+// _monitor and notification maps; a response given as an object contributes
+// its action, decision, or value. Returns "" when no response is present.
+func extractNotificationResponse(data map[string]interface{}) string {
+	scopes := []map[string]interface{}{data}
+	for _, key := range []string{"_monitor", "notification"} {
+		if m, ok := extractNestedMap(data, key); ok {
+			scopes = append(scopes, m)
+		}
+	}
+	for _, scope := range scopes {
+		for _, key := range notificationResponseKeys {
+			if resp := responseValue(scope[key]); resp != "" {
+				return resp
+			}
+		}
+	}
+	return ""
+}
+
+// responseValue normalizes one candidate response: a string, or an object
+// whose action, decision, or value field is a string.
+func responseValue(v interface{}) string {
+	switch r := v.(type) {
+	case string:
+		return strings.ToLower(strings.TrimSpace(r))
+	case map[string]interface{}:
+		for _, key := range []string{"action", "decision", "value"} {
+			if s, ok := extractString(r, key); ok {
+				return strings.ToLower(strings.TrimSpace(s))
+			}
+		}
+	}
+	return ""
+}
+
+// extractTags returns the non-empty strings of the data.tags array, in order
+// and without duplicates. Non-string elements are skipped; a missing or
+// non-array tags value yields nil.
+func extractTags(data map[string]interface{}) []string {
+	raw, ok := data["tags"].([]interface{})
+	if !ok {
+		return nil
+	}
+	var tags []string
+	seen := make(map[string]bool, len(raw))
+	for _, v := range raw {
+		s, ok := v.(string)
+		if !ok || s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		tags = append(tags, s)
+	}
+	return tags
+}
+
+// extractTurnNumber returns the conversation turn the event belongs to, or 0
+// if the payload carries none. Checks turn/turn_number at the top level, then
+// inside _monitor and conversation maps; the first positive whole number
+// wins.
+func extractTurnNumber(data map[string]interface{}) int64 {
+	sources := []map[string]interface{}{data}
+	for _, key := range []string{"_monitor", "conversation"} {
+		if m, ok := extractNestedMap(data, key); ok {
+			sources = append(sources, m)
+		}
+	}
+	for _, m := range sources {
+		for _, key := range []string{"turn", "turn_number"} {
+			if f, ok := extractFloat64(m, key); ok && f > 0 && f == float64(int64(f)) {
+				return int64(f)
+			}
+		}
+	}
+	return 0
+}
+
+// exitCodeKeys are the keys a Bash tool result may carry its exit code under.
+var exitCodeKeys = []string{"exit_code", "exitCode", "returncode", "return_code"}
+
+// extractExitCode returns the exit code of a Bash tool call: the first
+// whole number under exitCodeKeys in tool_response, then at the top level.
+// A numeric string counts. Nil for other tools or when no code is present,
+// so a missing code is distinguishable from a successful 0.
+func extractExitCode(data map[string]interface{}) *int64 {
+	if tn, _ := extractString(data, "tool_name"); tn != "Bash" {
+		return nil
+	}
+	var sources []map[string]interface{}
+	if m, ok := extractNestedMap(data, "tool_response"); ok {
+		sources = append(sources, m)
+	}
+	sources = append(sources, data)
+	for _, m := range sources {
+		for _, key := range exitCodeKeys {
+			switch v := m[key].(type) {
+			case float64:
+				if v == float64(int64(v)) {
+					code := int64(v)
+					return &code
+				}
+			case string:
+				if code, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+					return &code
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // extractTokenMetrics populates token and cost fields from the event data.
 // Claude Code places these at different nesting levels depending on hook type,
 // so we check multiple known paths defensively. First non-zero value wins.
@@ -237,4 +627,16 @@ func extractTokenMetrics(doc *Document, data map[string]interface{}) {
 			}
 		}
 	}
+
+	doc.TotalTokens = doc.InputTokens + doc.OutputTokens + doc.CacheReadTokens + doc.CacheCreateTokens
+	doc.CostPerKToken = costPerKToken(doc.CostUSD, doc.TotalTokens)
+}
+
+// costPerKToken returns the cost of 1,000 tokens at this event's rate, or 0
+// when the event carries no tokens (or no cost) to derive it from.
+func costPerKToken(costUSD float64, totalTokens int64) float64 {
+	if totalTokens <= 0 || costUSD <= 0 {
+		return 0
+	}
+	return costUSD / float64(totalTokens) * 1000
 }
@@ -1,6 +1,9 @@
 package store
 
 import (
+	"encoding/json"
+	"math"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -390,6 +393,9 @@ func TestHookEventToDocument_TokenMetrics_TopLevel(t *testing.T) {
 	if doc.CacheCreateTokens != 100 {
 		t.Errorf("CacheCreateTokens = %d, want 100", doc.CacheCreateTokens)
 	}
+	if doc.TotalTokens != 2300 {
+		t.Errorf("TotalTokens = %d, want 2300", doc.TotalTokens)
+	}
 	if doc.CostUSD != 0.0042 {
 		t.Errorf("CostUSD = %f, want 0.0042", doc.CostUSD)
 	}
@@ -519,6 +525,43 @@ func TestDocumentToPromptDocument(t *testing.T) {
 	}
 }
 
+func TestHookEventToDocument_MaxPromptBytes(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("é", 10) // 20 bytes
+	evt := hookevt.HookEvent{
+		HookType:  "UserPromptSubmit",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"prompt": long},
+	}
+
+	doc := HookEventToDocumentWithOptions(evt, TransformOptions{MaxPromptBytes: 7})
+	if doc.Prompt != "ééé" {
+		t.Errorf("Prompt = %q, want 3 runes (7 bytes cut at a rune boundary)", doc.Prompt)
+	}
+	if doc.PromptLengthOriginal != 20 {
+		t.Errorf("PromptLengthOriginal = %d, want 20", doc.PromptLengthOriginal)
+	}
+	if doc.Data["prompt"] != long {
+		t.Errorf("data.prompt was modified: %v", doc.Data["prompt"])
+	}
+	pdoc := DocumentToPromptDocument(doc)
+	if pdoc.Prompt != "ééé" || pdoc.PromptLength != 6 || pdoc.PromptLengthOriginal != 20 {
+		t.Errorf("prompt doc = %q len %d original %d, want ééé, 6, 20", pdoc.Prompt, pdoc.PromptLength, pdoc.PromptLengthOriginal)
+	}
+
+	// Within the limit (and with no limit) nothing is truncated or recorded.
+	for _, max := range []int{0, 20} {
+		doc := HookEventToDocumentWithOptions(evt, TransformOptions{MaxPromptBytes: max})
+		if doc.Prompt != long || doc.PromptLengthOriginal != 0 {
+			t.Errorf("max %d: Prompt = %q, PromptLengthOriginal = %d; want untouched, 0", max, doc.Prompt, doc.PromptLengthOriginal)
+		}
+		if pdoc := DocumentToPromptDocument(doc); pdoc.PromptLengthOriginal != 20 {
+			t.Errorf("max %d: prompt doc original = %d, want 20", max, pdoc.PromptLengthOriginal)
+		}
+	}
+}
+
 func TestDocumentToPromptDocument_EmptyPrompt(t *testing.T) {
 	t.Parallel()
 
@@ -627,3 +670,564 @@ func TestHookEventToDocument_TimestampUTC(t *testing.T) {
 		t.Errorf("Timestamp = %q, want UTC conversion 2026-02-25T15:00:00.000Z", doc.Timestamp)
 	}
 }
+
+func TestHookEventToDocument_Teammate(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{
+		HookType:  "TeammateIdle",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"teammate_id":   "tm-42",
+			"teammate_name": "reviewer",
+		},
+	}
+
+	doc := HookEventToDocument(evt)
+
+	if doc.TeammateID != "tm-42" {
+		t.Errorf("TeammateID = %q, want tm-42", doc.TeammateID)
+	}
+	if doc.TeammateName != "reviewer" {
+		t.Errorf("TeammateName = %q, want reviewer", doc.TeammateName)
+	}
+}
+
+func TestHookEventToDocument_Teammate_Nested(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{
+		HookType:  "TeammateIdle",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"teammate": map[string]interface{}{
+				"id":   "tm-7",
+				"name": "tester",
+			},
+		},
+	}
+
+	doc := HookEventToDocument(evt)
+
+	if doc.TeammateID != "tm-7" {
+		t.Errorf("TeammateID = %q, want tm-7", doc.TeammateID)
+	}
+	if doc.TeammateName != "tester" {
+		t.Errorf("TeammateName = %q, want tester", doc.TeammateName)
+	}
+}
+
+func TestHookEventToDocument_Teammate_Missing(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{
+		HookType:  "PreToolUse",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"tool_name": "Read"},
+	}
+
+	doc := HookEventToDocument(evt)
+
+	if doc.TeammateID != "" || doc.TeammateName != "" {
+		t.Errorf("teammate = (%q, %q), want empty", doc.TeammateID, doc.TeammateName)
+	}
+}
+
+func TestHookEventToDocument_TotalTokens(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{
+		HookType:  "Stop",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"usage": map[string]interface{}{
+				"input_tokens":                float64(3000),
+				"output_tokens":               float64(700),
+				"cache_read_input_tokens":     float64(12000),
+				"cache_creation_input_tokens": float64(300),
+			},
+		},
+	}
+	doc := HookEventToDocument(evt)
+
+	if doc.TotalTokens != 16000 {
+		t.Errorf("TotalTokens = %d, want 16000 (input+output+cache read+cache create)", doc.TotalTokens)
+	}
+
+	empty := HookEventToDocument(hookevt.HookEvent{HookType: "Notification", Timestamp: time.Now(), Data: map[string]interface{}{}})
+	if empty.TotalTokens != 0 {
+		t.Errorf("TotalTokens = %d, want 0 without usage", empty.TotalTokens)
+	}
+}
+
+func TestHookEventToDocument_CostPerKToken(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{
+		HookType:  "Stop",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"total_cost_usd": 0.05,
+			"usage": map[string]interface{}{
+				"input_tokens":  float64(8000),
+				"output_tokens": float64(2000),
+			},
+		},
+	}
+	doc := HookEventToDocument(evt)
+	if math.Abs(doc.CostPerKToken-0.005) > 1e-12 {
+		t.Errorf("CostPerKToken = %v, want 0.005 ($0.05 over 10K tokens)", doc.CostPerKToken)
+	}
+
+	// A cost with no tokens must not divide by zero.
+	costOnly := HookEventToDocument(hookevt.HookEvent{
+		HookType:  "Stop",
+		Timestamp: time.Now(),
+		Data:      map[string]interface{}{"total_cost_usd": 0.05},
+	})
+	if costOnly.CostPerKToken != 0 || math.IsInf(costOnly.CostPerKToken, 0) || math.IsNaN(costOnly.CostPerKToken) {
+		t.Errorf("CostPerKToken = %v, want 0 with zero tokens", costOnly.CostPerKToken)
+	}
+	if b, _ := json.Marshal(costOnly); strings.Contains(string(b), "cost_per_k_token") {
+		t.Errorf("zero cost_per_k_token should be omitted: %s", b)
+	}
+}
+
+func TestHookEventToDocument_MaxValueLen(t *testing.T) {
+	t.Parallel()
+
+	huge := strings.Repeat("x", 4<<20)
+	evt := hookevt.HookEvent{
+		HookType:  "PostToolUse",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"tool_name": "Bash",
+			"tool_response": map[string]interface{}{
+				"stdout": huge,
+			},
+		},
+	}
+	doc := HookEventToDocumentWithOptions(evt, TransformOptions{MaxValueLen: 1024})
+
+	if len(doc.DataFlat) > 1024+len(" Bash") {
+		t.Errorf("len(DataFlat) = %d, want bounded near 1024", len(doc.DataFlat))
+	}
+	if !strings.Contains(doc.DataFlat, "Bash") {
+		t.Errorf("DataFlat %q... should still contain short values", doc.DataFlat[:32])
+	}
+	resp := doc.Data["tool_response"].(map[string]interface{})
+	if resp["stdout"] != huge {
+		t.Error("Data should keep the full string")
+	}
+
+	unlimited := HookEventToDocument(evt)
+	if len(unlimited.DataFlat) < len(huge) {
+		t.Errorf("len(DataFlat) = %d, want full value without a limit", len(unlimited.DataFlat))
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"hello", 0, "hello"},
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"héllo", 2, "h"}, // é is 2 bytes; don't split it
+		{"héllo", 3, "hé"},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.in, tt.max); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestMergeEventData(t *testing.T) {
+	t.Parallel()
+
+	orig := HookEventToDocument(hookevt.HookEvent{
+		HookType:  "PreToolUse",
+		Timestamp: time.Date(2026, 2, 25, 14, 30, 0, 0, time.UTC),
+		Data: map[string]interface{}{
+			"tool_name":  "Bash",
+			"session_id": "s1",
+			"tool_input": map[string]interface{}{"command": "make test"},
+		},
+	})
+	orig.Source = "ci"
+
+	merged := MergeEventData(orig, map[string]interface{}{
+		"error":      "exit status 2",
+		"session_id": "s2",
+	}, TransformOptions{})
+
+	if merged.ID != orig.ID || merged.HookType != "PreToolUse" || merged.Timestamp != orig.Timestamp || merged.Source != "ci" {
+		t.Errorf("identity changed: %+v", merged)
+	}
+	if merged.ErrorMessage != "exit status 2" {
+		t.Errorf("ErrorMessage = %q, want recomputed from merged data", merged.ErrorMessage)
+	}
+	if merged.SessionID != "s2" {
+		t.Errorf("SessionID = %q, want patched value s2", merged.SessionID)
+	}
+	if merged.ToolName != "Bash" || !strings.Contains(merged.DataFlat, "make test") {
+		t.Errorf("original fields lost: tool=%q data_flat=%q", merged.ToolName, merged.DataFlat)
+	}
+	if _, ok := orig.Data["error"]; ok {
+		t.Error("MergeEventData must not mutate the original data map")
+	}
+}
+
+func TestHookEventToDocument_StripANSI(t *testing.T) {
+	t.Parallel()
+
+	colored := "\x1b[1;31mFAIL\x1b[0m TestFoo \x1b]0;title\x07(0.01s)\x1b[K"
+	evt := hookevt.HookEvent{
+		HookType:  "PostToolUseFailure",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"tool_name": "Bash",
+			"error":     "\x1b[31mexit status 1\x1b[0m",
+			"tool_response": map[string]interface{}{
+				"stdout": colored,
+			},
+		},
+	}
+
+	doc := HookEventToDocumentWithOptions(evt, TransformOptions{StripANSI: true})
+	if strings.Contains(doc.DataFlat, "\x1b") || strings.Contains(doc.DataFlat, "[0m") {
+		t.Errorf("DataFlat = %q, want no escape sequences", doc.DataFlat)
+	}
+	if !strings.Contains(doc.DataFlat, "FAIL TestFoo (0.01s)") {
+		t.Errorf("DataFlat = %q, want clean text \"FAIL TestFoo (0.01s)\"", doc.DataFlat)
+	}
+	if doc.ErrorMessage != "exit status 1" {
+		t.Errorf("ErrorMessage = %q, want exit status 1", doc.ErrorMessage)
+	}
+	stdout := doc.Data["tool_response"].(map[string]interface{})["stdout"]
+	if stdout != colored {
+		t.Error("Data should keep the original escape sequences")
+	}
+
+	raw := HookEventToDocument(evt)
+	if !strings.Contains(raw.DataFlat, "\x1b[1;31m") {
+		t.Error("without StripANSI, DataFlat should be unchanged")
+	}
+}
+
+func TestHookEventToDocument_Subagent(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name       string
+		data       map[string]interface{}
+		wantSub    bool
+		wantParent string
+	}{
+		{"parent only", map[string]interface{}{"parent_session_id": "sess-main"}, true, "sess-main"},
+		{"flag and parent", map[string]interface{}{"is_subagent": true, "parent_session_id": "sess-main"}, true, "sess-main"},
+		{"flag only", map[string]interface{}{"is_subagent": true}, true, ""},
+		{"explicit false", map[string]interface{}{"is_subagent": false}, false, ""},
+		{"main agent", map[string]interface{}{"session_id": "sess-main"}, false, ""},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{
+			HookType:  "PreToolUse",
+			Timestamp: time.Now(),
+			Data:      tt.data,
+		})
+		if doc.IsSubagent != tt.wantSub || doc.ParentSessionID != tt.wantParent {
+			t.Errorf("%s: (IsSubagent, ParentSessionID) = (%v, %q), want (%v, %q)",
+				tt.name, doc.IsSubagent, doc.ParentSessionID, tt.wantSub, tt.wantParent)
+		}
+	}
+}
+
+func TestHookEventToDocument_SessionMeta(t *testing.T) {
+	t.Parallel()
+
+	// Representative SessionStart payload as sent by hook-client.
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"session_id": "sess-1",
+		"transcript_path": "/home/u/.claude/projects/p/sess-1.jsonl",
+		"cwd": "/home/u/p",
+		"hook_event_name": "SessionStart",
+		"source": "startup",
+		"model": "claude-sonnet-4-5-20250929",
+		"version": "2.0.14",
+		"_monitor": {"project_dir": "/home/u/p", "has_claude_md": true}
+	}`), &data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name        string
+		hookType    string
+		data        map[string]interface{}
+		wantVersion string
+		wantModel   string
+	}{
+		{"session start", "SessionStart", data, "2.0.14", "claude-sonnet-4-5-20250929"},
+		{"model object", "SessionStart", map[string]interface{}{
+			"model": map[string]interface{}{"id": "claude-opus-4-1", "display_name": "Opus"},
+		}, "", "claude-opus-4-1"},
+		{"monitor version", "SessionStart", map[string]interface{}{
+			"_monitor": map[string]interface{}{"claude_version": "1.0.88"},
+		}, "1.0.88", ""},
+		{"other hook type", "PreToolUse", data, "", ""},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{
+			HookType:  tt.hookType,
+			Timestamp: time.Now(),
+			Data:      tt.data,
+		})
+		if doc.ClaudeVersion != tt.wantVersion || doc.SessionModel != tt.wantModel {
+			t.Errorf("%s: (ClaudeVersion, SessionModel) = (%q, %q), want (%q, %q)",
+				tt.name, doc.ClaudeVersion, doc.SessionModel, tt.wantVersion, tt.wantModel)
+		}
+	}
+}
+
+func TestHookEventToDocument_NotificationResponse(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		hookType string
+		data     map[string]interface{}
+		want     string
+	}{
+		{"top-level response", "Notification", map[string]interface{}{
+			"message":           "Claude needs your permission to use Bash",
+			"notification_type": "permission_prompt",
+			"response":          " Approve ",
+		}, "approve"},
+		{"explicit key wins", "Notification", map[string]interface{}{"notification_response": "dismiss", "action": "open"}, "dismiss"},
+		{"response object", "Notification", map[string]interface{}{
+			"user_response": map[string]interface{}{"decision": "deny", "at": "2026-02-25T14:30:00Z"},
+		}, "deny"},
+		{"monitor", "Notification", map[string]interface{}{
+			"_monitor": map[string]interface{}{"notification_response": "approve"},
+		}, "approve"},
+		{"nested notification", "Notification", map[string]interface{}{
+			"notification": map[string]interface{}{"action": "dismissed"},
+		}, "dismissed"},
+		{"no response", "Notification", map[string]interface{}{"message": "Claude is waiting for your input"}, ""},
+		{"non-string response", "Notification", map[string]interface{}{"response": 1.0}, ""},
+		{"other hook type", "PreToolUse", map[string]interface{}{"response": "approve"}, ""},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{
+			HookType:  tt.hookType,
+			Timestamp: time.Now(),
+			Data:      tt.data,
+		})
+		if doc.NotificationResponse != tt.want {
+			t.Errorf("%s: NotificationResponse = %q, want %q", tt.name, doc.NotificationResponse, tt.want)
+		}
+	}
+}
+
+func TestHookEventToDocument_ExitCode(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name string
+		data map[string]interface{}
+		want string // decimal code or "absent"
+	}{
+		{"zero", map[string]interface{}{"tool_name": "Bash", "tool_response": map[string]interface{}{"stdout": "ok", "exit_code": 0.0}}, "0"},
+		{"nonzero", map[string]interface{}{"tool_name": "Bash", "tool_response": map[string]interface{}{"stderr": "boom", "exitCode": 2.0}}, "2"},
+		{"returncode string", map[string]interface{}{"tool_name": "Bash", "tool_response": map[string]interface{}{"returncode": " 127 "}}, "127"},
+		{"top level", map[string]interface{}{"tool_name": "Bash", "exit_code": 1.0}, "1"},
+		{"absent", map[string]interface{}{"tool_name": "Bash", "tool_response": map[string]interface{}{"stdout": "ok"}}, "absent"},
+		{"fractional", map[string]interface{}{"tool_name": "Bash", "exit_code": 1.5}, "absent"},
+		{"other tool", map[string]interface{}{"tool_name": "Read", "tool_response": map[string]interface{}{"exit_code": 1.0}}, "absent"},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{
+			HookType:  "PostToolUse",
+			Timestamp: time.Now(),
+			Data:      tt.data,
+		})
+		got := "absent"
+		if doc.ExitCode != nil {
+			got = strconv.FormatInt(*doc.ExitCode, 10)
+		}
+		if got != tt.want {
+			t.Errorf("%s: ExitCode = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHookEventToDocument_Success(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		hookType string
+		want     string // "true", "false", or "absent"
+	}{
+		{"PostToolUse", "true"},
+		{"PostToolUseFailure", "false"},
+		{"PreToolUse", "absent"},
+		{"UserPromptSubmit", "absent"},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{
+			HookType:  tt.hookType,
+			Timestamp: time.Now(),
+			Data:      map[string]interface{}{"tool_name": "Bash"},
+		})
+		got := "absent"
+		if doc.Success != nil {
+			got = strconv.FormatBool(*doc.Success)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Success = %s, want %s", tt.hookType, got, tt.want)
+		}
+
+		// Absent means omitted from JSON, not false.
+		raw, _ := json.Marshal(doc)
+		if has := strings.Contains(string(raw), `"success"`); has != (tt.want != "absent") {
+			t.Errorf("%s: success key in JSON = %v", tt.hookType, has)
+		}
+	}
+}
+
+func TestHookEventToDocument_TurnNumber(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name string
+		data map[string]interface{}
+		want int64
+	}{
+		{"top-level turn", map[string]interface{}{"turn": float64(7)}, 7},
+		{"top-level turn_number", map[string]interface{}{"turn_number": float64(3)}, 3},
+		{"monitor", map[string]interface{}{"_monitor": map[string]interface{}{"turn": float64(4)}}, 4},
+		{"conversation", map[string]interface{}{"conversation": map[string]interface{}{"turn_number": float64(12)}}, 12},
+		{"top-level wins", map[string]interface{}{"turn": float64(2), "_monitor": map[string]interface{}{"turn": float64(9)}}, 2},
+		{"string ignored", map[string]interface{}{"turn": "5"}, 0},
+		{"fraction ignored", map[string]interface{}{"turn": 1.5}, 0},
+		{"negative ignored", map[string]interface{}{"turn": float64(-1)}, 0},
+		{"missing", map[string]interface{}{"tool_name": "Read"}, 0},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{
+			HookType:  "UserPromptSubmit",
+			Timestamp: time.Now(),
+			Data:      tt.data,
+		})
+		if doc.TurnNumber != tt.want {
+			t.Errorf("%s: TurnNumber = %d, want %d", tt.name, doc.TurnNumber, tt.want)
+		}
+	}
+}
+
+func TestHookEventToDocument_TimeBuckets(t *testing.T) {
+	t.Parallel()
+
+	est := time.FixedZone("EST", -5*60*60)
+	for _, tt := range []struct {
+		name     string
+		ts       time.Time
+		wantDay  string
+		wantHour int
+	}{
+		{"utc", time.Date(2026, 2, 25, 14, 30, 0, 0, time.UTC), "2026-02-25", 14},
+		{"midnight", time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC), "2026-02-25", 0},
+		// 23:30 EST on the 25th is 04:30 UTC on the 26th.
+		{"local evening crosses UTC midnight", time.Date(2026, 2, 25, 23, 30, 0, 0, est), "2026-02-26", 4},
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{HookType: "Stop", Timestamp: tt.ts})
+		if doc.Day != tt.wantDay || doc.Hour != tt.wantHour {
+			t.Errorf("%s: (Day, Hour) = (%q, %d), want (%q, %d)", tt.name, doc.Day, doc.Hour, tt.wantDay, tt.wantHour)
+		}
+	}
+}
+
+func TestHookEventToDocument_Tags(t *testing.T) {
+	t.Parallel()
+
+	doc := HookEventToDocument(hookevt.HookEvent{
+		HookType:  "PreToolUse",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"tags": []interface{}{"experiment-x", "urgent", float64(3), "", "urgent"},
+		},
+	})
+	if len(doc.Tags) != 2 || doc.Tags[0] != "experiment-x" || doc.Tags[1] != "urgent" {
+		t.Errorf("Tags = %q, want [experiment-x urgent]", doc.Tags)
+	}
+}
+
+func TestHookEventToDocument_Tags_Missing(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []map[string]interface{}{
+		{"tool_name": "Read"},
+		{"tags": "urgent"}, // not an array
+	} {
+		doc := HookEventToDocument(hookevt.HookEvent{HookType: "PreToolUse", Timestamp: time.Now(), Data: data})
+		if doc.Tags != nil {
+			t.Errorf("data %v: Tags = %q, want nil", data, doc.Tags)
+		}
+	}
+}
+
+func TestHookEventToDocument_NormalizePaths(t *testing.T) {
+	t.Parallel()
+
+	evt := hookevt.HookEvent{
+		HookType:  "PreToolUse",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"tool_name":  "Edit",
+			"cwd":        `C:\Users\dev\project\`,
+			"tool_input": map[string]interface{}{"file_path": `C:\Users\dev\project\src\main.go`},
+			"_monitor":   map[string]interface{}{"project_dir": `C:\Users\dev\project`},
+		},
+	}
+
+	doc := HookEventToDocumentWithOptions(evt, TransformOptions{NormalizePaths: true})
+	if doc.FilePath != "C:/Users/dev/project/src/main.go" {
+		t.Errorf("FilePath = %q, want C:/Users/dev/project/src/main.go", doc.FilePath)
+	}
+	if doc.Cwd != "C:/Users/dev/project" {
+		t.Errorf("Cwd = %q, want C:/Users/dev/project", doc.Cwd)
+	}
+	if doc.ProjectDir != "C:/Users/dev/project" {
+		t.Errorf("ProjectDir = %q, want C:/Users/dev/project", doc.ProjectDir)
+	}
+	if doc.Data["cwd"] != `C:\Users\dev\project\` {
+		t.Error("Data should keep the original path")
+	}
+
+	// Off by default.
+	doc = HookEventToDocument(evt)
+	if doc.Cwd != `C:\Users\dev\project\` {
+		t.Errorf("Cwd without option = %q, want unchanged", doc.Cwd)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in, want string
+	}{
+		{`C:\Users\dev\`, "C:/Users/dev"},
+		{`\\server\share\dir`, "//server/share/dir"},
+		{"/home/dev/project/", "/home/dev/project"},
+		{"/home/dev//", "/home/dev"},
+		{"/", "/"},
+		{`C:\`, "C:/"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizePath(tt.in); got != tt.want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package ttlcache provides a concurrent map bounded by size (least recently
+// used entries evicted first) and by per-entry age, for the in-memory
+// correlation features that remember something per session or per key.
+package ttlcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Cache maps keys to values, keeping at most maxSize entries and dropping an
+// entry ttl after it was last set or read. Safe for concurrent use. No
+// caller code runs while the lock is held, so methods cannot deadlock
+// however they are combined.
+type Cache[K comparable, V any] struct {
+	maxSize int
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	items map[K]*list.Element // key → element of lru holding *entry
+	lru   *list.List          // front = most recently used
+}
+
+// New returns an empty cache. maxSize <= 0 or ttl <= 0 disables that bound.
+func New[K comparable, V any](maxSize int, ttl time.Duration) *Cache[K, V] {
+	return NewWithClock[K, V](maxSize, ttl, time.Now)
+}
+
+// NewWithClock is New with the clock used for expiry, for tests.
+func NewWithClock[K comparable, V any](maxSize int, ttl time.Duration, now func() time.Time) *Cache[K, V] {
+	return &Cache[K, V]{
+		maxSize: maxSize,
+		ttl:     ttl,
+		now:     now,
+		items:   make(map[K]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the value for key, marking it most recently used and
+// restarting its TTL. An expired entry is removed and reported absent.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.live(key, now)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	e.expires = now.Add(c.ttl)
+	c.lru.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key, replacing any earlier value, marks it most
+// recently used with a fresh TTL, and evicts the least recently used entries
+// beyond maxSize.
+func (c *Cache[K, V]) Set(key K, value V) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, now.Add(c.ttl)
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.insert(key, value, now)
+}
+
+// Add stores value under key only if key is absent or expired, and reports
+// whether it did. A present key is marked most recently used with a fresh
+// TTL but keeps its value. The check and the insert are one atomic step, so
+// of several concurrent Adds for a key exactly one returns true.
+func (c *Cache[K, V]) Add(key K, value V) bool {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.live(key, now); ok {
+		el.Value.(*entry[K, V]).expires = now.Add(c.ttl)
+		c.lru.MoveToFront(el)
+		return false
+	}
+	c.insert(key, value, now)
+	return true
+}
+
+// Delete removes key, if present.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns the number of entries held. Expired entries are removed
+// lazily, when next looked up, so they count until then.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// live returns key's element if present and unexpired, removing it if
+// expired. Caller holds c.mu.
+func (c *Cache[K, V]) live(key K, now time.Time) (*list.Element, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if c.ttl > 0 && now.After(el.Value.(*entry[K, V]).expires) {
+		c.remove(el)
+		return nil, false
+	}
+	return el, true
+}
+
+// insert adds a new entry for key and evicts beyond maxSize. Caller holds
+// c.mu and has checked key is absent (or removed it).
+func (c *Cache[K, V]) insert(key K, value V, now time.Time) {
+	c.items[key] = c.lru.PushFront(&entry[K, V]{key: key, value: value, expires: now.Add(c.ttl)})
+	for c.maxSize > 0 && c.lru.Len() > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops el. Caller holds c.mu.
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
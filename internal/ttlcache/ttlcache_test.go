@@ -0,0 +1,127 @@
+package ttlcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_SizeEviction(t *testing.T) {
+	t.Parallel()
+	c := New[string, int](2, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a") // a is now the most recently used
+	c.Set("c", 3)
+
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("b survived; want the least recently used entry evicted")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Errorf("Get(%s) = %d, %v; want %d, true", key, v, ok, want)
+		}
+	}
+
+	// Replacing a value does not grow the cache or evict anything.
+	c.Set("a", 10)
+	if v, _ := c.Get("a"); v != 10 || c.Len() != 2 {
+		t.Errorf("after replace: a = %d, Len = %d", v, c.Len())
+	}
+}
+
+func TestCache_TTL(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	c := NewWithClock[string, int](0, time.Hour, func() time.Time { return now })
+	c.Set("read", 1)
+	c.Set("idle", 2)
+
+	// Reads restart the TTL; an entry left alone expires.
+	now = now.Add(50 * time.Minute)
+	if _, ok := c.Get("read"); !ok {
+		t.Fatal("read expired after 50m")
+	}
+	now = now.Add(50 * time.Minute)
+	if _, ok := c.Get("read"); !ok {
+		t.Error("read expired 50m after its last use")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2 before idle is looked up", c.Len())
+	}
+	if _, ok := c.Get("idle"); ok {
+		t.Error("idle still present after 100m")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1 after expiry", c.Len())
+	}
+
+	// An expired key can be added again.
+	now = now.Add(2 * time.Hour)
+	if !c.Add("read", 3) {
+		t.Error("Add over an expired entry = false, want true")
+	}
+	if v, _ := c.Get("read"); v != 3 {
+		t.Errorf("read = %d, want 3", v)
+	}
+}
+
+func TestCache_Add(t *testing.T) {
+	t.Parallel()
+	c := New[string, int](2, 0)
+	if !c.Add("a", 1) {
+		t.Error("first Add = false")
+	}
+	if c.Add("a", 2) {
+		t.Error("second Add = true")
+	}
+	if v, _ := c.Get("a"); v != 1 {
+		t.Errorf("a = %d, want the first value kept", v)
+	}
+
+	c.Add("b", 1)
+	c.Add("a", 0) // present: marks a recently used
+	c.Add("c", 1)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b survived; Add of a present key should mark it recently used")
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Errorf("after Delete: present %v, Len %d", ok, c.Len())
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	t.Parallel()
+	c := New[int, int](64, time.Minute)
+	var added atomic.Int64
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if c.Add(i%32, g) {
+					added.Add(1)
+				}
+				c.Get(i % 100)
+				c.Set(100+i%50, i)
+				if i%7 == 0 {
+					c.Delete(100 + i%50)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if c.Len() > 64 {
+		t.Errorf("Len = %d, want at most 64", c.Len())
+	}
+	if added.Load() < 32 {
+		t.Errorf("added %d keys, want at least one per key", added.Load())
+	}
+}
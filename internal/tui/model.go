@@ -1,17 +1,37 @@
 package tui
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"hooks-store/internal/ingest"
+	"hooks-store/internal/store"
 )
 
-const maxRecentEvents = 4
+const (
+	// defaultMaxRecentEvents is how many activity log lines are kept when
+	// Config.MaxRecentEvents is unset.
+	defaultMaxRecentEvents = 4
+
+	// defaultRenderWindow is how long events are coalesced into one Update
+	// after the first event of a burst arrives.
+	defaultRenderWindow = 100 * time.Millisecond
+
+	// defaultBacklogWarn is the pending-task count at which the footer
+	// backlog indicator turns red.
+	defaultBacklogWarn = 100
+
+	// backlogTimeout bounds each backlog query so a slow backend cannot
+	// stall the footer.
+	backlogTimeout = 2 * time.Second
+)
 
 // Config holds the static information displayed in the TUI header.
 type Config struct {
@@ -19,46 +39,169 @@ type Config struct {
 	MeiliURL   string
 	MeiliIndex string
 	ListenAddr string
+
+	// FileDir replaces the MeiliSearch line when events go to a FileStore
+	// (its directory) or a JSONLStore (its file).
+	FileDir string
+
+	// RenderWindow batches events arriving within this window into a single
+	// Update/redraw. Zero uses defaultRenderWindow; negative disables batching.
+	RenderWindow time.Duration
+
+	// Backlog, if set, is polled on each tick and shown in the footer.
+	// BacklogWarn is the pending count shown in red (0 → defaultBacklogWarn).
+	Backlog     func(context.Context) (store.Backlog, error)
+	BacklogWarn int64
+
+	// CollapseDuplicates starts the activity log with consecutive events of
+	// the same hook type and tool merged into one line with a repeat count.
+	// Toggled at runtime with "c".
+	CollapseDuplicates bool
+
+	// MaxRecentEvents is how many activity log lines are retained and shown
+	// (0 → defaultMaxRecentEvents).
+	MaxRecentEvents int
+
+	// DumpOnQuit, if set, is a file the retained activity log is written to
+	// as NDJSON when the TUI exits (see Run).
+	DumpOnQuit string
 }
 
 // Model is the Bubble Tea model for the hooks-store dashboard.
 type Model struct {
 	cfg          Config
+	window       time.Duration
 	eventCh      <-chan ingest.IngestEvent
 	ctx          context.Context
 	errCount     *atomic.Int64
 	ingested     int
 	errors       int64
 	lastEvent    time.Time
-	recentEvents []ingest.IngestEvent
+	recentEvents activityRing
+	collapse     bool
+	showCost     bool // activity log cost column, toggled with "$"
+	height       int  // terminal rows from tea.WindowSizeMsg; 0 until known
+
+	// Running usage totals of events seen since the TUI started,
+	// independent of the search backend.
+	costUSD     float64
+	totalTokens int64
+
+	backlog         store.Backlog
+	backlogErr      error
+	backlogKnown    bool
+	backlogInFlight bool
 }
 
 // NewModel creates a new TUI model.
 func NewModel(cfg Config, eventCh <-chan ingest.IngestEvent, ctx context.Context, errCount *atomic.Int64) Model {
+	window := cfg.RenderWindow
+	if window == 0 {
+		window = defaultRenderWindow
+	}
+	if cfg.BacklogWarn <= 0 {
+		cfg.BacklogWarn = defaultBacklogWarn
+	}
+	if cfg.MaxRecentEvents <= 0 {
+		cfg.MaxRecentEvents = defaultMaxRecentEvents
+	}
 	return Model{
-		cfg:      cfg,
-		eventCh:  eventCh,
-		ctx:      ctx,
-		errCount: errCount,
+		cfg:          cfg,
+		window:       window,
+		eventCh:      eventCh,
+		ctx:          ctx,
+		errCount:     errCount,
+		recentEvents: newActivityRing(cfg.MaxRecentEvents),
+		collapse:     cfg.CollapseDuplicates,
+	}
+}
+
+// activityEntry is one activity log line: the latest event and how many
+// consecutive duplicates it stands for (1 unless collapsing).
+type activityEntry struct {
+	evt   ingest.IngestEvent
+	count int
+}
+
+// addRecent puts evt at the top of the activity log, merging it into the
+// top line when collapsing and it repeats that line's hook type and tool.
+func (m *Model) addRecent(evt ingest.IngestEvent) {
+	if top := m.recentEvents.newest(); m.collapse && top != nil {
+		if top.evt.HookType == evt.HookType && top.evt.ToolName == evt.ToolName {
+			top.evt = evt
+			top.count++
+			return
+		}
 	}
+	m.recentEvents.push(activityEntry{evt: evt, count: 1})
 }
 
-// Run starts the Bubble Tea program and blocks until it exits.
+// Run starts the Bubble Tea program and blocks until it exits. With
+// Config.DumpOnQuit set, the final activity log is then written to that file.
 func Run(m Model) error {
 	p := tea.NewProgram(m, tea.WithAltScreen())
-	_, err := p.Run()
-	return err
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	if m.cfg.DumpOnQuit == "" {
+		return nil
+	}
+	if fm, ok := final.(Model); ok {
+		m = fm
+	}
+	if err := m.dumpRecent(m.cfg.DumpOnQuit); err != nil {
+		return fmt.Errorf("dump activity log: %w", err)
+	}
+	return nil
+}
+
+// dumpedEvent is one line of a DumpOnQuit file: the event, plus how many
+// consecutive duplicates it stood for when the log was collapsed.
+type dumpedEvent struct {
+	ingest.IngestEvent
+	Repeat int `json:"repeat,omitempty"`
+}
+
+// dumpRecent writes the retained activity log to path, oldest first, one
+// JSON IngestEvent per line.
+func (m Model) dumpRecent(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := m.recentEvents.len() - 1; i >= 0; i-- {
+		entry := m.recentEvents.at(i)
+		line := dumpedEvent{IngestEvent: entry.evt}
+		if entry.count > 1 {
+			line.Repeat = entry.count
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
 }
 
 // --- Messages ---
 
-type eventMsg ingest.IngestEvent
+// eventBatchMsg carries every event received within one render window,
+// oldest first. closed is set if the channel was closed while batching.
+type eventBatchMsg struct {
+	events []ingest.IngestEvent
+	closed bool
+}
+
 type tickMsg time.Time
 
+// backlogMsg carries the result of one backlog query.
+type backlogMsg struct {
+	backlog store.Backlog
+	err     error
+}
+
 // --- Bubble Tea interface ---
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(waitForEvent(m.eventCh, m.ctx), tickEvery(time.Second))
+	return tea.Batch(waitForEvents(m.eventCh, m.ctx, m.window), tickEvery(time.Second))
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -67,21 +210,43 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "c":
+			m.collapse = !m.collapse
+		case "$":
+			m.showCost = !m.showCost
 		}
 
-	case eventMsg:
-		evt := ingest.IngestEvent(msg)
-		m.ingested++
-		m.lastEvent = time.Now()
-		m.recentEvents = append([]ingest.IngestEvent{evt}, m.recentEvents...)
-		if len(m.recentEvents) > maxRecentEvents {
-			m.recentEvents = m.recentEvents[:maxRecentEvents]
+	case eventBatchMsg:
+		for _, evt := range msg.events {
+			m.ingested++
+			m.costUSD += evt.CostUSD
+			m.totalTokens += evt.TotalTokens
+			m.addRecent(evt)
 		}
-		return m, waitForEvent(m.eventCh, m.ctx)
+		if len(msg.events) > 0 {
+			m.lastEvent = time.Now()
+		}
+		if msg.closed {
+			return m, tea.Quit
+		}
+		return m, waitForEvents(m.eventCh, m.ctx, m.window)
 
 	case tickMsg:
 		m.errors = m.errCount.Load()
+		// One query at a time: a slow backend skips ticks instead of piling up.
+		if m.cfg.Backlog != nil && !m.backlogInFlight {
+			m.backlogInFlight = true
+			return m, tea.Batch(tickEvery(time.Second), queryBacklog(m.ctx, m.cfg.Backlog))
+		}
 		return m, tickEvery(time.Second)
+
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+
+	case backlogMsg:
+		m.backlogInFlight = false
+		m.backlogKnown = true
+		m.backlog, m.backlogErr = msg.backlog, msg.err
 	}
 
 	return m, nil
@@ -98,7 +263,11 @@ func (m Model) View() string {
 	b.WriteString(sep + "\n")
 
 	// Config block
-	b.WriteString("  " + labelStyle.Render("MeiliSearch:") + "  " + valueStyle.Render(fmt.Sprintf("%s (index: %s)", m.cfg.MeiliURL, m.cfg.MeiliIndex)) + "\n")
+	if m.cfg.FileDir != "" {
+		b.WriteString("  " + labelStyle.Render("Files:") + "        " + valueStyle.Render(m.cfg.FileDir) + "\n")
+	} else {
+		b.WriteString("  " + labelStyle.Render("MeiliSearch:") + "  " + valueStyle.Render(fmt.Sprintf("%s (index: %s)", m.cfg.MeiliURL, m.cfg.MeiliIndex)) + "\n")
+	}
 	b.WriteString("  " + labelStyle.Render("Listening:") + "    " + valueStyle.Render(m.cfg.ListenAddr) + "\n")
 	b.WriteString("  " + labelStyle.Render("Endpoints:") + "    " + valueStyle.Render("POST /ingest  GET /health  GET /stats") + "\n")
 	b.WriteString(sep + "\n")
@@ -122,14 +291,36 @@ func (m Model) View() string {
 		errLabel,
 		valueStyle.Render(fmt.Sprintf("Last: %s", lastStr)),
 	))
+	b.WriteString(fmt.Sprintf("  %s     %s\n",
+		valueStyle.Render(fmt.Sprintf("Cost: $%.4f", m.costUSD)),
+		valueStyle.Render(fmt.Sprintf("Tokens: %s", formatCount(m.totalTokens))),
+	))
 	b.WriteString(sep + "\n")
 
+	// Footer, rendered first so the activity log can be clipped to fit.
+	collapseHint := "c: collapse"
+	if m.collapse {
+		collapseHint = "c: expand"
+	}
+	costHint := "$: show cost"
+	if m.showCost {
+		costHint = "$: hide cost"
+	}
+	footer := footerStyle.Render("q: quit  " + collapseHint + "  " + costHint)
+	if m.cfg.Backlog != nil {
+		footer += "     " + m.backlogStatus()
+	}
+	footer = sep + "\n  " + footer + "\n"
+
 	// Activity log
 	b.WriteString("  " + titleStyle.Render("Recent Activity") + "\n")
-	if len(m.recentEvents) == 0 {
+	if m.recentEvents.len() == 0 {
 		b.WriteString("  " + dimStyle.Render("Waiting for events...") + "\n")
 	} else {
-		for _, evt := range m.recentEvents {
+		rows := m.activityRows(strings.Count(b.String(), "\n") + strings.Count(footer, "\n"))
+		for i := 0; i < rows; i++ {
+			entry := m.recentEvents.at(i)
+			evt := entry.evt
 			hookType := hookStyle(evt.HookType).Render(fmt.Sprintf("%-20s", evt.HookType))
 
 			toolName := "---"
@@ -142,30 +333,99 @@ func (m Model) View() string {
 
 			timeCol := dimStyle.Render(evt.Timestamp.Local().Format("15:04:05"))
 
-			b.WriteString(fmt.Sprintf("  %s %s %s   %s\n", hookType, toolCol, sizeCol, timeCol))
+			line := fmt.Sprintf("  %s %s %s   %s", hookType, toolCol, sizeCol, timeCol)
+			if m.showCost {
+				line = fmt.Sprintf("  %s %s %s %s   %s", hookType, toolCol, sizeCol,
+					valueStyle.Render(fmt.Sprintf("%9s", formatCost(evt.CostUSD))), timeCol)
+			}
+			if entry.count > 1 {
+				line += " " + valueStyle.Render(fmt.Sprintf("×%d", entry.count))
+			}
+			b.WriteString(line + "\n")
 		}
 	}
-	b.WriteString(sep + "\n")
-
-	// Footer
-	b.WriteString("  " + footerStyle.Render("q: quit") + "\n")
+	b.WriteString(footer)
 
 	return b.String()
 }
 
+// activityRows returns how many activity log entries (newest first) fit on
+// screen beside fixed other lines: every retained entry until the terminal
+// height is known, none when the terminal is too short for any. One row is
+// kept for the empty line Bubble Tea renders after the view's final newline.
+func (m Model) activityRows(fixed int) int {
+	n := m.recentEvents.len()
+	if m.height <= 0 {
+		return n
+	}
+	return max(0, min(n, m.height-fixed-1))
+}
+
+// backlogStatus renders the footer backlog indicator, red once the pending
+// count reaches cfg.BacklogWarn.
+func (m Model) backlogStatus() string {
+	switch {
+	case !m.backlogKnown:
+		return dimStyle.Render("Backlog: ...")
+	case m.backlogErr != nil:
+		return errorStyle.Render("Backlog: unavailable")
+	}
+	label := fmt.Sprintf("Backlog: %d pending", m.backlog.PendingTasks)
+	if m.backlog.IsIndexing {
+		label += " (indexing)"
+	}
+	if m.backlog.PendingTasks >= m.cfg.BacklogWarn {
+		return errorStyle.Render(label)
+	}
+	return footerStyle.Render(label)
+}
+
 // --- Commands ---
 
-func waitForEvent(ch <-chan ingest.IngestEvent, ctx context.Context) tea.Cmd {
+// queryBacklog runs one bounded backlog query off the Update goroutine.
+func queryBacklog(ctx context.Context, fn func(context.Context) (store.Backlog, error)) tea.Cmd {
+	return func() tea.Msg {
+		qctx, cancel := context.WithTimeout(ctx, backlogTimeout)
+		defer cancel()
+		b, err := fn(qctx)
+		return backlogMsg{backlog: b, err: err}
+	}
+}
+
+// waitForEvents blocks until an event arrives, then keeps collecting events
+// for one render window so a burst produces a single Update. Idle periods
+// cost nothing — no timer runs until the first event of a burst.
+func waitForEvents(ch <-chan ingest.IngestEvent, ctx context.Context, window time.Duration) tea.Cmd {
 	return func() tea.Msg {
+		var batch []ingest.IngestEvent
 		select {
 		case evt, ok := <-ch:
 			if !ok {
 				return tea.Quit()
 			}
-			return eventMsg(evt)
+			batch = append(batch, evt)
 		case <-ctx.Done():
 			return tea.Quit()
 		}
+		if window <= 0 {
+			return eventBatchMsg{events: batch}
+		}
+
+		timer := time.NewTimer(window)
+		defer timer.Stop()
+		for {
+			select {
+			case evt, ok := <-ch:
+				if !ok {
+					return eventBatchMsg{events: batch, closed: true}
+				}
+				batch = append(batch, evt)
+			case <-timer.C:
+				return eventBatchMsg{events: batch}
+			case <-ctx.Done():
+				return eventBatchMsg{events: batch, closed: true}
+			}
+		}
 	}
 }
 
@@ -175,6 +435,27 @@ func tickEvery(d time.Duration) tea.Cmd {
 	})
 }
 
+// formatCount abbreviates large counts (12.3k, 4.5M).
+func formatCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// formatCost renders a per-event cost for the activity log cost column,
+// blank for events that carry none.
+func formatCost(usd float64) string {
+	if usd == 0 {
+		return ""
+	}
+	return fmt.Sprintf("$%.4f", usd)
+}
+
 func formatBytes(b int) string {
 	switch {
 	case b >= 1<<20:
@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"hooks-store/internal/ingest"
+)
+
+func TestView_ClipsActivityLogToHeight(t *testing.T) {
+	m := NewModel(Config{MaxRecentEvents: 50}, nil, context.Background(), new(atomic.Int64))
+	for i := 0; i < 50; i++ {
+		m.addRecent(ingest.IngestEvent{HookType: "Stop"})
+	}
+	if got := strings.Count(m.View(), "Stop"); got != 50 {
+		t.Errorf("height unknown: %d entries shown, want all 50", got)
+	}
+
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 20})
+	m = next.(Model)
+	view := m.View()
+	if lines := strings.Count(view, "\n") + 1; lines > 20 {
+		t.Errorf("view is %d lines, want at most 20", lines)
+	}
+	if got := strings.Count(view, "Stop"); got == 0 || got >= 50 {
+		t.Errorf("height 20: %d entries shown, want some but not all", got)
+	}
+	if !strings.Contains(view, "q: quit") {
+		t.Error("footer clipped away")
+	}
+}
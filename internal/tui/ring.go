@@ -0,0 +1,42 @@
+package tui
+
+// activityRing holds the most recent activity entries in a fixed-capacity
+// circular buffer, so adding an event is O(1) with no reallocation however
+// large the history is.
+type activityRing struct {
+	buf  []activityEntry
+	head int // index of the newest entry
+	n    int // entries in use
+}
+
+func newActivityRing(capacity int) activityRing {
+	return activityRing{buf: make([]activityEntry, capacity)}
+}
+
+// push adds e as the newest entry, evicting the oldest when full.
+func (r *activityRing) push(e activityEntry) {
+	if len(r.buf) == 0 {
+		return
+	}
+	r.head = (r.head + 1) % len(r.buf)
+	r.buf[r.head] = e
+	if r.n < len(r.buf) {
+		r.n++
+	}
+}
+
+// newest returns the newest entry, or nil if the ring is empty.
+func (r *activityRing) newest() *activityEntry {
+	if r.n == 0 {
+		return nil
+	}
+	return &r.buf[r.head]
+}
+
+// len returns the number of entries held.
+func (r *activityRing) len() int { return r.n }
+
+// at returns the i-th newest entry (0 = newest); i must be < len().
+func (r *activityRing) at(i int) activityEntry {
+	return r.buf[(r.head-i+len(r.buf))%len(r.buf)]
+}
@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"testing"
+
+	"hooks-store/internal/ingest"
+)
+
+func entry(hookType string) activityEntry {
+	return activityEntry{evt: ingest.IngestEvent{HookType: hookType}, count: 1}
+}
+
+// hookTypes lists the ring's entries newest first via at.
+func hookTypes(r *activityRing) []string {
+	var got []string
+	for i := 0; i < r.len(); i++ {
+		got = append(got, r.at(i).evt.HookType)
+	}
+	return got
+}
+
+func TestActivityRing(t *testing.T) {
+	r := newActivityRing(3)
+	if r.len() != 0 || r.newest() != nil {
+		t.Fatalf("empty ring: len = %d, newest = %v", r.len(), r.newest())
+	}
+
+	r.push(entry("A"))
+	r.push(entry("B"))
+	if got := hookTypes(&r); len(got) != 2 || got[0] != "B" || got[1] != "A" {
+		t.Errorf("before wraparound = %v, want [B A]", got)
+	}
+
+	// Wraps around: D and E evict A and B, the oldest.
+	r.push(entry("C"))
+	r.push(entry("D"))
+	r.push(entry("E"))
+	want := []string{"E", "D", "C"}
+	got := hookTypes(&r)
+	if len(got) != len(want) {
+		t.Fatalf("after wraparound = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("after wraparound = %v, want %v", got, want)
+		}
+	}
+
+	// newest points into the buffer, so collapsing edits in place.
+	r.newest().count = 5
+	if r.at(0).count != 5 {
+		t.Errorf("at(0).count = %d after editing newest, want 5", r.at(0).count)
+	}
+}
+
+func TestActivityRing_ZeroCapacity(t *testing.T) {
+	r := newActivityRing(0)
+	r.push(entry("A"))
+	if r.len() != 0 || r.newest() != nil {
+		t.Errorf("zero capacity: len = %d, want 0", r.len())
+	}
+}